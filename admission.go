@@ -0,0 +1,59 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// parseSlots holds the semaphore limiting concurrent full parses, a nil channel means no limit
+var parseSlots atomic.Value
+
+func init() {
+	parseSlots.Store((chan struct{})(nil))
+}
+
+// Limit the number of full parses of raw bytes which may run at once, across all Values.
+// Parses triggered by Value() or Bytes() wait for a free slot, use ParseContext() to give
+// up waiting when a context is done.  A limit of 0 or less removes the limit.
+//
+// Parses already running when the limit changes finish without counting towards the new limit.
+func SetMaxConcurrentParses(n int) {
+	if n <= 0 {
+		parseSlots.Store((chan struct{})(nil))
+		return
+	}
+	parseSlots.Store(make(chan struct{}, n))
+}
+
+// admitParse waits for a free parse slot, returning the function which releases it.
+func admitParse(ctx context.Context) (func(), error) {
+	slots := parseSlots.Load().(chan struct{})
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Parse the raw bytes of this Value now, if they have not been parsed yet, so later calls to
+// Value() and Bytes() do not parse them.  If the number of concurrent parses is limited (see
+// SetMaxConcurrentParses()) and ctx is done before a slot is free, the return error is ctx.Err().
+func (this *Value) ParseContext(ctx context.Context) error {
+	if this.parsedValue != nil || this.parsedType == NULL || this.parsedType == NOT_JSON || this.raw == nil {
+		return nil
+	}
+	return this.parseRawContext(ctx)
+}
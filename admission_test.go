@@ -0,0 +1,56 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMaxConcurrentParses(t *testing.T) {
+	SetMaxConcurrentParses(1)
+	defer SetMaxConcurrentParses(0)
+
+	// hold the only slot
+	release, err := admitParse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := NewValueFromBytes([]byte(`{"a":1}`))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = val.ParseContext(ctx)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	done := make(chan interface{})
+	go func() {
+		done <- val.Value()
+	}()
+	select {
+	case <-done:
+		t.Errorf("Expected Value() to wait for a parse slot")
+	default:
+	}
+	release()
+	if actual := <-done; !reflect.DeepEqual(actual, map[string]interface{}{"a": 1.0}) {
+		t.Errorf("Expected {a:1}, got %v", actual)
+	}
+
+	// parsed values need no slot
+	release, _ = admitParse(context.Background())
+	defer release()
+	if err := val.ParseContext(ctx); err != nil {
+		t.Errorf("Expected no error for a parsed value, got %v", err)
+	}
+}
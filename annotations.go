@@ -0,0 +1,67 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+// Annotate a field of this Value with an arbitrary object (for example an index hint, a score
+// or lineage information) with the specified key.  Any existing annotation with this same key
+// on this path will be overwritten.
+//
+// Annotations are stored alongside the document, they are never part of Value() or Bytes().
+// The path is used only as the name of the field being annotated, it need not exist in the document.
+func (this *Value) AnnotatePath(path string, key string, val interface{}) {
+	this.checkMutable()
+	if this.annotations == nil {
+		this.annotations = make(map[string]map[string]interface{})
+	}
+	fieldAnnotations, ok := this.annotations[path]
+	if !ok {
+		fieldAnnotations = make(map[string]interface{})
+		this.annotations[path] = fieldAnnotations
+	}
+	fieldAnnotations[key] = val
+}
+
+// Return the annotations of the field at this path, keyed by annotation key.
+// If the field has no annotations, nil is returned.
+func (this *Value) Annotations(path string) map[string]interface{} {
+	fieldAnnotations, ok := this.annotations[path]
+	if !ok {
+		return nil
+	}
+	rv := make(map[string]interface{}, len(fieldAnnotations))
+	for k, v := range fieldAnnotations {
+		rv[k] = v
+	}
+	return rv
+}
+
+// Remove an annotation with this key from the field at this path.
+// If there had been an annotation with this key it is returned, otherwise nil.
+func (this *Value) RemoveAnnotation(path string, key string) interface{} {
+	fieldAnnotations, ok := this.annotations[path]
+	if !ok {
+		return nil
+	}
+	rv := fieldAnnotations[key]
+	delete(fieldAnnotations, key)
+	if len(fieldAnnotations) == 0 {
+		delete(this.annotations, path)
+	}
+	return rv
+}
+
+// Return the paths of this Value which have annotations.
+func (this *Value) AnnotatedPaths() []string {
+	rv := make([]string, 0, len(this.annotations))
+	for path := range this.annotations {
+		rv = append(rv, path)
+	}
+	return rv
+}
@@ -0,0 +1,51 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	input := `{"title":"dparval","body":"delayed parsing"}`
+	val := NewValueFromBytes([]byte(input))
+
+	val.AnnotatePath("title", "boost", 2.0)
+	val.AnnotatePath("title", "source", "crawler")
+	val.AnnotatePath("body", "boost", 0.5)
+
+	expected := map[string]interface{}{"boost": 2.0, "source": "crawler"}
+	if !reflect.DeepEqual(val.Annotations("title"), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Annotations("title"))
+	}
+	if val.Annotations("missing") != nil {
+		t.Errorf("Expected nil annotations for missing")
+	}
+	if len(val.AnnotatedPaths()) != 2 {
+		t.Errorf("Expected 2 annotated paths, got %v", val.AnnotatedPaths())
+	}
+
+	// annotations never leak into the document
+	if string(val.Bytes()) != input {
+		t.Errorf("Expected %s, got %s", input, val.Bytes())
+	}
+	val.SetPath("extra", true)
+	if _, ok := val.Value().(map[string]interface{})["boost"]; ok {
+		t.Errorf("Annotation leaked into Value()")
+	}
+
+	if val.RemoveAnnotation("body", "boost") != 0.5 {
+		t.Errorf("Expected removed annotation 0.5")
+	}
+	if val.Annotations("body") != nil {
+		t.Errorf("Expected no annotations for body after removal")
+	}
+}
@@ -0,0 +1,106 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+// If this Value is of type ARRAY, this method adds val to the end of the array, whether it has
+// been parsed or is still raw bytes, so Value(), Bytes() and Index() see the longer array.
+// If this Value is not of type ARRAY, nothing is done.
+//
+// NOTE: All incoming values are brought into the type system, so the val argument must be compatible with the NewValue() method.
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+// If a Schema is bound to this Value (see BindSchema()) which does not allow val, this panics.
+func (this *Value) Append(val interface{}) {
+	if this.parsedType == ARRAY {
+		n, err := this.count("")
+		if err == nil {
+			this.Insert(n, val)
+		}
+	}
+}
+
+// If this Value is of type ARRAY, this method inserts val at the specified index, and the element
+// there and those after it shift up to make room.  An index equal to the length of the array adds
+// val to the end, as Append() does, and a negative index counts from the end of the array, as for
+// Index().  If this Value is not of type ARRAY, or the index is outside of the array, nothing is done.
+//
+// NOTE: All incoming values are brought into the type system, so the val argument must be compatible with the NewValue() method.
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+// If a Schema is bound to this Value (see BindSchema()) which does not allow val, this panics.
+func (this *Value) Insert(index int, val interface{}) {
+	this.checkMutable()
+	if this.parsedType != ARRAY {
+		return
+	}
+	elements, err := this.growableElements()
+	if err != nil {
+		return
+	}
+	if index < 0 {
+		index += len(elements)
+	}
+	if index < 0 || index > len(elements) {
+		return
+	}
+	element, ok := this.checkSetIndex(index, val).(*Value)
+	if !ok {
+		element = NewValue(val)
+	}
+	elements = append(elements, nil)
+	copy(elements[index+1:], elements[index:])
+	elements[index] = element
+	this.replaceElements(elements)
+}
+
+// If this Value is of type ARRAY, this method stores val at the specified index as SetIndex() does,
+// except that an index at or beyond the end of the array grows it, with any elements between the
+// old end and the index set to null.  So SetIndexExtend(len, val) appends val, as Append() does.
+// If this Value is not of type ARRAY, or the index is negative, nothing is done.
+//
+// NOTE: All incoming values are brought into the type system, so the val argument must be compatible with the NewValue() method.
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+// If a Schema is bound to this Value (see BindSchema()) which does not allow val, or the nulls
+// padding the array, this panics.
+func (this *Value) SetIndexExtend(index int, val interface{}) {
+	this.checkMutable()
+	if this.parsedType != ARRAY || index < 0 {
+		return
+	}
+	elements, err := this.growableElements()
+	if err != nil {
+		return
+	}
+	if index < len(elements) {
+		if err := this.SetIndexErr(index, val); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if index > len(elements) {
+		this.checkSetIndex(len(elements), nil)
+	}
+	element, ok := this.checkSetIndex(index, val).(*Value)
+	if !ok {
+		element = NewValue(val)
+	}
+	for len(elements) < index {
+		elements = append(elements, NewValue(nil))
+	}
+	this.replaceElements(append(elements, element))
+}
+
+// growableElements returns the elements of this ARRAY, to be grown and stored with replaceElements().
+// Once the elements are parsed they are grown in place, so building an array with Append() does
+// not copy it each time.
+func (this *Value) growableElements() (ValueCollection, error) {
+	if parsedValue, ok := this.parsedValue.([]*Value); ok {
+		return ValueCollection(parsedValue), nil
+	}
+	return this.rememberedElements()
+}
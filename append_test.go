@@ -0,0 +1,109 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	raw := []byte(`{"items": [1, {"a": 2}], "empty": [], "name": "x"}`)
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		items, _ := doc.Path("items")
+		nested, _ := items.Index(1)
+		items.Append("three")
+		items.Append(map[string]interface{}{"b": 4.0})
+		nested.SetPath("a", 5.0)
+		empty, _ := doc.Path("empty")
+		for i := 0; i < 3; i++ {
+			empty.Append(float64(i))
+		}
+		name, _ := doc.Path("name")
+		name.Append(1.0)
+
+		if string(doc.Bytes()) != `{"empty":[0,1,2],"items":[1,{"a":5},"three",{"b":4}],"name":"x"}` {
+			t.Errorf("Unexpected document %s", doc.Bytes())
+		}
+		if last, err := doc.Path("items.3.b"); err != nil || last.Value() != 4.0 {
+			t.Errorf("Expected to find the appended element, got %v, %v", last, err)
+		}
+		if n, _ := items.Len(); n != 4 {
+			t.Errorf("Expected 4 elements, got %d", n)
+		}
+	}
+}
+
+func TestInsert(t *testing.T) {
+	raw := []byte(`["b", "d"]`)
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		doc.Insert(0, "a")
+		doc.Insert(2, "c")
+		doc.Insert(4, "e")
+		doc.Insert(-1, "e-")
+		doc.Insert(7, "out of range")
+		doc.Insert(-7, "out of range")
+		if string(doc.Bytes()) != `["a","b","c","d","e-","e"]` {
+			t.Errorf("Unexpected array %s", doc.Bytes())
+		}
+	}
+
+	doc := NewValueFromBytes([]byte(`[1, 2]`))
+	doc.BindSchema(&Schema{Type: ARRAY, Items: &Schema{Type: NUMBER}})
+	doc.Insert(1, 1.5)
+	if _, ok := expectPanic(func() { doc.Append("x") }).(*TypeMismatch); !ok {
+		t.Errorf("Expected a panic appending a string to an array of numbers")
+	}
+	if string(doc.Bytes()) != `[1,1.5,2]` {
+		t.Errorf("Unexpected array %s", doc.Bytes())
+	}
+}
+
+func TestSetIndexExtend(t *testing.T) {
+	raw := []byte(`[1, 2]`)
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		doc.SetIndexExtend(0, "one")
+		doc.SetIndexExtend(2, 3.0)
+		doc.SetIndexExtend(5, "six")
+		doc.SetIndexExtend(-1, "ignored")
+		if string(doc.Bytes()) != `["one",2,3,null,null,"six"]` {
+			t.Errorf("Unexpected array %s", doc.Bytes())
+		}
+		if last, err := doc.Index(-1); err != nil || last.Value() != "six" {
+			t.Errorf("Expected six at the end, got %v, %v", last, err)
+		}
+	}
+
+	// SetIndex ignores writes beyond the end, whether the array is raw or parsed
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		doc.SetIndex(2, 3.0)
+		if _, err := doc.Index(2); err == nil || string(doc.Bytes()) != `[1,2]` && string(doc.Bytes()) != `[1, 2]` {
+			t.Errorf("Expected a write beyond the end to be ignored, got %s", doc.Bytes())
+		}
+	}
+
+	doc := NewValueFromBytes(raw)
+	doc.BindSchema(&Schema{Type: ARRAY, Items: &Schema{Type: NUMBER}})
+	doc.SetIndexExtend(2, 3.0)
+	if _, ok := expectPanic(func() { doc.SetIndexExtend(4, 5.0) }).(*TypeMismatch); !ok {
+		t.Errorf("Expected a panic padding an array of numbers with null")
+	}
+	if string(doc.Bytes()) != `[1,2,3]` {
+		t.Errorf("Unexpected array %s", doc.Bytes())
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		arr := NewValueFromBytes([]byte(`[]`))
+		for j := 0; j < 10000; j++ {
+			arr.Append(float64(j))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strings"
+)
+
+// Create a new Value containing only the fields of doc selected by a field mask, following
+// the semantics of Google AIP-161 (as used for partial responses):
+//
+//         1. Each path is a dot-separated list of field names, for example "address.city".  Names containing dots may be `backquoted`.
+//         2. A path selects the named field including everything nested inside of it.
+//         3. The name * selects every field of an object.  A mask of only "*", or an empty mask, selects the whole document.
+//         4. Paths continue into every element of an array, so "items.id" selects the id of each item.
+//         5. Paths which do not exist in doc are ignored.
+//
+// Only the selected fields are accessed, so unselected parts of doc are never parsed.
+func ApplyFieldMask(doc *Value, mask []string) *Value {
+	root := &maskNode{}
+	for _, path := range mask {
+		root.add(splitMaskPath(path))
+	}
+	if len(mask) == 0 || root.all {
+		return doc
+	}
+	return root.apply(doc)
+}
+
+// maskNode is a node in the tree of paths of a field mask.
+type maskNode struct {
+	all      bool // everything beneath this node is selected
+	children map[string]*maskNode
+}
+
+func (this *maskNode) add(path []string) {
+	if len(path) == 0 || (len(path) == 1 && path[0] == "*") {
+		this.all = true
+		return
+	}
+	if this.children == nil {
+		this.children = make(map[string]*maskNode)
+	}
+	child, ok := this.children[path[0]]
+	if !ok {
+		child = &maskNode{}
+		this.children[path[0]] = child
+	}
+	child.add(path[1:])
+}
+
+func (this *maskNode) apply(val *Value) *Value {
+	if this.all {
+		return val
+	}
+	switch val.Type() {
+	case OBJECT:
+		rv := make(map[string]interface{})
+		wildcard := this.children["*"]
+		keys := make([]string, 0, len(this.children))
+		if wildcard != nil {
+			keys = val.Fields()
+		} else {
+			for k := range this.children {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			node := this.children[k]
+			if wildcard != nil {
+				if node == nil {
+					node = wildcard
+				} else {
+					node = node.merge(wildcard)
+				}
+			}
+			child, err := val.Path(k)
+			if err != nil || child.Type() == NOT_JSON {
+				continue
+			}
+			if selected := node.apply(child); selected != nil {
+				rv[k] = selected
+			}
+		}
+		return NewValue(rv)
+	case ARRAY:
+		elements, err := val.elements()
+		if err != nil {
+			return NewValue([]interface{}{})
+		}
+		rv := make([]interface{}, 0, len(elements))
+		for _, element := range elements {
+			if selected := this.apply(element); selected != nil {
+				rv = append(rv, selected)
+			}
+		}
+		return NewValue(rv)
+	}
+	// the path continues beneath a value which has no fields,
+	// so nothing is selected
+	return nil
+}
+
+// merge combines the selections of two nodes
+func (this *maskNode) merge(other *maskNode) *maskNode {
+	if this.all || other.all {
+		return &maskNode{all: true}
+	}
+	rv := &maskNode{children: make(map[string]*maskNode)}
+	for k, v := range this.children {
+		rv.children[k] = v
+	}
+	for k, v := range other.children {
+		if existing, ok := rv.children[k]; ok {
+			rv.children[k] = existing.merge(v)
+		} else {
+			rv.children[k] = v
+		}
+	}
+	return rv
+}
+
+// splitMaskPath splits a field mask path on dots, outside of backquotes.
+func splitMaskPath(path string) []string {
+	rv := make([]string, 0)
+	var current strings.Builder
+	quoted := false
+	for _, r := range path {
+		switch {
+		case r == '`':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			rv = append(rv, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(rv, current.String())
+}
@@ -0,0 +1,40 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestApplyFieldMask(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name":"marty","address":{"city":"x","zip":"1"},"items":[{"id":1,"qty":2},{"id":2},3],"labels":{"a":{"v":1,"w":2},"b":{"v":3}},"a.b":true}`))
+
+	var tests = []struct {
+		mask   []string
+		output string
+	}{
+		{nil, string(doc.Bytes())},
+		{[]string{"*"}, string(doc.Bytes())},
+		{[]string{"name"}, `{"name":"marty"}`},
+		{[]string{"name", "address.city", "missing", "name.first"}, `{"address":{"city":"x"},"name":"marty"}`},
+		{[]string{"address.*"}, `{"address":{"city":"x","zip":"1"}}`},
+		{[]string{"items.id"}, `{"items":[{"id":1},{"id":2}]}`},
+		{[]string{"labels.*.v"}, `{"labels":{"a":{"v":1},"b":{"v":3}}}`},
+		{[]string{"labels.*.v", "labels.a.w"}, `{"labels":{"a":{"v":1,"w":2},"b":{"v":3}}}`},
+		{[]string{"`a.b`"}, `{"a.b":true}`},
+	}
+
+	for _, test := range tests {
+		result := ApplyFieldMask(doc, test.mask)
+		if string(result.Bytes()) != test.output {
+			t.Errorf("Expected %s, got %s for %v", test.output, result.Bytes(), test.mask)
+		}
+	}
+}
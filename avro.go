@@ -0,0 +1,591 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	json "github.com/dustin/gojson"
+)
+
+// An avroSchema is the parsed form of an Avro schema.
+type avroSchema struct {
+	kind     string        // the avro type name
+	name     string        // full name for record, enum and fixed
+	fields   []avroField   // record
+	symbols  []string      // enum
+	items    *avroSchema   // array and map
+	branches []*avroSchema // union
+	size     int           // fixed
+}
+
+type avroField struct {
+	name       string
+	schema     *avroSchema
+	def        interface{}
+	hasDefault bool
+}
+
+// Create a new Value from data encoded with the Avro binary encoding (a single datum, not an
+// object container file) using the specified Avro schema (in its JSON form).
+//
+// Avro types are mapped to JSON types as follows: int, long, float and double become NUMBER
+// (a long too large for a float64 is kept exactly, as a json.Number is by NewValue()); bytes,
+// fixed, string and enum become STRING (bytes are mapped to the code points 0-255, as in
+// the Avro JSON encoding); record and map become OBJECT; array becomes ARRAY; unions become the
+// value of the selected branch.
+func NewValueFromAvro(schema, data []byte) (*Value, error) {
+	s, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	val, err := s.decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("avro: %d unexpected bytes after datum", r.Len())
+	}
+	return NewValue(val), nil
+}
+
+// Serialize this Value with the Avro binary encoding using the specified Avro schema (in its
+// JSON form).  The mapping of types is the inverse of NewValueFromAvro().
+func (this *Value) AvroBytes(schema []byte) ([]byte, error) {
+	s, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	err = s.encode(&buf, this.Value())
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func parseAvroSchema(schema []byte) (*avroSchema, error) {
+	val := NewValueFromBytes(schema)
+	if val.Type() == NOT_JSON {
+		return nil, fmt.Errorf("avro: schema is not valid JSON")
+	}
+	return buildAvroSchema(val.Value(), map[string]*avroSchema{}, "")
+}
+
+func buildAvroSchema(def interface{}, names map[string]*avroSchema, namespace string) (*avroSchema, error) {
+	switch def := def.(type) {
+	case string:
+		switch def {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{kind: def}, nil
+		}
+		if s, ok := names[def]; ok {
+			return s, nil
+		}
+		if s, ok := names[namespace+"."+def]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("avro: unknown type %s", def)
+	case []interface{}:
+		rv := avroSchema{kind: "union"}
+		for _, branch := range def {
+			s, err := buildAvroSchema(branch, names, namespace)
+			if err != nil {
+				return nil, err
+			}
+			rv.branches = append(rv.branches, s)
+		}
+		return &rv, nil
+	case map[string]interface{}:
+		kind, _ := def["type"].(string)
+		rv := avroSchema{kind: kind}
+		switch kind {
+		case "record", "error", "enum", "fixed":
+			rv.kind = strings.Replace(kind, "error", "record", 1)
+			name, _ := def["name"].(string)
+			if ns, ok := def["namespace"].(string); ok && !strings.Contains(name, ".") {
+				namespace = ns
+			}
+			rv.name = name
+			if namespace != "" && !strings.Contains(name, ".") {
+				rv.name = namespace + "." + name
+			}
+			// register before building fields, records may be recursive
+			names[name] = &rv
+			names[rv.name] = &rv
+		}
+		switch rv.kind {
+		case "record":
+			fields, _ := def["fields"].([]interface{})
+			for _, field := range fields {
+				field, ok := field.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("avro: invalid field in record %s", rv.name)
+				}
+				name, _ := field["name"].(string)
+				s, err := buildAvroSchema(field["type"], names, namespace)
+				if err != nil {
+					return nil, err
+				}
+				d, hasDefault := field["default"]
+				rv.fields = append(rv.fields, avroField{name, s, d, hasDefault})
+			}
+		case "enum":
+			symbols, _ := def["symbols"].([]interface{})
+			for _, symbol := range symbols {
+				s, _ := symbol.(string)
+				rv.symbols = append(rv.symbols, s)
+			}
+		case "fixed":
+			size, _ := def["size"].(float64)
+			rv.size = int(size)
+		case "array", "map":
+			key := "items"
+			if rv.kind == "map" {
+				key = "values"
+			}
+			s, err := buildAvroSchema(def[key], names, namespace)
+			if err != nil {
+				return nil, err
+			}
+			rv.items = s
+		default:
+			// a primitive type in its object form, or a named type reference
+			return buildAvroSchema(def["type"], names, namespace)
+		}
+		return &rv, nil
+	}
+	return nil, fmt.Errorf("avro: invalid schema %v", def)
+}
+
+func (this *avroSchema) decode(r *bytes.Reader) (interface{}, error) {
+	switch this.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, avroTruncated(this)
+		}
+		return b != 0, nil
+	case "int", "long":
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, avroTruncated(this)
+		}
+		if n < -maxExactInteger || n > maxExactInteger {
+			return json.Number(strconv.FormatInt(n, 10)), nil
+		}
+		return float64(n), nil
+	case "float":
+		var f float32
+		err := binary.Read(r, binary.LittleEndian, &f)
+		if err != nil {
+			return nil, avroTruncated(this)
+		}
+		return float64(f), nil
+	case "double":
+		var f float64
+		err := binary.Read(r, binary.LittleEndian, &f)
+		if err != nil {
+			return nil, avroTruncated(this)
+		}
+		return f, nil
+	case "bytes", "string":
+		n, err := binary.ReadVarint(r)
+		if err != nil || n < 0 || n > int64(r.Len()) {
+			return nil, avroTruncated(this)
+		}
+		return this.readString(r, int(n))
+	case "fixed":
+		if this.size > r.Len() {
+			return nil, avroTruncated(this)
+		}
+		return this.readString(r, this.size)
+	case "enum":
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, avroTruncated(this)
+		}
+		if n < 0 || n >= int64(len(this.symbols)) {
+			return nil, fmt.Errorf("avro: enum index %d out of range for %s", n, this.name)
+		}
+		return this.symbols[n], nil
+	case "union":
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, avroTruncated(this)
+		}
+		if n < 0 || n >= int64(len(this.branches)) {
+			return nil, fmt.Errorf("avro: union index %d out of range", n)
+		}
+		return this.branches[n].decode(r)
+	case "record":
+		rv := make(map[string]interface{}, len(this.fields))
+		for _, field := range this.fields {
+			val, err := field.schema.decode(r)
+			if err != nil {
+				return nil, err
+			}
+			rv[field.name] = val
+		}
+		return rv, nil
+	case "array":
+		rv := []interface{}{}
+		err := this.readBlocks(r, func() error {
+			val, err := this.items.decode(r)
+			rv = append(rv, val)
+			return err
+		})
+		return rv, err
+	case "map":
+		rv := map[string]interface{}{}
+		err := this.readBlocks(r, func() error {
+			n, err := binary.ReadVarint(r)
+			if err != nil || n < 0 || n > int64(r.Len()) {
+				return avroTruncated(this)
+			}
+			key, _ := (&avroSchema{kind: "string"}).readString(r, int(n))
+			val, err := this.items.decode(r)
+			rv[key.(string)] = val
+			return err
+		})
+		return rv, err
+	}
+	return nil, fmt.Errorf("avro: unsupported type %s", this.kind)
+}
+
+// The most items of an array whose items take no bytes (such as null) which will be decoded,
+// as the count of such items is not otherwise bounded by the size of the data.
+const avroMaxEmptyItems = 1 << 20
+
+// readBlocks reads the blocks of an array or map, calling item for each entry.
+func (this *avroSchema) readBlocks(r *bytes.Reader, item func() error) error {
+	// the entries of a map always have a key, so take at least one byte
+	empty := this.kind == "array" && this.items.empty(nil)
+	total := int64(0)
+	for {
+		count, err := binary.ReadVarint(r)
+		if err != nil {
+			return avroTruncated(this)
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// a negative count is followed by the block size in bytes
+			count = -count
+			_, err = binary.ReadVarint(r)
+			if err != nil {
+				return avroTruncated(this)
+			}
+		}
+		total += count
+		if count < 0 || (!empty && count > int64(r.Len())) {
+			return avroTruncated(this)
+		}
+		if empty && (total < 0 || total > avroMaxEmptyItems) {
+			return fmt.Errorf("avro: more than %d items in %s of empty items", avroMaxEmptyItems, this.kind)
+		}
+		for i := int64(0); i < count; i++ {
+			err = item()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// empty determines if values of this schema are encoded as no bytes at all.  Records
+// already being visited are not, as a record containing itself could not be encoded.
+func (this *avroSchema) empty(visiting map[*avroSchema]bool) bool {
+	switch this.kind {
+	case "null":
+		return true
+	case "fixed":
+		return this.size == 0
+	case "record":
+		if visiting[this] {
+			return false
+		}
+		if visiting == nil {
+			visiting = make(map[*avroSchema]bool)
+		}
+		visiting[this] = true
+		defer delete(visiting, this)
+		for _, field := range this.fields {
+			if !field.schema.empty(visiting) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (this *avroSchema) readString(r *bytes.Reader, n int) (interface{}, error) {
+	if n < 0 || n > r.Len() {
+		return nil, avroTruncated(this)
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	if this.kind == "string" {
+		return string(b), nil
+	}
+	runes := make([]rune, n)
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes), nil
+}
+
+func avroTruncated(s *avroSchema) error {
+	return fmt.Errorf("avro: unexpected end of data reading %s", s.kind)
+}
+
+func (this *avroSchema) encode(buf *bytes.Buffer, val interface{}) error {
+	switch this.kind {
+	case "null":
+		if val != nil {
+			return avroMismatch(this, val)
+		}
+		return nil
+	case "boolean":
+		b, ok := val.(bool)
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		return nil
+	case "int", "long":
+		n, ok := this.integer(val)
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		writeVarint(buf, n)
+		return nil
+	case "float":
+		f, ok := avroFloat(val)
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		return binary.Write(buf, binary.LittleEndian, float32(f))
+	case "double":
+		f, ok := avroFloat(val)
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		return binary.Write(buf, binary.LittleEndian, f)
+	case "string":
+		s, ok := val.(string)
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		writeVarint(buf, int64(len(s)))
+		buf.WriteString(s)
+		return nil
+	case "bytes", "fixed":
+		s, ok := val.(string)
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		b := make([]byte, 0, len(s))
+		for _, c := range s {
+			if c > 255 {
+				return fmt.Errorf("avro: invalid code point %U for %s", c, this.kind)
+			}
+			b = append(b, byte(c))
+		}
+		if this.kind == "bytes" {
+			writeVarint(buf, int64(len(b)))
+		} else if len(b) != this.size {
+			return fmt.Errorf("avro: expected %d bytes for fixed %s, got %d", this.size, this.name, len(b))
+		}
+		buf.Write(b)
+		return nil
+	case "enum":
+		s, ok := val.(string)
+		if ok {
+			for i, symbol := range this.symbols {
+				if symbol == s {
+					writeVarint(buf, int64(i))
+					return nil
+				}
+			}
+		}
+		return avroMismatch(this, val)
+	case "union":
+		for i, branch := range this.branches {
+			if branch.accepts(val) {
+				writeVarint(buf, int64(i))
+				return branch.encode(buf, val)
+			}
+		}
+		return avroMismatch(this, val)
+	case "record":
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		for _, field := range this.fields {
+			fieldVal, ok := m[field.name]
+			if !ok {
+				if !field.hasDefault {
+					return fmt.Errorf("avro: missing field %s for record %s", field.name, this.name)
+				}
+				fieldVal = field.def
+			}
+			err := field.schema.encode(buf, fieldVal)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "array":
+		a, ok := val.([]interface{})
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		if len(a) > 0 {
+			writeVarint(buf, int64(len(a)))
+			for _, item := range a {
+				err := this.items.encode(buf, item)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		writeVarint(buf, 0)
+		return nil
+	case "map":
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return avroMismatch(this, val)
+		}
+		if len(m) > 0 {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			writeVarint(buf, int64(len(m)))
+			for _, k := range keys {
+				writeVarint(buf, int64(len(k)))
+				buf.WriteString(k)
+				err := this.items.encode(buf, m[k])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		writeVarint(buf, 0)
+		return nil
+	}
+	return fmt.Errorf("avro: unsupported type %s", this.kind)
+}
+
+// accepts determines if val can be encoded with this schema, used to select a union branch.
+func (this *avroSchema) accepts(val interface{}) bool {
+	switch val := val.(type) {
+	case nil:
+		return this.kind == "null"
+	case bool:
+		return this.kind == "boolean"
+	case float64, json.Number:
+		switch this.kind {
+		case "int", "long":
+			_, ok := this.integer(val)
+			return ok
+		case "float", "double":
+			return true
+		}
+	case string:
+		switch this.kind {
+		case "string", "bytes":
+			return true
+		case "enum":
+			for _, symbol := range this.symbols {
+				if symbol == val {
+					return true
+				}
+			}
+		case "fixed":
+			return len([]rune(val)) == this.size
+		}
+	case []interface{}:
+		return this.kind == "array"
+	case map[string]interface{}:
+		return this.kind == "record" || this.kind == "map"
+	}
+	return false
+}
+
+// integer returns val as an int64 if it is a whole number (a float64 or json.Number) in the
+// range of this int or long schema.
+func (this *avroSchema) integer(val interface{}) (int64, bool) {
+	var n int64
+	switch val := val.(type) {
+	case float64:
+		// math.MaxInt64 is rounded up to 2^63 as a float64, which is out of range
+		if val != math.Trunc(val) || val < math.MinInt64 || val >= math.MaxInt64 {
+			return 0, false
+		}
+		n = int64(val)
+	case json.Number:
+		var err error
+		n, err = strconv.ParseInt(string(val), 10, 64)
+		if err != nil {
+			// for example 1e3, which is still a whole number
+			f, err := val.Float64()
+			if err != nil {
+				return 0, false
+			}
+			return this.integer(f)
+		}
+	default:
+		return 0, false
+	}
+	if this.kind == "int" && (n < math.MinInt32 || n > math.MaxInt32) {
+		return 0, false
+	}
+	return n, true
+}
+
+// avroFloat returns val as a float64 if it is a number.
+func avroFloat(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func avroMismatch(s *avroSchema, val interface{}) error {
+	return fmt.Errorf("avro: cannot encode %T as %s", val, s.kind)
+}
+
+func writeVarint(buf *bytes.Buffer, n int64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutVarint(b, n)])
+}
@@ -0,0 +1,159 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	json "github.com/dustin/gojson"
+)
+
+var avroUserSchema = []byte(`{
+	"type": "record",
+	"name": "User",
+	"namespace": "example",
+	"fields": [
+		{"name": "name", "type": "string"},
+		{"name": "age", "type": "int"},
+		{"name": "email", "type": ["null", "string"]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "scores", "type": {"type": "map", "values": "double"}},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "INACTIVE"]}},
+		{"name": "friend", "type": ["null", "User"], "default": null}
+	]
+}`)
+
+func TestAvroDecode(t *testing.T) {
+	schema := []byte(`{"type":"record","name":"r","fields":[{"name":"a","type":"long"},{"name":"b","type":"string"}]}`)
+	val, err := NewValueFromAvro(schema, []byte{0x02, 0x04, 'h', 'i'})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := map[string]interface{}{"a": 1.0, "b": "hi"}
+	if !reflect.DeepEqual(val.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Value())
+	}
+
+	_, err = NewValueFromAvro(schema, []byte{0x02, 0x04, 'h'})
+	if err == nil {
+		t.Errorf("Expected error for truncated data")
+	}
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{
+		"name": "marty",
+		"age": 37,
+		"email": "marty@example.com",
+		"tags": ["a", "b"],
+		"scores": {"x": 1.5},
+		"status": "INACTIVE",
+		"friend": {"name": "gerald", "age": 40, "email": null, "tags": [], "scores": {}, "status": "ACTIVE", "friend": null}
+	}`))
+
+	data, err := doc.AvroBytes(avroUserSchema)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	val, err := NewValueFromAvro(avroUserSchema, data)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(val.Value(), doc.Value()) {
+		t.Errorf("Expected %v, got %v", doc.Value(), val.Value())
+	}
+
+	// missing field without a default
+	_, err = NewValue(map[string]interface{}{"name": "marty"}).AvroBytes(avroUserSchema)
+	if err == nil {
+		t.Errorf("Expected error for missing field")
+	}
+
+	// wrong type
+	_, err = NewValue(map[string]interface{}{"name": 1.0}).AvroBytes(avroUserSchema)
+	if err == nil {
+		t.Errorf("Expected error for type mismatch")
+	}
+}
+
+func TestAvroUntrustedCounts(t *testing.T) {
+	huge := binary.AppendVarint(nil, 1<<40)
+	var tests = []struct {
+		schema string
+		data   []byte
+	}{
+		// more items than bytes
+		{`{"type":"array","items":"long"}`, huge},
+		{`{"type":"map","values":"null"}`, huge},
+		// items which take no bytes
+		{`{"type":"array","items":"null"}`, huge},
+		{`{"type":"array","items":{"type":"record","name":"e","fields":[]}}`, huge},
+		// a string longer than the data
+		{`"string"`, huge},
+		{`"bytes"`, binary.AppendVarint(nil, math.MaxInt64)},
+	}
+	for _, test := range tests {
+		_, err := NewValueFromAvro([]byte(test.schema), test.data)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.schema)
+		}
+	}
+
+	// empty items within the limit are still decoded
+	data := append(binary.AppendVarint(nil, 3), 0)
+	val, err := NewValueFromAvro([]byte(`{"type":"array","items":"null"}`), data)
+	if err != nil || !reflect.DeepEqual(val.Value(), []interface{}{nil, nil, nil}) {
+		t.Errorf("Expected 3 nulls, got %v, %v", val, err)
+	}
+
+	_, err = NewValue(1e19).AvroBytes([]byte(`"long"`))
+	if err == nil {
+		t.Errorf("Expected an error for a long out of range")
+	}
+}
+
+func TestAvroLargeIntegers(t *testing.T) {
+	for _, n := range []int64{math.MaxInt64, math.MinInt64, 1<<53 + 1, -(1<<53 + 1)} {
+		data := binary.AppendVarint(nil, n)
+		val, err := NewValueFromAvro([]byte(`"long"`), data)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", n, err)
+			continue
+		}
+		if string(val.Bytes()) != strconv.FormatInt(n, 10) {
+			t.Errorf("Expected %d exactly, got %s", n, val.Bytes())
+		}
+		out, err := val.AvroBytes([]byte(`"long"`))
+		if err != nil || !reflect.DeepEqual(out, data) {
+			t.Errorf("%d: expected the same bytes after a round trip, got %v, %v", n, out, err)
+		}
+	}
+
+	// small integers are still numbers of the usual type
+	val, err := NewValueFromAvro([]byte(`"int"`), binary.AppendVarint(nil, -5))
+	if err != nil || val.Value() != -5.0 {
+		t.Errorf("Expected -5, got %v, %v", val, err)
+	}
+
+	// exact numbers must be whole, and in range for an int
+	for _, n := range []json.Number{"1.5", "2147483648", "9223372036854775808"} {
+		if _, err := NewValue(n).AvroBytes([]byte(`"int"`)); err == nil {
+			t.Errorf("%s: expected an error encoding an int", n)
+		}
+	}
+	out, err := NewValue(json.Number("1e3")).AvroBytes([]byte(`"int"`))
+	if err != nil || !reflect.DeepEqual(out, binary.AppendVarint(nil, 1000)) {
+		t.Errorf("Expected 1000, got %v, %v", out, err)
+	}
+}
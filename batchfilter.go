@@ -0,0 +1,240 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// A Selection is a bitmap of the rows of a ColumnarBatch.
+type Selection struct {
+	bits []uint64
+	len  int
+}
+
+func newSelection(n int) *Selection {
+	return &Selection{
+		bits: make([]uint64, (n+63)/64),
+		len:  n,
+	}
+}
+
+func (this *Selection) set(i int) {
+	this.bits[i/64] |= 1 << uint(i%64)
+}
+
+// Determine if row i is selected.
+func (this *Selection) Contains(i int) bool {
+	return this.bits[i/64]&(1<<uint(i%64)) != 0
+}
+
+// The number of rows which are selected.
+func (this *Selection) Count() int {
+	rv := 0
+	for _, word := range this.bits {
+		rv += bits.OnesCount64(word)
+	}
+	return rv
+}
+
+// The number of rows in the batch, selected or not.
+func (this *Selection) Len() int {
+	return this.len
+}
+
+// Return the selected rows, in order.
+func (this *Selection) Indexes() []int {
+	rv := make([]int, 0, this.Count())
+	for i := 0; i < this.len; i++ {
+		if this.Contains(i) {
+			rv = append(rv, i)
+		}
+	}
+	return rv
+}
+
+// Return the selected documents of the batch.  Only these rows are built.
+func (this *ColumnarBatch) Rows(selection *Selection) ValueCollection {
+	rv := make(ValueCollection, 0, selection.Count())
+	for _, i := range selection.Indexes() {
+		rv = append(rv, this.Row(i))
+	}
+	return rv
+}
+
+// Evaluate expr against every document of batch, returning the rows for which it is true
+// (as Expression.Matches() would for each row).
+//
+// Comparisons between a shredded property and a literal, and the logic combining them,
+// are evaluated a column at a time without building any rows.  Any other part of the
+// expression is evaluated row by row, building only the rows it needs.
+func FilterBatch(batch *ColumnarBatch, expr *Expression) (*Selection, error) {
+	filter := batchFilter{
+		batch: batch,
+		rows:  make(ValueCollection, batch.Len()),
+	}
+	rv, err := filter.eval(expr.root)
+	if err != nil {
+		return nil, err
+	}
+	return rv.truth, nil
+}
+
+type batchFilter struct {
+	batch *ColumnarBatch
+	rows  ValueCollection // the rows built so far
+}
+
+// batchResult describes the result of a node for every row: truth selects the rows
+// for which it is true, boolean the rows for which it is true or false (rather than
+// null, missing or some other type).
+type batchResult struct {
+	truth, boolean *Selection
+}
+
+func (this *batchFilter) eval(node exprNode) (batchResult, error) {
+	switch node := node.(type) {
+	case *literalNode:
+		rv := this.newResult()
+		if node.val.Type() == BOOLEAN {
+			for i := 0; i < this.batch.Len(); i++ {
+				rv.boolean.set(i)
+				if isTrue(node.val) {
+					rv.truth.set(i)
+				}
+			}
+		}
+		return rv, nil
+	case *notNode:
+		operand, err := this.eval(node.operand)
+		if err != nil {
+			return batchResult{}, err
+		}
+		rv := this.newResult()
+		for w := range rv.truth.bits {
+			rv.truth.bits[w] = operand.boolean.bits[w] &^ operand.truth.bits[w]
+			rv.boolean.bits[w] = operand.boolean.bits[w]
+		}
+		return rv, nil
+	case *logicalNode:
+		left, err := this.eval(node.left)
+		if err != nil {
+			return batchResult{}, err
+		}
+		right, err := this.eval(node.right)
+		if err != nil {
+			return batchResult{}, err
+		}
+		rv := this.newResult()
+		for w := range rv.truth.bits {
+			if node.and {
+				rv.truth.bits[w] = left.truth.bits[w] & right.truth.bits[w]
+			} else {
+				rv.truth.bits[w] = left.truth.bits[w] | right.truth.bits[w]
+			}
+		}
+		// AND and OR are always true or false
+		for i := 0; i < this.batch.Len(); i++ {
+			rv.boolean.set(i)
+		}
+		return rv, nil
+	case *compareNode:
+		if column, literal, op, ok := this.columnComparison(node); ok {
+			return this.compareColumn(column, literal, op), nil
+		}
+	}
+	return this.rowwise(node)
+}
+
+func (this *batchFilter) newResult() batchResult {
+	return batchResult{
+		truth:   newSelection(this.batch.Len()),
+		boolean: newSelection(this.batch.Len()),
+	}
+}
+
+// columnComparison determines if node compares a shredded property to a literal,
+// returning the operator as if the property were on the left.
+func (this *batchFilter) columnComparison(node *compareNode) (*Column, *Value, string, bool) {
+	op := node.op
+	path, ok := node.left.(*pathNode)
+	literal, lok := node.right.(*literalNode)
+	if !ok || !lok {
+		path, ok = node.right.(*pathNode)
+		literal, lok = node.left.(*literalNode)
+		switch op {
+		case "<":
+			op = ">"
+		case "<=":
+			op = ">="
+		case ">":
+			op = "<"
+		case ">=":
+			op = "<="
+		}
+	}
+	if !ok || !lok || len(path.steps) != 1 {
+		return nil, nil, "", false
+	}
+	column := this.batch.Column(path.steps[0].key)
+	if column == nil {
+		return nil, nil, "", false
+	}
+	return column, literal.val, op, true
+}
+
+func (this *batchFilter) compareColumn(column *Column, literal *Value, op string) batchResult {
+	rv := this.newResult()
+	if literal.Type() == NULL {
+		// comparisons with null are null
+		return rv
+	}
+	lit := literal.Value()
+	f, isFloat := lit.(float64)
+	s, isString := lit.(string)
+	for i := 0; i < this.batch.Len(); i++ {
+		var cmp int
+		switch {
+		case column.Type == NUMBER && isFloat:
+			cmp = compareFloats(column.Numbers[i], f)
+		case column.Type == STRING && isString:
+			cmp = strings.Compare(column.Strings[i], s)
+		default:
+			cmp = compareNative(column.Value(i), lit, CompareOptions{})
+		}
+		rv.boolean.set(i)
+		if compared(op, cmp) {
+			rv.truth.set(i)
+		}
+	}
+	return rv
+}
+
+// rowwise evaluates node against each row in turn.
+func (this *batchFilter) rowwise(node exprNode) (batchResult, error) {
+	rv := this.newResult()
+	for i := range this.rows {
+		if this.rows[i] == nil {
+			this.rows[i] = this.batch.Row(i)
+		}
+		val, err := node.eval(&evalContext{doc: this.rows[i]})
+		if err != nil {
+			return batchResult{}, err
+		}
+		if val != nil && val.Type() == BOOLEAN {
+			rv.boolean.set(i)
+			if isTrue(val) {
+				rv.truth.set(i)
+			}
+		}
+	}
+	return rv, nil
+}
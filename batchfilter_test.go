@@ -0,0 +1,80 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterBatch(t *testing.T) {
+	docs := ValueCollection{
+		NewValueFromBytes([]byte(`{"name":"a","age":30,"active":true,"tags":["x"]}`)),
+		NewValueFromBytes([]byte(`{"name":"b","age":40,"active":false,"tags":[]}`)),
+		NewValueFromBytes([]byte(`{"name":"c","age":50,"active":true,"tags":["x","y"]}`)),
+	}
+	batch, err := NewColumnarBatch(docs)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var tests = []struct {
+		expr string
+		rows []int
+	}{
+		{`age > 35`, []int{1, 2}},
+		{`35 > age`, []int{0}},
+		{`name = "b" OR age >= 50`, []int{1, 2}},
+		{`active AND NOT (age = 30)`, []int{2}},
+		{`NOT (age = null)`, []int{}},
+		{`age > "x"`, []int{}},
+		{`tags[1] = "y"`, []int{2}},
+		{`NOT (missing = 1)`, []int{}},
+		{`age + 10 = 50 AND active = false`, []int{1}},
+	}
+	for _, test := range tests {
+		expr, err := ParseExpression(test.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error %v for %s", err, test.expr)
+		}
+		selection, err := FilterBatch(batch, expr)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.expr)
+			continue
+		}
+		if !reflect.DeepEqual(selection.Indexes(), test.rows) {
+			t.Errorf("Expected rows %v, got %v for %s", test.rows, selection.Indexes(), test.expr)
+		}
+		// the selection must agree with evaluating each document
+		for i, doc := range docs {
+			matches, _ := expr.Matches(doc)
+			if matches != selection.Contains(i) {
+				t.Errorf("Expected row %d to be %t for %s", i, matches, test.expr)
+			}
+		}
+	}
+}
+
+func TestBatchRows(t *testing.T) {
+	batch, _ := NewColumnarBatch(ValueCollection{
+		NewValue(map[string]interface{}{"n": 1.0}),
+		NewValue(map[string]interface{}{"n": 2.0}),
+		NewValue(map[string]interface{}{"n": 3.0}),
+	})
+	expr, _ := ParseExpression(`n != 2`)
+	selection, _ := FilterBatch(batch, expr)
+	if selection.Count() != 2 || selection.Len() != 3 {
+		t.Errorf("Expected 2 of 3 rows, got %d of %d", selection.Count(), selection.Len())
+	}
+	rows := batch.Rows(selection)
+	if len(rows) != 2 || string(rows[1].Bytes()) != `{"n":3}` {
+		t.Errorf("Unexpected rows %v", rows)
+	}
+}
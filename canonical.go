@@ -0,0 +1,171 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Serialize this Value in the canonical form defined by RFC 8785 (JSON Canonicalization
+// Scheme), suitable for use as the payload of a signature.  Semantically equal Values
+// always produce identical bytes:
+//
+//         1. Object keys are sorted by their UTF-16 code units, without whitespace.
+//         2. Strings escape only ", \ and control characters.
+//         3. Numbers are serialized as ECMAScript does for IEEE 754 doubles (for example 4.50 is 4.5 and 1E30 is 1e+30).
+//
+// If this Value is NOT_JSON the return error is *SyntaxError.
+func (this *Value) JWSPayload() ([]byte, error) {
+	if this.parsedType == NOT_JSON {
+		return nil, this.locateSyntaxError("", fmt.Errorf("not JSON"))
+	}
+	var buf bytes.Buffer
+	err := canonicalize(&buf, this.Value())
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func canonicalize(buf *bytes.Buffer, val interface{}) error {
+	switch val := val.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case string:
+		canonicalString(buf, val)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, v := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			err := canonicalize(buf, v)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Sort(utf16Keys(keys))
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			canonicalString(buf, k)
+			buf.WriteByte(':')
+			err := canonicalize(buf, val[k])
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		s, err := canonicalNumber(nativeNumber(val))
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	}
+	return nil
+}
+
+func canonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber serializes f as ECMAScript Number.prototype.toString() does.
+func canonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", &OutOfRange{Value: f, msg: "cannot canonicalize non-finite number"}
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	// shortest digits which round trip, as d.ddde±x
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := e[:strings.IndexByte(e, 'e')], e[strings.IndexByte(e, 'e')+1:]
+	digits := strings.Replace(mantissa, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	k, n := len(digits), x+1
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+	exponent := strconv.Itoa(int(math.Abs(float64(n - 1))))
+	if k == 1 {
+		return sign + digits + "e" + expSign + exponent, nil
+	}
+	return sign + digits[:1] + "." + digits[1:] + "e" + expSign + exponent, nil
+}
+
+// utf16Keys sorts strings by their UTF-16 code units, as RFC 8785 requires.
+type utf16Keys []string
+
+func (this utf16Keys) Len() int      { return len(this) }
+func (this utf16Keys) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+func (this utf16Keys) Less(i, j int) bool {
+	a, b := utf16.Encode([]rune(this[i])), utf16.Encode([]rune(this[j]))
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
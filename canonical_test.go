@@ -0,0 +1,77 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestJWSPayload(t *testing.T) {
+	var tests = []struct {
+		input  string
+		output string
+	}{
+		// examples from RFC 8785
+		{`{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000000000000000000000000001],"string":"\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/","literals":[null,true,false]}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		{`{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		{` [ 1 , { "b" : 2 , "a" : [ ] } ] `, `[1,{"a":[],"b":2}]`},
+	}
+
+	for _, test := range tests {
+		out, err := NewValueFromBytes([]byte(test.input)).JWSPayload()
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.input)
+			continue
+		}
+		if string(out) != test.output {
+			t.Errorf("Expected %s, got %s", test.output, string(out))
+		}
+	}
+
+	if _, err := NewValueFromBytes([]byte(`{"a":`)).JWSPayload(); err == nil {
+		t.Errorf("Expected error for NOT_JSON")
+	}
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	var tests = []struct {
+		input  float64
+		output string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{1, "1"},
+		{-1.5, "-1.5"},
+		{1e21, "1e+21"},
+		{1e20, "100000000000000000000"},
+		{123456789012345680000, "123456789012345680000"},
+		{1e-7, "1e-7"},
+		{0.000001, "0.000001"},
+		{1.5e-7, "1.5e-7"},
+		{9007199254740992, "9007199254740992"},
+		{5e-324, "5e-324"},
+		{1.7976931348623157e308, "1.7976931348623157e+308"},
+		{-1.2345e25, "-1.2345e+25"},
+	}
+
+	for _, test := range tests {
+		out, err := canonicalNumber(test.input)
+		if err != nil || out != test.output {
+			t.Errorf("Expected %s, got %s (%v) for %v", test.output, out, err, test.input)
+		}
+	}
+
+	if _, err := canonicalNumber(math.Inf(1)); err == nil {
+		t.Errorf("Expected error for infinity")
+	}
+}
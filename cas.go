@@ -0,0 +1,40 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strings"
+)
+
+// Store newVal at the dotted path inside this Value (as for SetDeepPath()) only if the value
+// currently there is equal to expected, as Equals() compares them, so numbers are compared by
+// their numeric value and objects and arrays deeply.  The return value reports whether the
+// change was made.  If the path does not exist, nothing is changed and false is returned.
+//
+// Value does no locking, so when a Value is shared between goroutines the caller must hold a
+// lock across the call for the comparison and the change to be atomic.  If the path cannot be
+// set, or a Schema or constraint rejects newVal, the error is returned as for SetDeepPath().
+func (this *Value) CompareAndSetPath(path string, expected, newVal interface{}) (bool, error) {
+	current, err := resolvePath(this, path)
+	if err != nil {
+		if _, ok := err.(*Undefined); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	if !current.Equals(NewValue(expected)) {
+		return false, nil
+	}
+	err = this.setSteps(newVal, strings.Split(path, "."))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
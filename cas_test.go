@@ -0,0 +1,65 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestCompareAndSetPath(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"version": 1, "state": {"tags": ["a"]}, "items": [10, 20]}`))
+	var tests = []struct {
+		path     string
+		expected interface{}
+		newVal   interface{}
+		swapped  bool
+		result   interface{}
+	}{
+		{"version", 1.0, 2.0, true, 2.0},
+		{"version", 1.0, 3.0, false, 2.0},
+		{"state.tags", []interface{}{"a"}, "b", true, "b"},
+		{"items.1", 20.0, 21.0, true, 21.0},
+		{"items.1", 20.0, 22.0, false, 21.0},
+		{"missing", nil, 1.0, false, nil},
+	}
+	for _, test := range tests {
+		swapped, err := doc.CompareAndSetPath(test.path, test.expected, test.newVal)
+		if err != nil || swapped != test.swapped {
+			t.Errorf("%s: expected %v, got %v, %v", test.path, test.swapped, swapped, err)
+		}
+		if test.result == nil {
+			if doc.ExistsPath(test.path) {
+				t.Errorf("%s: expected the path not to be created", test.path)
+			}
+			continue
+		}
+		val, err := resolvePath(doc, test.path)
+		if err != nil || val.Value() != test.result {
+			t.Errorf("%s: expected %v, got %v, %v", test.path, test.result, val, err)
+		}
+	}
+}
+
+func TestCompareAndSetPathSchema(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"n": 1, "items": [1]}`))
+	doc.BindSchema(&Schema{Type: OBJECT, Properties: map[string]*Schema{
+		"n":     {Type: NUMBER},
+		"items": {Type: ARRAY, Items: &Schema{Type: NUMBER}},
+	}})
+	for _, path := range []string{"n", "items.0"} {
+		swapped, err := doc.CompareAndSetPath(path, 1.0, "one")
+		if _, ok := err.(*TypeMismatch); !ok || swapped {
+			t.Errorf("%s: expected *TypeMismatch, got %v, %v", path, swapped, err)
+		}
+	}
+	if string(doc.Bytes()) != `{"n": 1, "items": [1]}` {
+		t.Errorf("Expected the document to be unchanged, got %s", doc.Bytes())
+	}
+}
@@ -0,0 +1,360 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	json "github.com/dustin/gojson"
+)
+
+// The major types of CBOR (RFC 8949)
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+// The maximum nesting of arrays, maps and tags accepted when decoding CBOR.
+const cborMaxDepth = 1000
+
+// decodeCBOR creates a Value from a single CBOR data item, as registered for application/cbor.
+//
+// CBOR types are mapped to JSON types as described in RFC 8949 section 6.1: integers and floats
+// become NUMBER (integers too large for a float64 are kept exactly), byte strings become STRING
+// (encoded as base64url without padding), text strings become STRING, arrays become ARRAY,
+// maps with text string keys become OBJECT, and undefined becomes NULL.  Tags are ignored
+// and their content decoded.  Any other map key or simple value is an error.
+func decodeCBOR(b []byte) (*Value, error) {
+	r := bytes.NewReader(b)
+	val, err := readCBOR(r, 0, int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("cbor: %d unexpected bytes after data item", r.Len())
+	}
+	return NewValue(val), nil
+}
+
+// encodeCBOR serializes a Value as a CBOR data item, as registered for application/cbor.
+// Numbers which are integers are encoded as integers, other numbers as the shortest float
+// which represents them exactly.  Object keys are written in sorted order.
+func encodeCBOR(val *Value) ([]byte, error) {
+	if val.Type() == NOT_JSON {
+		return nil, fmt.Errorf("cbor: cannot encode a Value which is not JSON")
+	}
+	buf := bytes.Buffer{}
+	err := writeCBOR(&buf, val.Value())
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readCBOR decodes the next data item from r, at the specified depth of nesting.
+// size is the length of the whole input, used to report the offset of errors.
+func readCBOR(r *bytes.Reader, depth int, size int64) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, &DepthExceeded{cborMaxDepth, size - int64(r.Len())}
+	}
+	major, info, n, err := readCBORHead(r)
+	if err != nil {
+		return nil, err
+	}
+	indefinite := info == 31
+	switch major {
+	case cborUnsigned:
+		if n <= maxExactInteger {
+			return float64(n), nil
+		}
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case cborNegative:
+		if n < maxExactInteger {
+			return -1 - float64(n), nil
+		}
+		exact := new(big.Int).SetUint64(n)
+		return json.Number(exact.Add(exact, big.NewInt(1)).Neg(exact).String()), nil
+	case cborBytes, cborText:
+		s, err := readCBORString(r, major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return base64.RawURLEncoding.EncodeToString(s), nil
+		}
+		if !utf8.Valid(s) {
+			return nil, fmt.Errorf("cbor: text string is not valid UTF-8")
+		}
+		return string(s), nil
+	case cborArray:
+		// each item takes at least one byte, so the count is bounded by the data
+		if !indefinite && n > uint64(r.Len()) {
+			return nil, cborTruncated()
+		}
+		rv := make([]interface{}, 0, int(n))
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite && cborBreak(r) {
+				break
+			}
+			item, err := readCBOR(r, depth+1, size)
+			if err != nil {
+				return nil, err
+			}
+			rv = append(rv, item)
+		}
+		return rv, nil
+	case cborMap:
+		// each entry takes at least two bytes
+		if !indefinite && n > uint64(r.Len())/2 {
+			return nil, cborTruncated()
+		}
+		rv := make(map[string]interface{}, int(n))
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite && cborBreak(r) {
+				break
+			}
+			key, err := readCBOR(r, depth+1, size)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key %v is not a string", key)
+			}
+			rv[k], err = readCBOR(r, depth+1, size)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rv, nil
+	case cborTag:
+		return readCBOR(r, depth+1, size)
+	}
+	// major type 7, the simple values and floats
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfFloat(uint16(n)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	case 31:
+		return nil, fmt.Errorf("cbor: unexpected break")
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", n)
+}
+
+// readCBORHead reads the initial byte of a data item and its argument.
+// For indefinite lengths (info 31) the argument is 0.
+func readCBORHead(r *bytes.Reader) (major byte, info byte, n uint64, err error) {
+	initial, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, cborTruncated()
+	}
+	major, info = initial>>5, initial&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		arg := make([]byte, 1<<(info-24))
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return 0, 0, 0, cborTruncated()
+		}
+		for _, b := range arg {
+			n = n<<8 | uint64(b)
+		}
+		return major, info, n, nil
+	case info == 31 && major >= cborBytes && major != cborTag:
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("cbor: invalid additional information %d for major type %d", info, major)
+}
+
+// readCBORString reads the contents of a byte or text string, joining the chunks of an
+// indefinite length string, which must all be definite strings of the same major type.
+func readCBORString(r *bytes.Reader, major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if n > uint64(r.Len()) {
+			return nil, cborTruncated()
+		}
+		rv := make([]byte, int(n))
+		r.Read(rv)
+		return rv, nil
+	}
+	rv := []byte{}
+	for !cborBreak(r) {
+		chunkMajor, info, n, err := readCBORHead(r)
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || info == 31 {
+			return nil, fmt.Errorf("cbor: invalid chunk in indefinite length string")
+		}
+		chunk, err := readCBORString(r, major, n, false)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, chunk...)
+	}
+	return rv, nil
+}
+
+// cborBreak consumes the break which ends an indefinite length item, if it is next.
+func cborBreak(r *bytes.Reader) bool {
+	b, err := r.ReadByte()
+	if err != nil {
+		return false
+	}
+	if b == 0xff {
+		return true
+	}
+	r.UnreadByte()
+	return false
+}
+
+func cborTruncated() error {
+	return fmt.Errorf("cbor: unexpected end of data")
+}
+
+// halfFloat converts an IEEE 754 half precision float to a float64.
+func halfFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var rv float64
+	switch exp {
+	case 0:
+		rv = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			rv = math.Inf(1)
+		} else {
+			rv = math.NaN()
+		}
+	default:
+		rv = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -rv
+	}
+	return rv
+}
+
+// writeCBOR encodes a native Go representation of JSON.
+func writeCBOR(buf *bytes.Buffer, val interface{}) error {
+	switch val := val.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | 22)
+	case bool:
+		if val {
+			buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case float64:
+		writeCBORNumber(buf, val)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			writeCBORInt(buf, i)
+		} else if u, err := strconv.ParseUint(string(val), 10, 64); err == nil {
+			writeCBORHead(buf, cborUnsigned, u)
+		} else {
+			f, _ := val.Float64()
+			writeCBORNumber(buf, f)
+		}
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(val)))
+		buf.WriteString(val)
+	case []interface{}:
+		writeCBORHead(buf, cborArray, uint64(len(val)))
+		for _, item := range val {
+			err := writeCBOR(buf, item)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeCBORHead(buf, cborMap, uint64(len(val)))
+		for _, k := range sortedKeys(val) {
+			writeCBORHead(buf, cborText, uint64(len(k)))
+			buf.WriteString(k)
+			err := writeCBOR(buf, val[k])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: cannot encode %T", val)
+	}
+	return nil
+}
+
+// writeCBORNumber encodes an integer as an integer, anything else as the
+// shortest float which represents it exactly.
+func writeCBORNumber(buf *bytes.Buffer, f float64) {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		writeCBORInt(buf, int64(f))
+		return
+	}
+	if f32 := float32(f); float64(f32) == f || math.IsNaN(f) {
+		buf.WriteByte(cborSimple<<5 | 26)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+		return
+	}
+	buf.WriteByte(cborSimple<<5 | 27)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+func writeCBORInt(buf *bytes.Buffer, i int64) {
+	if i < 0 {
+		writeCBORHead(buf, cborNegative, uint64(-(i + 1)))
+		return
+	}
+	writeCBORHead(buf, cborUnsigned, uint64(i))
+}
+
+// writeCBORHead writes the initial byte of a data item with the shortest encoding of n.
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
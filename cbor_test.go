@@ -0,0 +1,135 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+
+	json "github.com/dustin/gojson"
+)
+
+func TestCBORDecode(t *testing.T) {
+	// examples from RFC 8949 appendix A
+	var tests = []struct {
+		input    string
+		expected interface{}
+	}{
+		{"00", 0.0},
+		{"17", 23.0},
+		{"1818", 24.0},
+		{"3863", -100.0},
+		{"1bffffffffffffffff", json.Number("18446744073709551615")},
+		{"3bffffffffffffffff", json.Number("-18446744073709551616")},
+		{"f93c00", 1.0},
+		{"f97bff", 65504.0},
+		{"f90001", 5.960464477539063e-8},
+		{"fa47c35000", 100000.0},
+		{"fb3ff199999999999a", 1.1},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f7", nil},
+		{"6161", "a"},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"4401020304", "AQIDBA"},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+		{"83010203", []interface{}{1.0, 2.0, 3.0}},
+		{"9f018202039f0405ffff", []interface{}{1.0, []interface{}{2.0, 3.0}, []interface{}{4.0, 5.0}}},
+		{"a26161016162820203", map[string]interface{}{"a": 1.0, "b": []interface{}{2.0, 3.0}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": 1.0, "b": []interface{}{2.0, 3.0}}},
+	}
+	for _, test := range tests {
+		input, _ := hex.DecodeString(test.input)
+		val, err := DecodeAs("application/cbor", input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(val.Value(), test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.input, test.expected, val.Value())
+		}
+	}
+
+	for _, input := range []string{
+		"",                   // no data item
+		"0001",               // trailing data
+		"19ff",               // truncated argument
+		"62ff",               // truncated string
+		"9bffffffffffffffff", // more items than bytes
+		"bbffffffffffffffff", // more entries than bytes
+		"a10102",             // integer map key
+		"62c328",             // invalid UTF-8
+		"ff",                 // break outside an indefinite length item
+		"9f01",               // missing break
+		"7f4161ff",           // byte string chunk in a text string
+		"f0",                 // unassigned simple value
+		"1c",                 // reserved additional information
+	} {
+		b, _ := hex.DecodeString(input)
+		if val, err := DecodeAs("application/cbor", b); err == nil {
+			t.Errorf("%s: expected an error, got %v", input, val)
+		}
+	}
+
+	deep := make([]byte, cborMaxDepth+2)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+	if _, err := DecodeAs("application/cbor", deep); err == nil {
+		t.Errorf("Expected *DepthExceeded, got %v", err)
+	} else if _, ok := err.(*DepthExceeded); !ok {
+		t.Errorf("Expected *DepthExceeded, got %v", err)
+	}
+}
+
+func TestCBOREncode(t *testing.T) {
+	var tests = []struct {
+		input    interface{}
+		expected string
+	}{
+		{0.0, "00"},
+		{24.0, "1818"},
+		{-100.0, "3863"},
+		{100000.0, "1a000186a0"},
+		{1.5, "fa3fc00000"},
+		{1.1, "fb3ff199999999999a"},
+		{math.Pow(2, 70), "fa62800000"},
+		{json.Number("18446744073709551615"), "1bffffffffffffffff"},
+		{json.Number("-3"), "22"},
+		{true, "f5"},
+		{nil, "f6"},
+		{"a", "6161"},
+		{[]interface{}{1.0, []interface{}{2.0, 3.0}}, "8201820203"},
+		{map[string]interface{}{"b": []interface{}{2.0, 3.0}, "a": 1.0}, "a26161016162820203"},
+	}
+	for _, test := range tests {
+		out, err := EncodeAs("application/cbor", NewValue(test.input))
+		if err != nil || hex.EncodeToString(out) != test.expected {
+			t.Errorf("%v: expected %s, got %x, %v", test.input, test.expected, out, err)
+		}
+	}
+
+	doc := NewValueFromBytes([]byte(`{"name": "marty", "age": 37, "ratio": -0.25, "tags": ["a", null, true], "nested": {"x": {}}}`))
+	out, err := EncodeAs("application/cbor", doc)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	again, err := DecodeAs("application/cbor", out)
+	if err != nil || !again.Equals(doc) {
+		t.Errorf("Expected %s after a round trip, got %v, %v", doc.Bytes(), again, err)
+	}
+
+	if _, err := EncodeAs("application/cbor", NewValueFromBytes([]byte(`{"a":`))); err == nil {
+		t.Errorf("Expected an error encoding NOT_JSON")
+	}
+}
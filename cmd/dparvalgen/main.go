@@ -0,0 +1,399 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Generate MarshalValue and UnmarshalValue methods for structs, so they can be converted to and
+from dparval Values without reflection at runtime.
+
+Add a directive to a file in the package containing the structs:
+
+	//go:generate dparvalgen -type=User,Address
+
+Field names are taken from the dparval tag, then the json tag, then the field name itself.
+The "omitempty" option and the name "-" are honored.  Supported field types are strings,
+booleans, numbers, *dparval.Value, structs (and pointers to structs) with generated methods,
+and slices and string keyed maps of these.  Integers are converted exactly, rather than
+through a float64, so every int64 and uint64 survives a round trip.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var typeNames = flag.String("type", "", "comma separated list of struct type names")
+var output = flag.String("output", "", "output file name (default <first type>_dparval.go)")
+
+func main() {
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, pkg := range pkgs {
+		var files []*ast.File
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+		src, err := generate(name, files, types)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outName := *output
+		if outName == "" {
+			outName = strings.ToLower(types[0]) + "_dparval.go"
+		}
+		err = os.WriteFile(outName, src, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	log.Fatal("no package found in current directory")
+}
+
+type generator struct {
+	buf      bytes.Buffer
+	structs  map[string]*ast.StructType
+	vars     int
+	integers bool // the generated code converts integers, so imports strconv
+}
+
+// generate returns the source for a file in package pkgName containing the
+// methods for the named struct types declared in files.
+func generate(pkgName string, files []*ast.File, types []string) ([]byte, error) {
+	g := generator{structs: map[string]*ast.StructType{}}
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					g.structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+
+	for _, name := range types {
+		st, ok := g.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("struct type %s not found", name)
+		}
+		err := g.generateStruct(name, st)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the imports depend on the code generated
+	header := bytes.Buffer{}
+	fmt.Fprintf(&header, "// Code generated by dparvalgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&header, "package %s\n\n", pkgName)
+	fmt.Fprintf(&header, "import (\n\"fmt\"\n")
+	if g.integers {
+		fmt.Fprintf(&header, "\"strconv\"\n")
+	}
+	fmt.Fprintf(&header, "\n\"github.com/mschoch/dparval\"\n)\n\n")
+	src, err := format.Source(append(header.Bytes(), g.buf.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("internal error formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+type field struct {
+	goName    string
+	name      string
+	omitEmpty bool
+	typ       ast.Expr
+}
+
+func (this *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&this.buf, format, args...)
+}
+
+func (this *generator) tmp() string {
+	this.vars++
+	return fmt.Sprintf("v%d", this.vars)
+}
+
+func (this *generator) generateStruct(name string, st *ast.StructType) error {
+	var fields []field
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			fld := field{goName: ident.Name, name: ident.Name, typ: f.Type}
+			if f.Tag != nil {
+				tagValue, _ := strconv.Unquote(f.Tag.Value)
+				tag := reflect.StructTag(tagValue)
+				spec := tag.Get("dparval")
+				if spec == "" {
+					spec = tag.Get("json")
+				}
+				parts := strings.Split(spec, ",")
+				if parts[0] == "-" {
+					continue
+				}
+				if parts[0] != "" {
+					fld.name = parts[0]
+				}
+				for _, opt := range parts[1:] {
+					if opt == "omitempty" {
+						fld.omitEmpty = true
+					}
+				}
+			}
+			fields = append(fields, fld)
+		}
+	}
+
+	this.printf("// MarshalValue converts %s into a *dparval.Value.\n", name)
+	this.printf("func (this %s) MarshalValue() *dparval.Value {\n", name)
+	this.printf("rv := make(map[string]interface{}, %d)\n", len(fields))
+	for _, f := range fields {
+		src := "this." + f.goName
+		if f.omitEmpty {
+			cond, err := this.nonEmpty(f.typ, src)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", name, f.goName, err)
+			}
+			this.printf("if %s {\n", cond)
+		}
+		err := this.marshal(f.typ, src, fmt.Sprintf("rv[%q]", f.name))
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.goName, err)
+		}
+		if f.omitEmpty {
+			this.printf("}\n")
+		}
+	}
+	this.printf("return dparval.NewValue(rv)\n}\n\n")
+
+	this.printf("// UnmarshalValue populates %s from a *dparval.Value of type OBJECT.\n", name)
+	this.printf("func (this *%s) UnmarshalValue(val *dparval.Value) error {\n", name)
+	this.printf("if val.Type() != dparval.OBJECT {\nreturn fmt.Errorf(\"%s: expected object\")\n}\n", name)
+	for _, f := range fields {
+		v := this.tmp()
+		this.printf("if %s, err := val.Path(%q); err == nil {\n", v, f.name)
+		err := this.unmarshal(f.typ, v, "this."+f.goName, name+"."+f.name)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.goName, err)
+		}
+		this.printf("}\n")
+	}
+	this.printf("return nil\n}\n\n")
+	return nil
+}
+
+// kind classifies a field type expression.
+func (this *generator) kind(typ ast.Expr) string {
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		switch typ.Name {
+		case "string", "bool":
+			return typ.Name
+		case "int", "int8", "int16", "int32", "int64":
+			return "int"
+		case "uint", "uint8", "uint16", "uint32", "uint64":
+			return "uint"
+		case "float32", "float64":
+			return "number"
+		}
+		if _, ok := this.structs[typ.Name]; ok {
+			return "struct"
+		}
+	case *ast.StarExpr:
+		if sel, ok := typ.X.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "dparval" && sel.Sel.Name == "Value" {
+				return "value"
+			}
+		}
+		return "pointer"
+	case *ast.ArrayType:
+		if typ.Len == nil {
+			return "slice"
+		}
+	case *ast.MapType:
+		if key, ok := typ.Key.(*ast.Ident); ok && key.Name == "string" {
+			return "map"
+		}
+	}
+	return ""
+}
+
+func typeString(typ ast.Expr) string {
+	buf := bytes.Buffer{}
+	format.Node(&buf, token.NewFileSet(), typ)
+	return buf.String()
+}
+
+func (this *generator) nonEmpty(typ ast.Expr, src string) (string, error) {
+	switch this.kind(typ) {
+	case "string":
+		return src + ` != ""`, nil
+	case "bool":
+		return src, nil
+	case "number", "int", "uint":
+		return src + " != 0", nil
+	case "value", "pointer":
+		return src + " != nil", nil
+	case "slice", "map":
+		return "len(" + src + ") > 0", nil
+	}
+	return "", fmt.Errorf("omitempty not supported for type %s", typeString(typ))
+}
+
+// marshal writes statements assigning the JSON compatible form of src to dst.
+func (this *generator) marshal(typ ast.Expr, src, dst string) error {
+	switch this.kind(typ) {
+	case "string", "bool":
+		this.printf("%s = %s\n", dst, src)
+	case "value":
+		// a nil *Value must not be stored, as it is not a valid Value
+		this.printf("if %s == nil {\n%s = nil\n} else {\n%s = %s\n}\n", src, dst, dst, src)
+	case "number":
+		this.printf("%s = float64(%s)\n", dst, src)
+	case "int":
+		// the decimal text is kept exactly, where a float64 would round integers beyond 2^53
+		this.integers = true
+		this.printf("%s = dparval.NewValueFromBytes(strconv.AppendInt(nil, int64(%s), 10))\n", dst, src)
+	case "uint":
+		this.integers = true
+		this.printf("%s = dparval.NewValueFromBytes(strconv.AppendUint(nil, uint64(%s), 10))\n", dst, src)
+	case "struct":
+		this.printf("%s = %s.MarshalValue()\n", dst, src)
+	case "pointer":
+		this.printf("if %s == nil {\n%s = nil\n} else {\n", src, dst)
+		err := this.marshal(typ.(*ast.StarExpr).X, "(*"+src+")", dst)
+		if err != nil {
+			return err
+		}
+		this.printf("}\n")
+	case "slice":
+		s, i, item := this.tmp(), this.tmp(), this.tmp()
+		this.printf("%s := make([]interface{}, len(%s))\n", s, src)
+		this.printf("for %s, %s := range %s {\n", i, item, src)
+		err := this.marshal(typ.(*ast.ArrayType).Elt, item, s+"["+i+"]")
+		if err != nil {
+			return err
+		}
+		this.printf("}\n%s = %s\n", dst, s)
+	case "map":
+		m, k, item := this.tmp(), this.tmp(), this.tmp()
+		this.printf("%s := make(map[string]interface{}, len(%s))\n", m, src)
+		this.printf("for %s, %s := range %s {\n", k, item, src)
+		err := this.marshal(typ.(*ast.MapType).Value, item, m+"["+k+"]")
+		if err != nil {
+			return err
+		}
+		this.printf("}\n%s = %s\n", dst, m)
+	default:
+		return fmt.Errorf("unsupported type %s", typeString(typ))
+	}
+	return nil
+}
+
+// unmarshal writes statements assigning the *dparval.Value src to dst.
+func (this *generator) unmarshal(typ ast.Expr, src, dst, desc string) error {
+	switch this.kind(typ) {
+	case "string", "bool":
+		v := this.tmp()
+		this.printf("%s, ok := %s.Value().(%s)\n", v, src, typeString(typ))
+		this.printf("if !ok {\nreturn fmt.Errorf(\"%s: expected %s\")\n}\n", desc, typeString(typ))
+		this.printf("%s = %s\n", dst, v)
+	case "number":
+		v := this.tmp()
+		this.printf("%s, ok := %s.Value().(float64)\n", v, src)
+		this.printf("if !ok {\nreturn fmt.Errorf(\"%s: expected number\")\n}\n", desc)
+		this.printf("%s = %s(%s)\n", dst, typeString(typ), v)
+	case "int", "uint":
+		// parse the text of the number, which is exact however large it is
+		this.integers = true
+		parse, bits := "ParseInt", strings.TrimLeft(typeString(typ), "uint")
+		if this.kind(typ) == "uint" {
+			parse = "ParseUint"
+		}
+		if bits == "" {
+			bits = "0"
+		}
+		v, err := this.tmp(), this.tmp()
+		this.printf("%s, %s := strconv.%s(string(%s.Bytes()), 10, %s)\n", v, err, parse, src, bits)
+		this.printf("if %s.Type() != dparval.NUMBER || %s != nil {\nreturn fmt.Errorf(\"%s: expected integer\")\n}\n", src, err, desc)
+		this.printf("%s = %s(%s)\n", dst, typeString(typ), v)
+	case "value":
+		this.printf("%s = %s\n", dst, src)
+	case "struct":
+		this.printf("if err := %s.UnmarshalValue(%s); err != nil {\nreturn err\n}\n", dst, src)
+	case "pointer":
+		elem := typ.(*ast.StarExpr).X
+		v := this.tmp()
+		this.printf("if %s.Type() == dparval.NULL {\n%s = nil\n} else {\n", src, dst)
+		this.printf("%s := new(%s)\n", v, typeString(elem))
+		err := this.unmarshal(elem, src, "(*"+v+")", desc)
+		if err != nil {
+			return err
+		}
+		this.printf("%s = %s\n}\n", dst, v)
+	case "slice":
+		elem := typ.(*ast.ArrayType).Elt
+		items, s, i, item, child := this.tmp(), this.tmp(), this.tmp(), this.tmp(), this.tmp()
+		this.printf("%s, ok := %s.Value().([]interface{})\n", items, src)
+		this.printf("if !ok {\nreturn fmt.Errorf(\"%s: expected array\")\n}\n", desc)
+		this.printf("%s := make(%s, len(%s))\n", s, typeString(typ), items)
+		this.printf("for %s, %s := range %s {\n", i, item, items)
+		this.printf("%s := dparval.NewValue(%s)\n", child, item)
+		err := this.unmarshal(elem, child, s+"["+i+"]", desc)
+		if err != nil {
+			return err
+		}
+		this.printf("}\n%s = %s\n", dst, s)
+	case "map":
+		elem := typ.(*ast.MapType).Value
+		items, m, k, item, child, v := this.tmp(), this.tmp(), this.tmp(), this.tmp(), this.tmp(), this.tmp()
+		this.printf("%s, ok := %s.Value().(map[string]interface{})\n", items, src)
+		this.printf("if !ok {\nreturn fmt.Errorf(\"%s: expected object\")\n}\n", desc)
+		this.printf("%s := make(%s, len(%s))\n", m, typeString(typ), items)
+		this.printf("for %s, %s := range %s {\n", k, item, items)
+		this.printf("%s := dparval.NewValue(%s)\n", child, item)
+		this.printf("var %s %s\n", v, typeString(elem))
+		err := this.unmarshal(elem, child, v, desc)
+		if err != nil {
+			return err
+		}
+		this.printf("%s[%s] = %s\n}\n%s = %s\n", m, k, v, dst, m)
+	default:
+		return fmt.Errorf("unsupported type %s", typeString(typ))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSource = `package example
+
+import "github.com/mschoch/dparval"
+
+type User struct {
+	Name     string            ` + "`json:\"name\"`" + `
+	Age      int               ` + "`dparval:\"age,omitempty\"`" + `
+	ID       int64             ` + "`json:\"id\"`" + `
+	Views    uint64            ` + "`json:\"views,omitempty\"`" + `
+	Address  *Address
+	Tags     []string
+	Scores   map[string]float64
+	Extra    *dparval.Value
+	Ignored  string            ` + "`json:\"-\"`" + `
+	internal string
+}
+
+type Address struct {
+	Street string
+}
+`
+
+func TestGenerate(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "example.go", testSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate("example", []*ast.File{file}, []string{"User", "Address"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	_, err = parser.ParseFile(fset, "generated.go", src, 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s", err, src)
+	}
+	for _, expected := range []string{
+		"func (this User) MarshalValue() *dparval.Value",
+		"func (this *User) UnmarshalValue(val *dparval.Value) error",
+		"func (this Address) MarshalValue() *dparval.Value",
+		`rv["name"] = this.Name`,
+		`if this.Age != 0 {`,
+		`strconv.AppendInt(nil, int64(this.ID), 10)`,
+		`strconv.ParseUint(`,
+		`val.Path("Address")`,
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("Expected generated code to contain %q\n%s", expected, src)
+		}
+	}
+	for _, unexpected := range []string{"Ignored", "internal"} {
+		if strings.Contains(string(src), unexpected) {
+			t.Errorf("Expected generated code not to contain %q", unexpected)
+		}
+	}
+
+	_, err = generate("example", []*ast.File{file}, []string{"Missing"})
+	if err == nil {
+		t.Errorf("Expected error for missing type")
+	}
+}
+
+// roundTripMain marshals the zero User and one with every field set, printing their JSON
+// and the JSON of the Users they unmarshal to, then the error unmarshaling a fraction.
+const roundTripMain = `package main
+
+import (
+	"fmt"
+
+	"github.com/mschoch/dparval"
+)
+
+func roundTrip(u User) {
+	data := u.MarshalValue().Bytes()
+	var back User
+	err := back.UnmarshalValue(dparval.NewValueFromBytes(data))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s %s\n", data, back.MarshalValue().Bytes())
+}
+
+func main() {
+	roundTrip(User{})
+	roundTrip(User{Name: "a", Age: 1, Address: &Address{"s"}, Tags: []string{"t"},
+		Scores: map[string]float64{"x": 2}, Extra: dparval.NewValue(true),
+		ID: 1<<53 + 1, Views: 1<<64 - 1})
+	var u User
+	fmt.Println(u.UnmarshalValue(dparval.NewValueFromBytes([]byte(` + "`" + `{"id":1.5}` + "`" + `))))
+}
+`
+
+func TestGenerateCompiles(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "example.go", testSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate("main", []*ast.File{file}, []string{"User", "Address"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the program is built inside the module, so it imports this version of dparval
+	dir, err := os.MkdirTemp(".", "_roundtrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"example.go":   strings.Replace(testSource, "package example", "package main", 1),
+		"generated.go": string(src),
+		"main.go":      roundTripMain,
+	}
+	for name, contents := range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Generated code does not run: %v\n%s\n%s", err, out, src)
+	}
+	full := `{"Address":{"Street":"s"},"Extra":true,"Scores":{"x":2},"Tags":["t"],"age":1,` +
+		`"id":9007199254740993,"name":"a","views":18446744073709551615}`
+	expected := `{"Address":null,"Extra":null,"Scores":{},"Tags":[],"id":0,"name":""} ` +
+		`{"Address":null,"Extra":null,"Scores":{},"Tags":[],"id":0,"name":""}` + "\n" +
+		full + " " + full + "\n" +
+		"User.id: expected integer\n"
+	if string(out) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
@@ -0,0 +1,60 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strings"
+)
+
+// Return the Value at the first of paths which is defined inside doc, for documents whose shape
+// has changed over time, for example CoalescePath(doc, "email", "contact.email", "emails[0]").
+// Paths are dotted, as for FillTemplate(), and an index may also be written in brackets, so
+// "emails[0]" is the same as "emails.0".  The paths are tried in turn, and those after the
+// first defined one are not looked at.  A path holding null is defined.
+//
+// If none of the paths is defined, the return error is *Undefined.
+func CoalescePath(doc *Value, paths ...string) (*Value, error) {
+	for _, path := range paths {
+		rv, err := resolvePath(doc, bracketsToDots(path))
+		if err == nil {
+			return rv, nil
+		}
+		if _, ok := err.(*Undefined); !ok {
+			return nil, err
+		}
+	}
+	return nil, &Undefined{strings.Join(paths, ", ")}
+}
+
+// bracketsToDots rewrites each [n] step of path as .n
+func bracketsToDots(path string) string {
+	if strings.IndexByte(path, '[') < 0 {
+		return path
+	}
+	var buf strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				buf.WriteString(path[i:])
+				return buf.String()
+			}
+			if i > 0 {
+				buf.WriteByte('.')
+			}
+			buf.WriteString(path[i+1 : i+end])
+			i += end
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,44 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestCoalescePath(t *testing.T) {
+	paths := []string{"email", "contact.email", "emails[0]"}
+	var tests = []struct {
+		doc      string
+		expected interface{}
+	}{
+		{`{"email": "a@x"}`, "a@x"},
+		{`{"contact": {"email": "b@x"}, "emails": ["c@x"]}`, "b@x"},
+		{`{"contact": {}, "emails": ["c@x", "d@x"]}`, "c@x"},
+		{`{"email": null, "emails": ["c@x"]}`, nil},
+	}
+	for _, test := range tests {
+		val, err := CoalescePath(NewValueFromBytes([]byte(test.doc)), paths...)
+		if err != nil || val.Value() != test.expected {
+			t.Errorf("%s: expected %v, got %v, %v", test.doc, test.expected, val, err)
+		}
+	}
+
+	_, err := CoalescePath(NewValueFromBytes([]byte(`{"emails": []}`)), paths...)
+	if _, ok := err.(*Undefined); !ok {
+		t.Errorf("Expected *Undefined, got %v", err)
+	}
+
+	matrix := NewValueFromBytes([]byte(`{"rows": [[1, 2], [3, 4]]}`))
+	val, err := CoalescePath(matrix, "rows[1][0]")
+	if err != nil || val.Value() != 3.0 {
+		t.Errorf("Expected 3, got %v, %v", val, err)
+	}
+}
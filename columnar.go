@@ -0,0 +1,182 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	json "github.com/dustin/gojson"
+)
+
+// A Column holds the values of one top-level property across every document
+// of a ColumnarBatch.  Only the slice matching Type is filled.
+type Column struct {
+	Name    string
+	Type    int // NUMBER, STRING or BOOLEAN
+	Numbers []float64
+	Strings []string
+	Bools   []bool
+}
+
+// Return the value of this column for row i, as it would be returned by Value().
+func (this *Column) Value(i int) interface{} {
+	switch this.Type {
+	case NUMBER:
+		return this.Numbers[i]
+	case STRING:
+		return this.Strings[i]
+	default:
+		return this.Bools[i]
+	}
+}
+
+// A ColumnarBatch stores a batch of documents of type OBJECT with the same shape.
+// The top-level properties which hold a number, string or boolean in every document
+// are shredded into typed Columns, the rest of each document is kept as raw bytes.
+//
+// Scans over the shredded properties read the Columns directly, without parsing
+// or even looking at the documents.
+type ColumnarBatch struct {
+	columns  []*Column
+	byName   map[string]*Column
+	residual [][]byte
+}
+
+// Create a new ColumnarBatch from the specified documents, which must all be of type OBJECT.
+func NewColumnarBatch(docs ValueCollection) (*ColumnarBatch, error) {
+	members := make([]map[string]*Value, len(docs))
+	for i, doc := range docs {
+		if doc.Type() != OBJECT {
+			return nil, &TypeMismatch{Path: fmt.Sprintf("document %d", i), Expected: OBJECT, Actual: doc.Type()}
+		}
+		members[i] = doc.members()
+	}
+
+	rv := ColumnarBatch{
+		byName:   make(map[string]*Column),
+		residual: make([][]byte, len(docs)),
+	}
+	for _, name := range commonScalars(members) {
+		column := &Column{Name: name, Type: members[0][name].Type()}
+		for _, m := range members {
+			switch val := m[name].Value().(type) {
+			case float64:
+				column.Numbers = append(column.Numbers, val)
+			case string:
+				column.Strings = append(column.Strings, val)
+			case bool:
+				column.Bools = append(column.Bools, val)
+			}
+		}
+		rv.columns = append(rv.columns, column)
+		rv.byName[name] = column
+	}
+
+	for i, m := range members {
+		buf := bytes.Buffer{}
+		buf.WriteByte('{')
+		for _, k := range sortedMemberKeys(m) {
+			if _, ok := rv.byName[k]; ok {
+				continue
+			}
+			if buf.Len() > 1 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(k)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(m[k].Bytes())
+		}
+		buf.WriteByte('}')
+		rv.residual[i] = buf.Bytes()
+	}
+	return &rv, nil
+}
+
+// commonScalars returns the names of the properties which are a float64, string or bool,
+// of the same type, in every one of members.
+func commonScalars(members []map[string]*Value) []string {
+	if len(members) == 0 {
+		return nil
+	}
+	var rv []string
+	for _, name := range sortedMemberKeys(members[0]) {
+		first := members[0][name]
+		if !shreddable(first) {
+			continue
+		}
+		common := true
+		for _, m := range members[1:] {
+			val, ok := m[name]
+			if !ok || val.Type() != first.Type() || !shreddable(val) {
+				common = false
+				break
+			}
+		}
+		if common {
+			rv = append(rv, name)
+		}
+	}
+	return rv
+}
+
+// shreddable determines if val can be stored in a Column.  Numbers parsed with
+// EXACT_NUMBERS are not, as a Column would lose their exact text.
+func shreddable(val *Value) bool {
+	switch val.Type() {
+	case STRING, BOOLEAN:
+		return true
+	case NUMBER:
+		_, ok := val.Value().(float64)
+		return ok
+	}
+	return false
+}
+
+func sortedMemberKeys(members map[string]*Value) []string {
+	rv := make([]string, 0, len(members))
+	for k := range members {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// The number of documents in the batch.
+func (this *ColumnarBatch) Len() int {
+	return len(this.residual)
+}
+
+// Return the shredded Columns, sorted by name.
+func (this *ColumnarBatch) Columns() []*Column {
+	return this.columns
+}
+
+// Return the Column for the named property, or nil if that property was not shredded.
+func (this *ColumnarBatch) Column(name string) *Column {
+	return this.byName[name]
+}
+
+// Return document i of the batch.  Each call returns a new Value, built from the
+// raw bytes of the rest of the document with the shredded properties set on it.
+func (this *ColumnarBatch) Row(i int) *Value {
+	rv := NewValueFromBytes(this.residual[i])
+	for _, column := range this.columns {
+		if err := rv.SetPathErr(column.Name, column.Value(i)); err != nil {
+			panic("unexpected error setting a property of a new object")
+		}
+	}
+	return rv
+}
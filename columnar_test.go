@@ -0,0 +1,56 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnarBatch(t *testing.T) {
+	docs := ValueCollection{
+		NewValueFromBytes([]byte(`{"name":"a","age":30,"active":true,"tags":["x"],"extra":1}`)),
+		NewValueFromBytes([]byte(`{"name":"b","age":40,"active":false,"tags":[]}`)),
+		NewValue(map[string]interface{}{"name": "c", "age": 50.0, "active": true, "tags": nil, "extra": "one"}),
+	}
+	batch, err := NewColumnarBatch(docs)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if batch.Len() != 3 {
+		t.Errorf("Expected 3 rows, got %d", batch.Len())
+	}
+
+	var names []string
+	for _, column := range batch.Columns() {
+		names = append(names, column.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"active", "age", "name"}) {
+		t.Errorf("Unexpected columns %v", names)
+	}
+	age := batch.Column("age")
+	if age.Type != NUMBER || !reflect.DeepEqual(age.Numbers, []float64{30, 40, 50}) {
+		t.Errorf("Unexpected age column %#v", age)
+	}
+	if batch.Column("extra") != nil || batch.Column("tags") != nil {
+		t.Errorf("Expected extra and tags not to be shredded")
+	}
+
+	for i, doc := range docs {
+		if !reflect.DeepEqual(batch.Row(i).Value(), doc.Value()) {
+			t.Errorf("Expected row %d to be %v, got %v", i, doc.Value(), batch.Row(i).Value())
+		}
+	}
+
+	_, err = NewColumnarBatch(ValueCollection{NewValue(1.0)})
+	if err == nil {
+		t.Errorf("Expected error for a document which is not an object")
+	}
+}
@@ -0,0 +1,206 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"math"
+	"sort"
+
+	json "github.com/dustin/gojson"
+)
+
+// Determine if this Value is equal to another Value.  Numbers are compared by their
+// numeric value, objects and arrays are compared deeply.
+func (this *Value) Equals(other *Value) bool {
+	return this.Compare(other) == 0
+}
+
+// Compare this Value to another Value, returning -1, 0 or 1 if this Value sorts before,
+// equal to, or after the other Value.
+//
+// Values of different types are ordered by type:
+//
+//         NOT_JSON < NULL < BOOLEAN < NUMBER < STRING < ARRAY < OBJECT
+//
+// Values of the same type are ordered as follows:
+//
+//         1. false sorts before true.
+//         2. Numbers are ordered numerically.
+//         3. Strings are ordered byte-wise.
+//         4. Arrays are ordered element by element, a shorter array sorts before a longer array it is a prefix of.
+//         5. Objects with fewer keys sort first, then objects are ordered by their sorted keys, then by the values of those keys.
+//         6. NOT_JSON values are ordered by their raw bytes.
+func (this *Value) Compare(other *Value) int {
+	return this.CompareWithOptions(other, CompareOptions{})
+}
+
+// CompareOptions control how Values are compared by CompareWithOptions() and EqualsWithOptions().
+type CompareOptions struct {
+	// Numbers a and b (including those nested in objects and arrays) are considered
+	// equal when |a - b| <= Epsilon * max(1, |a|, |b|).  This allows numbers which have
+	// lost precision through float formatting to compare as equal.  0 means numbers must
+	// be exactly equal.
+	Epsilon float64
+
+	// Strings (but not the keys of objects) are compared after folding them with FoldString(),
+	// ignoring differences in case and in diacritics respectively.
+	FoldCase       bool
+	FoldDiacritics bool
+}
+
+// Compare this Value to another Value as Compare() does, honoring the specified options.
+func (this *Value) CompareWithOptions(other *Value, options CompareOptions) int {
+	if this.parsedType != other.parsedType {
+		return compareInts(this.parsedType, other.parsedType)
+	}
+	if this.parsedType == NOT_JSON {
+		return bytes.Compare(this.raw, other.raw)
+	}
+	return compareNative(this.Value(), other.Value(), options)
+}
+
+// Determine if this Value is equal to another Value as Equals() does, honoring the specified options.
+func (this *Value) EqualsWithOptions(other *Value, options CompareOptions) bool {
+	return this.CompareWithOptions(other, options) == 0
+}
+
+func compareNative(a, b interface{}, options CompareOptions) int {
+	ta, tb := nativeType(a), nativeType(b)
+	if ta != tb {
+		return compareInts(ta, tb)
+	}
+	switch a := a.(type) {
+	case bool:
+		b := b.(bool)
+		if a == b {
+			return 0
+		} else if !a {
+			return -1
+		}
+		return 1
+	case string:
+		a, b := FoldString(a, options), FoldString(b.(string), options)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if cmp := compareNative(a[i], b[i], options); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareInts(len(a), len(b))
+	case map[string]interface{}:
+		b := b.(map[string]interface{})
+		if len(a) != len(b) {
+			return compareInts(len(a), len(b))
+		}
+		akeys, bkeys := sortedKeys(a), sortedKeys(b)
+		for i := range akeys {
+			if akeys[i] != bkeys[i] {
+				if akeys[i] < bkeys[i] {
+					return -1
+				}
+				return 1
+			}
+		}
+		for _, k := range akeys {
+			if cmp := compareNative(a[k], b[k], options); cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	case nil:
+		return 0
+	default:
+		// numbers
+		af, bf := nativeNumber(a), nativeNumber(b)
+		if epsilon := options.Epsilon; epsilon > 0 && math.Abs(af-bf) <= epsilon*math.Max(1, math.Max(math.Abs(af), math.Abs(bf))) {
+			return 0
+		}
+		if af < bf {
+			return -1
+		} else if af > bf {
+			return 1
+		}
+		return 0
+	}
+}
+
+// nativeType identifies the type of a native Go representation of JSON.
+func nativeType(val interface{}) int {
+	switch val.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return BOOLEAN
+	case float64, json.Number:
+		return NUMBER
+	case string:
+		return STRING
+	case []interface{}:
+		return ARRAY
+	case map[string]interface{}:
+		return OBJECT
+	}
+	if _, ok := val.(stdjson.Number); ok {
+		return NUMBER
+	}
+	return NOT_JSON
+}
+
+// nativeNumber returns the numeric value of a float64 or json.Number.
+func nativeNumber(val interface{}) float64 {
+	switch val := val.(type) {
+	case float64:
+		return val
+	case json.Number:
+		f, _ := val.Float64()
+		return f
+	}
+	if n, ok := val.(stdjson.Number); ok {
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	// each value sorts strictly before the next
+	var ordered = []string{
+		`abc`,
+		`null`,
+		`false`,
+		`true`,
+		`-1`,
+		`2.5`,
+		`""`,
+		`"a"`,
+		`"b"`,
+		`[]`,
+		`[1]`,
+		`[1,2]`,
+		`[2]`,
+		`{}`,
+		`{"a":2}`,
+		`{"b":1}`,
+		`{"a":1,"b":1}`,
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		a := NewValueFromBytes([]byte(ordered[i]))
+		b := NewValueFromBytes([]byte(ordered[i+1]))
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Errorf("Expected %s to sort before %s", ordered[i], ordered[i+1])
+		}
+		if !a.Equals(a) {
+			t.Errorf("Expected %s to equal itself", ordered[i])
+		}
+	}
+
+	a := NewValueFromBytes([]byte(`{"x":[1,{"y":2.0}]}`))
+	b := NewValue(map[string]interface{}{"x": []interface{}{1.0, map[string]interface{}{"y": 2.0}}})
+	if !a.Equals(b) {
+		t.Errorf("Expected %s to equal %s", a.Bytes(), b.Bytes())
+	}
+}
+
+func TestCompareWithOptions(t *testing.T) {
+	a := NewValueFromBytes([]byte(`{"price":0.30000000000000004,"qty":[1e21]}`))
+	b := NewValueFromBytes([]byte(`{"price":0.3,"qty":[1.0000000000000001e21]}`))
+	if a.Equals(b) {
+		t.Errorf("Expected %s not to equal %s exactly", a.Bytes(), b.Bytes())
+	}
+	options := CompareOptions{Epsilon: 1e-9}
+	if !a.EqualsWithOptions(b, options) {
+		t.Errorf("Expected %s to equal %s with epsilon", a.Bytes(), b.Bytes())
+	}
+
+	c := NewValue(0.31)
+	if NewValue(0.3).CompareWithOptions(c, options) != -1 {
+		t.Errorf("Expected 0.3 to sort before 0.31 with epsilon")
+	}
+	if !NewValue(0.0).EqualsWithOptions(NewValue(1e-12), options) {
+		t.Errorf("Expected 0 to equal 1e-12 with epsilon")
+	}
+}
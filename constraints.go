@@ -0,0 +1,148 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Constraint is an expression which must not be false for a document, see AddConstraint().
+type Constraint struct {
+	Path string      // the dotted path whose changes are checked against the constraint
+	Expr *Expression // evaluated against the whole document
+}
+
+// When a constraint evaluates to false, the return error (or the value SetPath()
+// panics with) will be *ConstraintViolation.
+type ConstraintViolation struct {
+	Path       string
+	Constraint string
+	Err        error // set if the expression could not be evaluated
+}
+
+// Description of the constraint which was violated.
+func (this *ConstraintViolation) Error() string {
+	if this.Err != nil {
+		return fmt.Sprintf("constraint %s on %s could not be evaluated: %v", this.Constraint, this.Path, this.Err)
+	}
+	return fmt.Sprintf("constraint %s on %s is violated", this.Constraint, this.Path)
+}
+
+// Unwrap returns the error evaluating the constraint, if any.
+func (this *ConstraintViolation) Unwrap() error {
+	return this.Err
+}
+
+// Register a constraint expression (see ParseExpression()) for the dotted path inside this
+// Value, for example AddConstraint("price", "price >= 0") or AddConstraint("tags",
+// "LENGTH(tags) <= 10"), using the functions registered with RegisterFunction().  The
+// expression is evaluated against this whole Value, and as for CHECK constraints in SQL it
+// is violated only if it is false: a constraint on a missing or null property is satisfied.
+//
+// A change to this Value which sets the first step of path is checked against the constraint,
+// and if it would be violated the change is not made.  SetPath() panics with the
+// *ConstraintViolation, while SetPathErr(), SetDeepPath(), UpdateWhere() and Editor.Commit()
+// return it as their error.  Changes made directly to Values inside this one (for example with
+// SetPath() on a Value returned by Path()) are only checked by CheckConstraints().
+//
+// If the expression is malformed, the return error is *ExpressionError.
+func (this *Value) AddConstraint(path, expr string) error {
+	e, err := ParseExpression(expr)
+	if err != nil {
+		return err
+	}
+	this.checkMutable()
+	this.constraints = append(this.constraints, &Constraint{path, e})
+	return nil
+}
+
+// Return the constraints registered for this Value, in the order they were added.
+func (this *Value) Constraints() []*Constraint {
+	return this.constraints
+}
+
+// Evaluate every constraint registered for this Value, returning all those which are violated.
+func (this *Value) CheckConstraints() []*ConstraintViolation {
+	var rv []*ConstraintViolation
+	for _, constraint := range this.Constraints() {
+		if violation := constraint.check(this); violation != nil {
+			rv = append(rv, violation)
+		}
+	}
+	return rv
+}
+
+func (this *Constraint) check(doc *Value) *ConstraintViolation {
+	result, err := this.Expr.Eval(doc)
+	if err != nil {
+		if _, ok := err.(*Undefined); ok {
+			return nil
+		}
+		return &ConstraintViolation{this.Path, this.Expr.String(), err}
+	}
+	if result.Type() == BOOLEAN && !isTrue(result) {
+		return &ConstraintViolation{this.Path, this.Expr.String(), nil}
+	}
+	return nil
+}
+
+// checkConstraints panics if setting key to val would violate a constraint on this Value.
+func (this *Value) checkConstraints(key string, val interface{}) {
+	if violation := this.validateConstraints(key, val); violation != nil {
+		panic(violation)
+	}
+}
+
+// validateContents returns the first violation of a constraint on this Value if its
+// contents were replaced with other.
+func (this *Value) validateContents(other *Value) error {
+	for _, constraint := range this.Constraints() {
+		if violation := constraint.check(other); violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
+
+// validateConstraints returns the violation if setting key to val would violate a constraint
+// on this Value.  The constraints are evaluated against a copy of this Value with the change made.
+func (this *Value) validateConstraints(key string, val interface{}) error {
+	constraints := this.Constraints()
+	if constraints == nil {
+		return nil
+	}
+	var candidate *Value
+	for _, constraint := range constraints {
+		if constraint.Path != key && !strings.HasPrefix(constraint.Path, key+".") {
+			continue
+		}
+		if candidate == nil {
+			candidate = &Value{
+				raw:         this.raw,
+				parsedValue: this.parsedValue,
+				parsedType:  this.parsedType,
+				options:     this.options,
+				alias:       make(map[string]*Value, len(this.alias)+1),
+			}
+			for k, v := range this.alias {
+				candidate.alias[k] = v
+			}
+			for k, v := range this.children {
+				candidate.alias[k] = v
+			}
+			candidate.alias[key] = NewValue(val)
+		}
+		if violation := constraint.check(candidate); violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name": "widget", "price": 1, "tags": ["a", "b"], "stock": {"count": 3}}`))
+	err := doc.AddConstraint("price", "price >= 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doc.AddConstraint("tags", "LENGTH(tags) <= 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Constraints()) != 2 {
+		t.Errorf("Expected 2 constraints, got %d", len(doc.Constraints()))
+	}
+	if violations := doc.CheckConstraints(); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+
+	// allowed changes are made
+	doc.SetPath("price", 5.0)
+	if price, _ := doc.Path("price"); price.Value() != 5.0 {
+		t.Errorf("Expected price 5, got %v", price.Value())
+	}
+	// changes to other properties are not checked against the constraint
+	doc.SetPath("name", "gadget")
+
+	// violating changes panic and are not made
+	r := expectPanic(func() { doc.SetPath("price", -1.0) })
+	violation, ok := r.(*ConstraintViolation)
+	if !ok {
+		t.Fatalf("Expected *ConstraintViolation, got %v", r)
+	}
+	if violation.Path != "price" || violation.Constraint != "price >= 0" {
+		t.Errorf("Unexpected violation %v", violation)
+	}
+	if price, _ := doc.Path("price"); price.Value() != 5.0 {
+		t.Errorf("Expected price to remain 5, got %v", price.Value())
+	}
+	r = expectPanic(func() { doc.SetPath("tags", []interface{}{"a", "b", "c"}) })
+	if _, ok := r.(*ConstraintViolation); !ok {
+		t.Errorf("Expected *ConstraintViolation, got %v", r)
+	}
+
+	// changes made through other methods return the violation rather than panicking
+	err = doc.SetDeepPath("price", -1.0)
+	if _, ok := err.(*ConstraintViolation); !ok {
+		t.Errorf("Expected *ConstraintViolation from SetDeepPath, got %v", err)
+	}
+	editor, _ := doc.Edit("")
+	editor.Set("price", -2.0)
+	if _, ok := editor.Commit().(*ConstraintViolation); !ok {
+		t.Errorf("Expected *ConstraintViolation from Commit")
+	}
+	editor, _ = doc.Edit("tags")
+	editor.Append("", "c")
+	if _, ok := editor.Commit().(*ConstraintViolation); !ok {
+		t.Errorf("Expected *ConstraintViolation from Commit")
+	}
+	expected := `{"name":"gadget","price":5,"stock":{"count":3},"tags":["a","b"]}`
+	if string(doc.Bytes()) != expected {
+		t.Errorf("Expected violating changes not to be made, got %s", doc.Bytes())
+	}
+
+	// constraints on missing or null properties are satisfied
+	doc.SetPath("price", nil)
+	if violations := doc.CheckConstraints(); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+
+	// changes inside a child are only found on demand, and every violation is returned
+	doc.AddConstraint("stock.count", "stock.count >= 0")
+	doc.AddConstraint("name", "name = 'widget'")
+	stock, _ := doc.Path("stock")
+	stock.SetPath("count", -1.0)
+	violations := doc.CheckConstraints()
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %v", violations)
+	}
+	if violations[0].Path != "stock.count" || violations[1].Path != "name" {
+		t.Errorf("Unexpected violations %v", violations)
+	}
+	if ErrorCategory(violations[0]) != OUT_OF_RANGE {
+		t.Errorf("Expected OUT_OF_RANGE, got %d", ErrorCategory(violations[0]))
+	}
+}
+
+func TestConstraintParseError(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"price": 1}`))
+	err := doc.AddConstraint("price", "price >=")
+	if _, ok := err.(*ExpressionError); !ok {
+		t.Errorf("Expected *ExpressionError, got %v", err)
+	}
+	if len(doc.Constraints()) != 0 {
+		t.Errorf("Expected no constraints, got %d", len(doc.Constraints()))
+	}
+}
+
+func TestConstraintUpdateWhere(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"items":[{"sku":"X","qty":1},{"sku":"Y","qty":2}]}`))
+	doc.AddConstraint("items", "items[0].qty > 0")
+	count, err := UpdateWhere(doc, "items", `sku = "Y"`, map[string]interface{}{"qty": 0.0})
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 update, got %d, %v", count, err)
+	}
+	count, err = UpdateWhere(doc, "items", `qty >= 0`, map[string]interface{}{"qty": 0.0})
+	if _, ok := err.(*ConstraintViolation); !ok || count != 0 {
+		t.Errorf("Expected *ConstraintViolation, got %d, %v", count, err)
+	}
+	if string(doc.Bytes()) != `{"items":[{"qty":1,"sku":"X"},{"qty":0,"sku":"Y"}]}` {
+		t.Errorf("Expected violating updates not to be made, got %s", doc.Bytes())
+	}
+}
+
+func TestConstraintsNotAttached(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"price": 1}`))
+	doc.SetAttachment("constraints", "mine")
+	doc.AddConstraint("price", "price >= 0")
+	if doc.GetAttachment("constraints") != "mine" || len(doc.Constraints()) != 1 {
+		t.Errorf("Expected constraints and attachments to be independent")
+	}
+}
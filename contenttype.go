@@ -0,0 +1,146 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+
+	json "github.com/dustin/gojson"
+)
+
+// A function which creates a Value from bytes in some format.
+type DecodeFunc func([]byte) (*Value, error)
+
+// A function which serializes a Value in some format.
+type EncodeFunc func(*Value) ([]byte, error)
+
+// When you try to decode or encode using a content type which has not been registered,
+// the return error will be *UnsupportedContentType.
+type UnsupportedContentType struct {
+	ContentType string
+}
+
+// Description of the content type which is not supported.
+func (this *UnsupportedContentType) Error() string {
+	return fmt.Sprintf("unsupported content type %s", this.ContentType)
+}
+
+type codec struct {
+	decode DecodeFunc
+	encode EncodeFunc
+}
+
+var contentTypesMutex sync.RWMutex
+var contentTypes = map[string]codec{
+	"application/json":     {decodeJSON, encodeJSON},
+	"application/cbor":     {decodeCBOR, encodeCBOR},
+	"application/x-ndjson": {decodeNDJSON, encodeNDJSON},
+}
+
+// Register the functions used to decode and encode the specified content type (MIME type).
+// Any existing registration for the content type is replaced.  Either function may be nil
+// if that direction is not supported.
+func RegisterContentType(contentType string, decode DecodeFunc, encode EncodeFunc) {
+	contentTypesMutex.Lock()
+	defer contentTypesMutex.Unlock()
+	contentTypes[normalizeContentType(contentType)] = codec{decode, encode}
+}
+
+// Create a new Value from bytes of the specified content type.  Parameters in the content type
+// (such as charset) are ignored.
+//
+// The types application/json, application/cbor and application/x-ndjson are registered by
+// default.  NDJSON input is returned as a Value of type ARRAY containing each document.  CBOR
+// is mapped to JSON as described in RFC 8949 section 6.1.
+func DecodeAs(contentType string, b []byte) (*Value, error) {
+	c := lookupContentType(contentType)
+	if c.decode == nil {
+		return nil, &UnsupportedContentType{contentType}
+	}
+	return c.decode(b)
+}
+
+// Serialize the Value in the specified content type.
+func EncodeAs(contentType string, val *Value) ([]byte, error) {
+	c := lookupContentType(contentType)
+	if c.encode == nil {
+		return nil, &UnsupportedContentType{contentType}
+	}
+	return c.encode(val)
+}
+
+func lookupContentType(contentType string) codec {
+	contentTypesMutex.RLock()
+	defer contentTypesMutex.RUnlock()
+	return contentTypes[normalizeContentType(contentType)]
+}
+
+func normalizeContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+func decodeJSON(b []byte) (*Value, error) {
+	rv := NewValueFromBytes(b)
+	if rv.Type() == NOT_JSON {
+		return nil, newSyntaxError(b, "", json.Validate(b))
+	}
+	return rv, nil
+}
+
+func encodeJSON(val *Value) ([]byte, error) {
+	return val.Bytes(), nil
+}
+
+func decodeNDJSON(b []byte) (*Value, error) {
+	docs, err := SplitDocuments(b)
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		rv[i] = NewValueFromBytes(doc)
+	}
+	return NewValue(rv), nil
+}
+
+func encodeNDJSON(val *Value) ([]byte, error) {
+	docs := ValueCollection{val}
+	if val.Type() == ARRAY {
+		var err error
+		docs, err = val.elements()
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf := bytes.Buffer{}
+	for _, doc := range docs {
+		out := doc.Bytes()
+		if bytes.IndexByte(out, '\n') >= 0 {
+			// each document must be on a single line
+			compact := bytes.Buffer{}
+			err := json.Compact(&compact, out)
+			if err != nil {
+				return nil, err
+			}
+			out = compact.Bytes()
+		}
+		buf.Write(out)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
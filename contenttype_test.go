@@ -0,0 +1,81 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAs(t *testing.T) {
+	val, err := DecodeAs("application/json; charset=utf-8", []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(val.Value(), map[string]interface{}{"a": 1.0}) {
+		t.Errorf("Expected {a:1}, got %v", val.Value())
+	}
+
+	_, err = DecodeAs("application/json", []byte(`{"a":`))
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("Expected *SyntaxError, got %#v", err)
+	}
+
+	val, err = DecodeAs("application/x-ndjson", []byte("{\"a\":1}\n{\"a\":2}\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := []interface{}{map[string]interface{}{"a": 1.0}, map[string]interface{}{"a": 2.0}}
+	if !reflect.DeepEqual(val.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Value())
+	}
+
+	val, err = DecodeAs("application/cbor", []byte{0xa1, 0x61, 'a', 0x01})
+	if err != nil || !reflect.DeepEqual(val.Value(), map[string]interface{}{"a": 1.0}) {
+		t.Errorf("Expected {a:1}, got %v, %v", val, err)
+	}
+
+	_, err = DecodeAs("application/x-msgpack", []byte{})
+	if !reflect.DeepEqual(err, &UnsupportedContentType{"application/x-msgpack"}) {
+		t.Errorf("Expected *UnsupportedContentType, got %#v", err)
+	}
+}
+
+func TestEncodeAs(t *testing.T) {
+	val := NewValue([]interface{}{
+		NewValueFromBytes([]byte("{\n  \"a\": 1\n}")),
+		map[string]interface{}{"a": 2.0},
+	})
+	out, err := EncodeAs("application/x-ndjson", val)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if string(out) != "{\"a\":1}\n{\"a\":2}\n" {
+		t.Errorf("Unexpected output %q", string(out))
+	}
+}
+
+func TestRegisterContentType(t *testing.T) {
+	RegisterContentType("Text/Plain", func(b []byte) (*Value, error) {
+		return NewValue(string(b)), nil
+	}, func(val *Value) ([]byte, error) {
+		return []byte(strings.ToUpper(val.Value().(string))), nil
+	})
+
+	val, err := DecodeAs("text/plain", []byte("hello"))
+	if err != nil || val.Value() != "hello" {
+		t.Errorf("Expected hello, got %v (%v)", val, err)
+	}
+	out, err := EncodeAs("text/plain", val)
+	if err != nil || string(out) != "HELLO" {
+		t.Errorf("Expected HELLO, got %s (%v)", string(out), err)
+	}
+}
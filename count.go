@@ -0,0 +1,69 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+// Count the elements of the array at the requested path inside this Value.  If the array
+// has not been parsed, its elements are counted by scanning the raw bytes, without creating
+// Values for them.  This makes it cheap to return a total count alongside a page of elements.
+//
+// If the path does not exist, the return error is *Undefined.  If the value at the path
+// is not of type ARRAY, the return error is *TypeMismatch.
+func (this *Value) CountPath(path string) (int, error) {
+	val, err := this.Path(path)
+	if err != nil {
+		return 0, err
+	}
+	return val.count(path)
+}
+
+func (this *Value) count(path string) (int, error) {
+	if this.parsedType != ARRAY {
+		return 0, &TypeMismatch{Path: path, Expected: ARRAY, Actual: this.Type()}
+	}
+	switch parsedValue := this.parsedValue.(type) {
+	case []*Value:
+		return len(parsedValue), nil
+	case []interface{}:
+		return len(parsedValue), nil
+	}
+	if this.rawCount > 0 {
+		return this.rawCount - 1, nil
+	}
+	n, err := countElements(this.raw)
+	if err == nil {
+		// raw is never modified, so writes with SetIndex() need not scan it again
+		this.rawCount = n + 1
+	}
+	return n, err
+}
+
+// Return the number of elements of this ARRAY, or the number of properties of this OBJECT
+// (as returned by Fields()).  If this Value has not been parsed, they are counted by scanning
+// the raw bytes, without creating Values for them, so checking for an empty list is cheap.
+//
+// If this Value is of any other type, the return error is *TypeMismatch.
+func (this *Value) Len() (int, error) {
+	if this.parsedType != OBJECT {
+		return this.count("")
+	}
+	if this.parsedValue == nil && this.raw != nil && len(this.alias) == 0 {
+		keys, _, err := objectMembers(this.raw)
+		if err != nil {
+			return 0, err
+		}
+		// as for Value(), the last of duplicate keys wins
+		distinct := make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			distinct[k] = struct{}{}
+		}
+		return len(distinct), nil
+	}
+	return len(this.members()), nil
+}
@@ -0,0 +1,90 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestCountPath(t *testing.T) {
+	val := NewValueFromBytes([]byte(`{"items":[1,"a,b",{"c":[1,2]},[3]],"empty":[ ],"name":"x"}`))
+
+	var tests = []struct {
+		path  string
+		count int
+	}{
+		{"items", 4},
+		{"empty", 0},
+	}
+
+	for _, test := range tests {
+		count, err := val.CountPath(test.path)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.path)
+		}
+		if count != test.count {
+			t.Errorf("Expected %d, got %d for %s", test.count, count, test.path)
+		}
+	}
+
+	if _, err := val.CountPath("name"); err == nil {
+		t.Errorf("Expected error counting a string")
+	}
+	if _, err := val.CountPath("missing"); err == nil {
+		t.Errorf("Expected error counting a missing path")
+	}
+
+	parsed := NewValue(map[string]interface{}{"items": []interface{}{1.0, 2.0}})
+	count, err := parsed.CountPath("items")
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2, got %d, %v", count, err)
+	}
+}
+
+func TestLen(t *testing.T) {
+	raw := []byte(`{"items":[1,"a,b",{"c":[1,2]},[3]],"empty":[ ],"obj":{"a":1,"b":{"x":2},"a":3},"none":{}}`)
+
+	var tests = []struct {
+		path string
+		len  int
+	}{
+		{"items", 4},
+		{"empty", 0},
+		{"obj", 2},
+		{"none", 0},
+	}
+
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		for _, test := range tests {
+			val, _ := doc.Path(test.path)
+			n, err := val.Len()
+			if err != nil || n != test.len {
+				t.Errorf("Expected %d, got %d, %v for %s", test.len, n, err, test.path)
+			}
+		}
+		n, err := doc.Len()
+		if err != nil || n != 4 {
+			t.Errorf("Expected 4, got %d, %v for the document", n, err)
+		}
+	}
+
+	// properties set on the object are counted
+	doc := NewValueFromBytes(raw)
+	doc.SetPath("added", true)
+	doc.SetPath("items", nil)
+	n, err := doc.Len()
+	if err != nil || n != 5 || n != len(doc.Fields()) {
+		t.Errorf("Expected 5, got %d, %v", n, err)
+	}
+
+	if _, err := NewValue("x").Len(); err == nil {
+		t.Errorf("Expected error for the length of a string")
+	}
+}
@@ -0,0 +1,156 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// When a Value contains itself, directly or through one of its children
+// (for example after SetPath("self", val)), the return error is *CycleError.
+type CycleError struct {
+	Path string // JSON Pointer to the child which refers back to a containing Value
+}
+
+// Description of where the cycle was found.
+func (this *CycleError) Error() string {
+	return fmt.Sprintf("cycle detected: %s refers to a Value containing it", this.Path)
+}
+
+// When serializing a Value would produce more bytes than allowed, the return
+// error is *OutputSizeError.
+type OutputSizeError struct {
+	Limit int
+}
+
+// Description of the limit which was exceeded.
+func (this *OutputSizeError) Error() string {
+	return fmt.Sprintf("serialized value exceeds %d bytes", this.Limit)
+}
+
+// CheckCycles determines if this Value contains itself, which would cause
+// Value() and Bytes() to recurse forever.  If it does, the return error is *CycleError.
+func (this *Value) CheckCycles() error {
+	return this.checkCycles("", make(map[*Value]int))
+}
+
+// states of a Value during checkCycles
+const (
+	cycleVisiting = iota + 1
+	cycleChecked
+)
+
+func (this *Value) checkCycles(path string, state map[*Value]int) error {
+	switch state[this] {
+	case cycleVisiting:
+		return &CycleError{Path: path}
+	case cycleChecked:
+		// shared with another part of the document, already known to be acyclic
+		return nil
+	}
+	state[this] = cycleVisiting
+	for k, v := range this.containedValues() {
+		err := v.checkCycles(path+"/"+escapePointer(k), state)
+		if err != nil {
+			return err
+		}
+	}
+	state[this] = cycleChecked
+	return nil
+}
+
+// containedValues returns the Values held directly by this Value, keyed by
+// property name or index.
+func (this *Value) containedValues() map[string]*Value {
+	rv := make(map[string]*Value, len(this.alias)+len(this.children))
+	switch parsedValue := this.parsedValue.(type) {
+	case map[string]*Value:
+		for k, v := range parsedValue {
+			rv[k] = v
+		}
+	case []*Value:
+		for i, v := range parsedValue {
+			rv[strconv.Itoa(i)] = v
+		}
+	}
+	for k, v := range this.children {
+		rv[k] = v
+	}
+	for k, v := range this.alias {
+		rv[k] = v
+	}
+	return rv
+}
+
+// BytesLimit is like Bytes(), but returns *CycleError instead of recursing
+// forever if this Value contains itself, and *OutputSizeError instead of
+// serializing if the result would be larger than limit bytes.
+// A limit of 0 means there is no limit on the size.
+func (this *Value) BytesLimit(limit int) ([]byte, error) {
+	err := this.CheckCycles()
+	if err != nil {
+		return nil, err
+	}
+	// the same Value may appear many times in the output, so check a lower
+	// bound of the size before doing the work of serializing
+	if limit > 0 && this.minSize(limit) > limit {
+		return nil, &OutputSizeError{Limit: limit}
+	}
+	rv := this.Bytes()
+	if limit > 0 && len(rv) > limit {
+		return nil, &OutputSizeError{Limit: limit}
+	}
+	return rv, nil
+}
+
+// minSize returns a lower bound for the length of Bytes(), giving up as soon as
+// it is known to be more than limit.
+func (this *Value) minSize(limit int) int {
+	if this.raw != nil && !this.modified() {
+		return len(this.raw)
+	}
+	rv := 0
+	switch parsedValue := this.parsedValue.(type) {
+	case map[string]*Value:
+		rv = 2
+		for k, v := range parsedValue {
+			rv += len(k) + 3 + v.minSize(limit-rv)
+			if rv > limit {
+				return rv
+			}
+		}
+		return rv
+	case []*Value:
+		rv = 2
+		for _, v := range parsedValue {
+			rv += 1 + v.minSize(limit-rv)
+			if rv > limit {
+				return rv
+			}
+		}
+		return rv
+	case string:
+		return len(parsedValue) + 2
+	}
+	switch this.parsedType {
+	case ARRAY, OBJECT:
+		// only the overlaid Values are known without parsing
+		rv = 2
+		for _, v := range this.overlay() {
+			rv += v.minSize(limit - rv)
+			if rv > limit {
+				return rv
+			}
+		}
+		return rv
+	}
+	return 1
+}
@@ -0,0 +1,67 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestCheckCycles(t *testing.T) {
+	parent := NewValue(map[string]interface{}{"a": 1.0})
+	child := NewValue([]interface{}{"x"})
+	parent.SetPath("b", child)
+	if err := parent.CheckCycles(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	child.SetIndex(0, parent)
+	err := parent.CheckCycles()
+	if err, ok := err.(*CycleError); !ok || err.Path != "/b/0" {
+		t.Errorf("Expected *CycleError at /b/0, got %v", err)
+	}
+	if _, err := parent.BytesLimit(0); err == nil {
+		t.Errorf("Expected BytesLimit to report the cycle")
+	}
+
+	raw := NewValueFromBytes([]byte(`{"a":{"b":1}}`))
+	a, _ := raw.Path("a")
+	a.SetPath("up", raw)
+	if _, ok := raw.CheckCycles().(*CycleError); !ok {
+		t.Errorf("Expected *CycleError through a raw child")
+	}
+
+	// sharing a Value is not a cycle
+	shared := NewValue("s")
+	val := NewValue([]interface{}{shared, shared})
+	if err := val.CheckCycles(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestBytesLimit(t *testing.T) {
+	val := NewValueFromBytes([]byte(`{"a":[1,2,3]}`))
+	out, err := val.BytesLimit(0)
+	if err != nil || string(out) != `{"a":[1,2,3]}` {
+		t.Errorf("Unexpected result %s %v", out, err)
+	}
+	if _, err := val.BytesLimit(5); err == nil {
+		t.Errorf("Expected *OutputSizeError")
+	}
+
+	// each level doubles the output, without ever being serialized
+	doc := NewValue("0123456789")
+	for i := 0; i < 64; i++ {
+		doc = NewValue([]interface{}{doc, doc})
+	}
+	_, err = doc.BytesLimit(1 << 20)
+	if err, ok := err.(*OutputSizeError); !ok || err.Limit != 1<<20 {
+		t.Errorf("Expected *OutputSizeError, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+// the number of Values a Decoder allocates at once
+const decoderBlockSize = 128
+
+// A Decoder creates Values from bytes as NewValueFromBytes() does, but reuses its state
+// from one document to the next: Values are allocated in blocks, and the validator keeps
+// its stack of open objects and arrays.  A worker decoding millions of documents should
+// hold its own Decoder, as a Decoder is not safe for concurrent use.
+//
+// The Values of a block are only freed once none of them is reachable, so a Decoder is best
+// suited to documents which are processed and dropped, rather than kept for a long time.
+type Decoder struct {
+	values    []Value
+	validator validator
+}
+
+// Create a new Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Create a new Value object from a slice of bytes (this need not be valid JSON).
+func (this *Decoder) Decode(bytes []byte) *Value {
+	if len(this.values) == 0 {
+		this.values = make([]Value, decoderBlockSize)
+	}
+	rv := &this.values[0]
+	this.values = this.values[1:]
+	rv.raw = bytes
+	if literalType, ok := matchLiteral(bytes); ok {
+		rv.parsedType = literalType
+	} else if _, msg := this.validator.validate(bytes, false); msg == "" {
+		rv.parsedType = identifyType(bytes)
+	} else {
+		rv.parsedType = NOT_JSON
+	}
+	return rv
+}
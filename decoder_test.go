@@ -0,0 +1,68 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	inputs := []string{
+		`{}`, `[]`, ` { } `, `[ ]`, `{"a":1}`, `{"a":[1,2,{"b":null}],"c":"d"}`,
+		`[true, false, null, -1.5e3, "x\"\\\/\b\f\n\r\té"]`, `"abc"`, `12`, `[[[[]]]]`,
+		`{"a" : { "b" : [ 1 , 2 ] } }`, `[{},{}]`,
+		`{`, `}`, `[`, `[1,]`, `{"a":1,}`, `{"a"}`, `{"a":}`, `{a:1}`, `[1 2]`, `[1,,2]`,
+		`"abc`, `"\x"`, `"\u12"`, "\"a\tb\"", `[}`, `{]`, `[1]]`, `{}{}`, `[truee]`, `[01]`,
+		`["a"1]`, `{"a":1"b":2}`, `{"a":1,"b"}`, ``, ` `, `[-]`, `{"a":[}`, `{"a":{"b":1]}`,
+	}
+	decoder := NewDecoder()
+	for _, input := range inputs {
+		expected := NewValueFromBytes([]byte(input)).Type()
+		actual := decoder.Decode([]byte(input)).Type()
+		if actual != expected {
+			t.Errorf("Expected type %d for %q, got %d", expected, input, actual)
+		}
+	}
+
+	// every prefix of a large document is invalid, except the whole
+	for n := 0; n < len(codeJSON); n += len(codeJSON)/20 + 1 {
+		if decoder.Decode(codeJSON[:n]).Type() != NOT_JSON {
+			t.Errorf("Expected prefix of %d bytes to be NOT_JSON", n)
+		}
+	}
+	val := decoder.Decode(codeJSON)
+	if val.Type() != OBJECT {
+		t.Fatalf("Expected OBJECT, got %d", val.Type())
+	}
+	tree, err := val.Path("tree")
+	if err != nil || tree.Type() != OBJECT {
+		t.Errorf("Expected tree to be an OBJECT, got %v", err)
+	}
+
+	// decoded Values are independent of one another
+	a := decoder.Decode([]byte(`{"a":1}`))
+	b := decoder.Decode([]byte(`{"a":1}`))
+	a.SetPath("a", 2.0)
+	if b.Value().(map[string]interface{})["a"] != 1.0 {
+		t.Errorf("Expected b to be unaffected by changes to a")
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	docs := [][]byte{
+		[]byte(`{"name":"widget","price":12.5,"tags":["a","b"],"stock":{"count":3,"warehouse":"north"}}`),
+		[]byte(`[1,2,3,{"a":true}]`),
+		[]byte(`"hello"`),
+	}
+	decoder := NewDecoder()
+	for i := 0; i < b.N; i++ {
+		decoder.Decode(docs[i%len(docs)])
+	}
+}
@@ -0,0 +1,142 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// The format of a delta is a version byte, the length and CRC-32 of the old document's
+// bytes, then a sequence of operations building the new document's bytes:
+//
+//         'c' offset length    copy length bytes of the old document, starting at offset
+//         'i' length bytes     insert the following length bytes
+//
+// All numbers are unsigned varints, except the CRC-32, which is 4 bytes little endian.
+const (
+	deltaVersion = 1
+	deltaCopy    = 'c'
+	deltaInsert  = 'i'
+	deltaBlock   = 16 // the smallest run of bytes which is copied rather than inserted
+)
+
+// Encode the difference between two versions of a document as a compact binary delta,
+// which ApplyDelta() can apply to old to reproduce new.  Where the raw bytes of both
+// versions are available they are used directly, so unchanged parts of the document
+// are neither parsed nor re-encoded.
+func EncodeDelta(old, new *Value) []byte {
+	a, b := old.Bytes(), new.Bytes()
+
+	rv := []byte{deltaVersion}
+	rv = binary.AppendUvarint(rv, uint64(len(a)))
+	rv = binary.LittleEndian.AppendUint32(rv, crc32.ChecksumIEEE(a))
+
+	// index the blocks of the old bytes by their contents
+	blocks := make(map[string]int)
+	for o := 0; o+deltaBlock <= len(a); o += deltaBlock {
+		if _, ok := blocks[string(a[o:o+deltaBlock])]; !ok {
+			blocks[string(a[o:o+deltaBlock])] = o
+		}
+	}
+
+	insertFrom := 0
+	for i := 0; i+deltaBlock <= len(b); {
+		o, ok := blocks[string(b[i:i+deltaBlock])]
+		if !ok {
+			i++
+			continue
+		}
+		// extend the match in both directions
+		start, ostart := i, o
+		for start > insertFrom && ostart > 0 && b[start-1] == a[ostart-1] {
+			start--
+			ostart--
+		}
+		end, oend := i+deltaBlock, o+deltaBlock
+		for end < len(b) && oend < len(a) && b[end] == a[oend] {
+			end++
+			oend++
+		}
+		rv = appendInsert(rv, b[insertFrom:start])
+		rv = append(rv, deltaCopy)
+		rv = binary.AppendUvarint(rv, uint64(ostart))
+		rv = binary.AppendUvarint(rv, uint64(end-start))
+		insertFrom, i = end, end
+	}
+	return appendInsert(rv, b[insertFrom:])
+}
+
+func appendInsert(delta, data []byte) []byte {
+	if len(data) == 0 {
+		return delta
+	}
+	delta = append(delta, deltaInsert)
+	delta = binary.AppendUvarint(delta, uint64(len(data)))
+	return append(delta, data...)
+}
+
+// Apply a delta produced by EncodeDelta() to the old version of a document, returning the new version.
+// If the delta was not produced from this version of the document, or is malformed, an error is returned.
+func ApplyDelta(old *Value, delta []byte) (*Value, error) {
+	a := old.Bytes()
+	if len(delta) == 0 || delta[0] != deltaVersion {
+		return nil, fmt.Errorf("unsupported delta version")
+	}
+	r := bytes.NewReader(delta[1:])
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("malformed delta: %v", err)
+	}
+	var crc uint32
+	err = binary.Read(r, binary.LittleEndian, &crc)
+	if err != nil {
+		return nil, fmt.Errorf("malformed delta: %v", err)
+	}
+	if length != uint64(len(a)) || crc != crc32.ChecksumIEEE(a) {
+		return nil, fmt.Errorf("delta does not apply to this version of the document")
+	}
+
+	var out bytes.Buffer
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch op {
+		case deltaCopy:
+			offset, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("malformed delta: %v", err)
+			}
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("malformed delta: %v", err)
+			}
+			if offset > uint64(len(a)) || n > uint64(len(a))-offset {
+				return nil, fmt.Errorf("malformed delta: copy outside of the document")
+			}
+			out.Write(a[offset : offset+n])
+		case deltaInsert:
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("malformed delta: %v", err)
+			}
+			if n > uint64(r.Len()) {
+				return nil, fmt.Errorf("malformed delta: insert past the end of the delta")
+			}
+			data := make([]byte, n)
+			r.Read(data)
+			out.Write(data)
+		default:
+			return nil, fmt.Errorf("malformed delta: unknown operation %q", op)
+		}
+	}
+	return NewValueFromBytes(out.Bytes()), nil
+}
@@ -0,0 +1,52 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 20)
+	old := NewValueFromBytes([]byte(`{"name":"marty","bio":"` + long + `","tags":["a","b"],"rev":1}`))
+	var tests = []*Value{
+		NewValueFromBytes([]byte(`{"name":"marty","bio":"` + long + `","tags":["a","b"],"rev":2}`)),
+		NewValueFromBytes([]byte(`{"name":"steve","bio":"` + long + `!","tags":["a","b","c"],"rev":2}`)),
+		NewValueFromBytes([]byte(`[1,2,3]`)),
+		NewValueFromBytes([]byte(`not json`)),
+	}
+
+	for _, new := range tests {
+		delta := EncodeDelta(old, new)
+		result, err := ApplyDelta(old, delta)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, new.Bytes())
+			continue
+		}
+		if string(result.Bytes()) != string(new.Bytes()) {
+			t.Errorf("Expected %s, got %s", new.Bytes(), result.Bytes())
+		}
+	}
+
+	delta := EncodeDelta(old, tests[0])
+	if len(delta) > 32 {
+		t.Errorf("Expected a small change to produce a small delta, got %d bytes", len(delta))
+	}
+
+	_, err := ApplyDelta(tests[1], delta)
+	if err == nil {
+		t.Errorf("Expected error applying a delta to the wrong document")
+	}
+	_, err = ApplyDelta(old, delta[:len(delta)-1])
+	if err == nil {
+		t.Errorf("Expected error applying a truncated delta")
+	}
+}
@@ -0,0 +1,176 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+// Describe the differences between two Values field by field, for people to read.
+// Each line is marked with + (added in b), - (removed from b) or ~ (changed), and nested
+// differences are indented beneath their enclosing property or index, for example:
+//
+//         ~ name: "marty" => "gerald"
+//         - age: 35
+//           address:
+//           + zip: "94041"
+//           tags:
+//           ~ [1]: "b" => "c"
+//
+// If the Values are equal, the empty string is returned.
+func DiffHuman(a, b *Value) string {
+	return diffHuman(a, b, false)
+}
+
+// Describe the differences between two Values as DiffHuman() does, using ANSI colors
+// for display on a terminal.
+func DiffHumanColor(a, b *Value) string {
+	return diffHuman(a, b, true)
+}
+
+func diffHuman(a, b *Value, color bool) string {
+	d := humanDiff{color: color}
+	if a.Type() == NOT_JSON || b.Type() == NOT_JSON {
+		if !a.Equals(b) {
+			d.line(0, "~", "", fmt.Sprintf("%s => %s", describeValue(a), describeValue(b)))
+		}
+	} else {
+		d.diff(0, "", a.Value(), b.Value())
+	}
+	return strings.TrimSuffix(d.buf.String(), "\n")
+}
+
+type humanDiff struct {
+	buf   bytes.Buffer
+	color bool
+}
+
+// diff writes the differences between a and b, found at label, returning
+// true if there were any.
+func (this *humanDiff) diff(depth int, label string, a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			keys := sortedKeys(a)
+			for _, k := range sortedKeys(b) {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			return this.children(depth, label, keys, func(i int) (string, interface{}, bool, interface{}, bool) {
+				av, inA := a[keys[i]]
+				bv, inB := b[keys[i]]
+				return keys[i], av, inA, bv, inB
+			})
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			count := len(a)
+			if len(b) > count {
+				count = len(b)
+			}
+			keys := make([]string, count)
+			return this.children(depth, label, keys, func(i int) (string, interface{}, bool, interface{}, bool) {
+				var av, bv interface{}
+				if i < len(a) {
+					av = a[i]
+				}
+				if i < len(b) {
+					bv = b[i]
+				}
+				return fmt.Sprintf("[%d]", i), av, i < len(a), bv, i < len(b)
+			})
+		}
+	}
+	if compareNative(a, b, CompareOptions{}) != 0 {
+		this.line(depth, "~", label, fmt.Sprintf("%s => %s", describeNative(a), describeNative(b)))
+		return true
+	}
+	return false
+}
+
+// children writes the differences between the children of an object or
+// array, beneath a heading for label (unless this is the top level).
+func (this *humanDiff) children(depth int, label string, keys []string, child func(int) (string, interface{}, bool, interface{}, bool)) bool {
+	heading := this.buf.Len()
+	childDepth := depth
+	if label != "" {
+		this.line(depth, " ", label, "")
+		childDepth++
+	}
+	changed := false
+	for i := range keys {
+		name, av, inA, bv, inB := child(i)
+		if !inB {
+			this.line(childDepth, "-", name, describeNative(av))
+			changed = true
+		} else if !inA {
+			this.line(childDepth, "+", name, describeNative(bv))
+			changed = true
+		} else if this.diff(childDepth, name, av, bv) {
+			changed = true
+		}
+	}
+	if !changed {
+		// remove the heading
+		this.buf.Truncate(heading)
+	}
+	return changed
+}
+
+func (this *humanDiff) line(depth int, marker string, label string, detail string) {
+	start, end := "", ""
+	if this.color {
+		switch marker {
+		case "-":
+			start, end = ansiRed, ansiReset
+		case "+":
+			start, end = ansiGreen, ansiReset
+		case "~":
+			start, end = ansiYellow, ansiReset
+		}
+	}
+	this.buf.WriteString(strings.Repeat("  ", depth))
+	this.buf.WriteString(start)
+	this.buf.WriteString(marker)
+	this.buf.WriteString(" ")
+	if label != "" {
+		this.buf.WriteString(label)
+		this.buf.WriteString(":")
+		if detail != "" {
+			this.buf.WriteString(" ")
+		}
+	}
+	this.buf.WriteString(detail)
+	this.buf.WriteString(end)
+	this.buf.WriteString("\n")
+}
+
+func describeNative(val interface{}) string {
+	return string(NewValue(val).Bytes())
+}
+
+func describeValue(val *Value) string {
+	if val.Type() == NOT_JSON {
+		return fmt.Sprintf("not JSON %q", val.Bytes())
+	}
+	return string(val.Bytes())
+}
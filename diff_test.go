@@ -0,0 +1,51 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestDiffHuman(t *testing.T) {
+	var tests = []struct {
+		a    string
+		b    string
+		diff string
+	}{
+		{`{"a":1,"b":[1,2]}`, `{"b":[1,2.0],"a":1}`, ""},
+		{`{"name":"marty","age":35,"address":{"city":"x"},"tags":["a","b"],"same":{"x":1}}`,
+			`{"name":"gerald","address":{"city":"x","zip":"94041"},"tags":["a","c","d"],"same":{"x":1}}`,
+			"  address:\n" +
+				"  + zip: \"94041\"\n" +
+				"- age: 35\n" +
+				"~ name: \"marty\" => \"gerald\"\n" +
+				"  tags:\n" +
+				"  ~ [1]: \"b\" => \"c\"\n" +
+				"  + [2]: \"d\""},
+		{`{"a":{"b":{"c":1}}}`, `{"a":{"b":{"c":[1]}}}`,
+			"  a:\n" +
+				"    b:\n" +
+				"    ~ c: 1 => [1]"},
+		{`1`, `2`, "~ 1 => 2"},
+		{`abc`, `"abc"`, "~ not JSON \"abc\" => \"abc\""},
+	}
+
+	for _, test := range tests {
+		diff := DiffHuman(NewValueFromBytes([]byte(test.a)), NewValueFromBytes([]byte(test.b)))
+		if diff != test.diff {
+			t.Errorf("Expected diff:\n%s\ngot:\n%s", test.diff, diff)
+		}
+	}
+
+	diff := DiffHumanColor(NewValueFromBytes([]byte(`{"a":1}`)), NewValueFromBytes([]byte(`{"b":1}`)))
+	if diff != "\x1b[31m- a: 1\x1b[0m\n\x1b[32m+ b: 1\x1b[0m" {
+		t.Errorf("Unexpected colored diff %q", diff)
+	}
+}
@@ -0,0 +1,144 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	json "github.com/dustin/gojson"
+)
+
+// A DocumentSet is a collection of documents along with metadata describing the set as a whole.
+//
+// Serialized, a DocumentSet is an OBJECT of the form:
+//
+//         {"count":2,"documents":[...],"schemaVersion":1,"source":"feed"}
+type DocumentSet struct {
+	Source        string
+	SchemaVersion int
+	Documents     ValueCollection
+}
+
+// Create a new DocumentSet containing the specified documents.
+func NewDocumentSet(source string, schemaVersion int, docs ValueCollection) *DocumentSet {
+	return &DocumentSet{
+		Source:        source,
+		SchemaVersion: schemaVersion,
+		Documents:     docs,
+	}
+}
+
+// Create a new DocumentSet from a Value previously produced by a DocumentSet.
+// The documents themselves are not parsed.
+func NewDocumentSetFromValue(val *Value) (*DocumentSet, error) {
+	if val.Type() != OBJECT {
+		return nil, &TypeMismatch{Path: "document set", Expected: OBJECT, Actual: val.Type()}
+	}
+	rv := DocumentSet{}
+	source, err := val.Path("source")
+	if err == nil {
+		if s, ok := source.Value().(string); ok {
+			rv.Source = s
+		}
+	}
+	version, err := val.Path("schemaVersion")
+	if err == nil {
+		if v, ok := version.Value().(float64); ok {
+			rv.SchemaVersion = int(v)
+		}
+	}
+	docs, err := val.Path("documents")
+	if err != nil {
+		return nil, err
+	}
+	rv.Documents, err = docs.elements()
+	if err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+// Add a document to the set.
+func (this *DocumentSet) Add(doc *Value) {
+	this.Documents = append(this.Documents, doc)
+}
+
+// The number of documents in the set.
+func (this *DocumentSet) Count() int {
+	return len(this.Documents)
+}
+
+// Return the documents in the set on a channel, the channel is closed after the last document.
+func (this *DocumentSet) Channel() ValueChannel {
+	rv := make(ValueChannel)
+	go func() {
+		defer close(rv)
+		for _, doc := range this.Documents {
+			rv <- doc
+		}
+	}()
+	return rv
+}
+
+// Represent the entire set as a single Value of type OBJECT.
+func (this *DocumentSet) Value() *Value {
+	docs := make([]interface{}, len(this.Documents))
+	for i, doc := range this.Documents {
+		docs[i] = doc
+	}
+	return NewValue(map[string]interface{}{
+		"source":        this.Source,
+		"schemaVersion": float64(this.SchemaVersion),
+		"count":         float64(len(this.Documents)),
+		"documents":     docs,
+	})
+}
+
+// Serialize the entire set.
+func (this *DocumentSet) Bytes() []byte {
+	buf := bytes.Buffer{}
+	this.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// Serialize the set to w one document at a time, without building the entire output in memory.
+// The output is identical to that of Bytes().
+func (this *DocumentSet) WriteTo(w io.Writer) (int64, error) {
+	source, err := json.Marshal(this.Source)
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	write := func(b []byte) error {
+		n, err := w.Write(b)
+		written += int64(n)
+		return err
+	}
+	err = write([]byte(fmt.Sprintf(`{"count":%d,"documents":[`, len(this.Documents))))
+	if err != nil {
+		return written, err
+	}
+	for i, doc := range this.Documents {
+		if i > 0 {
+			err = write([]byte(","))
+			if err != nil {
+				return written, err
+			}
+		}
+		err = write(doc.Bytes())
+		if err != nil {
+			return written, err
+		}
+	}
+	err = write([]byte(fmt.Sprintf(`],"schemaVersion":%d,"source":%s}`, this.SchemaVersion, source)))
+	return written, err
+}
@@ -0,0 +1,68 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentSet(t *testing.T) {
+	set := NewDocumentSet("feed", 2, ValueCollection{
+		NewValueFromBytes([]byte(`{"name":"marty"}`)),
+	})
+	set.Add(NewValue(map[string]interface{}{"name": "gerald"}))
+
+	if set.Count() != 2 {
+		t.Errorf("Expected count 2, got %d", set.Count())
+	}
+
+	expectedBytes := []byte(`{"count":2,"documents":[{"name":"marty"},{"name":"gerald"}],"schemaVersion":2,"source":"feed"}`)
+	out := set.Bytes()
+	if !reflect.DeepEqual(out, expectedBytes) {
+		t.Errorf("Expected %s, got %s", string(expectedBytes), string(out))
+	}
+
+	valueBytes := set.Value().Bytes()
+	if !reflect.DeepEqual(valueBytes, expectedBytes) {
+		t.Errorf("Expected %s, got %s", string(expectedBytes), string(valueBytes))
+	}
+
+	buf := bytes.Buffer{}
+	n, err := set.WriteTo(&buf)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if n != int64(len(expectedBytes)) {
+		t.Errorf("Expected %d bytes written, got %d", len(expectedBytes), n)
+	}
+
+	count := 0
+	for _ = range set.Channel() {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 documents on channel, got %d", count)
+	}
+
+	// now read it back
+	readSet, err := NewDocumentSetFromValue(NewValueFromBytes(expectedBytes))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if readSet.Source != "feed" || readSet.SchemaVersion != 2 || readSet.Count() != 2 {
+		t.Errorf("Expected feed/2/2, got %s/%d/%d", readSet.Source, readSet.SchemaVersion, readSet.Count())
+	}
+	name, err := readSet.Documents[1].Path("name")
+	if err != nil || name.Value() != "gerald" {
+		t.Errorf("Expected name gerald, got %v (%v)", name, err)
+	}
+}
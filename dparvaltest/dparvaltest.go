@@ -0,0 +1,147 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Helpers for testing code which uses dparval Values.
+
+Values are compared semantically, so two Values are equal regardless of whether
+they are backed by raw bytes or by parsed data, and regardless of key order or
+number formatting in the raw bytes.
+*/
+package dparvaltest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	json "github.com/dustin/gojson"
+	"github.com/mschoch/dparval"
+)
+
+// When set (from the environment variable DPARVALTEST_UPDATE_GOLDEN), AssertGolden()
+// writes the Values it is given to the golden files instead of comparing them.
+var UpdateGolden = os.Getenv("DPARVALTEST_UPDATE_GOLDEN") != ""
+
+// Report an error on t if the Values are not equal, including a description of
+// the differences.
+func AssertEqualValues(t testing.TB, want, got *dparval.Value) {
+	t.Helper()
+	if diff := Diff(want, got); diff != "" {
+		t.Errorf("Values are not equal:\n%s", diff)
+	}
+}
+
+// Report an error on t if got is not equal to the Value stored in the golden file at path.
+// If UpdateGolden is set, got is written to the file (indented) instead.
+func AssertGolden(t testing.TB, path string, got *dparval.Value) {
+	t.Helper()
+	if UpdateGolden {
+		var buf bytes.Buffer
+		err := json.Indent(&buf, got.Bytes(), "", "  ")
+		if err != nil {
+			t.Fatalf("cannot write golden file %s: %v", path, err)
+		}
+		buf.WriteByte('\n')
+		err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+		if err != nil {
+			t.Fatalf("cannot write golden file %s: %v", path, err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file %s: %v", path, err)
+	}
+	if diff := Diff(dparval.NewValueFromBytes(want), got); diff != "" {
+		t.Errorf("Value does not match golden file %s:\n%s", path, diff)
+	}
+}
+
+// Describe the differences between two Values, one line per difference, identified
+// by JSON pointer.  If the Values are equal, the empty string is returned.
+func Diff(want, got *dparval.Value) string {
+	lines := make([]string, 0)
+	diffNative("", want.Value(), got.Value(), &lines)
+	if len(lines) == 0 && want.Type() != got.Type() {
+		// NOT_JSON Values have no native representation
+		lines = append(lines, fmt.Sprintf("/: want %s, got %s", describe(want), describe(got)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func diffNative(path string, want, got interface{}, lines *[]string) {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		if got, ok := got.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(want)+len(got))
+			for k := range want {
+				keys = append(keys, k)
+			}
+			for k := range got {
+				if _, ok := want[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				childPath := path + "/" + escape(k)
+				w, inWant := want[k]
+				g, inGot := got[k]
+				if !inGot {
+					*lines = append(*lines, fmt.Sprintf("%s: missing, want %s", childPath, format(w)))
+				} else if !inWant {
+					*lines = append(*lines, fmt.Sprintf("%s: unexpected %s", childPath, format(g)))
+				} else {
+					diffNative(childPath, w, g, lines)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if got, ok := got.([]interface{}); ok {
+			for i := 0; i < len(want) || i < len(got); i++ {
+				childPath := fmt.Sprintf("%s/%d", path, i)
+				if i >= len(got) {
+					*lines = append(*lines, fmt.Sprintf("%s: missing, want %s", childPath, format(want[i])))
+				} else if i >= len(want) {
+					*lines = append(*lines, fmt.Sprintf("%s: unexpected %s", childPath, format(got[i])))
+				} else {
+					diffNative(childPath, want[i], got[i], lines)
+				}
+			}
+			return
+		}
+	}
+	if !dparval.NewValue(want).Equals(dparval.NewValue(got)) {
+		if path == "" {
+			path = "/"
+		}
+		*lines = append(*lines, fmt.Sprintf("%s: want %s, got %s", path, format(want), format(got)))
+	}
+}
+
+func format(val interface{}) string {
+	return string(dparval.NewValue(val).Bytes())
+}
+
+func describe(val *dparval.Value) string {
+	if val.Type() == dparval.NOT_JSON {
+		return fmt.Sprintf("not JSON %q", val.Bytes())
+	}
+	return string(val.Bytes())
+}
+
+func escape(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
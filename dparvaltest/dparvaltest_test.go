@@ -0,0 +1,97 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparvaltest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mschoch/dparval"
+)
+
+// recorder captures the failures reported by the helpers
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (this *recorder) Helper() {}
+
+func (this *recorder) Errorf(format string, args ...interface{}) {
+	this.errors = append(this.errors, fmt.Sprintf(format, args...))
+}
+
+func (this *recorder) Fatalf(format string, args ...interface{}) {
+	this.Errorf(format, args...)
+}
+
+func TestDiff(t *testing.T) {
+	var tests = []struct {
+		want string
+		got  string
+		diff string
+	}{
+		{`{"a":1,"b":[1,2]}`, `{ "b" : [1, 2.0], "a" : 1e0 }`, ""},
+		{`{"a":1,"b":[1,2]}`, `{"a":2,"b":[1],"c/d":true}`, "/a: want 1, got 2\n/b/1: missing, want 2\n/c~1d: unexpected true"},
+		{`"x"`, `["x"]`, `/: want "x", got ["x"]`},
+		{`abc`, `null`, `/: want not JSON "abc", got null`},
+	}
+
+	for _, test := range tests {
+		diff := Diff(dparval.NewValueFromBytes([]byte(test.want)), dparval.NewValueFromBytes([]byte(test.got)))
+		if diff != test.diff {
+			t.Errorf("Expected diff %q, got %q", test.diff, diff)
+		}
+	}
+}
+
+func TestAssertEqualValues(t *testing.T) {
+	raw := dparval.NewValueFromBytes([]byte(`{"a":[1,{"b":"c"}]}`))
+	parsed := dparval.NewValue(map[string]interface{}{"a": []interface{}{1.0, map[string]interface{}{"b": "c"}}})
+
+	r := &recorder{TB: t}
+	AssertEqualValues(r, raw, parsed)
+	if len(r.errors) != 0 {
+		t.Errorf("Unexpected errors %v", r.errors)
+	}
+
+	parsed.SetPath("a", 1.0)
+	AssertEqualValues(r, raw, parsed)
+	if len(r.errors) != 1 {
+		t.Errorf("Expected 1 error, got %v", r.errors)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dparvaltest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "value.golden")
+	val := dparval.NewValueFromBytes([]byte(`{"b":[1,2],"a":"x"}`))
+
+	UpdateGolden = true
+	AssertGolden(t, path, val)
+	UpdateGolden = false
+
+	r := &recorder{TB: t}
+	AssertGolden(r, path, val)
+	if len(r.errors) != 0 {
+		t.Errorf("Unexpected errors %v", r.errors)
+	}
+	AssertGolden(r, path, dparval.NewValueFromBytes([]byte(`{"a":"y","b":[1,2]}`)))
+	if len(r.errors) != 1 {
+		t.Errorf("Expected 1 error, got %v", r.errors)
+	}
+}
@@ -0,0 +1,157 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparvaltest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+
+	json "github.com/dustin/gojson"
+	"github.com/mschoch/dparval"
+)
+
+// Check that the Value survives a round trip through Bytes() and NewValueFromBytes().
+// Any Value which is not NOT_JSON must serialize to valid JSON of the same type, which is
+// equal to the original Value.
+func CheckRoundTrip(v *dparval.Value) error {
+	if v.Type() == dparval.NOT_JSON {
+		return nil
+	}
+	out := v.Bytes()
+	again := dparval.NewValueFromBytes(out)
+	if again.Type() != v.Type() {
+		return fmt.Errorf("round trip changed type from %d to %d: %s", v.Type(), again.Type(), out)
+	}
+	if diff := Diff(v, again); diff != "" {
+		return fmt.Errorf("round trip changed value:\n%s", diff)
+	}
+	return nil
+}
+
+// Check that accessing JSON lazily, one Path() or Index() at a time without parsing,
+// finds the same values as parsing it eagerly.
+func CheckLazyEqualsEager(bytes []byte) error {
+	var eager interface{}
+	err := json.Unmarshal(bytes, &eager)
+	if err != nil {
+		if dparval.NewValueFromBytes(bytes).Type() != dparval.NOT_JSON {
+			return fmt.Errorf("invalid JSON was not identified as NOT_JSON")
+		}
+		return nil
+	}
+	return checkLazy("", dparval.NewValueFromBytes(bytes), eager)
+}
+
+func checkLazy(path string, lazy *dparval.Value, eager interface{}) error {
+	switch eager := eager.(type) {
+	case map[string]interface{}:
+		for k, v := range eager {
+			child, err := lazy.Path(k)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %v", path, escape(k), err)
+			}
+			err = checkLazy(path+"/"+escape(k), child, v)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, v := range eager {
+			child, err := lazy.Index(i)
+			if err != nil {
+				return fmt.Errorf("%s/%d: %v", path, i, err)
+			}
+			err = checkLazy(path+"/"+strconv.Itoa(i), child, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if diff := Diff(dparval.NewValue(eager), lazy); diff != "" {
+		if path == "" {
+			path = "/"
+		}
+		return fmt.Errorf("%s: lazy value differs from eager value:\n%s", path, diff)
+	}
+	return nil
+}
+
+// QuickValue is a random Value which can be generated by testing/quick, for example:
+//
+//         quick.Check(func(v dparvaltest.QuickValue) bool {
+//                 return dparvaltest.CheckRoundTrip(v.Value) == nil
+//         }, nil)
+//
+// Generated Values are a mix of Values created from raw bytes and from Go data.
+type QuickValue struct {
+	*dparval.Value
+}
+
+// Generate a random QuickValue, implementing quick.Generator.
+func (this QuickValue) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(QuickValue{RandomValue(r, size)})
+}
+
+// Create a random Value, with objects and arrays nested at most depth levels deep.
+func RandomValue(r *rand.Rand, depth int) *dparval.Value {
+	native := randomNative(r, depth)
+	if r.Intn(2) == 0 {
+		return dparval.NewValue(native)
+	}
+	bytes, err := json.Marshal(native)
+	if err != nil {
+		panic("unexpected marshal error on random data")
+	}
+	return dparval.NewValueFromBytes(bytes)
+}
+
+func randomNative(r *rand.Rand, depth int) interface{} {
+	kinds := 4
+	if depth > 0 {
+		kinds = 6
+	}
+	switch r.Intn(kinds) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 0
+	case 2:
+		if r.Intn(2) == 0 {
+			return float64(r.Intn(2000) - 1000)
+		}
+		return r.NormFloat64() * 1e6
+	case 3:
+		return randomString(r)
+	case 4:
+		rv := make([]interface{}, r.Intn(5))
+		for i := range rv {
+			rv[i] = randomNative(r, depth-1)
+		}
+		return rv
+	default:
+		rv := make(map[string]interface{})
+		for i := r.Intn(5); i > 0; i-- {
+			rv[randomString(r)] = randomNative(r, depth-1)
+		}
+		return rv
+	}
+}
+
+var randomRunes = []rune("abcxyz019 _-\"\\/\t\né世\U0001F600")
+
+func randomString(r *rand.Rand) string {
+	rv := make([]rune, r.Intn(8))
+	for i := range rv {
+		rv[i] = randomRunes[r.Intn(len(randomRunes))]
+	}
+	return string(rv)
+}
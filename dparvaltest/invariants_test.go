@@ -0,0 +1,45 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparvaltest
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+func TestInvariants(t *testing.T) {
+	roundTrip := func(v QuickValue) bool {
+		err := CheckRoundTrip(v.Value)
+		if err != nil {
+			t.Logf("%s: %v", v.Bytes(), err)
+		}
+		return err == nil
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	lazyEager := func(v QuickValue) bool {
+		err := CheckLazyEqualsEager(v.Bytes())
+		if err != nil {
+			t.Logf("%s: %v", v.Bytes(), err)
+		}
+		return err == nil
+	}
+	if err := quick.Check(lazyEager, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	for _, input := range []string{`{"a":[1,{"b":null}],"c":"é"}`, `[[],{}]`, `not json`, `3`} {
+		if err := CheckLazyEqualsEager([]byte(input)); err != nil {
+			t.Errorf("Unexpected error %v for %s", err, input)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// the kinds of change an Editor records
+const (
+	editSet = iota
+	editRemove
+	editAppend
+)
+
+type edit struct {
+	kind int
+	path string
+	val  *Value
+}
+
+// An Editor records changes to the subdocument at a path inside a Value, and applies them
+// all at once when Commit() is called.  See Edit().
+type Editor struct {
+	doc   *Value
+	path  string
+	edits []edit
+}
+
+// Begin editing the subdocument at the requested dotted path inside this Value (as for
+// FillTemplate(), "" is this Value itself).  The subdocument must be an OBJECT or ARRAY.
+//
+// If the path does not exist, the return error is *Undefined.  If the value at the path
+// is not an OBJECT or ARRAY, the return error is *TypeMismatch.
+func (this *Value) Edit(path string) (*Editor, error) {
+	sub, err := resolvePath(this, path)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Type() != OBJECT && sub.Type() != ARRAY {
+		return nil, &TypeMismatch{Path: path, Expected: OBJECT, Actual: sub.Type()}
+	}
+	return &Editor{doc: this, path: path}, nil
+}
+
+// Set the property or element at the dotted path, relative to the subdocument being edited.
+// The val argument must be compatible with the NewValue() method.
+func (this *Editor) Set(path string, val interface{}) {
+	this.edits = append(this.edits, edit{editSet, path, NewValue(val)})
+}
+
+// Remove the property or element at the dotted path, relative to the subdocument being
+// edited.  Later elements of an array shift down to fill the gap.
+func (this *Editor) Remove(path string) {
+	this.edits = append(this.edits, edit{editRemove, path, nil})
+}
+
+// Append an element to the array at the dotted path, relative to the subdocument being
+// edited ("" is the subdocument itself).  The val argument must be compatible with the
+// NewValue() method.
+func (this *Editor) Append(path string, val interface{}) {
+	this.edits = append(this.edits, edit{editAppend, path, NewValue(val)})
+}
+
+// Apply the recorded changes, in the order they were made, and splice the result into
+// the document.  Either every change is applied, or none is: the changes are made to a
+// copy of the subdocument, which replaces the original only once they have all succeeded.
+// Either way the recorded changes are then discarded, and the Editor may be used to
+// record and commit further changes.
+//
+// If a path does not exist, the return error is *Undefined.  If Append() was given a path
+// which is not an array, the return error is *TypeMismatch.  If a Schema bound to the
+// document (see BindSchema()) does not allow the result, or a constraint registered with
+// AddConstraint() would be violated, its error is returned as for SetPathErr().
+func (this *Editor) Commit() error {
+	edits := this.edits
+	this.edits = nil
+	chain, err := resolveChain(this.doc, this.path)
+	if err != nil {
+		return err
+	}
+	sub := chain[len(chain)-1]
+	// the raw bytes of an unmodified subdocument are reused, not parsed
+	working := sub.newChild(sub.Bytes())
+	for _, edit := range edits {
+		err = working.applyEdit(edit)
+		if err != nil {
+			return err
+		}
+	}
+	return replaceChain(chain, this.path, working)
+}
+
+// applyEdit makes one change recorded by an Editor to this Value.
+func (this *Value) applyEdit(edit edit) error {
+	parentPath, key := "", edit.path
+	if i := strings.LastIndex(edit.path, "."); i >= 0 {
+		parentPath, key = edit.path[:i], edit.path[i+1:]
+	}
+	if edit.kind == editAppend {
+		parentPath, key = edit.path, ""
+	}
+	chain, err := resolveChain(this, parentPath)
+	if err != nil {
+		return err
+	}
+	parent := chain[len(chain)-1]
+
+	switch edit.kind {
+	case editSet:
+		if parent.Type() == ARRAY {
+			elements, _ := parent.elements()
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(elements) {
+				return &Undefined{edit.path}
+			}
+			err = parent.SetIndexErr(index, edit.val)
+			if err != nil {
+				return err
+			}
+		} else if parent.Type() == OBJECT {
+			err = parent.SetPathErr(key, edit.val)
+			if err != nil {
+				return err
+			}
+		} else {
+			return &Undefined{edit.path}
+		}
+	case editRemove:
+		switch parent.Type() {
+		case ARRAY:
+			elements, _ := parent.elements()
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(elements) {
+				return &Undefined{edit.path}
+			}
+			kept := make(ValueCollection, 0, len(elements)-1)
+			kept = append(kept, elements[:index]...)
+			parent.replaceElements(append(kept, elements[index+1:]...))
+		case OBJECT:
+			members := parent.members()
+			if _, ok := members[key]; !ok {
+				return &Undefined{edit.path}
+			}
+			delete(members, key)
+			parent.replaceMembers(members)
+		default:
+			return &Undefined{edit.path}
+		}
+	case editAppend:
+		elements, err := parent.elements()
+		if err != nil {
+			return &TypeMismatch{Path: edit.path, Expected: ARRAY, Actual: parent.Type()}
+		}
+		parent.replaceElements(append(elements, edit.val))
+	}
+	return storeChain(chain, parentPath)
+}
+
+// replaceContents replaces the contents of this Value with those of other, keeping
+// the attachments and annotations of this Value.  If the Schema bound to this Value
+// does not allow other, or a constraint on this Value would be violated, its error is
+// returned and nothing is changed.
+func (this *Value) replaceContents(other *Value) error {
+	this.checkMutable()
+	if schema := this.Schema(); schema != nil {
+		if err := schema.check("", other); err != nil {
+			return err
+		}
+	}
+	if err := this.validateContents(other); err != nil {
+		return err
+	}
+	this.raw = other.raw
+	this.rawCount = other.rawCount
+	this.parsedValue = other.parsedValue
+	this.parsedType = other.parsedType
+	this.alias = other.alias
+	this.children = other.children
+	this.order = other.order
+	return nil
+}
@@ -0,0 +1,81 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"id": 1, "order": {"customer": {"name": "a", "email": "x"}, "items": [1, 2, 3]}}`))
+	editor, err := doc.Edit("order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor.Set("customer.name", "b")
+	editor.Remove("customer.email")
+	editor.Remove("items.0")
+	editor.Append("items", 4.0)
+	editor.Set("status", "shipped")
+
+	// nothing changes until the commit
+	if string(doc.Bytes()) != `{"id": 1, "order": {"customer": {"name": "a", "email": "x"}, "items": [1, 2, 3]}}` {
+		t.Errorf("Expected doc to be unchanged, got %s", doc.Bytes())
+	}
+	err = editor.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":1,"order":{"customer":{"name":"b"},"items":[2,3,4],"status":"shipped"}}`
+	if string(doc.Bytes()) != expected {
+		t.Errorf("Expected %s, got %s", expected, doc.Bytes())
+	}
+
+	// a failing change leaves the document untouched
+	editor.Set("customer.name", "c")
+	editor.Remove("missing")
+	if _, ok := editor.Commit().(*Undefined); !ok {
+		t.Errorf("Expected *Undefined")
+	}
+	editor.Append("customer", 1.0)
+	if _, ok := editor.Commit().(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch")
+	}
+	if string(doc.Bytes()) != expected {
+		t.Errorf("Expected %s, got %s", expected, doc.Bytes())
+	}
+
+	// editing the whole document
+	editor, _ = doc.Edit("")
+	editor.Remove("order")
+	editor.Commit()
+	if string(doc.Bytes()) != `{"id":1}` {
+		t.Errorf("Expected order removed, got %s", doc.Bytes())
+	}
+
+	// a negative index counts from the end, as for Index()
+	doc = NewValueFromBytes([]byte(`{"rows":[{"n":1},{"n":2}]}`))
+	editor, err = doc.Edit("rows.-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor.Set("n", 3.0)
+	err = editor.Commit()
+	if err != nil || string(doc.Bytes()) != `{"rows":[{"n":1},{"n":3}]}` {
+		t.Errorf("Expected the last row changed, got %s, %v", doc.Bytes(), err)
+	}
+
+	if _, err := doc.Edit("id"); err == nil {
+		t.Errorf("Expected an error editing a number")
+	}
+	if _, err := doc.Edit("nope"); err == nil {
+		t.Errorf("Expected an error editing a missing path")
+	}
+}
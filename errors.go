@@ -0,0 +1,109 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The categories of errors returned by this package
+const (
+	SYNTAX_ERROR      = iota + 1 // malformed JSON, expression or delta
+	TYPE_MISMATCH                // a value of one type was found where another was needed
+	OUT_OF_RANGE                 // a value or size outside of what is allowed
+	DEPTH_EXCEEDED               // a document nested more deeply than allowed
+	UNDEFINED                    // a property, index or parameter which does not exist
+	UNSUPPORTED                  // a request this package cannot carry out
+	INVALID_SIGNATURE            // a signature which could not be verified
+)
+
+// Every error type of this package implements ClassifiedError, so callers can switch on
+// Category() rather than matching error strings.  Code() is a stable identifier of the
+// specific error type, suitable for logs and metrics.
+type ClassifiedError interface {
+	error
+	Category() int
+	Code() string
+}
+
+// Return the category of err, or of the first ClassifiedError it wraps.
+// Errors not from this package have category 0.
+func ErrorCategory(err error) int {
+	var classified ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Category()
+	}
+	return 0
+}
+
+// When a value is not of the type required, the return error will be *TypeMismatch.
+type TypeMismatch struct {
+	Path     string // the path or description of the value (if known)
+	Expected int
+	Actual   int
+}
+
+// Description of the expected and actual types.
+func (this *TypeMismatch) Error() string {
+	if this.Path != "" {
+		return fmt.Sprintf("%s must be %s, got %s", this.Path, typeNames[this.Expected], typeNames[this.Actual])
+	}
+	return fmt.Sprintf("expected %s, got %s", typeNames[this.Expected], typeNames[this.Actual])
+}
+
+// When a value cannot be represented or is outside of the allowed range,
+// the return error will be *OutOfRange.
+type OutOfRange struct {
+	Value interface{}
+	msg   string
+}
+
+// Description of the value which was out of range.
+func (this *OutOfRange) Error() string {
+	return fmt.Sprintf("%v is out of range: %s", this.Value, this.msg)
+}
+
+// The category and code of each error type
+func (this *SyntaxError) Category() int            { return SYNTAX_ERROR }
+func (this *ExpressionError) Category() int        { return SYNTAX_ERROR }
+func (this *TypeMismatch) Category() int           { return TYPE_MISMATCH }
+func (this *ArgumentError) Category() int          { return TYPE_MISMATCH }
+func (this *OutOfRange) Category() int             { return OUT_OF_RANGE }
+func (this *OutputSizeError) Category() int        { return OUT_OF_RANGE }
+func (this *DepthExceeded) Category() int          { return DEPTH_EXCEEDED }
+func (this *Undefined) Category() int              { return UNDEFINED }
+func (this *UnboundParameter) Category() int       { return UNDEFINED }
+func (this *UnsupportedContentType) Category() int { return UNSUPPORTED }
+func (this *CycleError) Category() int             { return UNSUPPORTED }
+func (this *SignatureError) Category() int         { return INVALID_SIGNATURE }
+func (this *TrailingDataError) Category() int      { return SYNTAX_ERROR }
+func (this *UnknownProperty) Category() int        { return UNDEFINED }
+func (this *ConstraintViolation) Category() int    { return OUT_OF_RANGE }
+func (this *PointerError) Category() int           { return SYNTAX_ERROR }
+func (this *ParallelError) Category() int          { return ErrorCategory(this.Errors[0]) }
+
+func (this *SyntaxError) Code() string            { return "json_syntax" }
+func (this *ExpressionError) Code() string        { return "expression_syntax" }
+func (this *TypeMismatch) Code() string           { return "type_mismatch" }
+func (this *ArgumentError) Code() string          { return "argument_type" }
+func (this *OutOfRange) Code() string             { return "out_of_range" }
+func (this *OutputSizeError) Code() string        { return "output_size" }
+func (this *DepthExceeded) Code() string          { return "depth_exceeded" }
+func (this *Undefined) Code() string              { return "undefined" }
+func (this *UnboundParameter) Code() string       { return "unbound_parameter" }
+func (this *UnsupportedContentType) Code() string { return "unsupported_content_type" }
+func (this *CycleError) Code() string             { return "cycle" }
+func (this *SignatureError) Code() string         { return "invalid_signature" }
+func (this *TrailingDataError) Code() string      { return "trailing_data" }
+func (this *UnknownProperty) Code() string        { return "unknown_property" }
+func (this *ConstraintViolation) Code() string    { return "constraint_violation" }
+func (this *PointerError) Code() string           { return "pointer_syntax" }
+func (this *ParallelError) Code() string          { return "parallel" }
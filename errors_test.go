@@ -0,0 +1,57 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestErrorCategory(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"a": 1, "b": [1, 2]}`))
+
+	_, undefined := doc.Path("c")
+	_, mismatch := doc.CountPath("a")
+	_, syntax := NewValueFromBytesWithOptions([]byte(`{"a": [1,}`), ParseOptions{Strict: true})
+	_, expression := ParseExpression("a +")
+	_, outOfRange := canonicalNumber(math.Inf(1))
+	_, depth := NewValueFromBytesWithOptions([]byte(`[[[1]]]`), ParseOptions{MaxDepth: 2})
+	_, unsupported := DecodeAs("text/unknown", nil)
+
+	var tests = []struct {
+		err      error
+		category int
+		code     string
+	}{
+		{undefined, UNDEFINED, "undefined"},
+		{mismatch, TYPE_MISMATCH, "type_mismatch"},
+		{syntax, SYNTAX_ERROR, "json_syntax"},
+		{expression, SYNTAX_ERROR, "expression_syntax"},
+		{outOfRange, OUT_OF_RANGE, "out_of_range"},
+		{depth, DEPTH_EXCEEDED, "depth_exceeded"},
+		{unsupported, UNSUPPORTED, "unsupported_content_type"},
+		{fmt.Errorf("wrapped: %w", mismatch), TYPE_MISMATCH, "type_mismatch"},
+	}
+	for _, test := range tests {
+		if ErrorCategory(test.err) != test.category {
+			t.Errorf("Expected category %d for %v, got %d", test.category, test.err, ErrorCategory(test.err))
+		}
+		if classified, ok := test.err.(ClassifiedError); ok && classified.Code() != test.code {
+			t.Errorf("Expected code %s for %v, got %s", test.code, test.err, classified.Code())
+		}
+	}
+	if ErrorCategory(fmt.Errorf("other")) != 0 {
+		t.Errorf("Expected other errors to have category 0")
+	}
+	if mismatch.Error() != "a must be array, got number" {
+		t.Errorf("Unexpected message %q", mismatch.Error())
+	}
+}
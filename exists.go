@@ -0,0 +1,166 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Determine if Path(path) would find a Value, including dotted paths, without creating any
+// Values or parsing.  Aliases and remembered children are consulted as Path() does, and the
+// raw bytes are scanned in place, so presence checks are much cheaper than calling Path().
+func (this *Value) ExistsPath(path string) bool {
+	return !this.locate(path).missing
+}
+
+// Return the type of the value at path, as Path(path).Type() would, but without creating any
+// Values or parsing: the type of raw bytes is identified from their first byte, as for the
+// top level of NewValueFromBytes().  If the path does not exist, the result is NOT_JSON.
+func (this *Value) TypeAt(path string) int {
+	cur := this.locate(path)
+	switch {
+	case cur.missing:
+		return NOT_JSON
+	case cur.val != nil:
+		return cur.val.Type()
+	case cur.raw != nil:
+		return identifyType(cur.raw)
+	}
+	return nativeType(cur.native)
+}
+
+// locate finds the value at path as ExistsPath() does, without creating any Values.
+func (this *Value) locate(path string) existsCursor {
+	missing := existsCursor{missing: true}
+	if this.parsedType != OBJECT {
+		return missing
+	}
+	if cur := (existsCursor{val: this}).step(path); !cur.missing {
+		return cur
+	}
+	if strings.IndexByte(path, '.') < 0 {
+		return missing
+	}
+	cur := existsCursor{val: this}
+	for path != "" {
+		step := path
+		if dot := strings.IndexByte(path, '.'); dot >= 0 {
+			step, path = path[:dot], path[dot+1:]
+		} else {
+			path = ""
+		}
+		cur = cur.step(step)
+		if cur.missing {
+			return missing
+		}
+	}
+	return cur
+}
+
+// Determine if Index(index) would find a Value, without creating any Values or parsing.
+// As for Index(), a negative index counts from the end of the array.
+func (this *Value) ExistsIndex(index int) bool {
+	if this.parsedType != ARRAY {
+		return false
+	}
+	if index < 0 {
+		n, err := this.count("")
+		if err != nil {
+			return false
+		}
+		index += n
+	}
+	if index < 0 {
+		return false
+	}
+	return !(existsCursor{val: this}).step(strconv.Itoa(index)).missing
+}
+
+// existsCursor is a position inside a Value while checking for a path: either a Value, the
+// raw bytes of a value which has not been wrapped in a Value, or a value parsed by Value().
+type existsCursor struct {
+	val     *Value
+	raw     []byte
+	native  interface{}
+	missing bool
+}
+
+// step moves the cursor to a property, or to an element if step is an integer and the cursor
+// is on an ARRAY, consulting each source in the same order as Path() and Index().
+func (this existsCursor) step(step string) existsCursor {
+	missing := existsCursor{missing: true}
+	switch {
+	case this.val != nil:
+		val := this.val
+		index := 0
+		switch val.parsedType {
+		case OBJECT:
+		case ARRAY:
+			var err error
+			index, err = strconv.Atoi(step)
+			if err != nil || index < 0 {
+				return missing
+			}
+			step = strconv.Itoa(index)
+		default:
+			return missing
+		}
+		if alias, ok := val.alias[step]; ok {
+			return existsCursor{val: alias}
+		}
+		switch parsedValue := val.parsedValue.(type) {
+		case map[string]*Value:
+			if child, ok := parsedValue[step]; ok {
+				return existsCursor{val: child}
+			}
+			return missing
+		case []*Value:
+			if index < len(parsedValue) {
+				return existsCursor{val: parsedValue[index]}
+			}
+			return missing
+		}
+		if child, ok := val.children[step]; ok {
+			return existsCursor{val: child}
+		}
+		if val.raw != nil {
+			return existsCursor{raw: val.raw}.step(step)
+		}
+		return existsCursor{native: val.parsedValue}.step(step)
+	case this.raw != nil:
+		var raw []byte
+		var ok bool
+		switch this.raw[skipWhitespace(this.raw, 0)] {
+		case '{':
+			raw, ok = rawMember(this.raw, step)
+		case '[':
+			index, err := strconv.Atoi(step)
+			raw, ok = rawElement(this.raw, index)
+			ok = ok && err == nil
+		}
+		if !ok {
+			return missing
+		}
+		return existsCursor{raw: raw}
+	default:
+		switch native := this.native.(type) {
+		case map[string]interface{}:
+			if child, ok := native[step]; ok {
+				return existsCursor{native: child}
+			}
+		case []interface{}:
+			if index, err := strconv.Atoi(step); err == nil && index >= 0 && index < len(native) {
+				return existsCursor{native: native[index]}
+			}
+		}
+		return missing
+	}
+}
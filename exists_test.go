@@ -0,0 +1,115 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestExistsPath(t *testing.T) {
+	raw := []byte(`{"name": "marty", "a.b": 1, "n": null, "escaped": 2, "a": {"b": {"c": [1, {"d": 3}]}}}`)
+	paths := []string{"name", "a.b", "n", "escaped", "a.b.c", "a.b.c.1.d", "a.b.c.2", "a.b.c.x", "a.x", "name.x", "n.x", "missing", ""}
+
+	docs := map[string]*Value{
+		"raw":    NewValueFromBytes(raw),
+		"parsed": NewValueFromBytes(raw),
+		"native": NewValue(NewValueFromBytes(raw).Value()),
+	}
+	docs["parsed"].Value()
+	for name, doc := range docs {
+		for _, path := range paths {
+			_, err := doc.Path(path)
+			if expected := err == nil; doc.ExistsPath(path) != expected {
+				t.Errorf("Expected ExistsPath(%s) %v for the %s document", path, expected, name)
+			}
+		}
+	}
+
+	// aliases are consulted
+	doc := NewValueFromBytes(raw)
+	a, _ := doc.Path("a")
+	a.SetPath("x", 1.0)
+	doc.SetPath("added", map[string]interface{}{"y": true})
+	for _, path := range []string{"a.x", "added.y", "a.b.c.1.d"} {
+		if !doc.ExistsPath(path) {
+			t.Errorf("Expected %s to exist", path)
+		}
+	}
+	if NewValue("string").ExistsPath("x") {
+		t.Errorf("Expected no paths in a string")
+	}
+}
+
+func TestExistsIndex(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`[1, [2], {"a": 3}]`))
+	for _, index := range []int{0, 2, -1, -3} {
+		if !doc.ExistsIndex(index) {
+			t.Errorf("Expected index %d to exist", index)
+		}
+	}
+	for _, index := range []int{3, -4} {
+		if doc.ExistsIndex(index) {
+			t.Errorf("Expected index %d not to exist", index)
+		}
+	}
+	parsed := NewValue([]interface{}{1.0})
+	if !parsed.ExistsIndex(0) || parsed.ExistsIndex(1) {
+		t.Errorf("Expected only index 0 to exist")
+	}
+	if NewValueFromBytes([]byte(`{"0": 1}`)).ExistsIndex(0) {
+		t.Errorf("Expected no indexes in an object")
+	}
+}
+
+func TestExistsPathAllocations(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name": "marty", "address": {"street": "sutton oaks", "city": "x"}}`))
+	allocs := testing.AllocsPerRun(100, func() {
+		doc.ExistsPath("address.city")
+		doc.ExistsPath("missing")
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestTypeAt(t *testing.T) {
+	raw := []byte(`{"s": "x", "n": -1.5, "t": true, "z": null, "a": [1, {"o": {}}], "o": {"p": "q"}}`)
+	tests := map[string]int{
+		"s":       STRING,
+		"n":       NUMBER,
+		"t":       BOOLEAN,
+		"z":       NULL,
+		"a":       ARRAY,
+		"a.0":     NUMBER,
+		"a.1.o":   OBJECT,
+		"o.p":     STRING,
+		"missing": NOT_JSON,
+		"s.x":     NOT_JSON,
+	}
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		for path, expected := range tests {
+			if actual := doc.TypeAt(path); actual != expected {
+				t.Errorf("Expected type %d at %s, got %d", expected, path, actual)
+			}
+		}
+	}
+
+	doc := NewValueFromBytes(raw)
+	doc.SetPath("s", 1.0)
+	if doc.TypeAt("s") != NUMBER {
+		t.Errorf("Expected the type of the alias, got %d", doc.TypeAt("s"))
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		doc.TypeAt("a.1.o")
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,85 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"math"
+	"time"
+)
+
+// The attachment key under which SetExpiryPath() stores the path of the expiry of a Value.
+const EXPIRY_PATH_ATTACHMENT = "expiryPath"
+
+// Set the dotted path (as for FillTemplate()) within this Value which holds when it expires,
+// as a number of seconds since the Unix epoch, like the Expiry of Meta, or as a date string
+// recognized by FilterTimeRange().  The path is stored as an attachment, so it is never part
+// of Value() or Bytes().
+func (this *Value) SetExpiryPath(path string) {
+	this.SetAttachment(EXPIRY_PATH_ATTACHMENT, path)
+}
+
+// Return the time at which this Value expires, and whether it expires at all.  The Expiry of
+// its Meta (see FeedChannel()) is consulted first, then the path set by SetExpiryPath().  If
+// both are set, the earlier one applies.  A zero Expiry, or an expiry path which is not
+// defined or holds neither a number nor a date, means the Value never expires.
+func (this *Value) ExpiresAt() (time.Time, bool) {
+	var rv time.Time
+	expires := false
+	if meta := this.Meta(); meta != nil && meta.Expiry != 0 {
+		rv, expires = time.Unix(int64(meta.Expiry), 0), true
+	}
+	if path, ok := this.GetAttachment(EXPIRY_PATH_ATTACHMENT).(string); ok {
+		if t, ok := this.expiryAtPath(path); ok && (!expires || t.Before(rv)) {
+			rv, expires = t, true
+		}
+	}
+	return rv, expires
+}
+
+// The largest number of seconds since the Unix epoch accepted as an expiry, some way short
+// of the limit of time.Time.
+const maxExpirySeconds = 1 << 62
+
+func (this *Value) expiryAtPath(path string) (time.Time, bool) {
+	val, err := resolvePath(this, path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if val.Type() == NUMBER {
+		seconds := nativeNumber(val.Value())
+		// an expiry too far in the future for a time.Time (or NaN) never comes
+		if !(seconds > 0 && seconds < maxExpirySeconds) {
+			return time.Time{}, false
+		}
+		whole := math.Floor(seconds)
+		return time.Unix(int64(whole), int64((seconds-whole)*float64(time.Second))), true
+	}
+	return recognizeDate(val)
+}
+
+// Determine if this Value has expired at the specified time (see ExpiresAt()).  A Value
+// expires at the start of its expiry time, so it is expired when now is that time.
+func (this *Value) Expired(now time.Time) bool {
+	expiry, expires := this.ExpiresAt()
+	return expires && !now.Before(expiry)
+}
+
+// Sweep this collection at the specified time, splitting it into the Values which are still
+// live and those which have expired (see Expired()), each in their original order.
+func (this ValueCollection) SweepExpired(now time.Time) (live, expired ValueCollection) {
+	for _, val := range this {
+		if val.Expired(now) {
+			expired = append(expired, val)
+		} else {
+			live = append(live, val)
+		}
+	}
+	return live, expired
+}
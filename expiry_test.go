@@ -0,0 +1,79 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	fromFeed := NewValueFromMutation(&Mutation{Key: "a", Body: []byte(`{}`), Expiry: 1700000000})
+	never := NewValueFromMutation(&Mutation{Key: "b", Body: []byte(`{}`)})
+	plain := NewValueFromBytes([]byte(`{"n":1}`))
+
+	byNumber := NewValueFromBytes([]byte(`{"cache":{"expires":1699999999.5}}`))
+	byNumber.SetExpiryPath("cache.expires")
+	byDate := NewValueFromBytes([]byte(`{"expires":"2023-11-14T22:13:21Z"}`))
+	byDate.SetExpiryPath("expires")
+	notDate := NewValueFromBytes([]byte(`{"expires":"soon"}`))
+	notDate.SetExpiryPath("expires")
+	undefined := NewValueFromBytes([]byte(`{}`))
+	undefined.SetExpiryPath("expires")
+
+	farFuture := NewValueFromBytes([]byte(`{"expires":1e11}`))
+	farFuture.SetExpiryPath("expires")
+	outOfRange := NewValueFromBytes([]byte(`{"expires":1e300}`))
+	outOfRange.SetExpiryPath("expires")
+
+	// the earlier of the Meta and the path applies
+	both := NewValueFromMutation(&Mutation{Key: "c", Body: []byte(`{"expires":1600000000}`), Expiry: 1800000000})
+	both.SetExpiryPath("expires")
+
+	tests := []struct {
+		val      *Value
+		expired  bool
+		expires  bool
+		expireAt time.Time
+	}{
+		{fromFeed, true, true, now},
+		{never, false, false, time.Time{}},
+		{plain, false, false, time.Time{}},
+		{byNumber, true, true, now.Add(-500 * time.Millisecond)},
+		{byDate, false, true, now.Add(time.Second)},
+		{notDate, false, false, time.Time{}},
+		{undefined, false, false, time.Time{}},
+		{both, true, true, time.Unix(1600000000, 0)},
+		{farFuture, false, true, time.Unix(1e11, 0)},
+		{outOfRange, false, false, time.Time{}},
+	}
+	for i, test := range tests {
+		if test.val.Expired(now) != test.expired {
+			t.Errorf("Expected Expired() %v for %d", test.expired, i)
+		}
+		expireAt, expires := test.val.ExpiresAt()
+		if expires != test.expires || !expireAt.Equal(test.expireAt) {
+			t.Errorf("Expected ExpiresAt() %v, %v for %d, got %v, %v", test.expireAt, test.expires, i, expireAt, expires)
+		}
+	}
+	if string(byDate.Bytes()) != `{"expires":"2023-11-14T22:13:21Z"}` {
+		t.Errorf("Expiry path leaked into Bytes(): %s", byDate.Bytes())
+	}
+
+	live, expired := ValueCollection{fromFeed, never, byDate, both, plain}.SweepExpired(now)
+	if len(live) != 3 || live[0] != never || live[1] != byDate || live[2] != plain {
+		t.Errorf("Expected never, byDate and plain to be live, got %v", live)
+	}
+	if len(expired) != 2 || expired[0] != fromFeed || expired[1] != both {
+		t.Errorf("Expected fromFeed and both to have expired, got %v", expired)
+	}
+}
@@ -0,0 +1,756 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// When an expression cannot be parsed, the return error will be *ExpressionError,
+// describing where the problem was found.
+type ExpressionError struct {
+	Expr   string
+	Offset int // byte offset of the problem in the expression
+	msg    string
+}
+
+// Description of the problem, including its position in the expression.
+func (this *ExpressionError) Error() string {
+	return fmt.Sprintf("%s at offset %d in expression %q", this.msg, this.Offset, this.Expr)
+}
+
+// An Expression is a parsed expression which can be evaluated against many Values.
+//
+// The expression language supports:
+//
+//         1. Literals: numbers, 'single' or "double" quoted strings, TRUE, FALSE and NULL.
+//         2. Paths: dotted properties and indexes into the document, for example user.name, items[0] or items.0.  Keys which are not identifiers can be `backquoted`.
+//         3. Comparisons: = (or ==), != (or <>), <, <=, > and >=, ordered as by Value.Compare().
+//         4. Arithmetic: +, -, *, / and %.  The + operator also concatenates strings.
+//         5. Logic: AND (or &&), OR (or ||) and NOT (or !), and parentheses for grouping.
+//         6. Function calls: NAME(arg, ...) for any function registered with RegisterFunction().
+//         7. Parameters: $1, $2, ... or $name, bound when evaluating a PreparedExpr.
+//
+// Keywords are case-insensitive.  A path which is not defined in the document evaluates to missing.
+// Comparisons and arithmetic involving missing are missing, involving null (or mismatched types
+// for arithmetic) are null.
+type Expression struct {
+	expr string
+	root exprNode
+}
+
+// Parse an expression.  If the expression is malformed, the return error is *ExpressionError.
+func ParseExpression(expr string) (*Expression, error) {
+	tokens, err := lexExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	parser := exprParser{expr: expr, tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek().kind != tokEOF {
+		return nil, parser.errorf("unexpected %q", parser.peek().text)
+	}
+	return &Expression{expr: expr, root: root}, nil
+}
+
+// Parse and evaluate an expression against a document in one step.
+func Eval(expr string, doc *Value) (*Value, error) {
+	e, err := ParseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(doc)
+}
+
+// The source text of this Expression.
+func (this *Expression) String() string {
+	return this.expr
+}
+
+// Evaluate this Expression against a document.
+// If the expression evaluates to missing, the return value is nil, and the return error is *Undefined.
+func (this *Expression) Eval(doc *Value) (*Value, error) {
+	return this.eval(&evalContext{doc: doc})
+}
+
+// Determine if this Expression evaluates to true for a document.
+// Any other result, including missing, is treated as false.
+func (this *Expression) Matches(doc *Value) (bool, error) {
+	return this.matches(&evalContext{doc: doc})
+}
+
+func (this *Expression) matches(ctx *evalContext) (bool, error) {
+	rv, err := this.root.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	return isTrue(rv), nil
+}
+
+func (this *Expression) eval(ctx *evalContext) (*Value, error) {
+	rv, err := this.root.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rv == nil {
+		return nil, &Undefined{}
+	}
+	return unshared(rv), nil
+}
+
+// evalContext carries the state of a single evaluation.  Variables are
+// consulted before the document for the first step of a path.
+type evalContext struct {
+	doc        *Value
+	vars       map[string]*Value
+	positional []*Value
+	named      map[string]*Value
+	trace      *Trace
+	compare    CompareOptions
+}
+
+// exprNode is a node of a parsed expression.  A nil result with a nil error
+// means the node evaluated to missing.
+type exprNode interface {
+	eval(ctx *evalContext) (*Value, error)
+}
+
+func isTrue(val *Value) bool {
+	if val == nil || val.Type() != BOOLEAN {
+		return false
+	}
+	b, _ := val.Value().(bool)
+	return b
+}
+
+type literalNode struct {
+	val *Value
+}
+
+func (this *literalNode) eval(ctx *evalContext) (*Value, error) {
+	return this.val, nil
+}
+
+type pathStep struct {
+	key     string
+	index   int
+	numeric bool // the step may index into an array
+}
+
+type pathNode struct {
+	steps []pathStep
+}
+
+func (this *pathNode) eval(ctx *evalContext) (*Value, error) {
+	cur, steps := ctx.doc, this.steps
+	if v, ok := ctx.vars[steps[0].key]; ok {
+		ctx.trace.begin(steps[0].key).found(TRACE_VARIABLE)
+		cur, steps = v, steps[1:]
+	}
+	if len(steps) > 1 && ctx.trace == nil && cur != nil && cur.canFindNested(steps[0].key) {
+		keys := make([]string, len(steps))
+		for i, step := range steps {
+			keys[i] = step.key
+		}
+		if rv, ok, err := cur.findNested(keys); ok {
+			if _, undefined := err.(*Undefined); undefined {
+				return nil, nil
+			}
+			return rv, err
+		}
+	}
+	for _, step := range steps {
+		if cur == nil {
+			return nil, nil
+		}
+		var err error
+		if step.numeric && cur.Type() == ARRAY {
+			cur, err = cur.index(step.index, ctx.trace)
+		} else {
+			cur, err = cur.path(step.key, ctx.trace)
+		}
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	return cur, nil
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (this *notNode) eval(ctx *evalContext) (*Value, error) {
+	val, err := this.operand.eval(ctx)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	if val.Type() != BOOLEAN {
+		return singletonValue(nil), nil
+	}
+	return singletonValue(!isTrue(val)), nil
+}
+
+type logicalNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (this *logicalNode) eval(ctx *evalContext) (*Value, error) {
+	left, err := this.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	if isTrue(left) != this.and {
+		return singletonValue(!this.and), nil
+	}
+	right, err := this.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return singletonValue(isTrue(right)), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (this *compareNode) eval(ctx *evalContext) (*Value, error) {
+	left, right, err := evalOperands(ctx, this.left, this.right)
+	if err != nil || left == nil || right == nil {
+		return nil, err
+	}
+	if left.Type() == NULL || right.Type() == NULL {
+		return singletonValue(nil), nil
+	}
+	return singletonValue(compared(this.op, left.CompareWithOptions(right, ctx.compare))), nil
+}
+
+// compared determines if the result of Compare() satisfies the comparison operator op.
+func compared(op string, cmp int) bool {
+	switch op {
+	case "=", "==":
+		return cmp == 0
+	case "!=", "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	panic(fmt.Sprintf("unexpected comparison operator %s", op))
+}
+
+type arithNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (this *arithNode) eval(ctx *evalContext) (*Value, error) {
+	left, right, err := evalOperands(ctx, this.left, this.right)
+	if err != nil || left == nil || right == nil {
+		return nil, err
+	}
+	if this.op == "+" && left.Type() == STRING && right.Type() == STRING {
+		return NewValue(left.Value().(string) + right.Value().(string)), nil
+	}
+	if left.Type() != NUMBER || right.Type() != NUMBER {
+		return singletonValue(nil), nil
+	}
+	l, r := nativeNumber(left.Value()), nativeNumber(right.Value())
+	switch this.op {
+	case "+":
+		return numberResult(l + r), nil
+	case "-":
+		return numberResult(l - r), nil
+	case "*":
+		return numberResult(l * r), nil
+	case "/":
+		if r == 0 {
+			return singletonValue(nil), nil
+		}
+		return numberResult(l / r), nil
+	case "%":
+		if r == 0 {
+			return singletonValue(nil), nil
+		}
+		return numberResult(math.Mod(l, r)), nil
+	}
+	panic(fmt.Sprintf("unexpected arithmetic operator %s", this.op))
+}
+
+// numberResult brings the result of arithmetic into the type system, the
+// result is null if it overflowed.
+func numberResult(f float64) *Value {
+	if isNonFinite(f) {
+		return singletonValue(nil)
+	}
+	return NewValue(f)
+}
+
+type negNode struct {
+	operand exprNode
+}
+
+func (this *negNode) eval(ctx *evalContext) (*Value, error) {
+	val, err := this.operand.eval(ctx)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	if val.Type() != NUMBER {
+		return singletonValue(nil), nil
+	}
+	return NewValue(-nativeNumber(val.Value())), nil
+}
+
+func evalOperands(ctx *evalContext, left, right exprNode) (*Value, *Value, error) {
+	l, err := left.eval(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := right.eval(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+// The kinds of tokens in an expression
+const (
+	tokEOF = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokQuotedIdent
+	tokParam
+	tokOp
+)
+
+type exprToken struct {
+	kind   int
+	text   string // the literal value for strings and identifiers
+	offset int
+}
+
+func lexExpression(expr string) ([]exprToken, error) {
+	rv := make([]exprToken, 0)
+	afterDot := func() bool {
+		return len(rv) > 0 && rv[len(rv)-1].kind == tokOp && rv[len(rv)-1].text == "."
+	}
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c >= '0' && c <= '9':
+			i = lexNumber(expr, i, !afterDot())
+			rv = append(rv, exprToken{tokNumber, expr[start:i], start})
+		case c == '\'' || c == '"' || c == '`':
+			s, end, err := lexQuoted(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			kind := tokString
+			if c == '`' {
+				kind = tokQuotedIdent
+			}
+			rv = append(rv, exprToken{kind, s, start})
+		case c == '$':
+			i++
+			for i < len(expr) && (isIdentStart(expr[i]) || (expr[i] >= '0' && expr[i] <= '9')) {
+				i++
+			}
+			if i == start+1 {
+				return nil, &ExpressionError{expr, start, "expected parameter name after $"}
+			}
+			rv = append(rv, exprToken{tokParam, expr[start+1 : i], start})
+		case isIdentStart(c):
+			for i < len(expr) && (isIdentStart(expr[i]) || (expr[i] >= '0' && expr[i] <= '9')) {
+				i++
+			}
+			rv = append(rv, exprToken{tokIdent, expr[start:i], start})
+		default:
+			if i+1 < len(expr) {
+				switch expr[i : i+2] {
+				case "==", "!=", "<>", "<=", ">=", "&&", "||":
+					i += 2
+					rv = append(rv, exprToken{tokOp, expr[start:i], start})
+					continue
+				}
+			}
+			if !strings.ContainsRune("=<>+-*/%!().[],", rune(c)) {
+				return nil, &ExpressionError{expr, i, fmt.Sprintf("unexpected character %q", c)}
+			}
+			i++
+			rv = append(rv, exprToken{tokOp, expr[start:i], start})
+		}
+	}
+	rv = append(rv, exprToken{tokEOF, "", len(expr)})
+	return rv, nil
+}
+
+// lexNumber returns the end of the number starting at i.  If fractions are not
+// allowed (as for a path step like items.0) only the integer part is consumed.
+func lexNumber(expr string, i int, fractions bool) int {
+	digits := func() {
+		for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+			i++
+		}
+	}
+	digits()
+	if !fractions {
+		return i
+	}
+	if i+1 < len(expr) && expr[i] == '.' && expr[i+1] >= '0' && expr[i+1] <= '9' {
+		i++
+		digits()
+	}
+	if i < len(expr) && (expr[i] == 'e' || expr[i] == 'E') {
+		j := i + 1
+		if j < len(expr) && (expr[j] == '+' || expr[j] == '-') {
+			j++
+		}
+		if j < len(expr) && expr[j] >= '0' && expr[j] <= '9' {
+			i = j
+			digits()
+		}
+	}
+	return i
+}
+
+// lexQuoted returns the unescaped contents of the quoted text starting at i,
+// and the offset following the closing quote.
+func lexQuoted(expr string, i int) (string, int, error) {
+	quote := expr[i]
+	var buf strings.Builder
+	for j := i + 1; j < len(expr); j++ {
+		switch expr[j] {
+		case quote:
+			return buf.String(), j + 1, nil
+		case '\\':
+			j++
+			if j >= len(expr) {
+				break
+			}
+			switch expr[j] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			default:
+				buf.WriteByte(expr[j])
+			}
+		default:
+			buf.WriteByte(expr[j])
+		}
+	}
+	return "", 0, &ExpressionError{expr, i, "unterminated quoted text"}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type exprParser struct {
+	expr   string
+	tokens []exprToken
+	pos    int
+}
+
+func (this *exprParser) peek() exprToken {
+	return this.tokens[this.pos]
+}
+
+func (this *exprParser) next() exprToken {
+	rv := this.tokens[this.pos]
+	if rv.kind != tokEOF {
+		this.pos++
+	}
+	return rv
+}
+
+func (this *exprParser) errorf(format string, args ...interface{}) error {
+	return &ExpressionError{this.expr, this.peek().offset, fmt.Sprintf(format, args...)}
+}
+
+// isOp determines if the next token is one of the specified operators
+func (this *exprParser) isOp(ops ...string) bool {
+	tok := this.peek()
+	if tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeyword determines if the next token is the specified keyword
+func (this *exprParser) isKeyword(keyword string) bool {
+	tok := this.peek()
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (this *exprParser) expectOp(op string) error {
+	if !this.isOp(op) {
+		return this.errorf("expected %q", op)
+	}
+	this.next()
+	return nil
+}
+
+func (this *exprParser) parseOr() (exprNode, error) {
+	left, err := this.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for this.isKeyword("OR") || this.isOp("||") {
+		this.next()
+		right, err := this.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{false, left, right}
+	}
+	return left, nil
+}
+
+func (this *exprParser) parseAnd() (exprNode, error) {
+	left, err := this.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for this.isKeyword("AND") || this.isOp("&&") {
+		this.next()
+		right, err := this.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{true, left, right}
+	}
+	return left, nil
+}
+
+func (this *exprParser) parseNot() (exprNode, error) {
+	if this.isKeyword("NOT") || this.isOp("!") {
+		this.next()
+		operand, err := this.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+	return this.parseComparison()
+}
+
+func (this *exprParser) parseComparison() (exprNode, error) {
+	left, err := this.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if this.isOp("=", "==", "!=", "<>", "<", "<=", ">", ">=") {
+		op := this.next().text
+		right, err := this.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op, left, right}, nil
+	}
+	return left, nil
+}
+
+func (this *exprParser) parseAdditive() (exprNode, error) {
+	left, err := this.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for this.isOp("+", "-") {
+		op := this.next().text
+		right, err := this.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op, left, right}
+	}
+	return left, nil
+}
+
+func (this *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := this.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for this.isOp("*", "/", "%") {
+		op := this.next().text
+		right, err := this.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op, left, right}
+	}
+	return left, nil
+}
+
+func (this *exprParser) parseUnary() (exprNode, error) {
+	if this.isOp("-") {
+		this.next()
+		operand, err := this.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{operand}, nil
+	}
+	return this.parsePrimary()
+}
+
+func (this *exprParser) parsePrimary() (exprNode, error) {
+	tok := this.peek()
+	switch tok.kind {
+	case tokNumber:
+		this.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, &ExpressionError{this.expr, tok.offset, "invalid number"}
+		}
+		return &literalNode{NewValue(f)}, nil
+	case tokString:
+		this.next()
+		return &literalNode{NewValue(tok.text)}, nil
+	case tokIdent:
+		switch strings.ToUpper(tok.text) {
+		case "TRUE":
+			this.next()
+			return &literalNode{singletonValue(true)}, nil
+		case "FALSE":
+			this.next()
+			return &literalNode{singletonValue(false)}, nil
+		case "NULL":
+			this.next()
+			return &literalNode{singletonValue(nil)}, nil
+		}
+		if this.tokens[this.pos+1].kind == tokOp && this.tokens[this.pos+1].text == "(" {
+			return this.parseCall()
+		}
+		return this.parsePath()
+	case tokQuotedIdent:
+		return this.parsePath()
+	case tokParam:
+		this.next()
+		position, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return &paramNode{name: tok.text}, nil
+		}
+		if position < 1 {
+			return nil, &ExpressionError{this.expr, tok.offset, "parameter positions start at $1"}
+		}
+		return &paramNode{name: tok.text, position: position}, nil
+	case tokOp:
+		if tok.text == "(" {
+			this.next()
+			rv, err := this.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return rv, this.expectOp(")")
+		}
+	case tokEOF:
+		return nil, this.errorf("unexpected end of expression")
+	}
+	return nil, this.errorf("unexpected %q", tok.text)
+}
+
+func (this *exprParser) parseCall() (exprNode, error) {
+	tok := this.next()
+	fn, ok := lookupFunction(tok.text)
+	if !ok {
+		return nil, &ExpressionError{this.expr, tok.offset, fmt.Sprintf("unknown function %s", tok.text)}
+	}
+	rv := callNode{name: strings.ToUpper(tok.text), fn: fn}
+	this.next()
+	for !this.isOp(")") {
+		if len(rv.args) > 0 {
+			if err := this.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := this.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		rv.args = append(rv.args, arg)
+	}
+	this.next()
+	if !fn.accepts(len(rv.args)) {
+		return nil, &ExpressionError{this.expr, tok.offset, fmt.Sprintf("wrong number of arguments to %s", rv.name)}
+	}
+	return &rv, nil
+}
+
+func (this *exprParser) parsePath() (exprNode, error) {
+	rv := pathNode{steps: []pathStep{{key: this.next().text}}}
+	for {
+		if this.isOp(".") {
+			this.next()
+			tok := this.next()
+			switch tok.kind {
+			case tokIdent, tokQuotedIdent:
+				rv.steps = append(rv.steps, pathStep{key: tok.text})
+			case tokNumber:
+				index, _ := strconv.Atoi(tok.text)
+				rv.steps = append(rv.steps, pathStep{tok.text, index, true})
+			default:
+				return nil, &ExpressionError{this.expr, tok.offset, "expected property after ."}
+			}
+		} else if this.isOp("[") {
+			this.next()
+			tok := this.next()
+			switch tok.kind {
+			case tokNumber:
+				index, err := strconv.Atoi(tok.text)
+				if err != nil {
+					return nil, &ExpressionError{this.expr, tok.offset, "expected integer index"}
+				}
+				rv.steps = append(rv.steps, pathStep{tok.text, index, true})
+			case tokString:
+				rv.steps = append(rv.steps, pathStep{key: tok.text})
+			default:
+				return nil, &ExpressionError{this.expr, tok.offset, "expected index or quoted property"}
+			}
+			if err := this.expectOp("]"); err != nil {
+				return nil, err
+			}
+		} else {
+			return &rv, nil
+		}
+	}
+}
@@ -0,0 +1,107 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name":"marty","age":35,"tags":["a","b"],"address":{"city":"Mountain View"},"spaced key":1.5,"nothing":null}`))
+
+	var tests = []struct {
+		expr   string
+		output interface{}
+	}{
+		{`name`, "marty"},
+		{`age + 5`, 40.0},
+		{`age * 2 - 10 / 5`, 68.0},
+		{`-age % 10`, -5.0},
+		{`(age + 5) * 2`, 80.0},
+		{`name + " schoch"`, "marty schoch"},
+		{`tags[1]`, "b"},
+		{`tags.0`, "a"},
+		{`address.city = 'Mountain View'`, true},
+		{`address["city"] != "Mountain View"`, false},
+		{"`spaced key` >= 1.5", true},
+		{`age > 30 AND name = "marty"`, true},
+		{`age < 30 or tags[0] == "a"`, true},
+		{`NOT (age < 30)`, true},
+		{`!true || false`, false},
+		{`nothing = 1`, nil},
+		{`age / 0`, nil},
+		{`age + "x"`, nil},
+		{`name > 1`, true},
+		{`2.5e1`, 25.0},
+	}
+
+	for _, test := range tests {
+		result, err := Eval(test.expr, doc)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.expr)
+			continue
+		}
+		if !reflect.DeepEqual(result.Value(), test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, result.Value(), test.expr)
+		}
+	}
+}
+
+func TestEvalMissing(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"a":{"b":1}}`))
+	for _, expr := range []string{`c`, `a.c`, `a.b.c`, `c + 1`, `c = 1`, `NOT c`} {
+		result, err := Eval(expr, doc)
+		if _, ok := err.(*Undefined); !ok || result != nil {
+			t.Errorf("Expected missing for %s, got %v, %v", expr, result, err)
+		}
+	}
+
+	// without a document every path is missing, however many steps it has
+	for _, expr := range []string{`a`, `a.b`, `a.b.c`} {
+		result, err := Eval(expr, nil)
+		if _, ok := err.(*Undefined); !ok || result != nil {
+			t.Errorf("Expected missing for %s without a document, got %v, %v", expr, result, err)
+		}
+	}
+
+	e, _ := ParseExpression(`c = 1 OR a.b = 1`)
+	matches, err := e.Matches(doc)
+	if err != nil || !matches {
+		t.Errorf("Expected match, got %v, %v", matches, err)
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	var tests = []struct {
+		expr   string
+		offset int
+	}{
+		{`a +`, 3},
+		{`(a`, 2},
+		{`a # b`, 2},
+		{`"abc`, 0},
+		{`a b`, 2},
+		{`a.`, 2},
+		{`a[b]`, 2},
+	}
+
+	for _, test := range tests {
+		_, err := ParseExpression(test.expr)
+		exprErr, ok := err.(*ExpressionError)
+		if !ok {
+			t.Errorf("Expected *ExpressionError for %s, got %v", test.expr, err)
+			continue
+		}
+		if exprErr.Offset != test.offset {
+			t.Errorf("Expected offset %d, got %d for %s", test.offset, exprErr.Offset, test.expr)
+		}
+	}
+}
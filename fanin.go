@@ -0,0 +1,118 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// The policies FanIn() uses to choose between sources which have Values ready
+const (
+	FAN_IN_FAIR     = iota // sources take turns, so every source with a Value ready is served in turn (the default)
+	FAN_IN_PRIORITY        // the earliest source in the list with a Value ready is always served first
+)
+
+// FanInOptions control how FanIn() combines its sources.
+type FanInOptions struct {
+	Policy int // FAN_IN_FAIR or FAN_IN_PRIORITY
+}
+
+// FanInStats count the Values received from each source of FanIn().  They may be read
+// while FanIn() is running.
+type FanInStats struct {
+	received []uint64
+	closed   []int32
+}
+
+// The number of Values received from source i.
+func (this *FanInStats) Received(i int) uint64 {
+	return atomic.LoadUint64(&this.received[i])
+}
+
+// Determine if source i has been closed.
+func (this *FanInStats) Closed(i int) bool {
+	return atomic.LoadInt32(&this.closed[i]) != 0
+}
+
+// Combine the Values received on chs into a single channel, which is closed once every
+// source is closed.  A Value is only received from a source once the previous Value has
+// been sent on, so a slow consumer holds back every source rather than Values piling up.
+//
+// When more than one source has a Value ready, the source is chosen by opts.Policy.
+// Unlike a plain select, which chooses at random, FAN_IN_FAIR guarantees that a source
+// with a Value ready waits for at most one Value from each other source.
+func FanIn(chs []ValueChannel, opts FanInOptions) (ValueChannel, *FanInStats) {
+	rv := make(ValueChannel)
+	stats := FanInStats{
+		received: make([]uint64, len(chs)),
+		closed:   make([]int32, len(chs)),
+	}
+	go func() {
+		defer close(rv)
+		open := len(chs)
+		next := 0
+		for open > 0 {
+			source, val, ok := fanInReady(chs, next, opts.Policy)
+			if source < 0 {
+				source, val, ok = fanInWait(chs)
+			}
+			if !ok {
+				chs[source] = nil
+				atomic.StoreInt32(&stats.closed[source], 1)
+				open--
+				continue
+			}
+			atomic.AddUint64(&stats.received[source], 1)
+			if opts.Policy == FAN_IN_FAIR {
+				next = (source + 1) % len(chs)
+			}
+			rv <- val
+		}
+	}()
+	return rv, &stats
+}
+
+// fanInReady receives from the first source with a Value ready (or closed), starting
+// with source start for FAN_IN_FAIR, or the first source for FAN_IN_PRIORITY.  If no
+// source is ready, the returned source is -1.
+func fanInReady(chs []ValueChannel, start int, policy int) (int, *Value, bool) {
+	if policy == FAN_IN_PRIORITY {
+		start = 0
+	}
+	for i := range chs {
+		source := (start + i) % len(chs)
+		if chs[source] == nil {
+			continue
+		}
+		select {
+		case val, ok := <-chs[source]:
+			return source, val, ok
+		default:
+		}
+	}
+	return -1, nil, false
+}
+
+// fanInWait blocks until any open source has a Value ready (or is closed).
+func fanInWait(chs []ValueChannel) (int, *Value, bool) {
+	cases := make([]reflect.SelectCase, len(chs))
+	for i, ch := range chs {
+		cases[i].Dir = reflect.SelectRecv
+		if ch != nil {
+			cases[i].Chan = reflect.ValueOf(ch)
+		}
+	}
+	source, val, ok := reflect.Select(cases)
+	if !ok {
+		return source, nil, false
+	}
+	return source, val.Interface().(*Value), true
+}
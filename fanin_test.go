@@ -0,0 +1,65 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+// bufferedSource returns a closed channel holding n copies of val.
+func bufferedSource(val string, n int) ValueChannel {
+	rv := make(ValueChannel, n)
+	for i := 0; i < n; i++ {
+		rv <- NewValue(val)
+	}
+	close(rv)
+	return rv
+}
+
+func TestFanInFair(t *testing.T) {
+	out, stats := FanIn([]ValueChannel{bufferedSource("a", 4), bufferedSource("b", 2)}, FanInOptions{})
+	var actual []interface{}
+	for val := range out {
+		actual = append(actual, val.Value())
+	}
+	expected := []interface{}{"a", "b", "a", "b", "a", "a"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if stats.Received(0) != 4 || stats.Received(1) != 2 || !stats.Closed(0) || !stats.Closed(1) {
+		t.Errorf("Unexpected stats %d %d", stats.Received(0), stats.Received(1))
+	}
+}
+
+func TestFanInPriority(t *testing.T) {
+	out, _ := FanIn([]ValueChannel{bufferedSource("a", 2), bufferedSource("b", 2)}, FanInOptions{Policy: FAN_IN_PRIORITY})
+	var actual []interface{}
+	for val := range out {
+		actual = append(actual, val.Value())
+	}
+	expected := []interface{}{"a", "a", "b", "b"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	// sources which are not yet ready are waited for
+	slow := make(ValueChannel)
+	go func() {
+		slow <- NewValue("s")
+		close(slow)
+	}()
+	out, stats := FanIn([]ValueChannel{slow}, FanInOptions{Policy: FAN_IN_PRIORITY})
+	for range out {
+	}
+	if stats.Received(0) != 1 {
+		t.Errorf("Expected 1 value from the slow source, got %d", stats.Received(0))
+	}
+}
@@ -0,0 +1,94 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"io"
+)
+
+// A Mutation is a change to a document, as delivered by a change feed such as DCP
+// or any other change data capture stream.
+type Mutation struct {
+	Key     string
+	Body    []byte // the new body of the document, ignored for deletions
+	Seqno   uint64 // the sequence number of the change
+	Cas     uint64 // the compare-and-swap value of the document after the change
+	Expiry  uint32 // when the document expires, in seconds since the Unix epoch, 0 means never
+	Deleted bool   // the document was deleted
+}
+
+// A Feed is a source of Mutations.  Next returns the next Mutation, or io.EOF
+// once the feed has ended.
+type Feed interface {
+	Next() (*Mutation, error)
+}
+
+// The attachment key under which the Meta of a document from a Feed is stored.
+const META_ATTACHMENT = "meta"
+
+// Meta describes the document a Value was created from, as delivered by a Feed.
+type Meta struct {
+	Key     string
+	Seqno   uint64
+	Cas     uint64
+	Expiry  uint32
+	Deleted bool
+}
+
+// Return the Meta attached to this Value by FeedChannel(), or nil if there is none.
+func (this *Value) Meta() *Meta {
+	meta, _ := this.GetAttachment(META_ATTACHMENT).(*Meta)
+	return meta
+}
+
+// Create a Value for a Mutation, with its Meta attached.  A deletion is a tombstone:
+// a Value of type NULL whose Meta has Deleted set.
+func NewValueFromMutation(m *Mutation) *Value {
+	var rv *Value
+	if m.Deleted {
+		rv = NewValue(nil)
+	} else {
+		rv = NewValueFromBytes(m.Body)
+	}
+	rv.SetAttachment(META_ATTACHMENT, &Meta{
+		Key:     m.Key,
+		Seqno:   m.Seqno,
+		Cas:     m.Cas,
+		Expiry:  m.Expiry,
+		Deleted: m.Deleted,
+	})
+	return rv
+}
+
+// Read every Mutation from feed, sending a Value for each (see NewValueFromMutation())
+// on the returned channel, which is closed when the feed ends.  Once the channel is
+// closed, the returned function returns the error which ended the feed, or nil if it
+// ended with io.EOF.
+func FeedChannel(feed Feed) (ValueChannel, func() error) {
+	rv := make(ValueChannel)
+	var err error
+	go func() {
+		defer close(rv)
+		for {
+			var m *Mutation
+			m, err = feed.Next()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				return
+			}
+			rv <- NewValueFromMutation(m)
+		}
+	}()
+	return rv, func() error {
+		return err
+	}
+}
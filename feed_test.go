@@ -0,0 +1,74 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type testFeed struct {
+	mutations []*Mutation
+	err       error
+}
+
+func (this *testFeed) Next() (*Mutation, error) {
+	if len(this.mutations) == 0 {
+		return nil, this.err
+	}
+	m := this.mutations[0]
+	this.mutations = this.mutations[1:]
+	return m, nil
+}
+
+func TestFeedChannel(t *testing.T) {
+	feed := &testFeed{
+		mutations: []*Mutation{
+			{Key: "a", Body: []byte(`{"n":1}`), Seqno: 1, Cas: 100},
+			{Key: "b", Body: []byte(`{"n":2}`), Seqno: 2, Cas: 101, Expiry: 1700000000},
+			{Key: "a", Seqno: 3, Cas: 102, Deleted: true},
+		},
+		err: io.EOF,
+	}
+	ch, errFn := FeedChannel(feed)
+	var metas []Meta
+	var values []interface{}
+	for val := range ch {
+		metas = append(metas, *val.Meta())
+		values = append(values, val.Value())
+	}
+	if errFn() != nil {
+		t.Errorf("Unexpected error %v", errFn())
+	}
+	expected := []Meta{
+		{Key: "a", Seqno: 1, Cas: 100},
+		{Key: "b", Seqno: 2, Cas: 101, Expiry: 1700000000},
+		{Key: "a", Seqno: 3, Cas: 102, Deleted: true},
+	}
+	if !reflect.DeepEqual(metas, expected) {
+		t.Errorf("Expected %v, got %v", expected, metas)
+	}
+	if !reflect.DeepEqual(values, []interface{}{map[string]interface{}{"n": 1.0}, map[string]interface{}{"n": 2.0}, nil}) {
+		t.Errorf("Unexpected values %v", values)
+	}
+
+	ch, errFn = FeedChannel(&testFeed{err: fmt.Errorf("connection lost")})
+	for range ch {
+	}
+	if errFn() == nil || errFn().Error() != "connection lost" {
+		t.Errorf("Expected connection lost, got %v", errFn())
+	}
+
+	if NewValue(1.0).Meta() != nil {
+		t.Errorf("Expected no meta")
+	}
+}
@@ -0,0 +1,42 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+// Create a new Value with the same shape as doc, where every leaf is replaced by the name of
+// its type ("null", "boolean", "number" or "string").  Objects and arrays are kept, so the
+// result describes which fields exist without containing any of their data, for example:
+//
+//         {"name":"marty","tags":["a",1]} => {"name":"string","tags":["string","number"]}
+//
+// If doc is NOT_JSON, the result is the string "not_json".
+func FieldMask(doc *Value) *Value {
+	if doc.Type() == NOT_JSON {
+		return NewValue(typeNames[NOT_JSON])
+	}
+	return NewValue(fieldMask(doc.Value()))
+}
+
+func fieldMask(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			rv[k] = fieldMask(v)
+		}
+		return rv
+	case []interface{}:
+		rv := make([]interface{}, len(val))
+		for i, v := range val {
+			rv[i] = fieldMask(v)
+		}
+		return rv
+	}
+	return typeNames[nativeType(val)]
+}
@@ -0,0 +1,34 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestFieldMask(t *testing.T) {
+	var tests = []struct {
+		input  string
+		output string
+	}{
+		{`{"name":"marty","tags":["a",1],"address":{"zip":null,"po":false},"empty":{}}`,
+			`{"address":{"po":"boolean","zip":"null"},"empty":{},"name":"string","tags":["string","number"]}`},
+		{`[]`, `[]`},
+		{`3.5`, `"number"`},
+		{`abc`, `"not_json"`},
+	}
+
+	for _, test := range tests {
+		result := FieldMask(NewValueFromBytes([]byte(test.input)))
+		if string(result.Bytes()) != test.output {
+			t.Errorf("Expected %s, got %s", test.output, result.Bytes())
+		}
+	}
+}
@@ -0,0 +1,173 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"sort"
+
+	json "github.com/dustin/gojson"
+)
+
+// If this Value is of type OBJECT, return its keys (including any set with SetPath()).
+// If this Value is not of type OBJECT, nil is returned.
+//
+// The keys are sorted, unless the Value was created with the DOCUMENT_ORDER_KEYS option,
+// in which case they are in the order they appear in the raw bytes, followed by keys
+// added with SetPath() in the order they were added.  With this option Bytes() also
+// writes the keys in this order.
+func (this *Value) Fields() []string {
+	if this.parsedType != OBJECT {
+		return nil
+	}
+	if this.documentOrder() {
+		this.initOrder()
+		members := this.members()
+		rv := make([]string, 0, len(this.order))
+		for _, k := range this.order {
+			if _, ok := members[k]; ok {
+				rv = append(rv, k)
+			}
+		}
+		return rv
+	}
+	members := this.members()
+	rv := make([]string, 0, len(members))
+	for k := range members {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func (this *Value) documentOrder() bool {
+	return this.options != nil && this.options.KeyOrder == DOCUMENT_ORDER_KEYS
+}
+
+// members returns the properties of this OBJECT, with any aliases applied.
+// Properties which are NOT_JSON are omitted, as they are by Value().
+func (this *Value) members() map[string]*Value {
+	rv := make(map[string]*Value)
+	switch parsedValue := this.parsedValue.(type) {
+	case map[string]*Value:
+		for k, v := range parsedValue {
+			rv[k] = v
+		}
+	default:
+		if parsedValue, ok := parsedValue.(map[string]interface{}); ok && this.raw == nil {
+			// the raw bytes were dropped after parsing
+			for k, v := range parsedValue {
+				rv[k] = this.newParsedChild(v)
+			}
+			break
+		}
+		keys, values, err := objectMembers(this.raw)
+		if err != nil {
+			panic("unexpected scan error on valid JSON")
+		}
+		interner := this.keyInterner()
+		for i, k := range keys {
+			if interner != nil {
+				k = interner.Intern(k)
+			}
+			rv[k] = this.newChild(values[i])
+		}
+	}
+	for k, v := range this.overlay() {
+		rv[k] = v
+	}
+	for k, v := range rv {
+		if v.Type() == NOT_JSON {
+			delete(rv, k)
+		}
+	}
+	return rv
+}
+
+// replaceMembers replaces the contents of this OBJECT with members.  Keys in document
+// order keep their place, those missing from members are no longer written.
+func (this *Value) replaceMembers(members map[string]*Value) {
+	this.checkMutable()
+	if this.documentOrder() {
+		this.initOrder()
+	}
+	this.raw = nil
+	this.alias = nil
+	this.children = nil
+	this.parsedValue = members
+}
+
+// initOrder records the order of the keys of this OBJECT, if it has not
+// already been recorded.  This must happen before any keys are added.
+func (this *Value) initOrder() {
+	if this.order != nil {
+		return
+	}
+	this.order = make([]string, 0)
+	seen := make(map[string]bool)
+	switch parsedValue := this.parsedValue.(type) {
+	case map[string]*Value:
+		for k := range parsedValue {
+			this.order = append(this.order, k)
+		}
+		sort.Strings(this.order)
+	default:
+		keys, _, err := objectMembers(this.raw)
+		if err != nil {
+			panic("unexpected scan error on valid JSON")
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				this.order = append(this.order, k)
+			}
+		}
+	}
+}
+
+// trackKey records a key set with SetPath(), if keys are in document order.
+func (this *Value) trackKey(key string) {
+	if !this.documentOrder() {
+		return
+	}
+	this.initOrder()
+	for _, k := range this.order {
+		if k == key {
+			return
+		}
+	}
+	this.order = append(this.order, key)
+}
+
+// orderedBytes serializes this OBJECT with its keys in document order.
+func (this *Value) orderedBytes() []byte {
+	this.initOrder()
+	members := this.members()
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, k := range this.order {
+		v, ok := members[k]
+		if !ok {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			panic("unexpected marshall error on valid data")
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(v.encode())
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
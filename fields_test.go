@@ -0,0 +1,52 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	input := []byte(`{"z":1,"a":{"y":2,"b":3},"m":null}`)
+
+	val := NewValueFromBytes(input)
+	val.SetPath("c", 4.0)
+	expected := []string{"a", "c", "m", "z"}
+	if !reflect.DeepEqual(val.Fields(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Fields())
+	}
+
+	val, _ = NewValueFromBytesWithOptions(input, ParseOptions{KeyOrder: DOCUMENT_ORDER_KEYS})
+	val.SetPath("c", 4.0)
+	val.SetPath("a", map[string]interface{}{"x": 5.0})
+	expected = []string{"z", "a", "m", "c"}
+	if !reflect.DeepEqual(val.Fields(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Fields())
+	}
+	if string(val.Bytes()) != `{"z":1,"a":{"x":5},"m":null,"c":4}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+
+	// nested objects keep their document order
+	val, _ = NewValueFromBytesWithOptions(input, ParseOptions{KeyOrder: DOCUMENT_ORDER_KEYS})
+	nested, _ := val.Path("a")
+	if !reflect.DeepEqual(nested.Fields(), []string{"y", "b"}) {
+		t.Errorf("Expected [y b], got %v", nested.Fields())
+	}
+	val.SetPath("n", true)
+	if string(val.Bytes()) != `{"z":1,"a":{"y":2,"b":3},"m":null,"n":true}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+
+	if NewValue("x").Fields() != nil {
+		t.Errorf("Expected nil fields for a string")
+	}
+}
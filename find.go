@@ -0,0 +1,70 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+)
+
+// Return the value of every property named key at any depth inside this Value, in document
+// order, with the properties of an object in the order of Fields() and a property before any
+// found inside it.  For example Find("id") returns every "id", however deeply it is nested.
+//
+// Parts of the document held as raw bytes which cannot contain the key are skipped without
+// creating Values for their contents.
+func (this *Value) Find(key string) ValueCollection {
+	var rv ValueCollection
+	this.find(key, quotedKey(key), &rv)
+	return rv
+}
+
+// quotedKey returns key as it appears in raw bytes which have no escapes, or nil if it
+// cannot appear without escapes.  Unlike json.Marshal(), <, > and & are not escaped.
+func quotedKey(key string) []byte {
+	buf := bytes.Buffer{}
+	encoder := stdjson.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(key)
+	quoted := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if err != nil || bytes.IndexByte(quoted, '\\') >= 0 {
+		return nil
+	}
+	return quoted
+}
+
+func (this *Value) find(key string, quoted []byte, rv *ValueCollection) {
+	switch this.Type() {
+	case OBJECT, ARRAY:
+	default:
+		return
+	}
+	if quoted != nil && this.raw != nil && !this.modified() && bytes.IndexByte(this.raw, '\\') < 0 && !bytes.Contains(this.raw, quoted) {
+		// without escapes, any property named key appears as quoted in the raw bytes
+		return
+	}
+	if this.Type() == OBJECT {
+		for _, k := range this.Fields() {
+			child, err := this.member(k)
+			if err != nil {
+				continue
+			}
+			if k == key {
+				*rv = append(*rv, child)
+			}
+			child.find(key, quoted, rv)
+		}
+		return
+	}
+	elements, _ := this.elements()
+	for _, element := range elements {
+		element.find(key, quoted, rv)
+	}
+}
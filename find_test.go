@@ -0,0 +1,67 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{
+		"id": 1,
+		"user": {"id": 2, "friends": [{"id": 3}, {"name": "x"}, {"id": {"id": 4}}]},
+		"other": {"name": "id"},
+		"escaped": {"\u0069d": 5}
+	}`))
+	actual := []interface{}{}
+	for _, val := range doc.Find("id") {
+		actual = append(actual, val.Value())
+	}
+	expected := []interface{}{5.0, 1.0, 3.0, map[string]interface{}{"id": 4.0}, 4.0, 2.0}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	if found := doc.Find("missing"); len(found) != 0 {
+		t.Errorf("Expected nothing, got %v", found)
+	}
+	if found := NewValue("id").Find("id"); len(found) != 0 {
+		t.Errorf("Expected nothing in a string, got %v", found)
+	}
+}
+
+func TestFindAliases(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"a": {"b": {"c": 1}}, "d": [{"x": 1}]}`))
+	a, _ := doc.Path("a")
+	a.SetPath("b", map[string]interface{}{"id": "new"})
+	d, _ := doc.Path("d")
+	d.SetIndex(0, map[string]interface{}{"id": "element"})
+
+	actual := []interface{}{}
+	for _, val := range doc.Find("id") {
+		actual = append(actual, val.Value())
+	}
+	expected := []interface{}{"new", "element"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestFindSpecialKeys(t *testing.T) {
+	raw := []byte("{\"x\": {\"a<b&c\": 1, \"l\u2028s\": 2}}")
+	for _, key := range []string{"a<b&c", "l\u2028s"} {
+		for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+			if found := doc.Find(key); len(found) != 1 {
+				t.Errorf("Expected to find %q, got %v", key, found)
+			}
+		}
+	}
+}
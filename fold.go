@@ -0,0 +1,129 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The precomposed letters of the Latin and Greek scripts whose canonical decomposition (NFD)
+// is the letter given as the key followed only by combining marks.
+var precomposedLetters = map[rune]string{
+	'A': "ÀÁÂÃÄÅĀĂĄǍǞǠǺȀȂȦḀẠẢẤẦẨẪẬẮẰẲẴẶ",
+	'B': "ḂḄḆ",
+	'C': "ÇĆĈĊČḈ",
+	'D': "ĎḊḌḎḐḒ",
+	'E': "ÈÉÊËĒĔĖĘĚȄȆȨḔḖḘḚḜẸẺẼẾỀỂỄỆ",
+	'F': "Ḟ",
+	'G': "ĜĞĠĢǦǴḠ",
+	'H': "ĤȞḢḤḦḨḪ",
+	'I': "ÌÍÎÏĨĪĬĮİǏȈȊḬḮỈỊ",
+	'J': "Ĵ",
+	'K': "ĶǨḰḲḴ",
+	'L': "ĹĻĽḶḸḺḼ",
+	'M': "ḾṀṂ",
+	'N': "ÑŃŅŇǸṄṆṈṊ",
+	'O': "ÒÓÔÕÖŌŎŐƠǑǪǬȌȎȪȬȮȰṌṎṐṒỌỎỐỒỔỖỘỚỜỞỠỢ",
+	'P': "ṔṖ",
+	'R': "ŔŖŘȐȒṘṚṜṞ",
+	'S': "ŚŜŞŠȘṠṢṤṦṨ",
+	'T': "ŢŤȚṪṬṮṰ",
+	'U': "ÙÚÛÜŨŪŬŮŰŲƯǓǕǗǙǛȔȖṲṴṶṸṺỤỦỨỪỬỮỰ",
+	'V': "ṼṾ",
+	'W': "ŴẀẂẄẆẈ",
+	'X': "ẊẌ",
+	'Y': "ÝŶŸȲẎỲỴỶỸ",
+	'Z': "ŹŻŽẐẒẔ",
+	'a': "àáâãäåāăąǎǟǡǻȁȃȧḁạảấầẩẫậắằẳẵặ",
+	'b': "ḃḅḇ",
+	'c': "çćĉċčḉ",
+	'd': "ďḋḍḏḑḓ",
+	'e': "èéêëēĕėęěȅȇȩḕḗḙḛḝẹẻẽếềểễệ",
+	'f': "ḟ",
+	'g': "ĝğġģǧǵḡ",
+	'h': "ĥȟḣḥḧḩḫẖ",
+	'i': "ìíîïĩīĭįǐȉȋḭḯỉị",
+	'j': "ĵǰ",
+	'k': "ķǩḱḳḵ",
+	'l': "ĺļľḷḹḻḽ",
+	'm': "ḿṁṃ",
+	'n': "ñńņňǹṅṇṉṋ",
+	'o': "òóôõöōŏőơǒǫǭȍȏȫȭȯȱṍṏṑṓọỏốồổỗộớờởỡợ",
+	'p': "ṕṗ",
+	'r': "ŕŗřȑȓṙṛṝṟ",
+	's': "śŝşšșṡṣṥṧṩ",
+	't': "ţťțṫṭṯṱẗ",
+	'u': "ùúûüũūŭůűųưǔǖǘǚǜȕȗṳṵṷṹṻụủứừửữự",
+	'v': "ṽṿ",
+	'w': "ŵẁẃẅẇẉẘ",
+	'x': "ẋẍ",
+	'y': "ýÿŷȳẏẙỳỵỷỹ",
+	'z': "źżžẑẓẕ",
+	'Æ': "ǢǼ",
+	'Ø': "Ǿ",
+	'æ': "ǣǽ",
+	'ø': "ǿ",
+	'ſ': "ẛ",
+	'Ʒ': "Ǯ",
+	'ʒ': "ǯ",
+	'Α': "Ά",
+	'Ε': "Έ",
+	'Η': "Ή",
+	'Ι': "ΊΪ",
+	'Ο': "Ό",
+	'Υ': "ΎΫ",
+	'Ω': "Ώ",
+	'α': "ά",
+	'ε': "έ",
+	'η': "ή",
+	'ι': "ΐίϊ",
+	'ο': "ό",
+	'υ': "ΰϋύ",
+	'ω': "ώ",
+}
+
+// decomposedBase maps each precomposed letter to its base letter.
+var decomposedBase = func() map[rune]rune {
+	rv := make(map[rune]rune)
+	for base, letters := range precomposedLetters {
+		for _, r := range letters {
+			rv[r] = base
+		}
+	}
+	return rv
+}()
+
+// Fold s as the options require, so that strings which differ only in case (FoldCase) or in
+// their accents and other diacritics (FoldDiacritics) fold to the same string.
+//
+// Case folding uses the simple case mappings of Unicode.  Diacritics are removed by decomposing
+// precomposed letters as canonical decomposition (NFD) would, then dropping the combining marks,
+// so "Crème Brûlée" folds to "creme brulee" with both options.  Letters which have no canonical
+// decomposition, such as "ø" or "ł", are kept.
+func FoldString(s string, options CompareOptions) string {
+	if !options.FoldCase && !options.FoldDiacritics {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if options.FoldDiacritics {
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			if base, ok := decomposedBase[r]; ok {
+				r = base
+			}
+		}
+		if options.FoldCase {
+			r = unicode.ToLower(unicode.ToUpper(r))
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,75 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestFoldString(t *testing.T) {
+	both := CompareOptions{FoldCase: true, FoldDiacritics: true}
+	tests := []struct {
+		input   string
+		options CompareOptions
+		output  string
+	}{
+		{"Crème Brûlée", CompareOptions{}, "Crème Brûlée"},
+		{"Crème Brûlée", CompareOptions{FoldCase: true}, "crème brûlée"},
+		{"Crème Brûlée", CompareOptions{FoldDiacritics: true}, "Creme Brulee"},
+		{"Crème Brûlée", both, "creme brulee"},
+		{"Crème", both, "creme"}, // already decomposed
+		{"Ǻngström", both, "angstrom"},
+		{"Ελληνικά", both, "ελληνικα"},
+		{"Tiếng Việt", both, "tieng viet"},
+		{"Øre Łódź", both, "øre łodz"},
+	}
+	for _, test := range tests {
+		if actual := FoldString(test.input, test.options); actual != test.output {
+			t.Errorf("Expected %q for %q, got %q", test.output, test.input, actual)
+		}
+	}
+}
+
+func TestCompareFolding(t *testing.T) {
+	a := NewValueFromBytes([]byte(`{"name": "José", "tags": ["CAFÉ"]}`))
+	b := NewValueFromBytes([]byte(`{"name": "jose", "tags": ["cafe"]}`))
+	if a.Equals(b) {
+		t.Errorf("Expected values to differ without folding")
+	}
+	if a.EqualsWithOptions(b, CompareOptions{FoldCase: true}) {
+		t.Errorf("Expected values to differ folding only case")
+	}
+	if !a.EqualsWithOptions(b, CompareOptions{FoldCase: true, FoldDiacritics: true}) {
+		t.Errorf("Expected values to be equal folding case and diacritics")
+	}
+	// object keys are not folded
+	c := NewValueFromBytes([]byte(`{"Name": "jose", "tags": ["cafe"]}`))
+	if b.EqualsWithOptions(c, CompareOptions{FoldCase: true, FoldDiacritics: true}) {
+		t.Errorf("Expected keys to be compared exactly")
+	}
+}
+
+func TestQueryFolding(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"people": [{"name": "Zoë"}, {"name": "ZOE"}, {"name": "Zed"}]}`))
+	results, err := doc.Query("$.people[?(@.name = 'zoe')].name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no exact matches, got %v", results)
+	}
+	results, err = doc.QueryWithOptions("$.people[?(@.name = 'zoe')].name", CompareOptions{FoldCase: true, FoldDiacritics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Value() != "Zoë" || results[1].Value() != "ZOE" {
+		t.Errorf("Expected Zoë and ZOE, got %v", results)
+	}
+}
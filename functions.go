@@ -0,0 +1,172 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// An argument type which accepts a Value of any type (including NULL).
+const ANY_TYPE = -1
+
+var typeNames = map[int]string{
+	ANY_TYPE: "any",
+	NOT_JSON: "not_json",
+	NULL:     "null",
+	BOOLEAN:  "boolean",
+	NUMBER:   "number",
+	STRING:   "string",
+	ARRAY:    "array",
+	OBJECT:   "object",
+}
+
+// A function which can be called from expressions.  The arguments have already been
+// validated against the declared argument types.
+type FunctionImpl func(args []*Value) (*Value, error)
+
+// A Function which can be called from expressions, see RegisterFunction().
+type Function struct {
+	Args     []int // the Value type (or ANY_TYPE) of each argument
+	Variadic bool  // the last argument may be repeated any number of times (including zero)
+	Impl     FunctionImpl
+}
+
+// When a function is called with an argument of the wrong type,
+// the return error will be *ArgumentError.
+type ArgumentError struct {
+	Function string
+	Position int // 1-based position of the argument
+	Expected int
+	Actual   int
+}
+
+// Description of the argument which was of the wrong type.
+func (this *ArgumentError) Error() string {
+	return fmt.Sprintf("argument %d of %s must be %s, got %s", this.Position, this.Function, typeNames[this.Expected], typeNames[this.Actual])
+}
+
+var functionsMutex sync.RWMutex
+var functions = map[string]Function{
+	"ABS":    {[]int{NUMBER}, false, absFunction},
+	"LENGTH": {[]int{ANY_TYPE}, false, lengthFunction},
+	"LOWER":  {[]int{STRING}, false, lowerFunction},
+	"TYPE":   {[]int{ANY_TYPE}, false, typeFunction},
+	"UPPER":  {[]int{STRING}, false, upperFunction},
+}
+
+// Register a function which can be called from expressions by name.  Names are case-insensitive.
+// Any existing registration for the name is replaced, expressions which have already been parsed
+// continue to use the function registered at the time they were parsed.
+//
+// The functions ABS, LENGTH, LOWER, TYPE and UPPER are registered by default.
+//
+// Before the function is called, its arguments are validated:
+//
+//         1. If any argument is missing, the function is not called and the result is missing.
+//         2. If any argument is NULL where another type is expected, the function is not called and the result is NULL.
+//         3. If any other argument is not of the expected type, the return error is *ArgumentError.
+//
+// A Variadic function must declare at least one argument type, the one repeated, otherwise
+// RegisterFunction() panics.
+func RegisterFunction(name string, fn Function) {
+	if fn.Variadic && len(fn.Args) == 0 {
+		panic(fmt.Sprintf("variadic function %s must declare at least one argument", name))
+	}
+	functionsMutex.Lock()
+	defer functionsMutex.Unlock()
+	functions[strings.ToUpper(name)] = fn
+}
+
+func lookupFunction(name string) (Function, bool) {
+	functionsMutex.RLock()
+	defer functionsMutex.RUnlock()
+	fn, ok := functions[strings.ToUpper(name)]
+	return fn, ok
+}
+
+// accepts determines if the function can be called with count arguments
+func (this Function) accepts(count int) bool {
+	if this.Variadic {
+		return count >= len(this.Args)-1
+	}
+	return count == len(this.Args)
+}
+
+func (this Function) argType(position int) int {
+	if position >= len(this.Args) {
+		return this.Args[len(this.Args)-1]
+	}
+	return this.Args[position]
+}
+
+type callNode struct {
+	name string
+	fn   Function
+	args []exprNode
+}
+
+func (this *callNode) eval(ctx *evalContext) (*Value, error) {
+	args := make([]*Value, len(this.args))
+	null := false
+	for i, arg := range this.args {
+		val, err := arg.eval(ctx)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		expected := this.fn.argType(i)
+		if expected != ANY_TYPE && val.Type() != expected {
+			if val.Type() != NULL {
+				return nil, &ArgumentError{this.name, i + 1, expected, val.Type()}
+			}
+			null = true
+		}
+		args[i] = unshared(val)
+	}
+	if null {
+		return NewValue(nil), nil
+	}
+	return this.fn.Impl(args)
+}
+
+func absFunction(args []*Value) (*Value, error) {
+	return NewValue(math.Abs(nativeNumber(args[0].Value()))), nil
+}
+
+// lengthFunction counts the characters of a string, or the elements or keys of an array or
+// object without parsing them (see Len()).
+func lengthFunction(args []*Value) (*Value, error) {
+	switch args[0].Type() {
+	case STRING:
+		return NewValue(float64(utf8.RuneCountInString(args[0].Value().(string)))), nil
+	case ARRAY, OBJECT:
+		n, err := args[0].Len()
+		if err != nil {
+			return nil, err
+		}
+		return NewValue(float64(n)), nil
+	}
+	return NewValue(nil), nil
+}
+
+func lowerFunction(args []*Value) (*Value, error) {
+	return NewValue(strings.ToLower(args[0].Value().(string))), nil
+}
+
+func typeFunction(args []*Value) (*Value, error) {
+	return NewValue(typeNames[args[0].Type()]), nil
+}
+
+func upperFunction(args []*Value) (*Value, error) {
+	return NewValue(strings.ToUpper(args[0].Value().(string))), nil
+}
@@ -0,0 +1,93 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	RegisterFunction("custom_score", Function{
+		Args:     []int{NUMBER, NUMBER},
+		Variadic: true,
+		Impl: func(args []*Value) (*Value, error) {
+			sum := 0.0
+			for _, arg := range args {
+				sum += nativeNumber(arg.Value())
+			}
+			return NewValue(sum), nil
+		},
+	})
+
+	doc := NewValueFromBytes([]byte(`{"name":"Marty","tags":["a","b"],"n":-2,"nothing":null,"nested":{"items":[1,[2],{}],"k":"v"}}`))
+
+	var tests = []struct {
+		expr   string
+		output interface{}
+	}{
+		{`lower(name)`, "marty"},
+		{`UPPER(name) = "MARTY"`, true},
+		{`LENGTH(tags) + LENGTH(name)`, 7.0},
+		{`LENGTH("é世")`, 2.0},
+		{`LENGTH(nested)`, 2.0},
+		{`LENGTH(nested.items)`, 3.0},
+		{`ABS(n)`, 2.0},
+		{`TYPE(tags)`, "array"},
+		{`CUSTOM_SCORE(1)`, 1.0},
+		{`CUSTOM_SCORE(1, 2, n)`, 1.0},
+		{`LOWER(nothing)`, nil},
+	}
+
+	for _, test := range tests {
+		result, err := Eval(test.expr, doc)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.expr)
+			continue
+		}
+		if !reflect.DeepEqual(result.Value(), test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, result.Value(), test.expr)
+		}
+	}
+
+	// arrays and objects are counted without being parsed
+	for _, raw := range []string{`[1, [2, 3], {"a": 4}]`, `{"a": [1], "b": {}, "c": 3}`} {
+		val := NewValueFromBytes([]byte(raw))
+		result, err := lengthFunction([]*Value{val})
+		if err != nil || result.Value() != 3.0 || val.parsedValue != nil {
+			t.Errorf("Expected 3 without parsing %s, got %v, %v", raw, result, err)
+		}
+	}
+
+	_, err := Eval(`LOWER(missing)`, doc)
+	if _, ok := err.(*Undefined); !ok {
+		t.Errorf("Expected *Undefined, got %v", err)
+	}
+
+	if expectPanic(func() { RegisterFunction("no_args", Function{Variadic: true}) }) == nil {
+		t.Errorf("Expected a variadic function without arguments to be rejected")
+	}
+	if _, ok := lookupFunction("no_args"); ok {
+		t.Errorf("Expected the rejected function not to be registered")
+	}
+
+	_, err = Eval(`LOWER(tags)`, doc)
+	expected := &ArgumentError{"LOWER", 1, STRING, ARRAY}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("Expected %v, got %v", expected, err)
+	}
+
+	for _, expr := range []string{`NO_SUCH(1)`, `LOWER()`, `CUSTOM_SCORE()`, `LOWER(name name)`} {
+		_, err := ParseExpression(expr)
+		if _, ok := err.(*ExpressionError); !ok {
+			t.Errorf("Expected *ExpressionError for %s, got %v", expr, err)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// An IndexSpec describes the entries of a secondary index, see IndexKeys().
+type IndexSpec struct {
+	Keys []IndexKey
+
+	// IncludeDocKey appends the key from the Meta of the document (see FeedChannel()) to every
+	// entry, so the entries of different documents with equal keys are distinct.
+	IncludeDocKey bool
+}
+
+// An IndexKey is one component of the entries of a secondary index.
+type IndexKey struct {
+	Path  string // dotted steps as for PathAll(), so "tags.*" indexes each element of tags
+	Order SortOrder
+}
+
+// The encoding of a value in an index entry begins with a tag byte, ordered as Compare()
+// orders types, with missing lowest.  Tags are never 0, so that the end of an array (or
+// of a string) sorts before anything which could follow it.
+const (
+	indexEnd     = 0x00
+	indexMissing = 0x01
+	indexNull    = 0x02
+	indexFalse   = 0x03
+	indexTrue    = 0x04
+	indexNumber  = 0x05
+	indexString  = 0x06
+	indexArray   = 0x07
+	indexObject  = 0x08
+)
+
+// Return the index entries of doc for spec.  Each entry is the encoding of the value at the
+// path of each IndexKey in turn, such that comparing entries with bytes.Compare() orders them
+// as Compare() would order those values (reversed for DESCENDING keys), with missing values
+// sorting first.  Entries can be stored as the keys of an ordered key-value store.
+//
+// A path which selects several values (see PathAll()) produces an entry for each of them, and
+// if several paths do so, an entry for every combination.  A path which selects nothing is
+// missing.  Duplicate entries are removed, and the entries are returned in order.
+func IndexKeys(doc *Value, spec IndexSpec) ([][]byte, error) {
+	entries := [][]byte{nil}
+	for _, key := range spec.Keys {
+		vals, err := doc.PathAll(strings.Split(key.Path, ".")...)
+		if err != nil {
+			return nil, err
+		}
+		components := make([][]byte, 0, len(vals))
+		for _, val := range vals {
+			components = append(components, encodeIndexComponent(val, key.Order))
+		}
+		if len(components) == 0 {
+			components = append(components, encodeIndexComponent(nil, key.Order))
+		}
+		next := make([][]byte, 0, len(entries)*len(components))
+		for _, entry := range entries {
+			for _, component := range components {
+				next = append(next, append(entry[:len(entry):len(entry)], component...))
+			}
+		}
+		entries = next
+	}
+	if spec.IncludeDocKey {
+		var docKey *Value
+		if meta := doc.Meta(); meta != nil {
+			docKey = NewValue(meta.Key)
+		}
+		for i := range entries {
+			entries[i] = append(entries[i], encodeIndexComponent(docKey, ASCENDING)...)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i], entries[j]) < 0 })
+	rv := entries[:0]
+	for i, entry := range entries {
+		if i == 0 || !bytes.Equal(entry, entries[i-1]) {
+			rv = append(rv, entry)
+		}
+	}
+	return rv, nil
+}
+
+// encodeIndexComponent encodes val, which is missing if nil.  Every encoding is self-delimiting,
+// so inverting its bytes reverses the order for DESCENDING keys.
+func encodeIndexComponent(val *Value, order SortOrder) []byte {
+	var buf bytes.Buffer
+	if val == nil {
+		buf.WriteByte(indexMissing)
+	} else {
+		encodeIndexValue(&buf, val.Value())
+	}
+	rv := buf.Bytes()
+	if order == DESCENDING {
+		for i := range rv {
+			rv[i] = ^rv[i]
+		}
+	}
+	return rv
+}
+
+func encodeIndexValue(buf *bytes.Buffer, val interface{}) {
+	switch val := val.(type) {
+	case nil:
+		buf.WriteByte(indexNull)
+	case bool:
+		if val {
+			buf.WriteByte(indexTrue)
+		} else {
+			buf.WriteByte(indexFalse)
+		}
+	case string:
+		buf.WriteByte(indexString)
+		encodeIndexString(buf, val)
+	case []interface{}:
+		buf.WriteByte(indexArray)
+		for _, v := range val {
+			encodeIndexValue(buf, v)
+		}
+		buf.WriteByte(indexEnd)
+	case map[string]interface{}:
+		// objects are ordered by their number of keys, then their sorted keys, then their values
+		buf.WriteByte(indexObject)
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(val)))
+		buf.Write(n[:])
+		keys := sortedKeys(val)
+		for _, k := range keys {
+			encodeIndexString(buf, k)
+		}
+		for _, k := range keys {
+			encodeIndexValue(buf, val[k])
+		}
+	default:
+		buf.WriteByte(indexNumber)
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], encodeIndexNumber(nativeNumber(val)))
+		buf.Write(n[:])
+	}
+}
+
+// encodeIndexString escapes the 0 bytes of s as 0 0xFF, and terminates it with 0 1,
+// so that a string sorts before any longer string it is a prefix of.
+func encodeIndexString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		buf.WriteByte(s[i])
+		if s[i] == 0 {
+			buf.WriteByte(0xFF)
+		}
+	}
+	buf.WriteByte(0)
+	buf.WriteByte(1)
+}
+
+// encodeIndexNumber returns bits which sort as unsigned integers as the numbers do.
+func encodeIndexNumber(f float64) uint64 {
+	if f == 0 {
+		// -0 is equal to 0
+		f = 0
+	}
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		return ^bits
+	}
+	return bits | 1<<63
+}
+
+// Reconstruct a partial document from an index entry produced by IndexKeys() with the same spec,
+// so that a query covered by the index can be answered without fetching the document.  The value
+// of each IndexKey is stored at its path, where each step is a property of an object except "*",
+// which is an array holding the one value, so PathAll() and FillTemplate() find the value again.
+// Missing values are not stored.  With IncludeDocKey, the key is attached as the Meta of the
+// result (see FeedChannel()).
+//
+// If the entry is malformed, or was not produced with this spec, an error is returned.
+func DecodeIndexEntry(key []byte, spec IndexSpec) (*Value, error) {
+	decoder := indexDecoder{data: key}
+	rv := map[string]interface{}{}
+	for _, k := range spec.Keys {
+		decoder.invert = k.Order == DESCENDING
+		val, missing, err := decoder.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if !missing {
+			storeIndexValue(rv, strings.Split(k.Path, "."), val)
+		}
+	}
+	var meta *Meta
+	if spec.IncludeDocKey {
+		decoder.invert = false
+		val, missing, err := decoder.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		if !missing {
+			docKey, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("malformed index entry: document key is not a string")
+			}
+			meta = &Meta{Key: docKey}
+		}
+	}
+	if decoder.pos != len(key) {
+		return nil, fmt.Errorf("malformed index entry: unexpected data at offset %d", decoder.pos)
+	}
+	doc := NewValue(rv)
+	if meta != nil {
+		doc.SetAttachment(META_ATTACHMENT, meta)
+	}
+	return doc, nil
+}
+
+// storeIndexValue stores val at the steps inside doc, creating objects (or arrays for "*") as needed.
+func storeIndexValue(doc map[string]interface{}, steps []string, val interface{}) {
+	if len(steps) > 1 && steps[1] != "*" {
+		child, ok := doc[steps[0]].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			doc[steps[0]] = child
+		}
+		storeIndexValue(child, steps[1:], val)
+		return
+	}
+	for i := len(steps) - 1; i > 0; i-- {
+		if steps[i] == "*" {
+			val = []interface{}{val}
+		} else {
+			val = map[string]interface{}{steps[i]: val}
+		}
+	}
+	doc[steps[0]] = val
+}
+
+type indexDecoder struct {
+	data   []byte
+	pos    int
+	invert bool // the bytes of the current component are inverted, as for DESCENDING keys
+}
+
+var errIndexEnd = fmt.Errorf("malformed index entry: unexpected end")
+
+func (this *indexDecoder) next() (byte, error) {
+	if this.pos >= len(this.data) {
+		return 0, errIndexEnd
+	}
+	b := this.data[this.pos]
+	this.pos++
+	if this.invert {
+		b = ^b
+	}
+	return b, nil
+}
+
+func (this *indexDecoder) read(n int) ([]byte, error) {
+	if this.pos+n > len(this.data) {
+		return nil, errIndexEnd
+	}
+	rv := make([]byte, n)
+	for i := range rv {
+		rv[i], _ = this.next()
+	}
+	return rv, nil
+}
+
+// decodeValue decodes the native representation of the next value, or reports that it is missing.
+func (this *indexDecoder) decodeValue() (interface{}, bool, error) {
+	tag, err := this.next()
+	if err != nil {
+		return nil, false, err
+	}
+	if tag == indexMissing {
+		return nil, true, nil
+	}
+	val, err := this.decodeTagged(tag)
+	return val, false, err
+}
+
+func (this *indexDecoder) decodeTagged(tag byte) (interface{}, error) {
+	switch tag {
+	case indexNull:
+		return nil, nil
+	case indexFalse:
+		return false, nil
+	case indexTrue:
+		return true, nil
+	case indexNumber:
+		n, err := this.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return decodeIndexNumber(binary.BigEndian.Uint64(n)), nil
+	case indexString:
+		return this.decodeString()
+	case indexArray:
+		rv := []interface{}{}
+		for {
+			tag, err := this.next()
+			if err != nil {
+				return nil, err
+			}
+			if tag == indexEnd {
+				return rv, nil
+			}
+			val, err := this.decodeTagged(tag)
+			if err != nil {
+				return nil, err
+			}
+			rv = append(rv, val)
+		}
+	case indexObject:
+		n, err := this.read(4)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, binary.BigEndian.Uint32(n))
+		if len(keys) > len(this.data)-this.pos {
+			return nil, errIndexEnd
+		}
+		for i := range keys {
+			keys[i], err = this.decodeString()
+			if err != nil {
+				return nil, err
+			}
+		}
+		rv := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			tag, err := this.next()
+			if err != nil {
+				return nil, err
+			}
+			rv[k], err = this.decodeTagged(tag)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rv, nil
+	}
+	return nil, fmt.Errorf("malformed index entry: unknown tag %d at offset %d", tag, this.pos-1)
+}
+
+func (this *indexDecoder) decodeString() (string, error) {
+	var buf []byte
+	for {
+		b, err := this.next()
+		if err != nil {
+			return "", err
+		}
+		if b != 0 {
+			buf = append(buf, b)
+			continue
+		}
+		b, err = this.next()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case 1:
+			return string(buf), nil
+		case 0xFF:
+			buf = append(buf, 0)
+		default:
+			return "", fmt.Errorf("malformed index entry: invalid string escape at offset %d", this.pos-1)
+		}
+	}
+}
+
+// decodeIndexNumber is the inverse of encodeIndexNumber.
+func decodeIndexNumber(bits uint64) float64 {
+	if bits&(1<<63) != 0 {
+		return math.Float64frombits(bits &^ (1 << 63))
+	}
+	return math.Float64frombits(^bits)
+}
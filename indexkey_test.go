@@ -0,0 +1,153 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"testing"
+)
+
+// the values in the order Compare() sorts them
+var indexOrderValues = []string{
+	`null`, `false`, `true`, `-1e10`, `-2.5`, `0`, `0.5`, `3`, `1e300`,
+	`""`, `"a"`, `"a\u0000"`, `"a\u0000b"`, `"ab"`, `"b"`,
+	`[]`, `[null]`, `[1]`, `[1,2]`, `[1,"a"]`, `[2]`, `["a"]`,
+	`{}`, `{"b":1}`, `{"a":1,"b":0}`, `{"a":2,"b":0}`, `{"a":1,"c":0}`,
+}
+
+func TestIndexKeyOrder(t *testing.T) {
+	for _, order := range []SortOrder{ASCENDING, DESCENDING} {
+		var prev *Value
+		var prevKey []byte
+		for _, s := range append([]string{"missing"}, indexOrderValues...) {
+			var val *Value
+			if s != "missing" {
+				val = NewValueFromBytes([]byte(s))
+			}
+			key := encodeIndexComponent(val, order)
+			if prevKey != nil {
+				if prev != nil && val.Compare(prev) <= 0 {
+					t.Fatalf("Test values out of order at %s", s)
+				}
+				cmp := bytes.Compare(prevKey, key)
+				if (order == ASCENDING && cmp >= 0) || (order == DESCENDING && cmp <= 0) {
+					t.Errorf("Expected %s to sort after the previous value, order %d", s, order)
+				}
+			}
+			prev, prevKey = val, key
+		}
+	}
+
+	// equal numbers encode the same, however they are written
+	a := encodeIndexComponent(NewValueFromBytes([]byte(`{"n": [1.0, -0]}`)), ASCENDING)
+	b := encodeIndexComponent(NewValueFromBytes([]byte(`{"n": [1, 0]}`)), ASCENDING)
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected equal values to encode the same")
+	}
+}
+
+func TestIndexKeys(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"type": "order", "total": 20, "tags": ["b", "a", "b"], "items": [{"sku": "x"}, {"sku": "y"}]}`))
+
+	entries, err := IndexKeys(doc, IndexSpec{Keys: []IndexKey{{Path: "type"}, {Path: "total", Order: DESCENDING}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	expected := append(encodeIndexComponent(NewValue("order"), ASCENDING), encodeIndexComponent(NewValue(20.0), DESCENDING)...)
+	if !bytes.Equal(entries[0], expected) {
+		t.Errorf("Expected %x, got %x", expected, entries[0])
+	}
+
+	// arrays fan out, duplicates are removed and the entries are sorted
+	entries, err = IndexKeys(doc, IndexSpec{Keys: []IndexKey{{Path: "tags.*"}, {Path: "items.sku"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d", len(entries))
+	}
+	first := append(encodeIndexComponent(NewValue("a"), ASCENDING), encodeIndexComponent(NewValue("x"), ASCENDING)...)
+	if !bytes.Equal(entries[0], first) {
+		t.Errorf("Expected first entry %x, got %x", first, entries[0])
+	}
+	for i := 1; i < len(entries); i++ {
+		if bytes.Compare(entries[i-1], entries[i]) >= 0 {
+			t.Errorf("Expected entries in order")
+		}
+	}
+
+	// missing paths, and the document key
+	doc.SetAttachment(META_ATTACHMENT, &Meta{Key: "order::1"})
+	entries, err = IndexKeys(doc, IndexSpec{Keys: []IndexKey{{Path: "missing"}}, IncludeDocKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = append(encodeIndexComponent(nil, ASCENDING), encodeIndexComponent(NewValue("order::1"), ASCENDING)...)
+	if len(entries) != 1 || !bytes.Equal(entries[0], expected) {
+		t.Errorf("Expected %x, got %x", expected, entries)
+	}
+}
+
+func TestDecodeIndexEntry(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"type": "order", "customer": {"name": "ada", "tier": 2}, "tags": ["a\u0000b", "c"], "extra": {"x": [1, {"y": null}]}}`))
+	doc.SetAttachment(META_ATTACHMENT, &Meta{Key: "order::1"})
+	spec := IndexSpec{
+		Keys: []IndexKey{
+			{Path: "type"},
+			{Path: "customer.name", Order: DESCENDING},
+			{Path: "customer.tier"},
+			{Path: "tags.*", Order: DESCENDING},
+			{Path: "extra"},
+			{Path: "missing"},
+		},
+		IncludeDocKey: true,
+	}
+	entries, err := IndexKeys(doc, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	decoded, err := DecodeIndexEntry(entries[1], spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewValueFromBytes([]byte(`{"type": "order", "customer": {"name": "ada", "tier": 2}, "tags": ["a\u0000b"], "extra": {"x": [1, {"y": null}]}}`))
+	if !decoded.Equals(expected) {
+		t.Errorf("Expected %s, got %s", expected.Bytes(), decoded.Bytes())
+	}
+	if decoded.Meta() == nil || decoded.Meta().Key != "order::1" {
+		t.Errorf("Expected the document key, got %v", decoded.Meta())
+	}
+	// the decoded document produces the same entry
+	again, err := IndexKeys(decoded, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || !bytes.Equal(again[0], entries[1]) {
+		t.Errorf("Expected the decoded document to produce the same entry")
+	}
+
+	for _, malformed := range [][]byte{
+		entries[0][:len(entries[0])-1],
+		append(append([]byte{}, entries[0]...), 0),
+		{0x09},
+		{},
+	} {
+		_, err := DecodeIndexEntry(malformed, spec)
+		if err == nil {
+			t.Errorf("Expected an error decoding %x", malformed)
+		}
+	}
+}
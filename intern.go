@@ -0,0 +1,83 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"sync"
+)
+
+// A KeyInterner is a table of object keys, shared by every Value parsed with it
+// (see ParseOptions.Keys), so that many documents with the same keys keep only
+// one copy of each key.  It is safe for concurrent use.
+type KeyInterner struct {
+	mutex sync.RWMutex
+	keys  map[string]string
+	max   int
+}
+
+// Create a new KeyInterner holding at most max distinct keys, 0 means no limit.
+// Once the table is full, keys which are not already in it are not shared.
+func NewKeyInterner(max int) *KeyInterner {
+	return &KeyInterner{
+		keys: make(map[string]string),
+		max:  max,
+	}
+}
+
+// Return the shared copy of key, adding it to the table if there is room.
+func (this *KeyInterner) Intern(key string) string {
+	this.mutex.RLock()
+	rv, ok := this.keys[key]
+	this.mutex.RUnlock()
+	if ok {
+		return rv
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if rv, ok := this.keys[key]; ok {
+		return rv
+	}
+	if this.max > 0 && len(this.keys) >= this.max {
+		return key
+	}
+	this.keys[key] = key
+	return key
+}
+
+// Return the number of distinct keys in the table.
+func (this *KeyInterner) Len() int {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return len(this.keys)
+}
+
+// internTree replaces the keys of the objects in a parsed value with their shared copies.
+func (this *KeyInterner) internTree(val interface{}) {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			this.internTree(v)
+			// assigning to an existing key replaces the stored key too
+			val[this.Intern(k)] = v
+		}
+	case []interface{}:
+		for _, v := range val {
+			this.internTree(v)
+		}
+	}
+}
+
+// keyInterner returns the interner in the options of this Value, or nil if there is none.
+func (this *Value) keyInterner() *KeyInterner {
+	if this.options == nil {
+		return nil
+	}
+	return this.options.Keys
+}
@@ -0,0 +1,46 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestKeyInterner(t *testing.T) {
+	keys := NewKeyInterner(0)
+	options := ParseOptions{Keys: keys}
+
+	var seen []string
+	for _, doc := range []string{`{"name":"a","tags":[{"kind":1}]}`, `{"name":"b","tags":[{"kind":2}]}`} {
+		val, err := NewValueFromBytesWithOptions([]byte(doc), options)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		m := val.Value().(map[string]interface{})
+		for k := range m {
+			if k == "name" {
+				seen = append(seen, k)
+			}
+		}
+	}
+	if len(seen) != 2 || unsafe.StringData(seen[0]) != unsafe.StringData(seen[1]) {
+		t.Errorf("Expected both documents to share the key name")
+	}
+	if keys.Len() != 3 {
+		t.Errorf("Expected 3 keys, got %d", keys.Len())
+	}
+
+	limited := NewKeyInterner(1)
+	limited.Intern("a")
+	if limited.Intern("b") != "b" || limited.Len() != 1 {
+		t.Errorf("Expected a full table not to grow, got %d keys", limited.Len())
+	}
+}
@@ -0,0 +1,159 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// When a signature cannot be verified, the return error will be *SignatureError.
+type SignatureError struct {
+	msg string
+}
+
+// Description of why the signature could not be verified.
+func (this *SignatureError) Error() string {
+	return "invalid signature: " + this.msg
+}
+
+// Verify a JWS with a detached payload (RFC 7515 Appendix F), in compact serialization
+// "header..signature", over the canonical form of doc (see JWSPayload()).  If the protected
+// header contains "b64": false (RFC 7797) the canonical bytes are signed as-is, otherwise
+// they are base64url encoded as usual.
+//
+// The key must match the "alg" of the header:
+//
+//         1. HS256, HS384, HS512: []byte
+//         2. RS256, RS384, RS512, PS256, PS384, PS512: *rsa.PublicKey
+//         3. ES256, ES384, ES512: *ecdsa.PublicKey
+//         4. EdDSA: ed25519.PublicKey
+//
+// If the signature is not valid, the return error is *SignatureError.
+func VerifyDetachedJWS(jws string, doc *Value, key interface{}) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return &SignatureError{"expected compact serialization with detached payload"}
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return &SignatureError{"malformed header encoding"}
+	}
+	header := NewValueFromBytes(headerBytes)
+	alg, err := header.Path("alg")
+	if err != nil || alg.Type() != STRING {
+		return &SignatureError{"missing alg in header"}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return &SignatureError{"malformed signature encoding"}
+	}
+	payload, err := doc.JWSPayload()
+	if err != nil {
+		return err
+	}
+	input := parts[0] + "."
+	if b64, err := header.Path("b64"); err == nil && b64.Value() == false {
+		input += string(payload)
+	} else {
+		input += base64.RawURLEncoding.EncodeToString(payload)
+	}
+	return verifySignature(alg.Value().(string), []byte(input), signature, key)
+}
+
+func verifySignature(alg string, input, signature []byte, key interface{}) error {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return &SignatureError{fmt.Sprintf("%s requires an ed25519.PublicKey, got %T", alg, key)}
+		}
+		if !ed25519.Verify(pub, input, signature) {
+			return &SignatureError{"signature does not match"}
+		}
+		return nil
+	}
+	if len(alg) != 5 {
+		return &SignatureError{fmt.Sprintf("unsupported alg %s", alg)}
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return &SignatureError{fmt.Sprintf("unsupported alg %s", alg)}
+	}
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return &SignatureError{fmt.Sprintf("%s requires a []byte key, got %T", alg, key)}
+		}
+		var mac = hmac.New(sha256.New, secret)
+		switch hash {
+		case crypto.SHA384:
+			mac = hmac.New(sha512.New384, secret)
+		case crypto.SHA512:
+			mac = hmac.New(sha512.New, secret)
+		}
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return &SignatureError{"signature does not match"}
+		}
+		return nil
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return &SignatureError{fmt.Sprintf("%s requires an *rsa.PublicKey, got %T", alg, key)}
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return &SignatureError{"signature does not match"}
+		}
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return &SignatureError{fmt.Sprintf("%s requires an *ecdsa.PublicKey, got %T", alg, key)}
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return &SignatureError{"signature has the wrong length"}
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return &SignatureError{"signature does not match"}
+		}
+		return nil
+	}
+	return &SignatureError{fmt.Sprintf("unsupported alg %s", alg)}
+}
@@ -0,0 +1,99 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func detachedJWS(t *testing.T, header string, doc *Value, sign func([]byte) []byte) string {
+	payload, err := doc.JWSPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := base64.RawURLEncoding.EncodeToString([]byte(header))
+	input := h + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if header == `{"alg":"HS256","b64":false,"crit":["b64"]}` {
+		input = h + "." + string(payload)
+	}
+	return h + ".." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func TestVerifyDetachedJWS(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"id":"doc1", "amount": 10.50}`))
+	// the same document, differently formatted
+	same := NewValueFromBytes([]byte(`{"amount":10.5,"id":"doc1"}`))
+	other := NewValueFromBytes([]byte(`{"amount":11,"id":"doc1"}`))
+
+	secret := []byte("secret")
+	hs256 := func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rs256 := func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		return sig
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	es256 := func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	}
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	eddsa := func(input []byte) []byte {
+		return ed25519.Sign(edPriv, input)
+	}
+
+	var tests = []struct {
+		header string
+		sign   func([]byte) []byte
+		key    interface{}
+	}{
+		{`{"alg":"HS256"}`, hs256, secret},
+		{`{"alg":"HS256","b64":false,"crit":["b64"]}`, hs256, secret},
+		{`{"alg":"RS256"}`, rs256, &rsaKey.PublicKey},
+		{`{"alg":"ES256"}`, es256, &ecKey.PublicKey},
+		{`{"alg":"EdDSA"}`, eddsa, edPub},
+	}
+
+	for _, test := range tests {
+		jws := detachedJWS(t, test.header, doc, test.sign)
+		if err := VerifyDetachedJWS(jws, same, test.key); err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.header)
+		}
+		if _, ok := VerifyDetachedJWS(jws, other, test.key).(*SignatureError); !ok {
+			t.Errorf("Expected *SignatureError for modified document with %s", test.header)
+		}
+	}
+
+	jws := detachedJWS(t, `{"alg":"HS256"}`, doc, hs256)
+	if _, ok := VerifyDetachedJWS(jws, doc, &rsaKey.PublicKey).(*SignatureError); !ok {
+		t.Errorf("Expected *SignatureError for wrong key type")
+	}
+	if _, ok := VerifyDetachedJWS("abc.def.ghi", doc, secret).(*SignatureError); !ok {
+		t.Errorf("Expected *SignatureError for attached payload")
+	}
+}
@@ -0,0 +1,135 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+
+	json "github.com/dustin/gojson"
+)
+
+// Determine if the string at path matches a SQL LIKE pattern, in which % matches any sequence
+// of characters, _ matches any single character, and \ makes the character following it
+// (including %, _ and \) match only itself.  The whole string must match the pattern.
+//
+// The string is found as ExistsPath() finds it, and unless it contains escapes it is matched
+// against the raw bytes in place, so no Values are created, and nothing is parsed.
+//
+// If the path does not exist, the return error is *Undefined.  If the value at the path
+// is not of type STRING, the return error is *TypeMismatch.
+func (this *Value) LikePath(path, pattern string) (bool, error) {
+	cur := this.locate(path)
+	if cur.missing {
+		return false, &Undefined{path}
+	}
+	var s []byte
+	switch {
+	case cur.val != nil:
+		if cur.val.parsedType != STRING {
+			return false, &TypeMismatch{Path: path, Expected: STRING, Actual: cur.val.Type()}
+		}
+		if cur.val.raw == nil {
+			return likeMatch(cur.val.Value().(string), pattern), nil
+		}
+		s = cur.val.raw
+	case cur.raw != nil:
+		if t := identifyType(cur.raw); t != STRING {
+			return false, &TypeMismatch{Path: path, Expected: STRING, Actual: t}
+		}
+		s = cur.raw
+	default:
+		native, ok := cur.native.(string)
+		if !ok {
+			return false, &TypeMismatch{Path: path, Expected: STRING, Actual: nativeType(cur.native)}
+		}
+		return likeMatch(native, pattern), nil
+	}
+	if bytes.IndexByte(s, '\\') >= 0 {
+		var unescaped string
+		err := json.Unmarshal(s, &unescaped)
+		if err != nil {
+			return false, err
+		}
+		return likeMatch(unescaped, pattern), nil
+	}
+	// without escapes, the contents of the quotes are the string itself
+	return likeMatch(s[1:len(s)-1], pattern), nil
+}
+
+// likeMatch determines if s matches the LIKE pattern, backtracking to the last % on a mismatch.
+func likeMatch[T string | []byte](s T, pattern string) bool {
+	si, pi := 0, 0
+	starS, starP := -1, -1
+	for si < len(s) {
+		if pi < len(pattern) {
+			switch pattern[pi] {
+			case '%':
+				starS, starP = si, pi+1
+				pi++
+				continue
+			case '_':
+				si += runeSize(s[si], len(s)-si)
+				pi++
+				continue
+			case '\\':
+				if pi+1 < len(pattern) {
+					pi++
+				}
+			}
+			size := runeSize(pattern[pi], len(pattern)-pi)
+			if matchBytes(s, si, pattern[pi:pi+size]) {
+				si += size
+				pi += size
+				continue
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// let the last % match one more character
+		starS += runeSize(s[starS], len(s)-starS)
+		si, pi = starS, starP
+	}
+	for pi < len(pattern) && pattern[pi] == '%' {
+		pi++
+	}
+	return pi == len(pattern)
+}
+
+// matchBytes determines if s contains the bytes of c at offset i.
+func matchBytes[T string | []byte](s T, i int, c string) bool {
+	if i+len(c) > len(s) {
+		return false
+	}
+	for j := 0; j < len(c); j++ {
+		if s[i+j] != c[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// runeSize returns the length of the UTF-8 encoded character beginning with b, of
+// at most n bytes.  Invalid bytes are treated as characters of one byte.
+func runeSize(b byte, n int) int {
+	size := 1
+	switch {
+	case b >= 0xF0 && b < 0xF8:
+		size = 4
+	case b >= 0xE0 && b < 0xF0:
+		size = 3
+	case b >= 0xC0 && b < 0xE0:
+		size = 2
+	}
+	if size > n {
+		return n
+	}
+	return size
+}
@@ -0,0 +1,100 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestLikeMatch(t *testing.T) {
+	tests := []struct {
+		s       string
+		pattern string
+		match   bool
+	}{
+		{"marty", "marty", true},
+		{"marty", "mar%", true},
+		{"marty", "%ty", true},
+		{"marty", "%ar%", true},
+		{"marty", "m_rty", true},
+		{"marty", "m_ty", false},
+		{"marty", "%", true},
+		{"", "%", true},
+		{"", "_", false},
+		{"marty", "Marty", false},
+		{"marty", "mart", false},
+		{"aaab", "%a%ab", true},
+		{"crème", "cr_me", true},
+		{"crème", "cr__me", false},
+		{"100%", "100\\%", true},
+		{"1000", "100\\%", false},
+		{"a_b", "a\\_b", true},
+		{"axb", "a\\_b", false},
+		{"a\\b", "a\\\\b", true},
+	}
+	for _, test := range tests {
+		if likeMatch(test.s, test.pattern) != test.match {
+			t.Errorf("Expected %v matching %q against %q", test.match, test.s, test.pattern)
+		}
+		if likeMatch([]byte(test.s), test.pattern) != test.match {
+			t.Errorf("Expected %v matching bytes %q against %q", test.match, test.s, test.pattern)
+		}
+	}
+}
+
+func TestLikePath(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name": "marty", "escaped": "caf\u00e9", "n": 1, "a": {"b": "sutton oaks"}}`))
+	doc.SetPath("alias", "steve")
+
+	tests := []struct {
+		path    string
+		pattern string
+		match   bool
+	}{
+		{"name", "m%", true},
+		{"name", "s%", false},
+		{"escaped", "caf_", true},
+		{"escaped", "café", true},
+		{"a.b", "%oaks", true},
+		{"alias", "st_ve", true},
+	}
+	for _, test := range tests {
+		match, err := doc.LikePath(test.path, test.pattern)
+		if err != nil {
+			t.Errorf("Error matching %s: %v", test.path, err)
+		}
+		if match != test.match {
+			t.Errorf("Expected %v matching %s against %q", test.match, test.path, test.pattern)
+		}
+	}
+
+	if _, err := doc.LikePath("missing", "%"); err == nil {
+		t.Errorf("Expected *Undefined for a missing path")
+	} else if _, ok := err.(*Undefined); !ok {
+		t.Errorf("Expected *Undefined, got %v", err)
+	}
+	if _, err := doc.LikePath("n", "%"); err == nil {
+		t.Errorf("Expected *TypeMismatch for a number")
+	} else if _, ok := err.(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch, got %v", err)
+	}
+
+	native := NewValue(map[string]interface{}{"name": "marty"})
+	if match, err := native.LikePath("name", "_arty"); err != nil || !match {
+		t.Errorf("Expected a match on a parsed Value, got %v, %v", match, err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		doc.LikePath("a.b", "%oak_")
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
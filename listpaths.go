@@ -0,0 +1,69 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A Leaf is a value inside a document which has no values inside it: a null, boolean,
+// number or string, or an empty object or array.
+type Leaf struct {
+	Pointer string // the JSON Pointer to the value, see Pointer()
+	Type    int
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Return every Leaf of this Value, in the order of Fields() for the properties of objects,
+// and of the elements of arrays.  Aliases set at any level are respected.  If this Value
+// has no values inside it, the result is the single Leaf with the empty pointer.  If this
+// Value is NOT_JSON, the result is empty.
+func (this *Value) ListLeaves() []Leaf {
+	var rv []Leaf
+	listLeaves(this, "", &rv)
+	return rv
+}
+
+// Return the JSON Pointer of every Leaf of this Value, as ListLeaves() orders them.
+func (this *Value) ListPaths() []string {
+	leaves := this.ListLeaves()
+	rv := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		rv[i] = leaf.Pointer
+	}
+	return rv
+}
+
+func listLeaves(val *Value, pointer string, rv *[]Leaf) {
+	switch val.Type() {
+	case NOT_JSON:
+		return
+	case OBJECT:
+		if fields := val.Fields(); len(fields) > 0 {
+			for _, k := range fields {
+				child, err := val.member(k)
+				if err == nil {
+					listLeaves(child, pointer+"/"+pointerEscaper.Replace(k), rv)
+				}
+			}
+			return
+		}
+	case ARRAY:
+		if elements, err := val.elements(); err == nil && len(elements) > 0 {
+			for i, child := range elements {
+				listLeaves(child, pointer+"/"+strconv.Itoa(i), rv)
+			}
+			return
+		}
+	}
+	*rv = append(*rv, Leaf{pointer, val.Type()})
+}
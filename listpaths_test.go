@@ -0,0 +1,57 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListLeaves(t *testing.T) {
+	raw := []byte(`{"name": "marty", "a/b": {"c~d": [1, null, {"e": true}]}, "empty": {}, "none": [], "x.y": 1.5}`)
+	expected := []Leaf{
+		{"/a~1b/c~0d/0", NUMBER},
+		{"/a~1b/c~0d/1", NULL},
+		{"/a~1b/c~0d/2/e", BOOLEAN},
+		{"/empty", OBJECT},
+		{"/name", STRING},
+		{"/none", ARRAY},
+		{"/x.y", NUMBER},
+	}
+
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		leaves := doc.ListLeaves()
+		if !reflect.DeepEqual(leaves, expected) {
+			t.Errorf("Expected %v, got %v", expected, leaves)
+		}
+		for _, leaf := range leaves {
+			val, err := doc.Pointer(leaf.Pointer)
+			if err != nil || val.Type() != leaf.Type {
+				t.Errorf("Expected %s to refer to a %s, got %v, %v", leaf.Pointer, typeNames[leaf.Type], val, err)
+			}
+		}
+	}
+
+	// aliases are respected
+	doc := NewValueFromBytes(raw)
+	doc.SetPath("empty", map[string]interface{}{"f": "g"})
+	doc.SetPath("name", []interface{}{})
+	paths := doc.ListPaths()
+	if !reflect.DeepEqual(paths, []string{"/a~1b/c~0d/0", "/a~1b/c~0d/1", "/a~1b/c~0d/2/e", "/empty/f", "/name", "/none", "/x.y"}) {
+		t.Errorf("Unexpected paths %v", paths)
+	}
+
+	if leaves := NewValue("x").ListLeaves(); !reflect.DeepEqual(leaves, []Leaf{{"", STRING}}) {
+		t.Errorf("Expected the empty pointer for a string, got %v", leaves)
+	}
+	if leaves := NewValueFromBytes([]byte(`{`)).ListLeaves(); leaves != nil {
+		t.Errorf("Expected no leaves for NOT_JSON, got %v", leaves)
+	}
+}
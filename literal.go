@@ -0,0 +1,81 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+)
+
+// matchLiteral determines if bytes hold a single true, false, null or number literal,
+// optionally surrounded by whitespace, returning its type.  This is much cheaper than
+// running the full validator on the small scalars typically found by Path() and Index().
+// Anything else (including malformed literals) is left to the validator.
+func matchLiteral(b []byte) (int, bool) {
+	b = bytes.Trim(b, " \t\r\n")
+	if len(b) == 0 {
+		return 0, false
+	}
+	switch b[0] {
+	case 't':
+		return BOOLEAN, string(b) == "true"
+	case 'f':
+		return BOOLEAN, string(b) == "false"
+	case 'n':
+		return NULL, string(b) == "null"
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return NUMBER, matchNumber(b)
+	}
+	return 0, false
+}
+
+// matchNumber determines if b is exactly a number as defined by the JSON grammar:
+//
+//	-? (0 | [1-9][0-9]*) (\.[0-9]+)? ([eE][+-]?[0-9]+)?
+func matchNumber(b []byte) bool {
+	i := 0
+	if b[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(b) && b[i] == '0':
+		i++
+	case i < len(b) && b[i] >= '1' && b[i] <= '9':
+		i = skipDigits(b, i)
+	default:
+		return false
+	}
+	if i < len(b) && b[i] == '.' {
+		end := skipDigits(b, i+1)
+		if end == i+1 {
+			return false
+		}
+		i = end
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		end := skipDigits(b, i)
+		if end == i {
+			return false
+		}
+		i = end
+	}
+	return i == len(b)
+}
+
+// skipDigits returns the offset of the first non-digit in b at or after i.
+func skipDigits(b []byte, i int) int {
+	for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,60 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestMatchLiteral(t *testing.T) {
+	var tests = []struct {
+		input   string
+		matched bool
+		typ     int
+	}{
+		{"true", true, BOOLEAN},
+		{" false\n", true, BOOLEAN},
+		{"null", true, NULL},
+		{"0", true, NUMBER},
+		{"-12.5e+3", true, NUMBER},
+		{"1E9", true, NUMBER},
+		{"tru", false, 0},
+		{"nulll", false, 0},
+		{"01", false, 0},
+		{"-", false, 0},
+		{"1.", false, 0},
+		{"1e", false, 0},
+		{"1 2", false, 0},
+		{`"a"`, false, 0},
+		{"{}", false, 0},
+		{"", false, 0},
+	}
+	for _, test := range tests {
+		typ, matched := matchLiteral([]byte(test.input))
+		if matched != test.matched || (matched && typ != test.typ) {
+			t.Errorf("Expected %v (%d) for %q, got %v (%d)", test.matched, test.typ, test.input, matched, typ)
+		}
+		// the result must agree with the validator
+		val := NewValueFromBytes([]byte(test.input))
+		if matched && val.Type() != test.typ {
+			t.Errorf("Expected type %d for %q, got %d", test.typ, test.input, val.Type())
+		}
+		if !matched && test.input != `"a"` && test.input != "{}" && val.Type() != NOT_JSON {
+			t.Errorf("Expected NOT_JSON for %q, got %d", test.input, val.Type())
+		}
+	}
+}
+
+func BenchmarkScalarFromBytes(b *testing.B) {
+	scalars := [][]byte{[]byte("true"), []byte("null"), []byte("-12.5e3")}
+	for i := 0; i < b.N; i++ {
+		NewValueFromBytes(scalars[i%len(scalars)])
+	}
+}
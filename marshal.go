@@ -0,0 +1,29 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+// ValueMarshaler is implemented by types which can convert themselves into a Value.
+// Values of these types may be passed directly to NewValue(), SetPath() and SetIndex().
+//
+// Implementations for annotated structs can be generated with the dparvalgen command,
+// avoiding the cost of reflection at runtime.
+type ValueMarshaler interface {
+	MarshalValue() *Value
+}
+
+// ValueUnmarshaler is implemented by types which can populate themselves from a Value.
+type ValueUnmarshaler interface {
+	UnmarshalValue(*Value) error
+}
+
+// Populate the target from this Value. This is a convenience for calling target.UnmarshalValue().
+func (this *Value) Unmarshal(target ValueUnmarshaler) error {
+	return target.UnmarshalValue(this)
+}
@@ -0,0 +1,53 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testContact struct {
+	Name string
+}
+
+func (this testContact) MarshalValue() *Value {
+	return NewValue(map[string]interface{}{"name": this.Name})
+}
+
+func (this *testContact) UnmarshalValue(val *Value) error {
+	name, err := val.Path("name")
+	if err != nil {
+		return err
+	}
+	s, ok := name.Value().(string)
+	if !ok {
+		return fmt.Errorf("name must be a string")
+	}
+	this.Name = s
+	return nil
+}
+
+func TestValueMarshaler(t *testing.T) {
+	val := NewValue([]interface{}{testContact{"marty"}})
+	out := val.Bytes()
+	if string(out) != `[{"name":"marty"}]` {
+		t.Errorf("Expected [{\"name\":\"marty\"}], got %s", string(out))
+	}
+
+	contact := testContact{}
+	err := NewValueFromBytes([]byte(`{"name":"gerald"}`)).Unmarshal(&contact)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if contact.Name != "gerald" {
+		t.Errorf("Expected gerald, got %s", contact.Name)
+	}
+}
@@ -0,0 +1,112 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"container/heap"
+)
+
+// The order of a sorted stream of Values
+type SortOrder int
+
+const (
+	ASCENDING SortOrder = iota
+	DESCENDING
+)
+
+// Merge channels which are each sorted by the value at the dotted path (as for FillTemplate())
+// into a single channel sorted the same way, which is closed once every channel is closed.
+// Values are ordered as by Compare(), and Values without the path are lower than all others.
+// Equal Values are taken from the earlier channel in chs first.
+//
+// Only the key of the first Value waiting on each channel is extracted, so the Values are
+// only parsed as far as the path.
+func MergeSorted(chs []ValueChannel, path string, order SortOrder) ValueChannel {
+	rv := make(ValueChannel)
+	go func() {
+		defer close(rv)
+		heads := mergeHeap{order: order}
+		for i, ch := range chs {
+			if entry, ok := receiveEntry(i, ch, path); ok {
+				heads.entries = append(heads.entries, entry)
+			}
+		}
+		heap.Init(&heads)
+		for heads.Len() > 0 {
+			head := heads.entries[0]
+			rv <- head.val
+			if entry, ok := receiveEntry(head.source, chs[head.source], path); ok {
+				heads.entries[0] = entry
+				heap.Fix(&heads, 0)
+			} else {
+				heap.Pop(&heads)
+			}
+		}
+	}()
+	return rv
+}
+
+type mergeEntry struct {
+	val    *Value
+	key    *Value // nil if val does not have the path
+	source int
+}
+
+type mergeHeap struct {
+	entries []*mergeEntry
+	order   SortOrder
+}
+
+// receiveEntry waits for the next Value on ch, returning false if ch has been closed.
+func receiveEntry(source int, ch ValueChannel, path string) (*mergeEntry, bool) {
+	val, ok := <-ch
+	if !ok {
+		return nil, false
+	}
+	rv := mergeEntry{val: val, source: source}
+	rv.key, _ = resolvePath(val, path)
+	return &rv, true
+}
+
+func (this *mergeHeap) Len() int { return len(this.entries) }
+func (this *mergeHeap) Swap(i, j int) {
+	this.entries[i], this.entries[j] = this.entries[j], this.entries[i]
+}
+
+func (this *mergeHeap) Less(i, j int) bool {
+	a, b := this.entries[i], this.entries[j]
+	cmp := 0
+	switch {
+	case a.key == nil && b.key == nil:
+	case a.key == nil:
+		cmp = -1
+	case b.key == nil:
+		cmp = 1
+	default:
+		cmp = a.key.Compare(b.key)
+	}
+	if this.order == DESCENDING {
+		cmp = -cmp
+	}
+	if cmp == 0 {
+		return a.source < b.source
+	}
+	return cmp < 0
+}
+
+func (this *mergeHeap) Push(x interface{}) {
+	this.entries = append(this.entries, x.(*mergeEntry))
+}
+
+func (this *mergeHeap) Pop() interface{} {
+	last := this.entries[len(this.entries)-1]
+	this.entries = this.entries[:len(this.entries)-1]
+	return last
+}
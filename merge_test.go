@@ -0,0 +1,58 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sortedChannel(docs ...string) ValueChannel {
+	rv := make(ValueChannel)
+	go func() {
+		defer close(rv)
+		for _, doc := range docs {
+			rv <- NewValueFromBytes([]byte(doc))
+		}
+	}()
+	return rv
+}
+
+func TestMergeSorted(t *testing.T) {
+	merged := MergeSorted([]ValueChannel{
+		sortedChannel(`{"id":"a","n":1}`, `{"id":"b","n":4}`, `{"id":"c","n":9}`),
+		sortedChannel(`{"id":"d"}`, `{"id":"e","n":4}`),
+		sortedChannel(),
+		sortedChannel(`{"id":"f","n":2}`),
+	}, "n", ASCENDING)
+
+	var ids []interface{}
+	for val := range merged {
+		id, _ := val.Path("id")
+		ids = append(ids, id.Value())
+	}
+	expected := []interface{}{"d", "a", "f", "b", "e", "c"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected %v, got %v", expected, ids)
+	}
+
+	merged = MergeSorted([]ValueChannel{
+		sortedChannel(`{"a":{"n":3}}`, `{"a":{"n":1}}`),
+		sortedChannel(`{"a":{"n":2}}`),
+	}, "a.n", DESCENDING)
+	var ns []interface{}
+	for val := range merged {
+		n, _ := resolvePath(val, "a.n")
+		ns = append(ns, n.Value())
+	}
+	if !reflect.DeepEqual(ns, []interface{}{3.0, 2.0, 1.0}) {
+		t.Errorf("Expected 3, 2, 1, got %v", ns)
+	}
+}
@@ -0,0 +1,201 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The IDs of the messages which LocalizeError() looks up in the catalog of the current locale.
+// Each message may refer to the details of the error using the placeholders listed.
+const (
+	MSG_UNDEFINED                = "undefined"                // {path}
+	MSG_UNDEFINED_UNKNOWN        = "undefined_unknown"        // (none)
+	MSG_TYPE_MISMATCH            = "type_mismatch"            // {path} {expected} {actual}
+	MSG_TYPE_MISMATCH_UNKNOWN    = "type_mismatch_unknown"    // {expected} {actual}
+	MSG_ARGUMENT_TYPE            = "argument_type"            // {function} {position} {expected} {actual}
+	MSG_UNBOUND_PARAMETER        = "unbound_parameter"        // {name}
+	MSG_DEPTH_EXCEEDED           = "depth_exceeded"           // {max_depth} {offset}
+	MSG_OUTPUT_SIZE              = "output_size"              // {limit}
+	MSG_UNSUPPORTED_CONTENT_TYPE = "unsupported_content_type" // {content_type}
+	MSG_TRAILING_DATA            = "trailing_data"            // {offset}
+	MSG_UNKNOWN_PROPERTY         = "unknown_property"         // {path}
+	MSG_CONSTRAINT_VIOLATION     = "constraint_violation"     // {constraint} {path}
+	MSG_CONSTRAINT_ERROR         = "constraint_error"         // {constraint} {path} {error}
+	MSG_POINTER_SYNTAX           = "pointer_syntax"           // {pointer}
+	MSG_PARALLEL_ERROR           = "parallel_error"           // {index} {error}
+	MSG_PARALLEL_ERRORS          = "parallel_errors"          // {index} {error} {count}
+)
+
+// The locale whose messages are built in.
+const DEFAULT_LOCALE = "en"
+
+var messagesMutex sync.RWMutex
+var currentLocale = DEFAULT_LOCALE
+var catalogs = map[string]map[string]string{
+	DEFAULT_LOCALE: {
+		MSG_UNDEFINED:                "{path} is not defined",
+		MSG_UNDEFINED_UNKNOWN:        "not defined",
+		MSG_TYPE_MISMATCH:            "{path} must be {expected}, got {actual}",
+		MSG_TYPE_MISMATCH_UNKNOWN:    "expected {expected}, got {actual}",
+		MSG_ARGUMENT_TYPE:            "argument {position} of {function} must be {expected}, got {actual}",
+		MSG_UNBOUND_PARAMETER:        "parameter ${name} is not bound",
+		MSG_DEPTH_EXCEEDED:           "maximum depth {max_depth} exceeded at offset {offset}",
+		MSG_OUTPUT_SIZE:              "serialized value exceeds {limit} bytes",
+		MSG_UNSUPPORTED_CONTENT_TYPE: "unsupported content type {content_type}",
+		MSG_TRAILING_DATA:            "unexpected data after the first value at offset {offset}",
+		MSG_UNKNOWN_PROPERTY:         "{path} is not allowed by the schema",
+		MSG_CONSTRAINT_VIOLATION:     "constraint {constraint} on {path} is violated",
+		MSG_CONSTRAINT_ERROR:         "constraint {constraint} on {path} could not be evaluated: {error}",
+		MSG_POINTER_SYNTAX:           "{pointer} is not a valid JSON Pointer",
+		MSG_PARALLEL_ERROR:           "value {index}: {error}",
+		MSG_PARALLEL_ERRORS:          "value {index}: {error} (and {count} more errors)",
+	},
+}
+
+// Register the messages of a locale, keyed by message ID (the MSG_ constants).  Messages
+// already registered for the locale are replaced, those missing fall back to DEFAULT_LOCALE.
+func RegisterMessages(locale string, messages map[string]string) {
+	messagesMutex.Lock()
+	defer messagesMutex.Unlock()
+	catalog, ok := catalogs[locale]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		catalogs[locale] = catalog
+	}
+	for id, message := range messages {
+		catalog[id] = message
+	}
+}
+
+// Set the locale used by LocalizeError().
+func SetLocale(locale string) {
+	messagesMutex.Lock()
+	defer messagesMutex.Unlock()
+	currentLocale = locale
+}
+
+// Return the locale used by LocalizeError().
+func Locale() string {
+	messagesMutex.RLock()
+	defer messagesMutex.RUnlock()
+	return currentLocale
+}
+
+// Return a message describing err, or the first error with a message ID it wraps, in the
+// current locale, suitable for showing to end users.  Errors without a message ID (including
+// those not from this package) are described by Error(), and a nil error by "".
+//
+// Error() itself always returns the DEFAULT_LOCALE message, so logs do not depend on the locale.
+func LocalizeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var localizable interface {
+		message() (string, map[string]string)
+	}
+	if !errors.As(err, &localizable) {
+		return err.Error()
+	}
+	id, args := localizable.message()
+
+	messagesMutex.RLock()
+	message, ok := catalogs[currentLocale][id]
+	if !ok {
+		message = catalogs[DEFAULT_LOCALE][id]
+	}
+	messagesMutex.RUnlock()
+
+	replacements := make([]string, 0, 2*len(args))
+	for name, val := range args {
+		replacements = append(replacements, "{"+name+"}", val)
+	}
+	return strings.NewReplacer(replacements...).Replace(message)
+}
+
+func (this *Undefined) message() (string, map[string]string) {
+	if this.Path == "" {
+		return MSG_UNDEFINED_UNKNOWN, nil
+	}
+	return MSG_UNDEFINED, map[string]string{"path": this.Path}
+}
+
+func (this *TypeMismatch) message() (string, map[string]string) {
+	args := map[string]string{
+		"path":     this.Path,
+		"expected": typeNames[this.Expected],
+		"actual":   typeNames[this.Actual],
+	}
+	if this.Path == "" {
+		return MSG_TYPE_MISMATCH_UNKNOWN, args
+	}
+	return MSG_TYPE_MISMATCH, args
+}
+
+func (this *ArgumentError) message() (string, map[string]string) {
+	return MSG_ARGUMENT_TYPE, map[string]string{
+		"function": this.Function,
+		"position": strconv.Itoa(this.Position),
+		"expected": typeNames[this.Expected],
+		"actual":   typeNames[this.Actual],
+	}
+}
+
+func (this *UnboundParameter) message() (string, map[string]string) {
+	return MSG_UNBOUND_PARAMETER, map[string]string{"name": this.Name}
+}
+
+func (this *DepthExceeded) message() (string, map[string]string) {
+	return MSG_DEPTH_EXCEEDED, map[string]string{
+		"max_depth": strconv.Itoa(this.MaxDepth),
+		"offset":    strconv.FormatInt(this.Offset, 10),
+	}
+}
+
+func (this *OutputSizeError) message() (string, map[string]string) {
+	return MSG_OUTPUT_SIZE, map[string]string{"limit": strconv.Itoa(this.Limit)}
+}
+
+func (this *UnsupportedContentType) message() (string, map[string]string) {
+	return MSG_UNSUPPORTED_CONTENT_TYPE, map[string]string{"content_type": this.ContentType}
+}
+
+func (this *TrailingDataError) message() (string, map[string]string) {
+	return MSG_TRAILING_DATA, map[string]string{"offset": strconv.Itoa(this.Offset)}
+}
+
+func (this *UnknownProperty) message() (string, map[string]string) {
+	return MSG_UNKNOWN_PROPERTY, map[string]string{"path": this.Path}
+}
+
+func (this *ConstraintViolation) message() (string, map[string]string) {
+	args := map[string]string{"constraint": this.Constraint, "path": this.Path}
+	if this.Err != nil {
+		args["error"] = LocalizeError(this.Err)
+		return MSG_CONSTRAINT_ERROR, args
+	}
+	return MSG_CONSTRAINT_VIOLATION, args
+}
+
+func (this *PointerError) message() (string, map[string]string) {
+	return MSG_POINTER_SYNTAX, map[string]string{"pointer": strconv.Quote(this.Pointer)}
+}
+
+func (this *ParallelError) message() (string, map[string]string) {
+	args := map[string]string{"index": strconv.Itoa(this.Indexes[0]), "error": LocalizeError(this.Errors[0])}
+	if len(this.Errors) > 1 {
+		args["count"] = strconv.Itoa(len(this.Errors) - 1)
+		return MSG_PARALLEL_ERRORS, args
+	}
+	return MSG_PARALLEL_ERROR, args
+}
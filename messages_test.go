@@ -0,0 +1,70 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLocalizeError(t *testing.T) {
+	errs := []error{
+		&Undefined{Path: "name"},
+		&Undefined{},
+		&TypeMismatch{Path: "items", Expected: ARRAY, Actual: STRING},
+		&TypeMismatch{Expected: OBJECT, Actual: NULL},
+		&ArgumentError{Function: "ABS", Position: 1, Expected: NUMBER, Actual: STRING},
+		&UnboundParameter{Name: "limit"},
+		&DepthExceeded{MaxDepth: 3, Offset: 12},
+		&OutputSizeError{Limit: 100},
+		&UnsupportedContentType{ContentType: "text/csv"},
+		&TrailingDataError{Offset: 7},
+		&UnknownProperty{Path: "extra"},
+		&ConstraintViolation{Path: "price", Constraint: "price >= 0"},
+		&ConstraintViolation{Path: "price", Constraint: "price >= 0", Err: &UnboundParameter{Name: "min"}},
+		&PointerError{Pointer: "a/b"},
+		&ParallelError{Indexes: []int{2}, Errors: []error{&Undefined{Path: "id"}}},
+		&ParallelError{Indexes: []int{2, 5}, Errors: []error{&Undefined{Path: "id"}, fmt.Errorf("other")}},
+		fmt.Errorf("other"),
+	}
+	// the default messages match Error()
+	for _, err := range errs {
+		if LocalizeError(err) != err.Error() {
+			t.Errorf("Expected %q, got %q", err.Error(), LocalizeError(err))
+		}
+	}
+
+	RegisterMessages("fr", map[string]string{
+		MSG_UNDEFINED: "{path} n'est pas défini",
+	})
+	SetLocale("fr")
+	defer SetLocale(DEFAULT_LOCALE)
+	if Locale() != "fr" {
+		t.Errorf("Expected locale fr, got %s", Locale())
+	}
+	if actual := LocalizeError(errs[0]); actual != "name n'est pas défini" {
+		t.Errorf("Expected french message, got %q", actual)
+	}
+	// missing messages fall back to the default locale
+	if actual := LocalizeError(errs[5]); actual != "parameter $limit is not bound" {
+		t.Errorf("Expected default message, got %q", actual)
+	}
+	// wrapped errors are found, as by errors.As()
+	if actual := LocalizeError(fmt.Errorf("loading: %w", errs[0])); actual != "name n'est pas défini" {
+		t.Errorf("Expected french message for a wrapped error, got %q", actual)
+	}
+	if actual := LocalizeError(nil); actual != "" {
+		t.Errorf("Expected an empty message for nil, got %q", actual)
+	}
+	// Error() does not depend on the locale
+	if errs[0].Error() != "name is not defined" {
+		t.Errorf("Expected default message from Error(), got %q", errs[0].Error())
+	}
+}
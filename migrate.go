@@ -0,0 +1,91 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"sort"
+)
+
+// A Migration upgrades documents to a Version of their schema.
+type Migration struct {
+	Version int // the version of the documents once migrated, greater than 0
+
+	// Match, if not nil, determines if the Migration applies to a document.
+	// Documents it rejects are passed on unchanged by this Migration.
+	Match func(val *Value) bool
+
+	// Transform returns the migrated document, which may be val changed in place.
+	// Returning a nil Value (and a nil error) drops the document.
+	Transform func(val *Value) (*Value, error)
+}
+
+// The attachment key under which Migrate() stores the version of the last Migration applied.
+const APPLIED_VERSION_ATTACHMENT = "appliedVersion"
+
+// Return the Version of the last Migration Migrate() applied to this Value, or 0 if there is none.
+func (this *Value) AppliedVersion() int {
+	version, _ := this.GetAttachment(APPLIED_VERSION_ATTACHMENT).(int)
+	return version
+}
+
+// Apply migrations to each Value received on ch, sending the results on the returned channel,
+// which is closed once ch is closed.  The migrations are applied in order of Version, skipping
+// those no greater than the AppliedVersion() of the Value, so a Value which has already been
+// migrated is not migrated again.  The version is an attachment, so it is not written by a
+// SpillWriter: documents read back from a spill file (or any other store) start at version 0
+// unless the caller restores APPLIED_VERSION_ATTACHMENT from their contents.
+// After each Migration the Value records its Version (see AppliedVersion()).  When Transform
+// returns a new Value, it is given the attachments and score of the Value it replaces, as for Salvage().
+//
+// If a Transform fails, no more migrations are applied to that Value, which is sent on with the
+// error attached (see StageError()) and the version of the last Migration which succeeded.
+func Migrate(ch ValueChannel, migrations []Migration) ValueChannel {
+	sorted := append([]Migration(nil), migrations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	rv := make(ValueChannel)
+	go func() {
+		defer close(rv)
+		for val := range ch {
+			val = migrate(val, sorted)
+			if val != nil {
+				rv <- val
+			}
+		}
+	}()
+	return rv
+}
+
+// migrate applies the sorted migrations to val, returning nil if one drops it.
+func migrate(val *Value, migrations []Migration) *Value {
+	version := val.AppliedVersion()
+	for _, migration := range migrations {
+		if migration.Version <= version || (migration.Match != nil && !migration.Match(val)) {
+			continue
+		}
+		out, err := migration.Transform(val)
+		if err != nil {
+			val.SetAttachment(STAGE_ERROR_ATTACHMENT, err)
+			return val
+		}
+		if out == nil {
+			return nil
+		}
+		if out != val {
+			for k, v := range val.attachments {
+				out.SetAttachment(k, v)
+			}
+			out.score = val.score
+		}
+		val = out
+		version = migration.Version
+		val.SetAttachment(APPLIED_VERSION_ATTACHMENT, version)
+	}
+	return val
+}
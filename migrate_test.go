@@ -0,0 +1,94 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	migrations := []Migration{
+		{
+			// split name into first and last, only for documents which have one
+			Version: 2,
+			Match:   func(val *Value) bool { return val.ExistsPath("name") },
+			Transform: func(val *Value) (*Value, error) {
+				name, _ := val.Path("name")
+				s, ok := name.Value().(string)
+				if !ok {
+					return nil, fmt.Errorf("name is not a string")
+				}
+				val.RemovePath("name")
+				val.SetPath("first", s[:1])
+				val.SetPath("last", s[1:])
+				return val, nil
+			},
+		},
+		{
+			// add a version property, dropping documents marked for deletion
+			Version: 1,
+			Transform: func(val *Value) (*Value, error) {
+				if val.ExistsPath("drop") {
+					return nil, nil
+				}
+				val.SetPath("v", 1.0)
+				return val, nil
+			},
+		},
+		{
+			// wrap the document in an envelope
+			Version: 3,
+			Transform: func(val *Value) (*Value, error) {
+				return NewValue(map[string]interface{}{"doc": val.Value()}), nil
+			},
+		},
+	}
+
+	already := NewValueFromBytes([]byte(`{"name": "ab"}`))
+	already.SetAttachment(APPLIED_VERSION_ATTACHMENT, 2)
+	in := make(ValueChannel)
+	go func() {
+		defer close(in)
+		in <- NewValueFromMutation(&Mutation{Key: "k1", Body: []byte(`{"name": "ab"}`)})
+		in <- NewValueFromBytes([]byte(`{"id": 1}`))
+		in <- NewValueFromBytes([]byte(`{"drop": true}`))
+		in <- NewValueFromBytes([]byte(`{"name": 5}`))
+		in <- already
+	}()
+
+	var actual []interface{}
+	var versions []int
+	var results ValueCollection
+	for val := range Migrate(in, migrations) {
+		actual = append(actual, val.Value())
+		versions = append(versions, val.AppliedVersion())
+		results = append(results, val)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"doc": map[string]interface{}{"v": 1.0, "first": "a", "last": "b"}},
+		map[string]interface{}{"doc": map[string]interface{}{"v": 1.0, "id": 1.0}},
+		map[string]interface{}{"v": 1.0, "name": 5.0},
+		map[string]interface{}{"doc": map[string]interface{}{"name": "ab"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if !reflect.DeepEqual(versions, []int{3, 3, 1, 3}) {
+		t.Errorf("Expected versions [3 3 1 3], got %v", versions)
+	}
+	if meta := results[0].Meta(); meta == nil || meta.Key != "k1" {
+		t.Errorf("Expected the meta to be kept, got %v", meta)
+	}
+	if results[2].StageError() == nil {
+		t.Errorf("Expected the failed migration to be attached")
+	}
+}
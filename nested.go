@@ -0,0 +1,61 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+
+	jsonpointer "github.com/dustin/go-jsonpointer"
+)
+
+// canFindNested determines if a path beginning with first can be resolved by findNested():
+// this Value has only its raw bytes, unmodified, and first has not already been resolved.
+func (this *Value) canFindNested(first string) bool {
+	if this.parsedType != OBJECT && this.parsedType != ARRAY {
+		return false
+	}
+	_, resolved := this.children[first]
+	return this.raw != nil && this.parsedValue == nil && this.alias == nil && !resolved
+}
+
+// findNested resolves a path of several steps inside the raw bytes of this Value with
+// a single scan, descending through the document once.  Resolving one step at a time
+// would scan each level to its end before descending into it, making deep paths cost
+// the size of the document times their depth.
+//
+// The Values between this Value and the result are never created, so unlike Path()
+// changes made to the result are not seen by this Value.  Use it only to read.
+//
+// Numeric steps index arrays (and name properties of objects), as in resolvePath().
+// The return bool is false if the steps cannot be resolved this way (see canFindNested()).
+func (this *Value) findNested(steps []string) (*Value, bool, error) {
+	if len(steps) < 2 || !this.canFindNested(steps[0]) {
+		return nil, false, nil
+	}
+	pointer := strings.Builder{}
+	for _, step := range steps {
+		// JSON Pointer only accepts canonical array indexes
+		if index, err := strconv.Atoi(step); err == nil && (index < 0 || strconv.Itoa(index) != step) {
+			return nil, false, nil
+		}
+		pointer.WriteByte('/')
+		pointer.WriteString(escapePointer(step))
+	}
+	path := strings.Join(steps, ".")
+	res, err := jsonpointer.Find(this.raw, pointer.String())
+	if err != nil {
+		return nil, true, this.locateSyntaxError(path, err)
+	}
+	if res == nil {
+		return nil, true, &Undefined{path}
+	}
+	return this.newChild(res), true, nil
+}
@@ -0,0 +1,91 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindNested(t *testing.T) {
+	doc := []byte(`{"a": {"b": [10, {"c/d": "x", "0": "zero"}]}, "e": [[1, 2]]}`)
+	var tests = []struct {
+		path     string
+		expected interface{}
+	}{
+		{"a.b.1.c/d", "x"},
+		{"a.b.0", 10.0},
+		{"a.b.1.0", "zero"},
+		{"e.0.1", 2.0},
+		{"a.b.2", nil},
+		{"a.x.c", nil},
+		{"a.b.01", map[string]interface{}{"c/d": "x", "0": "zero"}},
+	}
+	for _, test := range tests {
+		val, err := resolvePath(NewValueFromBytes(doc), test.path)
+		if test.expected == nil {
+			if _, ok := err.(*Undefined); !ok {
+				t.Errorf("Expected *Undefined for %s, got %v", test.path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.path)
+			continue
+		}
+		if !reflect.DeepEqual(val.Value(), test.expected) {
+			t.Errorf("Expected %v for %s, got %v", test.expected, test.path, val.Value())
+		}
+	}
+
+	// a modified step is seen
+	val := NewValueFromBytes(doc)
+	a, _ := val.Path("a")
+	a.SetPath("b", "changed")
+	if _, err := resolvePath(val, "a.b.0"); err == nil {
+		t.Errorf("Expected modified step to be honored")
+	}
+
+	// expressions resolve nested paths the same way
+	expr, err := ParseExpression(`a.b[1]["c/d"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv, _ := expr.Eval(NewValueFromBytes(doc)); rv == nil || rv.Value() != "x" {
+		t.Errorf("Expected x, got %v", rv)
+	}
+}
+
+// deepDocument nests depth objects, each with a large property before the next level.
+func deepDocument(depth int) ([]byte, string) {
+	padding := `"` + strings.Repeat("x", 1000) + `"`
+	doc := "1"
+	for i := 0; i < depth; i++ {
+		doc = fmt.Sprintf(`{"pad":%s,"next":%s}`, padding, doc)
+	}
+	return []byte(doc), strings.TrimSuffix(strings.Repeat("next.", depth), ".")
+}
+
+func BenchmarkNestedPath(b *testing.B) {
+	for _, depth := range []int{4, 16, 64} {
+		doc, path := deepDocument(depth)
+		b.Run(fmt.Sprintf("depth%d", depth), func(b *testing.B) {
+			b.SetBytes(int64(len(doc)))
+			for i := 0; i < b.N; i++ {
+				val, err := resolvePath(NewValueFromBytes(doc), path)
+				if err != nil || val.Type() != NUMBER {
+					b.Fatalf("expected number, got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// The policies for bringing nil maps (map[string]interface{}(nil)) and nil slices
+// ([]interface{}(nil)) into the type system
+const (
+	NIL_COLLECTIONS_AS_EMPTY = iota // a nil map is an empty OBJECT, a nil slice is an empty ARRAY (the default)
+	NIL_COLLECTIONS_AS_NULL         // nil maps and nil slices are Values of type NULL
+)
+
+var nilCollectionPolicy int32 = NIL_COLLECTIONS_AS_EMPTY
+
+// Set the policy used by NewValue() (and so by SetPath() and SetIndex()) for nil maps
+// and nil slices, for the whole application.  Nested nil collections are handled the same way.
+func SetNilCollectionPolicy(policy int) {
+	switch policy {
+	case NIL_COLLECTIONS_AS_EMPTY, NIL_COLLECTIONS_AS_NULL:
+		atomic.StoreInt32(&nilCollectionPolicy, int32(policy))
+	default:
+		panic(fmt.Sprintf("unknown nil collection policy %d", policy))
+	}
+}
+
+// Return the policy for nil collections set by SetNilCollectionPolicy().
+func NilCollectionPolicy() int {
+	return int(atomic.LoadInt32(&nilCollectionPolicy))
+}
+
+func nilCollectionsAsNull() bool {
+	return NilCollectionPolicy() == NIL_COLLECTIONS_AS_NULL
+}
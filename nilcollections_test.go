@@ -0,0 +1,51 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestNilCollectionPolicy(t *testing.T) {
+	defer SetNilCollectionPolicy(NIL_COLLECTIONS_AS_EMPTY)
+
+	doc := map[string]interface{}{
+		"m": map[string]interface{}(nil),
+		"s": []interface{}(nil),
+	}
+
+	tests := []struct {
+		policy   int
+		input    interface{}
+		typ      int
+		expected string
+	}{
+		{NIL_COLLECTIONS_AS_EMPTY, map[string]interface{}(nil), OBJECT, `{}`},
+		{NIL_COLLECTIONS_AS_EMPTY, []interface{}(nil), ARRAY, `[]`},
+		{NIL_COLLECTIONS_AS_EMPTY, doc, OBJECT, `{"m":{},"s":[]}`},
+		{NIL_COLLECTIONS_AS_NULL, map[string]interface{}(nil), NULL, `null`},
+		{NIL_COLLECTIONS_AS_NULL, []interface{}(nil), NULL, `null`},
+		{NIL_COLLECTIONS_AS_NULL, doc, OBJECT, `{"m":null,"s":null}`},
+		// empty but non-nil collections are unaffected
+		{NIL_COLLECTIONS_AS_NULL, map[string]interface{}{}, OBJECT, `{}`},
+		{NIL_COLLECTIONS_AS_NULL, []interface{}{}, ARRAY, `[]`},
+	}
+
+	for _, test := range tests {
+		SetNilCollectionPolicy(test.policy)
+		val := NewValue(test.input)
+		if val.Type() != test.typ {
+			t.Errorf("Expected type %d, got %d for %#v", test.typ, val.Type(), test.input)
+		}
+		if string(val.Bytes()) != test.expected {
+			t.Errorf("Expected %s, got %s for %#v", test.expected, val.Bytes(), test.input)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// The policies for bringing non-finite numbers (NaN, +Inf and -Inf), which JSON cannot
+// represent, into the type system
+const (
+	NON_FINITE_PANIC     = iota // NewValue() panics, as for any other unsupported value (the default)
+	NON_FINITE_AS_NULL          // the number becomes a Value of type NULL
+	NON_FINITE_AS_STRING        // the number becomes a Value of type STRING: "NaN", "Infinity" or "-Infinity"
+)
+
+var nonFinitePolicy int32 = NON_FINITE_PANIC
+
+// Set the policy used by NewValue() (and so by SetPath() and SetIndex()) for non-finite
+// numbers, for the whole application.
+func SetNonFinitePolicy(policy int) {
+	switch policy {
+	case NON_FINITE_PANIC, NON_FINITE_AS_NULL, NON_FINITE_AS_STRING:
+		atomic.StoreInt32(&nonFinitePolicy, int32(policy))
+	default:
+		panic(fmt.Sprintf("unknown non-finite policy %d", policy))
+	}
+}
+
+// Return the policy for non-finite numbers set by SetNonFinitePolicy().
+func NonFinitePolicy() int {
+	return int(atomic.LoadInt32(&nonFinitePolicy))
+}
+
+func isNonFinite(val float64) bool {
+	return math.IsNaN(val) || math.IsInf(val, 0)
+}
+
+func newNonFiniteValue(val float64) *Value {
+	switch NonFinitePolicy() {
+	case NON_FINITE_AS_NULL:
+		return newNullValue()
+	case NON_FINITE_AS_STRING:
+		switch {
+		case math.IsNaN(val):
+			return newStringValue("NaN")
+		case val > 0:
+			return newStringValue("Infinity")
+		default:
+			return newStringValue("-Infinity")
+		}
+	}
+	panic(fmt.Sprintf("Cannot create value for non-finite number %v", val))
+}
@@ -0,0 +1,47 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNonFinitePolicy(t *testing.T) {
+	defer SetNonFinitePolicy(NON_FINITE_PANIC)
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Expected panic creating a Value for NaN")
+			}
+		}()
+		NewValue(math.NaN())
+	}()
+
+	SetNonFinitePolicy(NON_FINITE_AS_NULL)
+	val := NewValue(map[string]interface{}{"a": math.Inf(1), "b": []interface{}{math.NaN()}})
+	if string(val.Bytes()) != `{"a":null,"b":[null]}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+
+	SetNonFinitePolicy(NON_FINITE_AS_STRING)
+	val = NewValue([]interface{}{math.NaN(), math.Inf(1), math.Inf(-1), 1.5})
+	if string(val.Bytes()) != `["NaN","Infinity","-Infinity",1.5]` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+
+	// overflow in expressions is null regardless of the policy
+	SetNonFinitePolicy(NON_FINITE_PANIC)
+	result, err := Eval(`1e308 * 10`, NewValue(nil))
+	if err != nil || result.Type() != NULL {
+		t.Errorf("Expected null, got %v, %v", result, err)
+	}
+}
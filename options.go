@@ -0,0 +1,264 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	json "github.com/dustin/gojson"
+)
+
+// The ways numbers may be represented when a Value is parsed
+const (
+	FLOAT_NUMBERS = iota // numbers are parsed into float64 (the default)
+	EXACT_NUMBERS        // numbers are parsed into json.Number, preserving their exact text
+)
+
+// The orders in which the keys of an OBJECT may be reported by Fields()
+const (
+	SORTED_KEYS         = iota // keys are sorted (the default)
+	DOCUMENT_ORDER_KEYS        // keys are in the order they appear in the raw bytes, followed by keys added with SetPath()
+)
+
+// The policies for the raw bytes of a Value once they have been parsed
+const (
+	KEEP_RAW             = iota // the raw bytes are kept (the default)
+	DROP_RAW_AFTER_PARSE        // the raw bytes are released, Bytes() re-encodes the parsed value
+	DROP_RAW_IF_CLEAN           // the raw bytes are released only if re-encoding the parsed value reproduces them exactly
+)
+
+// The policies for bytes following the first JSON value
+const (
+	TRAILING_DATA_NOT_JSON = iota // the Value is NOT_JSON, as for NewValueFromBytes() (the default)
+	TRAILING_DATA_ERROR           // the return error is *TrailingDataError
+	TRAILING_DATA_IGNORE          // the Value holds only the first value
+)
+
+// ParseOptions control how NewValueFromBytesWithOptions() creates a Value.  The options are
+// inherited by any Values accessed inside of it through Path() and Index().
+type ParseOptions struct {
+	MaxDepth int  // the maximum nesting of objects and arrays, 0 means no limit
+	Numbers  int  // FLOAT_NUMBERS or EXACT_NUMBERS
+	Strict   bool // return *SyntaxError for invalid JSON, instead of a Value of type NOT_JSON
+	KeyOrder int  // SORTED_KEYS or DOCUMENT_ORDER_KEYS
+
+	// Keys, if not nil, is used to share the keys of parsed objects with other
+	// Values parsed with the same KeyInterner
+	Keys *KeyInterner
+
+	// Objects and arrays smaller than EagerBelow bytes are parsed immediately, as for
+	// small documents parsing once costs less than scanning the raw bytes on every
+	// access.  Larger documents stay lazy.  0 means documents are never parsed eagerly.
+	// This does not apply with DOCUMENT_ORDER_KEYS, which needs the raw bytes.
+	EagerBelow int
+
+	// RawRetention is KEEP_RAW, DROP_RAW_AFTER_PARSE or DROP_RAW_IF_CLEAN.  Dropping the raw
+	// bytes saves memory when many parsed documents are kept, for example in a cache.
+	// This does not apply with DOCUMENT_ORDER_KEYS, which needs the raw bytes.
+	RawRetention int
+
+	// RFC8259, if set, rejects anything RFC 8259 does not allow, including strings which
+	// are not valid UTF-8, with a *SyntaxError describing the first problem.  This is for
+	// gateways whose results must match those of other strict parsers exactly.
+	RFC8259 bool
+
+	// TrailingData is TRAILING_DATA_NOT_JSON, TRAILING_DATA_ERROR or TRAILING_DATA_IGNORE.
+	// Reporting or ignoring the bytes after a valid first value surfaces framing bugs, which
+	// otherwise just produce a NOT_JSON Value.  With TRAILING_DATA_IGNORE the raw bytes of
+	// the Value are those consumed: the first value, and any whitespace following it.
+	TrailingData int
+
+	// Shapes, if not nil, records the shapes of parsed objects, so that objects with the
+	// same keys are parsed into maps and slices allocated at the right size up front
+	Shapes *ShapePool
+}
+
+// When bytes follow the first JSON value, and ParseOptions.TrailingData is TRAILING_DATA_ERROR,
+// the return error will be *TrailingDataError.
+type TrailingDataError struct {
+	Offset int // the number of bytes consumed: the first value, and any whitespace following it
+}
+
+// Description of where the trailing data begins.
+func (this *TrailingDataError) Error() string {
+	return fmt.Sprintf("unexpected data after the first value at offset %d", this.Offset)
+}
+
+// When a document is nested more deeply than allowed by ParseOptions.MaxDepth,
+// the return error will be *DepthExceeded.
+type DepthExceeded struct {
+	MaxDepth int
+	Offset   int64 // byte offset at which the limit was exceeded
+}
+
+// Description of the depth limit which was exceeded.
+func (this *DepthExceeded) Error() string {
+	return fmt.Sprintf("maximum depth %d exceeded at offset %d", this.MaxDepth, this.Offset)
+}
+
+// Create a new Value object from a slice of bytes, honoring the specified options.
+func NewValueFromBytesWithOptions(bytes []byte, options ParseOptions) (*Value, error) {
+	rv := NewValueFromBytes(bytes)
+	if rv.parsedType == NOT_JSON && options.TrailingData != TRAILING_DATA_NOT_JSON {
+		prefix := validator{}
+		end, msg := prefix.validatePrefix(bytes, false)
+		if msg == "" && end < len(bytes) {
+			if options.TrailingData == TRAILING_DATA_ERROR {
+				return nil, &TrailingDataError{Offset: end}
+			}
+			bytes = bytes[:end]
+			rv = NewValueFromBytes(bytes)
+		}
+	}
+	if options.RFC8259 {
+		strict := validator{}
+		offset, msg := strict.validate(bytes, true)
+		if msg != "" {
+			return nil, syntaxErrorAt(bytes, "", int64(offset), msg)
+		}
+	}
+	if rv.parsedType == NOT_JSON {
+		if options.Strict {
+			return nil, newSyntaxError(bytes, "", json.Validate(bytes))
+		}
+	} else if options.MaxDepth > 0 {
+		offset, exceeded := exceedsDepth(bytes, options.MaxDepth)
+		if exceeded {
+			return nil, &DepthExceeded{options.MaxDepth, int64(offset)}
+		}
+	}
+	if options != (ParseOptions{}) {
+		rv.options = &options
+	}
+	if len(bytes) < options.EagerBelow && options.KeyOrder != DOCUMENT_ORDER_KEYS {
+		err := rv.parseEager()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
+type optionsKey struct{}
+
+// Return a copy of ctx carrying the specified options, for use by NewValueFromBytesContext().
+func WithOptions(ctx context.Context, options ParseOptions) context.Context {
+	return context.WithValue(ctx, optionsKey{}, options)
+}
+
+// Return the options carried by ctx, or the default options if there are none.
+func FromContext(ctx context.Context) ParseOptions {
+	options, _ := ctx.Value(optionsKey{}).(ParseOptions)
+	return options
+}
+
+// Create a new Value object from a slice of bytes, honoring the options carried by ctx.
+// This allows options to be set once (for example per request) and honored by code which
+// has no knowledge of them.
+func NewValueFromBytesContext(ctx context.Context, bytes []byte) (*Value, error) {
+	return NewValueFromBytesWithOptions(bytes, FromContext(ctx))
+}
+
+// parseRaw parses the raw bytes of this Value into parsedValue, honoring
+// the number mode, shape pool and key interner of its options.
+func (this *Value) parseRaw() error {
+	return this.parseRawContext(context.Background())
+}
+
+func (this *Value) parseRawContext(ctx context.Context) (err error) {
+	release, err := admitParse(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if hooks := currentSpanHooks(); hooks != nil {
+		size, span := len(this.raw), hooks.start(PARSE_OPERATION)
+		defer func() {
+			hooks.end(span, PARSE_OPERATION, size, err)
+		}()
+	}
+	if this.options != nil && this.options.Numbers == EXACT_NUMBERS {
+		decoder := json.NewDecoder(bytes.NewReader(this.raw))
+		decoder.UseNumber()
+		err = decoder.Decode(&this.parsedValue)
+	} else if this.options != nil && this.options.Shapes != nil && this.parsedType == OBJECT {
+		var parsedValue interface{}
+		parsedValue, err = this.options.Shapes.parse(this.raw)
+		if err == nil {
+			this.parsedValue = parsedValue
+		}
+	} else {
+		err = json.Unmarshal(this.raw, &this.parsedValue)
+	}
+	if err != nil {
+		return err
+	}
+	if this.keyInterner() != nil {
+		this.keyInterner().internTree(this.parsedValue)
+	}
+	this.retainRaw()
+	return nil
+}
+
+// retainRaw releases the raw bytes of this parsed Value, if its options allow it.
+func (this *Value) retainRaw() {
+	if this.options == nil || this.documentOrder() {
+		return
+	}
+	switch this.options.RawRetention {
+	case DROP_RAW_AFTER_PARSE:
+		this.raw = nil
+	case DROP_RAW_IF_CLEAN:
+		encoded, err := json.Marshal(this.parsedValue)
+		if err == nil && bytes.Equal(encoded, this.raw) {
+			this.raw = nil
+		}
+	}
+}
+
+// parseEager replaces the raw bytes of this OBJECT or ARRAY with the Values parsed from them.
+func (this *Value) parseEager() error {
+	if this.parsedType != OBJECT && this.parsedType != ARRAY {
+		return nil
+	}
+	err := this.parseRaw()
+	if err != nil {
+		return err
+	}
+	this.parsedValue = NewValue(this.parsedValue).parsedValue
+	this.raw = nil
+	return nil
+}
+
+// newParsedChild creates a Value for a parsed value found inside this Value, which
+// inherits the options of this Value.
+func (this *Value) newParsedChild(val interface{}) *Value {
+	var rv *Value
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		rv = NewValueFromParsed(val)
+	default:
+		rv = NewValue(val)
+	}
+	if rv.parsedType == OBJECT || rv.parsedType == ARRAY {
+		rv.options = this.options
+	}
+	return rv
+}
+
+// newChild creates a Value for raw bytes found inside this Value, which
+// inherits the options of this Value.
+func (this *Value) newChild(raw []byte) *Value {
+	rv := NewValueFromBytes(raw)
+	rv.options = this.options
+	return rv
+}
@@ -0,0 +1,182 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	json "github.com/dustin/gojson"
+)
+
+func TestParseOptions(t *testing.T) {
+	var tests = []struct {
+		input   []byte
+		options ParseOptions
+		err     error
+	}{
+		{[]byte(`{"a":[{"b":"[[["}]}`), ParseOptions{MaxDepth: 3}, nil},
+		{[]byte(`{"a":[{"b":[]}]}`), ParseOptions{MaxDepth: 3}, &DepthExceeded{3, 11}},
+		{[]byte(`abc`), ParseOptions{}, nil},
+		{[]byte(`abc`), ParseOptions{Strict: true}, &SyntaxError{}},
+	}
+
+	for _, test := range tests {
+		_, err := NewValueFromBytesWithOptions(test.input, test.options)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("Expected error %v, got %v for %s", test.err, err, string(test.input))
+		}
+		if depthErr, ok := err.(*DepthExceeded); ok && !reflect.DeepEqual(depthErr, test.err) {
+			t.Errorf("Expected error %v, got %v for %s", test.err, err, string(test.input))
+		}
+	}
+}
+
+func TestExactNumbers(t *testing.T) {
+	ctx := WithOptions(context.Background(), ParseOptions{Numbers: EXACT_NUMBERS})
+	if FromContext(ctx).Numbers != EXACT_NUMBERS {
+		t.Errorf("Expected options from context")
+	}
+
+	val, err := NewValueFromBytesContext(ctx, []byte(`{"id":12345678901234567890,"nested":{"n":1.50}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := map[string]interface{}{
+		"id":     json.Number("12345678901234567890"),
+		"nested": map[string]interface{}{"n": json.Number("1.50")},
+	}
+	if !reflect.DeepEqual(val.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Value())
+	}
+
+	// options are inherited by nested values
+	nested, _ := val.Path("nested")
+	n, _ := nested.Path("n")
+	if n.Value() != json.Number("1.50") {
+		t.Errorf("Expected json.Number 1.50, got %#v", n.Value())
+	}
+
+	// exact numbers can be brought back into the type system
+	val = NewValue(map[string]interface{}{"id": json.Number("12345678901234567890")})
+	if string(val.Bytes()) != `{"id":12345678901234567890}` {
+		t.Errorf("Unexpected bytes %s", string(val.Bytes()))
+	}
+}
+
+func TestEagerParse(t *testing.T) {
+	options := ParseOptions{EagerBelow: 32, Numbers: EXACT_NUMBERS}
+	small, err := NewValueFromBytesWithOptions([]byte(`{"a":{"b":1.50}}`), options)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if small.raw != nil {
+		t.Errorf("Expected small document to be parsed eagerly")
+	}
+	a, _ := small.Path("a")
+	b, _ := a.Path("b")
+	if b.Value() != json.Number("1.50") {
+		t.Errorf("Expected json.Number 1.50, got %#v", b.Value())
+	}
+	if string(small.Bytes()) != `{"a":{"b":1.50}}` {
+		t.Errorf("Unexpected bytes %s", small.Bytes())
+	}
+
+	large, _ := NewValueFromBytesWithOptions([]byte(`{"a":{"b":1.50},"c":"a longer string"}`), options)
+	if large.raw == nil || large.parsedValue != nil {
+		t.Errorf("Expected large document to stay lazy")
+	}
+
+	ordered, _ := NewValueFromBytesWithOptions([]byte(`{"b":1,"a":2}`), ParseOptions{EagerBelow: 32, KeyOrder: DOCUMENT_ORDER_KEYS})
+	if string(ordered.Bytes()) != `{"b":1,"a":2}` {
+		t.Errorf("Unexpected bytes %s", ordered.Bytes())
+	}
+}
+
+func TestRawRetention(t *testing.T) {
+	doc := []byte(`{"a": {"b": [1, 2]}, "c": "x"}`)
+
+	kept, _ := NewValueFromBytesWithOptions(doc, ParseOptions{RawRetention: KEEP_RAW})
+	kept.Value()
+	if kept.raw == nil {
+		t.Errorf("Expected raw bytes to be kept")
+	}
+
+	clean, _ := NewValueFromBytesWithOptions(doc, ParseOptions{RawRetention: DROP_RAW_IF_CLEAN})
+	clean.Value()
+	if clean.raw == nil {
+		t.Errorf("Expected raw bytes which do not re-encode exactly to be kept")
+	}
+	clean, _ = NewValueFromBytesWithOptions([]byte(`{"a":{"b":[1,2]},"c":"x"}`), ParseOptions{RawRetention: DROP_RAW_IF_CLEAN})
+	clean.Value()
+	if clean.raw != nil {
+		t.Errorf("Expected clean raw bytes to be dropped")
+	}
+
+	dropped, _ := NewValueFromBytesWithOptions(doc, ParseOptions{RawRetention: DROP_RAW_AFTER_PARSE})
+	before := dropped.Value()
+	if dropped.raw != nil {
+		t.Errorf("Expected raw bytes to be dropped")
+	}
+	a, err := dropped.Path("a")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	b, _ := a.Path("b")
+	two, err := b.Index(1)
+	if err != nil || two.Value() != 2.0 {
+		t.Errorf("Expected 2, got %v, %v", two, err)
+	}
+	if fields := dropped.Fields(); !reflect.DeepEqual(fields, []string{"a", "c"}) {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	if n, _ := a.CountPath("b"); n != 2 {
+		t.Errorf("Expected 2 elements, got %d", n)
+	}
+
+	b.SetIndex(0, "y")
+	if string(dropped.Bytes()) != `{"a":{"b":["y",2]},"c":"x"}` {
+		t.Errorf("Unexpected bytes %s", dropped.Bytes())
+	}
+	if !reflect.DeepEqual(before, map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, 2.0}}, "c": "x"}) {
+		t.Errorf("Expected earlier result of Value() not to change, got %v", before)
+	}
+}
+
+func TestTrailingData(t *testing.T) {
+	input := []byte(`{"a": 1}  {"b": 2}`)
+
+	val, err := NewValueFromBytesWithOptions(input, ParseOptions{})
+	if err != nil || val.Type() != NOT_JSON {
+		t.Errorf("Expected NOT_JSON by default, got %v", err)
+	}
+
+	_, err = NewValueFromBytesWithOptions(input, ParseOptions{TrailingData: TRAILING_DATA_ERROR})
+	if terr, ok := err.(*TrailingDataError); !ok || terr.Offset != 10 {
+		t.Errorf("Expected *TrailingDataError at offset 10, got %#v", err)
+	}
+
+	val, err = NewValueFromBytesWithOptions(input, ParseOptions{TrailingData: TRAILING_DATA_IGNORE})
+	if err != nil || val.Type() != OBJECT {
+		t.Fatalf("Expected the first object, got %v", err)
+	}
+	if string(val.Bytes()) != `{"a": 1}  ` {
+		t.Errorf("Expected the consumed bytes, got %q", val.Bytes())
+	}
+
+	// invalid JSON, or a single value, is not trailing data
+	for _, input := range []string{`{"a": }`, `{"a": 1}  `} {
+		_, err = NewValueFromBytesWithOptions([]byte(input), ParseOptions{TrailingData: TRAILING_DATA_ERROR})
+		if err != nil {
+			t.Errorf("Expected no error for %s, got %v", input, err)
+		}
+	}
+}
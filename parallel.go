@@ -0,0 +1,91 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// When fn fails for any of the Values given to ForEachParallel(), or ctx is done before every
+// Value has been given to fn, the return error is *ParallelError.
+type ParallelError struct {
+	Indexes []int   // the positions in the collection of the Values which failed, in order
+	Errors  []error // the error for each of them
+}
+
+// Description of the first error, and how many more there are.
+func (this *ParallelError) Error() string {
+	if len(this.Errors) > 1 {
+		return fmt.Sprintf("value %d: %v (and %d more errors)", this.Indexes[0], this.Errors[0], len(this.Errors)-1)
+	}
+	return fmt.Sprintf("value %d: %v", this.Indexes[0], this.Errors[0])
+}
+
+// Return every error, so errors.Is() and errors.As() find any of them.
+func (this *ParallelError) Unwrap() []error {
+	return this.Errors
+}
+
+// Call fn for every Value in coll, from at most n goroutines at once, 0 means GOMAXPROCS.
+// Every Value is given to fn even if fn fails for others, and ForEachParallel() returns
+// once fn has returned for all of them.  If ctx is done, no more Values are given to fn,
+// and the first of them is reported with the error of ctx.  To stop at the first failure,
+// fn can cancel ctx.
+//
+// If fn fails for any Values, or ctx is done, the return error is *ParallelError.
+func ForEachParallel(ctx context.Context, coll ValueCollection, n int, fn func(*Value) error) error {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if n > len(coll) {
+		n = len(coll)
+	}
+	errs := make([]error, len(coll))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= len(coll) {
+					return
+				}
+				errs[i] = fn(coll[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rv ParallelError
+	started := int(next.Load())
+	if started > len(coll) {
+		started = len(coll)
+	}
+	for i, err := range errs[:started] {
+		if err != nil {
+			rv.Indexes = append(rv.Indexes, i)
+			rv.Errors = append(rv.Errors, err)
+		}
+	}
+	if started < len(coll) {
+		rv.Indexes = append(rv.Indexes, started)
+		rv.Errors = append(rv.Errors, ctx.Err())
+	}
+	if rv.Errors == nil {
+		return nil
+	}
+	return &rv
+}
@@ -0,0 +1,89 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachParallel(t *testing.T) {
+	coll := make(ValueCollection, 20)
+	for i := range coll {
+		coll[i] = NewValue(float64(i))
+	}
+
+	var mutex sync.Mutex
+	seen := make(map[float64]bool)
+	var active, maxActive int32
+	err := ForEachParallel(context.Background(), coll, 3, func(val *Value) error {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		mutex.Lock()
+		seen[val.Value().(float64)] = true
+		if n > maxActive {
+			maxActive = n
+		}
+		mutex.Unlock()
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(seen) != len(coll) || maxActive > 3 {
+		t.Errorf("Expected all %d values with at most 3 at once, got %d with %d at once", len(coll), len(seen), maxActive)
+	}
+
+	// every failure is reported, in order
+	err = ForEachParallel(context.Background(), coll, 0, func(val *Value) error {
+		if n := val.Value().(float64); int(n)%7 == 3 {
+			return &Undefined{Path: fmt.Sprint(n)}
+		}
+		return nil
+	})
+	parallelErr, ok := err.(*ParallelError)
+	if !ok || !reflect.DeepEqual(parallelErr.Indexes, []int{3, 10, 17}) || len(parallelErr.Errors) != 3 {
+		t.Fatalf("Expected failures at 3, 10 and 17, got %v", err)
+	}
+	if err.Error() != "value 3: 3 is not defined (and 2 more errors)" || ErrorCategory(err) != UNDEFINED {
+		t.Errorf("Unexpected error %q with category %d", err, ErrorCategory(err))
+	}
+	var undefined *Undefined
+	if !errors.As(err, &undefined) || undefined.Path != "3" {
+		t.Errorf("Expected to find the first *Undefined, got %v", undefined)
+	}
+
+	// cancelling stops more values being started
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	err = ForEachParallel(ctx, coll, 2, func(val *Value) error {
+		if atomic.AddInt32(&calls, 1) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || calls >= int32(len(coll)) {
+		t.Errorf("Expected cancellation after about 5 values, got %v after %d", err, calls)
+	}
+	if parallelErr, ok := err.(*ParallelError); !ok || parallelErr.Indexes[0] != int(calls) {
+		t.Errorf("Expected the first value not started to be reported, got %v after %d", err, calls)
+	}
+
+	if err := ForEachParallel(context.Background(), nil, 4, nil); err != nil {
+		t.Errorf("Unexpected error %v for an empty collection", err)
+	}
+}
@@ -0,0 +1,472 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	json "github.com/dustin/gojson"
+)
+
+// The column WriteParquet() writes the rest of each document to, when it is given no schema.
+const PARQUET_JSON_COLUMN = "_json"
+
+// The Parquet enums used by WriteParquet() (see parquet.thrift)
+const (
+	parquetBoolean   = 0 // physical types
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8 = 0 // converted types
+	parquetJSON = 19
+
+	parquetRequired = 0 // repetition types
+	parquetOptional = 1
+
+	parquetPlain = 0 // encodings
+	parquetRLE   = 3
+)
+
+var parquetMagic = []byte("PAR1")
+
+// Write the documents of coll, which must all be of type OBJECT, to w as a Parquet file with
+// a single row group.  Each column is written as one uncompressed PLAIN data page.
+//
+// If schema is nil, the documents are shredded as by NewColumnarBatch(): each Column becomes
+// a required column, of type DOUBLE, BYTE_ARRAY (UTF8) or BOOLEAN, and the rest of each document
+// is written as JSON to the column PARQUET_JSON_COLUMN, unless it is empty in every document.
+//
+// Otherwise schema is an OBJECT mapping the path of each column (resolved in each document as by
+// Path(), so "address.city" flattens a nested property) to the type of its values:
+//
+//         1. "number", written as DOUBLE.
+//         2. "integer", written exactly as INT64.
+//         3. "string", written as BYTE_ARRAY (UTF8).
+//         4. "boolean", written as BOOLEAN.
+//         5. "json", the Bytes() of a value of any type, written as BYTE_ARRAY (JSON).
+//
+// These columns are optional, a document where the path is missing or null has null in the column.
+//
+// If a document is not an OBJECT, or a value does not have the type of its column, the return
+// error is *TypeMismatch.  If a value of an "integer" column is not an integer which fits in
+// 64 bits, the return error is *OutOfRange.
+func WriteParquet(w io.Writer, coll ValueCollection, schema *Value) error {
+	var columns []*parquetColumn
+	var err error
+	if schema == nil {
+		columns, err = shreddedColumns(coll)
+	} else {
+		columns, err = schemaColumns(coll, schema)
+	}
+	if err != nil {
+		return err
+	}
+	return writeParquetFile(w, columns, len(coll))
+}
+
+// A parquetColumn holds the values of one column, encoded for a PLAIN data page.
+type parquetColumn struct {
+	name      string
+	physical  int
+	converted int // -1 if there is none
+	optional  bool
+	defined   []bool // for an optional column, whether each row has a value
+	bools     []bool // the values of a BOOLEAN column, packed when the page is written
+	values    bytes.Buffer
+}
+
+func newParquetColumn(name string, physical, converted int, optional bool) *parquetColumn {
+	return &parquetColumn{name: name, physical: physical, converted: converted, optional: optional}
+}
+
+func (this *parquetColumn) double(f float64) {
+	binary.Write(&this.values, binary.LittleEndian, math.Float64bits(f))
+}
+
+func (this *parquetColumn) integer(i int64) {
+	binary.Write(&this.values, binary.LittleEndian, i)
+}
+
+func (this *parquetColumn) byteArray(b []byte) {
+	binary.Write(&this.values, binary.LittleEndian, uint32(len(b)))
+	this.values.Write(b)
+}
+
+// add appends the value of an optional column for the next row, where val is nil
+// if the path of the column was not found.  The path is used to report errors.
+func (this *parquetColumn) add(path string, val *Value) error {
+	if val == nil || val.Type() == NULL {
+		this.defined = append(this.defined, false)
+		return nil
+	}
+	expected := NUMBER
+	switch {
+	case this.converted == parquetJSON:
+		expected = val.Type()
+	case this.physical == parquetByteArray:
+		expected = STRING
+	case this.physical == parquetBoolean:
+		expected = BOOLEAN
+	}
+	if val.Type() != expected {
+		return &TypeMismatch{Path: path, Expected: expected, Actual: val.Type()}
+	}
+	switch {
+	case this.converted == parquetJSON:
+		this.byteArray(val.Bytes())
+	case this.physical == parquetByteArray:
+		this.byteArray([]byte(val.Value().(string)))
+	case this.physical == parquetBoolean:
+		this.bools = append(this.bools, val.Value().(bool))
+	case this.physical == parquetInt64:
+		i, ok := parquetInteger(val.Value())
+		if !ok {
+			return &OutOfRange{Value: val.Value(), msg: fmt.Sprintf("%s must be a 64 bit integer", path)}
+		}
+		this.integer(i)
+	default:
+		f, ok := val.Value().(float64)
+		if !ok {
+			f, _ = val.Value().(json.Number).Float64()
+		}
+		this.double(f)
+	}
+	this.defined = append(this.defined, true)
+	return nil
+}
+
+// parquetInteger returns the number n as an int64, if it is an integer which fits.
+func parquetInteger(n interface{}) (int64, bool) {
+	if f, ok := n.(float64); ok {
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	i, err := strconv.ParseInt(string(n.(json.Number)), 10, 64)
+	if err != nil {
+		f, err := n.(json.Number).Float64()
+		if err != nil {
+			return 0, false
+		}
+		return parquetInteger(f)
+	}
+	return i, true
+}
+
+// page returns the body of the data page of this column.
+func (this *parquetColumn) page() []byte {
+	buf := bytes.Buffer{}
+	if this.optional {
+		// the definition levels, as a single bit-packed run of the RLE hybrid encoding
+		levels := binary.AppendUvarint(nil, uint64((len(this.defined)+7)/8)<<1|1)
+		levels = append(levels, packBits(this.defined)...)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(levels)))
+		buf.Write(levels)
+	}
+	if this.physical == parquetBoolean {
+		buf.Write(packBits(this.bools))
+	}
+	buf.Write(this.values.Bytes())
+	return buf.Bytes()
+}
+
+// packBits packs bits 8 to a byte, beginning with the least significant bit.
+func packBits(bits []bool) []byte {
+	rv := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			rv[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return rv
+}
+
+// shreddedColumns returns the columns of coll as shredded by NewColumnarBatch().
+func shreddedColumns(coll ValueCollection) ([]*parquetColumn, error) {
+	batch, err := NewColumnarBatch(coll)
+	if err != nil {
+		return nil, err
+	}
+	var rv []*parquetColumn
+	for _, column := range batch.Columns() {
+		if column.Name == PARQUET_JSON_COLUMN {
+			return nil, fmt.Errorf("parquet: the property %s clashes with the column for the rest of each document", column.Name)
+		}
+		switch column.Type {
+		case NUMBER:
+			pc := newParquetColumn(column.Name, parquetDouble, -1, false)
+			for _, f := range column.Numbers {
+				pc.double(f)
+			}
+			rv = append(rv, pc)
+		case STRING:
+			pc := newParquetColumn(column.Name, parquetByteArray, parquetUTF8, false)
+			for _, s := range column.Strings {
+				pc.byteArray([]byte(s))
+			}
+			rv = append(rv, pc)
+		case BOOLEAN:
+			pc := newParquetColumn(column.Name, parquetBoolean, -1, false)
+			pc.bools = column.Bools
+			rv = append(rv, pc)
+		}
+	}
+	residual := newParquetColumn(PARQUET_JSON_COLUMN, parquetByteArray, parquetJSON, false)
+	empty := true
+	for _, rest := range batch.residual {
+		residual.byteArray(rest)
+		empty = empty && len(rest) == 2
+	}
+	if !empty {
+		rv = append(rv, residual)
+	}
+	return rv, nil
+}
+
+// schemaColumns returns the columns of coll described by schema, see WriteParquet().
+func schemaColumns(coll ValueCollection, schema *Value) ([]*parquetColumn, error) {
+	if schema.Type() != OBJECT {
+		return nil, &TypeMismatch{Path: "schema", Expected: OBJECT, Actual: schema.Type()}
+	}
+	members := schema.members()
+	var rv []*parquetColumn
+	for _, name := range sortedMemberKeys(members) {
+		var pc *parquetColumn
+		switch members[name].Value() {
+		case "number":
+			pc = newParquetColumn(name, parquetDouble, -1, true)
+		case "integer":
+			pc = newParquetColumn(name, parquetInt64, -1, true)
+		case "string":
+			pc = newParquetColumn(name, parquetByteArray, parquetUTF8, true)
+		case "boolean":
+			pc = newParquetColumn(name, parquetBoolean, -1, true)
+		case "json":
+			pc = newParquetColumn(name, parquetByteArray, parquetJSON, true)
+		default:
+			return nil, fmt.Errorf("parquet: unknown type %s for column %s", members[name].Bytes(), name)
+		}
+		rv = append(rv, pc)
+	}
+	for i, doc := range coll {
+		if doc.Type() != OBJECT {
+			return nil, &TypeMismatch{Path: fmt.Sprintf("document %d", i), Expected: OBJECT, Actual: doc.Type()}
+		}
+		for _, pc := range rv {
+			val, err := doc.Path(pc.name)
+			if _, ok := err.(*Undefined); err != nil && !ok {
+				return nil, err
+			}
+			err = pc.add(fmt.Sprintf("document %d: %s", i, pc.name), val)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rv, nil
+}
+
+// writeParquetFile writes the columns as a Parquet file with one row group of rows rows.
+func writeParquetFile(w io.Writer, columns []*parquetColumn, rows int) error {
+	offset := int64(0)
+	write := func(b []byte) error {
+		n, err := w.Write(b)
+		offset += int64(n)
+		return err
+	}
+	err := write(parquetMagic)
+	if err != nil {
+		return err
+	}
+
+	chunkOffsets := make([]int64, len(columns))
+	chunkSizes := make([]int64, len(columns))
+	for i, column := range columns {
+		page := column.page()
+		if len(page) > math.MaxInt32 {
+			return fmt.Errorf("parquet: column %s is too large for one page", column.name)
+		}
+		header := newThriftWriter()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.begin(5) // DataPageHeader
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunkOffsets[i] = offset
+		chunkSizes[i] = int64(header.buf.Len() + len(page))
+		err = write(header.buf.Bytes())
+		if err != nil {
+			return err
+		}
+		err = write(page)
+		if err != nil {
+			return err
+		}
+	}
+
+	meta := newThriftWriter() // FileMetaData
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(columns)+1)
+	meta.element()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+	for _, column := range columns {
+		meta.element() // SchemaElement
+		meta.i32(1, int32(column.physical))
+		if column.optional {
+			meta.i32(3, parquetOptional)
+		} else {
+			meta.i32(3, parquetRequired)
+		}
+		meta.binary(4, []byte(column.name))
+		if column.converted >= 0 {
+			meta.i32(6, int32(column.converted))
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(rows))
+	meta.list(4, thriftStruct, 1)
+	meta.element() // RowGroup
+	meta.list(1, thriftStruct, len(columns))
+	total := int64(0)
+	for i, column := range columns {
+		meta.element() // ColumnChunk
+		meta.i64(2, chunkOffsets[i])
+		meta.begin(3) // ColumnMetaData
+		meta.i32(1, int32(column.physical))
+		meta.list(2, thriftI32, 2)
+		meta.varint(parquetPlain)
+		meta.varint(parquetRLE)
+		meta.list(3, thriftBinary, 1)
+		meta.binaryElement([]byte(column.name))
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(rows))
+		meta.i64(6, chunkSizes[i])
+		meta.i64(7, chunkSizes[i])
+		meta.i64(9, chunkOffsets[i])
+		meta.end()
+		meta.end()
+		total += chunkSizes[i]
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.end()
+	meta.binary(6, []byte("dparval"))
+	meta.end()
+
+	err = write(meta.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	footer := binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len()))
+	err = write(append(footer, parquetMagic...))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// The Thrift compact protocol types used by Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a Thrift struct with the compact protocol, as Parquet metadata is.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // the id of the last field written to each struct being written
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+// field writes the header of a field, as a delta from the previous field where it can.
+func (this *thriftWriter) field(id int16, typ byte) {
+	delta := id - this.last[len(this.last)-1]
+	if delta > 0 && delta <= 15 {
+		this.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		this.buf.WriteByte(typ)
+		this.varint(int64(id))
+	}
+	this.last[len(this.last)-1] = id
+}
+
+// varint writes a zigzag encoded integer, which is also how an i32 list element is written.
+func (this *thriftWriter) varint(v int64) {
+	this.buf.Write(binary.AppendUvarint(nil, uint64(v<<1)^uint64(v>>63)))
+}
+
+// binaryElement writes a binary value without a field header, as a list element is written.
+func (this *thriftWriter) binaryElement(b []byte) {
+	this.buf.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	this.buf.Write(b)
+}
+
+func (this *thriftWriter) i32(id int16, v int32) {
+	this.field(id, thriftI32)
+	this.varint(int64(v))
+}
+
+func (this *thriftWriter) i64(id int16, v int64) {
+	this.field(id, thriftI64)
+	this.varint(v)
+}
+
+func (this *thriftWriter) binary(id int16, b []byte) {
+	this.field(id, thriftBinary)
+	this.binaryElement(b)
+}
+
+// list writes the header of a list of n elements of type typ, which are then written in turn.
+func (this *thriftWriter) list(id int16, typ byte, n int) {
+	this.field(id, thriftList)
+	if n < 15 {
+		this.buf.WriteByte(byte(n)<<4 | typ)
+	} else {
+		this.buf.WriteByte(0xf0 | typ)
+		this.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+	}
+}
+
+// begin starts a struct field, whose fields are then written, followed by end().
+func (this *thriftWriter) begin(id int16) {
+	this.field(id, thriftStruct)
+	this.last = append(this.last, 0)
+}
+
+// element starts a struct which is an element of a list, ended by end().
+func (this *thriftWriter) element() {
+	this.last = append(this.last, 0)
+}
+
+func (this *thriftWriter) end() {
+	this.buf.WriteByte(0)
+	this.last = this.last[:len(this.last)-1]
+}
@@ -0,0 +1,265 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	json "github.com/dustin/gojson"
+)
+
+// thriftReader decodes the Thrift compact protocol, returning each struct as a map of field id to value.
+type thriftReader struct {
+	b []byte
+	i int
+}
+
+func (this *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(this.b[this.i:])
+	this.i += n
+	return v
+}
+
+func (this *thriftReader) varint() int64 {
+	u := this.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (this *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return this.varint()
+	case thriftBinary:
+		n := int(this.uvarint())
+		this.i += n
+		return string(this.b[this.i-n : this.i])
+	case thriftList:
+		header := this.b[this.i]
+		this.i++
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(this.uvarint())
+		}
+		rv := make([]interface{}, n)
+		for k := range rv {
+			rv[k] = this.value(header & 0xf)
+		}
+		return rv
+	case thriftStruct:
+		return this.structure()
+	}
+	panic("unexpected thrift type")
+}
+
+func (this *thriftReader) structure() map[int]interface{} {
+	rv := map[int]interface{}{}
+	last := 0
+	for {
+		header := this.b[this.i]
+		this.i++
+		if header == 0 {
+			return rv
+		}
+		id := last + int(header>>4)
+		if header>>4 == 0 {
+			id = int(this.varint())
+		}
+		rv[id] = this.value(header & 0xf)
+		last = id
+	}
+}
+
+// readParquet decodes the columns of a file written by WriteParquet(), returning the names of the
+// columns and the value of each in every row, nil for null.  It checks the structure of the file.
+func readParquet(t *testing.T, file []byte) ([]string, [][]interface{}) {
+	if !bytes.HasPrefix(file, parquetMagic) || !bytes.HasSuffix(file, parquetMagic) {
+		t.Fatalf("Expected the Parquet magic")
+	}
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	reader := &thriftReader{b: file[:len(file)-8], i: len(file) - 8 - size}
+	meta := reader.structure()
+	if reader.i != len(file)-8 {
+		t.Fatalf("Expected the metadata to fill the footer")
+	}
+	rows := int(meta[3].(int64))
+	schema := meta[2].([]interface{})
+	if schema[0].(map[int]interface{})[5].(int64) != int64(len(schema)-1) {
+		t.Fatalf("Unexpected root schema element %v", schema[0])
+	}
+	groups := meta[4].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("Expected one row group, got %d", len(groups))
+	}
+	chunks := groups[0].(map[int]interface{})[1].([]interface{})
+
+	var names []string
+	var columns [][]interface{}
+	for i, element := range schema[1:] {
+		element := element.(map[int]interface{})
+		name := element[4].(string)
+		physical := element[1].(int64)
+		optional := element[3].(int64) == parquetOptional
+		chunk := chunks[i].(map[int]interface{})[3].(map[int]interface{})
+		if chunk[1].(int64) != physical || chunk[3].([]interface{})[0] != name || chunk[5].(int64) != int64(rows) {
+			t.Fatalf("Unexpected column chunk %v for %s", chunk, name)
+		}
+
+		reader := &thriftReader{b: file, i: int(chunk[9].(int64))}
+		header := reader.structure()
+		end := reader.i + int(header[3].(int64))
+		if int64(end)-chunk[9].(int64) != chunk[7].(int64) || header[5].(map[int]interface{})[1].(int64) != int64(rows) {
+			t.Fatalf("Unexpected page header %v for %s", header, name)
+		}
+		page := file[reader.i:end]
+
+		defined := make([]bool, rows)
+		for k := range defined {
+			defined[k] = true
+		}
+		if optional {
+			levels := &thriftReader{b: page, i: 4}
+			if levels.uvarint() != uint64((rows+7)/8)<<1|1 {
+				t.Fatalf("Expected a single bit-packed run for %s", name)
+			}
+			for k := range defined {
+				defined[k] = page[levels.i+k/8]&(1<<uint(k%8)) != 0
+			}
+			page = page[4+binary.LittleEndian.Uint32(page):]
+		}
+		values := make([]interface{}, rows)
+		bit := 0
+		for k := range values {
+			if !defined[k] {
+				continue
+			}
+			switch physical {
+			case parquetBoolean:
+				values[k] = page[bit/8]&(1<<uint(bit%8)) != 0
+				bit++
+			case parquetInt64:
+				values[k] = int64(binary.LittleEndian.Uint64(page))
+				page = page[8:]
+			case parquetDouble:
+				values[k] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+				page = page[8:]
+			case parquetByteArray:
+				n := binary.LittleEndian.Uint32(page)
+				values[k] = string(page[4 : 4+n])
+				page = page[4+n:]
+			}
+		}
+		names = append(names, name)
+		columns = append(columns, values)
+	}
+	return names, columns
+}
+
+func TestWriteParquet(t *testing.T) {
+	docs := ValueCollection{
+		NewValueFromBytes([]byte(`{"id": 1, "name": "a", "ok": true, "tags": ["x"]}`)),
+		NewValueFromBytes([]byte(`{"id": 2.5, "name": "b", "ok": false, "tags": []}`)),
+		NewValueFromBytes([]byte(`{"id": 3, "name": "", "ok": true, "tags": [], "extra": null}`)),
+	}
+	buf := bytes.Buffer{}
+	err := WriteParquet(&buf, docs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, columns := readParquet(t, buf.Bytes())
+	if !reflect.DeepEqual(names, []string{"id", "name", "ok", PARQUET_JSON_COLUMN}) {
+		t.Fatalf("Unexpected columns %v", names)
+	}
+	expected := [][]interface{}{
+		{1.0, 2.5, 3.0},
+		{"a", "b", ""},
+		{true, false, true},
+		{`{"tags":["x"]}`, `{"tags":[]}`, `{"extra":null,"tags":[]}`},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+
+	// without a rest of the documents, there is no JSON column
+	buf.Reset()
+	WriteParquet(&buf, ValueCollection{NewValue(map[string]interface{}{"n": 1.0})}, nil)
+	if names, _ := readParquet(t, buf.Bytes()); !reflect.DeepEqual(names, []string{"n"}) {
+		t.Errorf("Unexpected columns %v", names)
+	}
+}
+
+func TestWriteParquetSchema(t *testing.T) {
+	docs := ValueCollection{
+		NewValueFromBytes([]byte(`{"id": 1, "price": 9.5, "name": "a", "address": {"city": "x"}, "ok": true}`)),
+		NewValue(map[string]interface{}{"id": json.Number("9007199254740993"), "name": nil, "meta": []interface{}{1.0}}),
+	}
+	for i := 0; i < 8; i++ {
+		docs = append(docs, NewValueFromBytes([]byte(`{"ok": false}`)))
+	}
+	schema := NewValue(map[string]interface{}{
+		"id":           "integer",
+		"price":        "number",
+		"name":         "string",
+		"address.city": "string",
+		"ok":           "boolean",
+		"meta":         "json",
+	})
+	buf := bytes.Buffer{}
+	err := WriteParquet(&buf, docs, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, columns := readParquet(t, buf.Bytes())
+	if !reflect.DeepEqual(names, []string{"address.city", "id", "meta", "name", "ok", "price"}) {
+		t.Fatalf("Unexpected columns %v", names)
+	}
+	nulls := make([]interface{}, 8)
+	falses := []interface{}{false, false, false, false, false, false, false, false}
+	expected := [][]interface{}{
+		append([]interface{}{"x", nil}, nulls...),
+		append([]interface{}{int64(1), int64(9007199254740993)}, nulls...),
+		append([]interface{}{nil, "[1]"}, nulls...),
+		append([]interface{}{"a", nil}, nulls...),
+		append([]interface{}{true, nil}, falses...),
+		append([]interface{}{9.5, nil}, nulls...),
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+}
+
+func TestWriteParquetErrors(t *testing.T) {
+	var tests = []struct {
+		doc    string
+		schema string
+		check  func(error) bool
+	}{
+		{`{"n": "1"}`, `{"n": "number"}`, func(err error) bool { _, ok := err.(*TypeMismatch); return ok }},
+		{`{"n": 1.5}`, `{"n": "integer"}`, func(err error) bool { _, ok := err.(*OutOfRange); return ok }},
+		{`{"n": 1e19}`, `{"n": "integer"}`, func(err error) bool { _, ok := err.(*OutOfRange); return ok }},
+		{`[1]`, `{"n": "number"}`, func(err error) bool { _, ok := err.(*TypeMismatch); return ok }},
+		{`{"n": 1}`, `["n"]`, func(err error) bool { _, ok := err.(*TypeMismatch); return ok }},
+		{`{"n": 1}`, `{"n": "date"}`, func(err error) bool { return err != nil }},
+		{`{"_json": 1}`, ``, func(err error) bool { return err != nil }},
+	}
+	for _, test := range tests {
+		var schema *Value
+		if test.schema != "" {
+			schema = NewValueFromBytes([]byte(test.schema))
+		}
+		err := WriteParquet(&bytes.Buffer{}, ValueCollection{NewValueFromBytes([]byte(test.doc))}, schema)
+		if !test.check(err) {
+			t.Errorf("Unexpected error %v for %s with %s", err, test.doc, test.schema)
+		}
+	}
+}
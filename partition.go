@@ -0,0 +1,70 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"hash/fnv"
+)
+
+// Distribute the Values received on ch across n channels, which are closed once ch is closed.
+// Each Value is sent to channel by(val) % n, so Values with the same hash arrive on the same
+// channel in the order they were received.  If by is nil, Values are distributed round-robin.
+//
+// The channels are unbuffered, so a slow consumer of one channel holds back the others.
+func Partition(ch ValueChannel, n int, by func(*Value) uint64) []ValueChannel {
+	rv := make([]ValueChannel, n)
+	for i := range rv {
+		rv[i] = make(ValueChannel)
+	}
+	go func() {
+		defer func() {
+			for _, out := range rv {
+				close(out)
+			}
+		}()
+		next := uint64(0)
+		for val := range ch {
+			if by == nil {
+				rv[next%uint64(n)] <- val
+				next++
+			} else {
+				rv[by(val)%uint64(n)] <- val
+			}
+		}
+	}()
+	return rv
+}
+
+// Return a function for Partition() which hashes the value at the dotted path (as for
+// FillTemplate()).  Equal values hash the same, however they are written in the raw bytes.
+// Values without the path all hash the same.
+func HashPath(path string) func(*Value) uint64 {
+	return func(val *Value) uint64 {
+		h := fnv.New64a()
+		key, err := resolvePath(val, path)
+		if err == nil {
+			payload, err := key.JWSPayload()
+			if err == nil {
+				h.Write(payload)
+			}
+		}
+		return h.Sum64()
+	}
+}
+
+// A function for Partition() which hashes the key in the Meta of a Value (see FeedChannel()).
+// Values without Meta all hash the same.
+func HashKey(val *Value) uint64 {
+	h := fnv.New64a()
+	if meta := val.Meta(); meta != nil {
+		h.Write([]byte(meta.Key))
+	}
+	return h.Sum64()
+}
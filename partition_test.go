@@ -0,0 +1,86 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// collectPartitions reads every partition concurrently, returning what each received.
+func collectPartitions(chs []ValueChannel) [][]interface{} {
+	rv := make([][]interface{}, len(chs))
+	var wg sync.WaitGroup
+	for i, ch := range chs {
+		wg.Add(1)
+		go func(i int, ch ValueChannel) {
+			defer wg.Done()
+			for val := range ch {
+				rv[i] = append(rv[i], val.Value())
+			}
+		}(i, ch)
+	}
+	wg.Wait()
+	return rv
+}
+
+func TestPartitionRoundRobin(t *testing.T) {
+	ch := make(ValueChannel)
+	go func() {
+		defer close(ch)
+		for i := 0; i < 5; i++ {
+			ch <- NewValue(float64(i))
+		}
+	}()
+	actual := collectPartitions(Partition(ch, 2, nil))
+	expected := [][]interface{}{{0.0, 2.0, 4.0}, {1.0, 3.0}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestPartitionByHash(t *testing.T) {
+	docs := []string{`{"k":"a","n":1}`, `{"k":"b","n":2}`, `{"k":"a","n":3}`, `{"k" : "b","n":4}`, `{"n":5}`}
+	ch := make(ValueChannel)
+	go func() {
+		defer close(ch)
+		for _, doc := range docs {
+			ch <- NewValueFromBytes([]byte(doc))
+		}
+	}()
+	partitions := collectPartitions(Partition(ch, 3, HashPath("k")))
+
+	// each key must arrive on a single partition, in order
+	seen := make(map[interface{}]int)
+	for i, partition := range partitions {
+		last := make(map[interface{}]float64)
+		for _, v := range partition {
+			m := v.(map[string]interface{})
+			if p, ok := seen[m["k"]]; ok && p != i {
+				t.Errorf("Key %v found on partitions %d and %d", m["k"], p, i)
+			}
+			seen[m["k"]] = i
+			if m["n"].(float64) < last[m["k"]] {
+				t.Errorf("Key %v out of order", m["k"])
+			}
+			last[m["k"]] = m["n"].(float64)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected 3 distinct keys, got %v", seen)
+	}
+
+	a := NewValueFromMutation(&Mutation{Key: "x", Body: []byte(`1`)})
+	b := NewValueFromMutation(&Mutation{Key: "x", Body: []byte(`2`)})
+	if HashKey(a) != HashKey(b) || HashKey(a) == HashKey(NewValue(1.0)) {
+		t.Errorf("Expected HashKey to hash the meta key")
+	}
+}
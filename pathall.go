@@ -0,0 +1,85 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+)
+
+// Return every Value found by following steps from this Value, for example
+// PathAll("addresses", "city") returns the city of every element of addresses.
+//
+// Each step is looked up as follows:
+//
+//         1. "*" selects every property of an OBJECT, or every element of an ARRAY.
+//         2. An integer selects that element of an ARRAY (negative integers count from the end).
+//         3. Any other step selects that property of an OBJECT.  Applied to an ARRAY, it selects
+//            that property of every element instead, so arrays are flattened without a "*".
+//
+// Steps which are not found are skipped, so the result may be empty, and is in document order.
+// As for Path(), raw bytes are only parsed as far as needed, and aliases are respected.
+func (this *Value) PathAll(steps ...string) (ValueCollection, error) {
+	rv := ValueCollection{this}
+	for _, step := range steps {
+		var next ValueCollection
+		for _, val := range rv {
+			var err error
+			next, err = val.pathAllStep(step, next)
+			if err != nil {
+				return nil, err
+			}
+		}
+		rv = next
+	}
+	return rv, nil
+}
+
+// pathAllStep appends the Values step selects from this Value to rv.
+func (this *Value) pathAllStep(step string, rv ValueCollection) (ValueCollection, error) {
+	switch this.Type() {
+	case OBJECT:
+		if step == "*" {
+			children, err := queryChildren(this)
+			return append(rv, children...), err
+		}
+		child, err := this.member(step)
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return rv, nil
+			}
+			return nil, err
+		}
+		return append(rv, child), nil
+	case ARRAY:
+		elements, err := this.elements()
+		if err != nil {
+			return nil, err
+		}
+		if step == "*" {
+			return append(rv, elements...), nil
+		}
+		if index, err := strconv.Atoi(step); err == nil {
+			if index < 0 {
+				index += len(elements)
+			}
+			if index >= 0 && index < len(elements) {
+				rv = append(rv, elements[index])
+			}
+			return rv, nil
+		}
+		for _, element := range elements {
+			rv, err = element.pathAllStep(step, rv)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rv, nil
+}
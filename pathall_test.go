@@ -0,0 +1,69 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathAll(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{
+		"addresses": [{"city": "Paris", "zip": 1}, {"zip": 2}, {"city": "Rome"}],
+		"orders": [{"items": [{"sku": "a"}, {"sku": "b"}]}, {"items": [{"sku": "c"}]}],
+		"name": {"first": "Ada", "last": "Lovelace"}
+	}`))
+
+	tests := []struct {
+		steps  []string
+		result []interface{}
+	}{
+		{[]string{"addresses", "city"}, []interface{}{"Paris", "Rome"}},
+		{[]string{"addresses", "*", "zip"}, []interface{}{1.0, 2.0}},
+		{[]string{"orders", "items", "sku"}, []interface{}{"a", "b", "c"}},
+		{[]string{"orders", "0", "items", "-1", "sku"}, []interface{}{"b"}},
+		{[]string{"name", "*"}, []interface{}{"Ada", "Lovelace"}},
+		{[]string{"addresses", "5"}, []interface{}{}},
+		{[]string{"missing", "city"}, []interface{}{}},
+		{[]string{"name", "first", "x"}, []interface{}{}},
+	}
+	for _, test := range tests {
+		results, err := doc.PathAll(test.steps...)
+		if err != nil {
+			t.Errorf("Error for %v: %v", test.steps, err)
+			continue
+		}
+		actual := []interface{}{}
+		for _, result := range results {
+			actual = append(actual, result.Value())
+		}
+		if !reflect.DeepEqual(actual, test.result) {
+			t.Errorf("Expected %v for %v, got %v", test.result, test.steps, actual)
+		}
+	}
+
+	results, _ := doc.PathAll()
+	if len(results) != 1 || results[0] != doc {
+		t.Errorf("Expected no steps to select the document, got %v", results)
+	}
+}
+
+func TestPathAllAliases(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"addresses": [{"city": "Paris"}, {"city": "Rome"}]}`))
+	addresses, _ := doc.Path("addresses")
+	addresses.SetIndex(1, map[string]interface{}{"city": "Oslo"})
+	results, err := doc.PathAll("addresses", "city")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Value() != "Oslo" {
+		t.Errorf("Expected the alias Oslo, got %v", results)
+	}
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Return the Values at many paths at once, keyed by path.  Each path is resolved as by Path(),
+// including dotted paths, but the raw bytes of each object are scanned only once for all of
+// the paths inside it, rather than once per path.  Paths which are not found are not in the
+// result.
+func (this *Value) Paths(paths []string) (map[string]*Value, error) {
+	rv := make(map[string]*Value, len(paths))
+	err := this.collectPaths(paths, "", true, rv)
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// collectPaths stores the Value at each of paths in rv, keyed by prefix+path.  If literal is
+// set, a path is first looked up as a single property, as Path() does, otherwise it is split
+// into steps at each dot.
+func (this *Value) collectPaths(paths []string, prefix string, literal bool, rv map[string]*Value) error {
+	var members map[string][]byte // the members of the raw bytes, once scanned
+	nested := make(map[string][]string)
+	var order []string
+	for _, path := range paths {
+		dot := strings.IndexByte(path, '.')
+		if literal || dot < 0 {
+			val, err := this.scannedStep(path, &members)
+			if err == nil {
+				rv[prefix+path] = val
+				continue
+			}
+			if _, ok := err.(*Undefined); !ok {
+				return err
+			}
+		}
+		if dot >= 0 {
+			first := path[:dot]
+			if _, ok := nested[first]; !ok {
+				order = append(order, first)
+			}
+			nested[first] = append(nested[first], path[dot+1:])
+		}
+	}
+	for _, first := range order {
+		child, err := this.scannedStep(first, &members)
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				continue
+			}
+			return err
+		}
+		err = child.collectPaths(nested[first], prefix+first+".", false, rv)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scannedStep looks up one step as Path() or Index() would (integer steps index into arrays),
+// but finds properties in the raw bytes using a single scan, stored in members, shared by every step.
+func (this *Value) scannedStep(step string, members *map[string][]byte) (*Value, error) {
+	if this.parsedType == ARRAY {
+		index, err := strconv.Atoi(step)
+		if err != nil {
+			return nil, &Undefined{step}
+		}
+		return this.Index(index)
+	}
+	if this.parsedType != OBJECT || this.raw == nil {
+		return this.member(step)
+	}
+	_, aliased := this.alias[step]
+	_, remembered := this.children[step]
+	_, parsed := this.parsedValue.(map[string]*Value)
+	if aliased || remembered || parsed {
+		return this.member(step)
+	}
+	if *members == nil {
+		keys, values, err := objectMembers(this.raw)
+		if err != nil {
+			return nil, this.locateSyntaxError(step, err)
+		}
+		*members = make(map[string][]byte, len(keys))
+		for i, k := range keys {
+			if _, ok := (*members)[k]; !ok {
+				(*members)[k] = values[i]
+			}
+		}
+	}
+	raw, ok := (*members)[step]
+	if !ok {
+		return nil, &Undefined{step}
+	}
+	child := this.rememberChild(step, this.newChild(raw))
+	this.bindChild(step, child)
+	return child, nil
+}
@@ -0,0 +1,79 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name": "marty", "a.b": 1, "a": {"b": 2, "c": [10, {"d": 3}]}, "n": null}`))
+	doc.SetPath("name", "steve")
+
+	paths := []string{"name", "a.b", "a.c.0", "a.c.1.d", "n", "missing", "a.missing", "a.c.5"}
+	results, err := doc.Paths(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := make(map[string]interface{}, len(results))
+	for k, v := range results {
+		actual[k] = v.Value()
+	}
+	expected := map[string]interface{}{
+		"name":    "steve",
+		"a.b":     1.0,
+		"a.c.0":   10.0,
+		"a.c.1.d": 3.0,
+		"n":       nil,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	// the results are the same as from Path()
+	for _, path := range paths {
+		val, err := doc.Path(path)
+		if (err == nil) != (results[path] != nil) {
+			t.Errorf("Expected Path(%s) to agree, got %v, %v", path, val, err)
+		}
+		if err == nil && !val.Equals(results[path]) {
+			t.Errorf("Expected Path(%s) to return %v, got %v", path, val.Value(), results[path].Value())
+		}
+	}
+}
+
+func BenchmarkPaths(b *testing.B) {
+	fields := make([]string, 30)
+	paths := make([]string, 0, 15)
+	for i := range fields {
+		fields[i] = fmt.Sprintf(`"field%d": "value %d"`, i, i)
+		if i%2 == 0 {
+			paths = append(paths, fmt.Sprintf("field%d", i))
+		}
+	}
+	raw := []byte("{" + strings.Join(fields, ",") + "}")
+
+	b.Run("Path", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			doc := NewValueFromBytes(raw)
+			for _, path := range paths {
+				doc.Path(path)
+			}
+		}
+	})
+	b.Run("Paths", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewValueFromBytes(raw).Paths(paths)
+		}
+	})
+}
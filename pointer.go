@@ -0,0 +1,110 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// When a string passed to Pointer() is not a JSON Pointer, the return error is *PointerError.
+type PointerError struct {
+	Pointer string
+}
+
+// Description of the malformed pointer.
+func (this *PointerError) Error() string {
+	return fmt.Sprintf("%q is not a valid JSON Pointer", this.Pointer)
+}
+
+// Return the Value referred to by a JSON Pointer (RFC 6901), such as "/orders/2/items/0/sku".
+// The empty pointer refers to this Value.  Each reference token is looked up with Path() in
+// an OBJECT, and with Index() in an ARRAY, so aliases set at any level are respected, and
+// raw bytes are only parsed as far as needed.  Unlike Path(), a token containing dots is
+// always a single property name.
+//
+// If the pointer does not begin with "/", or contains a "~" not followed by "0" or "1", the
+// return error is *PointerError.  If no Value is found, the return error is *Undefined.
+func (this *Value) Pointer(ptr string) (*Value, error) {
+	tokens, err := pointerTokens(ptr)
+	if err != nil {
+		return nil, err
+	}
+	rv := this
+	for _, token := range tokens {
+		var next *Value
+		switch rv.Type() {
+		case OBJECT:
+			next, err = rv.member(token)
+		case ARRAY:
+			index, ok := pointerIndex(token)
+			if !ok {
+				return nil, &Undefined{ptr}
+			}
+			next, err = rv.Index(index)
+		default:
+			return nil, &Undefined{ptr}
+		}
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return nil, &Undefined{ptr}
+			}
+			return nil, err
+		}
+		rv = next
+	}
+	return rv, nil
+}
+
+// member returns the property key of an OBJECT, as Path() does, but never
+// treating the dots in key as separate steps.
+func (this *Value) member(key string) (*Value, error) {
+	rv, err := this.path(key, nil)
+	if rv != nil {
+		this.bindChild(key, rv)
+	}
+	return rv, err
+}
+
+// pointerTokens splits a JSON Pointer into its unescaped reference tokens.
+func pointerTokens(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, &PointerError{ptr}
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, &PointerError{ptr}
+			}
+		}
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// pointerIndex parses an array index, which in a JSON Pointer must be "0" or
+// digits without a leading zero.  The token "-" (past the end) never refers to a Value.
+func pointerIndex(token string) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(token)
+	return index, err == nil
+}
@@ -0,0 +1,83 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPointer(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"orders": [{"id": 1}, {"id": 2}, {"id": 3, "items": [{"sku": "abc"}]}], "a/b": {"m~n": 4}, "c.d": 5, "": 6}`))
+
+	tests := []struct {
+		ptr    string
+		result interface{}
+	}{
+		{"/orders/2/items/0/sku", "abc"},
+		{"/orders/0/id", 1.0},
+		{"/a~1b/m~0n", 4.0},
+		{"/c.d", 5.0},
+		{"/", 6.0},
+	}
+	for _, test := range tests {
+		val, err := doc.Pointer(test.ptr)
+		if err != nil {
+			t.Errorf("Error resolving %s: %v", test.ptr, err)
+			continue
+		}
+		if !reflect.DeepEqual(val.Value(), test.result) {
+			t.Errorf("Expected %v for %s, got %v", test.result, test.ptr, val.Value())
+		}
+	}
+
+	val, err := doc.Pointer("")
+	if err != nil || val != doc {
+		t.Errorf("Expected the empty pointer to refer to the document, got %v, %v", val, err)
+	}
+
+	for _, ptr := range []string{"/orders/3", "/orders/-", "/orders/01", "/orders/x", "/orders/0/id/x", "/missing/0"} {
+		_, err := doc.Pointer(ptr)
+		if undefined, ok := err.(*Undefined); !ok || undefined.Path != ptr {
+			t.Errorf("Expected *Undefined for %s, got %v", ptr, err)
+		}
+	}
+	for _, ptr := range []string{"orders", "/a~2b", "/a~"} {
+		_, err := doc.Pointer(ptr)
+		if _, ok := err.(*PointerError); !ok {
+			t.Errorf("Expected *PointerError for %s, got %v", ptr, err)
+		}
+	}
+}
+
+func TestPointerAliases(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"orders": [{"id": 1, "items": [{"sku": "abc"}]}]}`))
+	orders, _ := doc.Path("orders")
+	order, _ := orders.Index(0)
+	items, _ := order.Path("items")
+	items.SetIndex(0, map[string]interface{}{"sku": "xyz"})
+
+	val, err := doc.Pointer("/orders/0/items/0/sku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.Value() != "xyz" {
+		t.Errorf("Expected alias xyz, got %v", val.Value())
+	}
+
+	doc.SetPath("orders", []interface{}{map[string]interface{}{"id": 9.0}})
+	val, err = doc.Pointer("/orders/0/id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.Value() != 9.0 {
+		t.Errorf("Expected alias 9, got %v", val.Value())
+	}
+}
@@ -0,0 +1,102 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+)
+
+// When an expression refers to a parameter which was not bound,
+// the return error will be *UnboundParameter.
+type UnboundParameter struct {
+	Name string
+}
+
+// Description of the parameter which was not bound.
+func (this *UnboundParameter) Error() string {
+	return fmt.Sprintf("parameter $%s is not bound", this.Name)
+}
+
+// A PreparedExpr is an expression parsed once, with parameters ($1, $2, ... or $name)
+// bound each time it is evaluated.
+type PreparedExpr struct {
+	expr *Expression
+}
+
+// Prepare an expression containing parameters.  If the expression is malformed, the return
+// error is *ExpressionError.
+func PrepareExpr(expr string) (*PreparedExpr, error) {
+	e, err := ParseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedExpr{e}, nil
+}
+
+// The source text of this PreparedExpr.
+func (this *PreparedExpr) String() string {
+	return this.expr.String()
+}
+
+// Evaluate this PreparedExpr against a document, binding args to $1, $2, ...
+// The args are brought into the type system, so they must be compatible with the NewValue() method.
+//
+// If the expression evaluates to missing, the return value is nil, and the return error is *Undefined.
+// If the expression refers to a parameter not bound, the return error is *UnboundParameter.
+func (this *PreparedExpr) Eval(doc *Value, args ...interface{}) (*Value, error) {
+	return this.expr.eval(positionalContext(doc, args))
+}
+
+// Evaluate this PreparedExpr against a document, binding args to $name.
+func (this *PreparedExpr) EvalNamed(doc *Value, args map[string]interface{}) (*Value, error) {
+	return this.expr.eval(namedContext(doc, args))
+}
+
+// Determine if this PreparedExpr evaluates to true for a document, binding args to $1, $2, ...
+func (this *PreparedExpr) Matches(doc *Value, args ...interface{}) (bool, error) {
+	return this.expr.matches(positionalContext(doc, args))
+}
+
+// Determine if this PreparedExpr evaluates to true for a document, binding args to $name.
+func (this *PreparedExpr) MatchesNamed(doc *Value, args map[string]interface{}) (bool, error) {
+	return this.expr.matches(namedContext(doc, args))
+}
+
+func positionalContext(doc *Value, args []interface{}) *evalContext {
+	rv := evalContext{doc: doc, positional: make([]*Value, len(args))}
+	for i, arg := range args {
+		rv.positional[i] = NewValue(arg)
+	}
+	return &rv
+}
+
+func namedContext(doc *Value, args map[string]interface{}) *evalContext {
+	rv := evalContext{doc: doc, named: make(map[string]*Value, len(args))}
+	for k, arg := range args {
+		rv.named[k] = NewValue(arg)
+	}
+	return &rv
+}
+
+type paramNode struct {
+	name     string
+	position int // 1-based position, or 0 for named parameters
+}
+
+func (this *paramNode) eval(ctx *evalContext) (*Value, error) {
+	if this.position > 0 {
+		if this.position <= len(ctx.positional) {
+			return ctx.positional[this.position-1], nil
+		}
+	} else if val, ok := ctx.named[this.name]; ok {
+		return val, nil
+	}
+	return nil, &UnboundParameter{this.name}
+}
@@ -0,0 +1,77 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestPreparedExpr(t *testing.T) {
+	docs := []*Value{
+		NewValueFromBytes([]byte(`{"type":"order","total":25}`)),
+		NewValueFromBytes([]byte(`{"type":"order","total":150}`)),
+		NewValueFromBytes([]byte(`{"type":"user","total":500}`)),
+	}
+
+	positional, err := PrepareExpr(`type = $1 AND total > $2`)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	named, err := PrepareExpr(`type = $type AND total > $min`)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var tests = []struct {
+		kind    string
+		min     float64
+		matches int
+	}{
+		{"order", 0, 2},
+		{"order", 100, 1},
+		{"user", 100, 1},
+		{"user", 1000, 0},
+	}
+
+	for _, test := range tests {
+		count, countNamed := 0, 0
+		for _, doc := range docs {
+			matches, err := positional.Matches(doc, test.kind, test.min)
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if matches {
+				count++
+			}
+			matches, err = named.MatchesNamed(doc, map[string]interface{}{"type": test.kind, "min": test.min})
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if matches {
+				countNamed++
+			}
+		}
+		if count != test.matches || countNamed != test.matches {
+			t.Errorf("Expected %d matches, got %d and %d for %v", test.matches, count, countNamed, test)
+		}
+	}
+
+	result, err := positional.Eval(docs[0], "order")
+	if _, ok := err.(*UnboundParameter); !ok || result != nil {
+		t.Errorf("Expected *UnboundParameter, got %v, %v", result, err)
+	}
+
+	for _, expr := range []string{`$`, `$0`} {
+		_, err := PrepareExpr(expr)
+		if _, ok := err.(*ExpressionError); !ok {
+			t.Errorf("Expected *ExpressionError for %s, got %v", expr, err)
+		}
+	}
+}
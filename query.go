@@ -0,0 +1,421 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A Query is a parsed JSONPath query which can select from many Values.
+//
+// The supported subset of JSONPath is:
+//
+//         1. $ for the document, which every query starts with.
+//         2. .name or ['name'] for a property, and [n] for an element (negative n counts from the end).
+//         3. [a,b,...] for several properties or elements, and [start:end] for a slice of an array.
+//         4. .* or [*] for every property or element.
+//         5. ..name, ..* or ..[...] for recursive descent: the selector applies at every level.
+//         6. [?(expr)] for the properties or elements for which expr is true.  expr is an
+//            Expression (see ParseExpression()), in which @ is the property or element and $
+//            is the document, for example $.store.book[?(@.price < 10)].title.  If expr is
+//            only a path, such as @.isbn, it selects those for which the path is defined.
+//
+// Properties are looked up as by Path(), respecting aliases set at any level, so raw bytes
+// are only parsed as far as needed.  Results are in document order, with the properties of an object
+// in the order of Fields().
+type Query struct {
+	query string
+	steps []queryStep
+}
+
+type queryStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     bool
+	start     *int
+	end       *int
+	filter    *Expression
+}
+
+// Parse a JSONPath query.  If the query is malformed, the return error is *ExpressionError.
+func ParseQuery(query string) (*Query, error) {
+	parser := queryParser{query: query}
+	if !strings.HasPrefix(query, "$") {
+		return nil, parser.errorf(0, "query must start with $")
+	}
+	parser.pos = 1
+	rv := Query{query: query}
+	for parser.pos < len(query) {
+		step, err := parser.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		rv.steps = append(rv.steps, step)
+	}
+	return &rv, nil
+}
+
+// Parse and run a JSONPath query (see Query) against this Value in one step,
+// returning the Values selected.
+func (this *Value) Query(query string) (ValueCollection, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Select(this)
+}
+
+// The source text of this Query.
+func (this *Query) String() string {
+	return this.query
+}
+
+// Parse and run a JSONPath query against this Value as Query() does, with
+// the comparisons in filters honoring the specified options (see CompareWithOptions()).
+func (this *Value) QueryWithOptions(query string, options CompareOptions) (ValueCollection, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.SelectWithOptions(this, options)
+}
+
+// Return the Values this Query selects from doc.  If nothing matches, the result is empty.
+func (this *Query) Select(doc *Value) (ValueCollection, error) {
+	return this.SelectWithOptions(doc, CompareOptions{})
+}
+
+// Return the Values this Query selects from doc as Select() does, with the comparisons
+// in filters honoring the specified options (see CompareWithOptions()).
+func (this *Query) SelectWithOptions(doc *Value, options CompareOptions) (ValueCollection, error) {
+	rv := ValueCollection{doc}
+	for _, step := range this.steps {
+		var next ValueCollection
+		for _, val := range rv {
+			var err error
+			if step.recursive {
+				err = descendants(val, func(val *Value) error {
+					return step.selectFrom(doc, val, options, &next)
+				})
+			} else {
+				err = step.selectFrom(doc, val, options, &next)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		rv = next
+	}
+	return rv, nil
+}
+
+// descendants calls fn for val and every Value inside it, parents first.
+func descendants(val *Value, fn func(*Value) error) error {
+	err := fn(val)
+	if err != nil {
+		return err
+	}
+	children, err := queryChildren(val)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		err = descendants(child, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryChildren returns the properties of an OBJECT, in the order of Fields(),
+// or the elements of an ARRAY.
+func queryChildren(val *Value) (ValueCollection, error) {
+	switch val.Type() {
+	case OBJECT:
+		fields := val.Fields()
+		rv := make(ValueCollection, 0, len(fields))
+		for _, k := range fields {
+			child, err := val.member(k)
+			if err != nil {
+				return nil, err
+			}
+			rv = append(rv, child)
+		}
+		return rv, nil
+	case ARRAY:
+		return val.elements()
+	}
+	return nil, nil
+}
+
+// selectFrom appends the Values this step selects from val to rv.
+func (this *queryStep) selectFrom(doc, val *Value, options CompareOptions, rv *ValueCollection) error {
+	switch {
+	case this.wildcard || this.filter != nil:
+		children, err := queryChildren(val)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if this.filter != nil {
+				result, err := this.filter.root.eval(&evalContext{
+					doc:     child,
+					vars:    map[string]*Value{"@": child, "$": doc},
+					compare: options,
+				})
+				if err != nil {
+					return err
+				}
+				if _, exists := this.filter.root.(*pathNode); !isTrue(result) && !(exists && result != nil) {
+					continue
+				}
+			}
+			*rv = append(*rv, child)
+		}
+	case this.names != nil:
+		if val.Type() != OBJECT {
+			return nil
+		}
+		for _, name := range this.names {
+			child, err := val.member(name)
+			if err != nil {
+				if _, ok := err.(*Undefined); ok {
+					continue
+				}
+				return err
+			}
+			*rv = append(*rv, child)
+		}
+	case this.indexes != nil || this.slice:
+		if val.Type() != ARRAY {
+			return nil
+		}
+		elements, err := val.elements()
+		if err != nil {
+			return err
+		}
+		for _, i := range this.selectedIndexes(len(elements)) {
+			*rv = append(*rv, elements[i])
+		}
+	}
+	return nil
+}
+
+// selectedIndexes returns the indexes this step selects from an array of length n.
+func (this *queryStep) selectedIndexes(n int) []int {
+	clamp := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		rv := *i
+		if rv < 0 {
+			rv += n
+		}
+		if rv < 0 {
+			return 0
+		}
+		if rv > n {
+			return n
+		}
+		return rv
+	}
+	var rv []int
+	if this.slice {
+		for i := clamp(this.start, 0); i < clamp(this.end, n); i++ {
+			rv = append(rv, i)
+		}
+		return rv
+	}
+	for _, i := range this.indexes {
+		if i < 0 {
+			i += n
+		}
+		if i >= 0 && i < n {
+			rv = append(rv, i)
+		}
+	}
+	return rv
+}
+
+type queryParser struct {
+	query string
+	pos   int
+}
+
+func (this *queryParser) errorf(offset int, msg string) *ExpressionError {
+	return &ExpressionError{this.query, offset, msg}
+}
+
+func (this *queryParser) parseStep() (queryStep, error) {
+	var rv queryStep
+	q := this.query
+	switch {
+	case strings.HasPrefix(q[this.pos:], ".."):
+		rv.recursive = true
+		this.pos += 2
+		if this.pos < len(q) && q[this.pos] == '[' {
+			return rv, this.parseBracket(&rv)
+		}
+	case q[this.pos] == '.':
+		this.pos++
+	case q[this.pos] == '[':
+		return rv, this.parseBracket(&rv)
+	default:
+		return rv, this.errorf(this.pos, "expected . or [")
+	}
+	start := this.pos
+	for this.pos < len(q) && q[this.pos] != '.' && q[this.pos] != '[' {
+		this.pos++
+	}
+	switch name := q[start:this.pos]; name {
+	case "":
+		return rv, this.errorf(start, "expected property name")
+	case "*":
+		rv.wildcard = true
+	default:
+		rv.names = []string{name}
+	}
+	return rv, nil
+}
+
+// parseBracket parses a selector in brackets, starting at the [.
+func (this *queryParser) parseBracket(step *queryStep) error {
+	q := this.query
+	this.pos++
+	this.skipSpace()
+	switch {
+	case strings.HasPrefix(q[this.pos:], "*"):
+		step.wildcard = true
+		this.pos++
+	case strings.HasPrefix(q[this.pos:], "?("):
+		err := this.parseFilter(step)
+		if err != nil {
+			return err
+		}
+	default:
+		for {
+			this.skipSpace()
+			if this.pos < len(q) && (q[this.pos] == '\'' || q[this.pos] == '"') {
+				name, end, err := lexQuoted(q, this.pos)
+				if err != nil {
+					return this.errorf(this.pos, "unterminated string")
+				}
+				step.names = append(step.names, name)
+				this.pos = end
+			} else {
+				start := this.pos
+				i, ok := this.parseInt()
+				this.skipSpace()
+				if this.pos < len(q) && q[this.pos] == ':' && step.indexes == nil && step.names == nil {
+					step.slice = true
+					if ok {
+						step.start = &i
+					}
+					this.pos++
+					this.skipSpace()
+					if end, ok := this.parseInt(); ok {
+						step.end = &end
+					}
+					this.skipSpace()
+					break
+				}
+				if !ok {
+					return this.errorf(start, "expected property name, index, slice, * or filter")
+				}
+				step.indexes = append(step.indexes, i)
+			}
+			this.skipSpace()
+			if step.slice || this.pos >= len(q) || q[this.pos] != ',' {
+				break
+			}
+			this.pos++
+		}
+		if step.names != nil && step.indexes != nil {
+			return this.errorf(this.pos, "cannot mix property names and indexes")
+		}
+	}
+	this.skipSpace()
+	if this.pos >= len(q) || q[this.pos] != ']' {
+		return this.errorf(this.pos, "expected ]")
+	}
+	this.pos++
+	return nil
+}
+
+// parseFilter parses ?(expr), starting at the ?.  In the expression @ and $ are
+// rewritten as the variables `@` and `$`.
+func (this *queryParser) parseFilter(step *queryStep) error {
+	q := this.query
+	start := this.pos + 2
+	var expr strings.Builder
+	depth := 1
+	for i := start; i < len(q); i++ {
+		switch c := q[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			_, end, err := lexQuoted(q, i)
+			if err != nil {
+				return this.errorf(i, "unterminated string")
+			}
+			expr.WriteString(q[i:end])
+			i = end - 1
+		case c == '@' || (c == '$' && (i+1 == len(q) || !isIdentStart(q[i+1]) && (q[i+1] < '0' || q[i+1] > '9'))):
+			expr.WriteString("`" + string(c) + "`")
+		case c == '(':
+			depth++
+			expr.WriteByte(c)
+		case c == ')':
+			depth--
+			if depth == 0 {
+				filter, err := ParseExpression(expr.String())
+				if err != nil {
+					if err, ok := err.(*ExpressionError); ok {
+						return this.errorf(start, err.msg)
+					}
+					return err
+				}
+				step.filter = filter
+				this.pos = i + 1
+				return nil
+			}
+			expr.WriteByte(c)
+		default:
+			expr.WriteByte(c)
+		}
+	}
+	return this.errorf(this.pos, "unterminated filter")
+}
+
+// parseInt parses an optionally negative integer, leaving pos unchanged if there is none.
+func (this *queryParser) parseInt() (int, bool) {
+	q := this.query
+	end := this.pos
+	if end < len(q) && q[end] == '-' {
+		end++
+	}
+	for end < len(q) && q[end] >= '0' && q[end] <= '9' {
+		end++
+	}
+	i, err := strconv.Atoi(q[this.pos:end])
+	if err != nil {
+		return 0, false
+	}
+	this.pos = end
+	return i, true
+}
+
+func (this *queryParser) skipSpace() {
+	for this.pos < len(this.query) && this.query[this.pos] == ' ' {
+		this.pos++
+	}
+}
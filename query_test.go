@@ -0,0 +1,88 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+var storeDoc = []byte(`{"store": {
+	"book": [
+		{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+		{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+		{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+		{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+	],
+	"bicycle": {"color": "red", "price": 19.95}
+}, "limit": 10}`)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		query  string
+		result []interface{}
+	}{
+		{"$.store.book[*].author", []interface{}{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}},
+		{"$..author", []interface{}{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}},
+		{"$.store..price", []interface{}{19.95, 8.95, 12.99, 8.99, 22.99}},
+		{"$.store.book[2].title", []interface{}{"Moby Dick"}},
+		{"$.store.book[-1].title", []interface{}{"The Lord of the Rings"}},
+		{"$.store.book[0,1].price", []interface{}{8.95, 12.99}},
+		{"$.store.book[:2].price", []interface{}{8.95, 12.99}},
+		{"$.store.book[2:].price", []interface{}{8.99, 22.99}},
+		{"$['store']['bicycle']['color']", []interface{}{"red"}},
+		{"$.store.bicycle.*", []interface{}{"red", 19.95}},
+		{"$..book[?(@.isbn)].title", []interface{}{"Moby Dick", "The Lord of the Rings"}},
+		{"$.store.book[?(@.price < 10)].title", []interface{}{"Sayings of the Century", "Moby Dick"}},
+		{"$.store.book[?(@.price > $.limit && @.category = 'fiction')].author", []interface{}{"Evelyn Waugh", "J. R. R. Tolkien"}},
+		{"$.store.book[?(@.author = 'a)b')]", []interface{}{}},
+		{"$.store.missing", []interface{}{}},
+		{"$.limit.x", []interface{}{}},
+		{"$.store.book[9]", []interface{}{}},
+	}
+	doc := NewValueFromBytes(storeDoc)
+	for _, test := range tests {
+		results, err := doc.Query(test.query)
+		if err != nil {
+			t.Errorf("Error running %s: %v", test.query, err)
+			continue
+		}
+		actual := []interface{}{}
+		for _, result := range results {
+			actual = append(actual, result.Value())
+		}
+		if !reflect.DeepEqual(actual, test.result) {
+			t.Errorf("Expected %v for %s, got %v", test.result, test.query, actual)
+		}
+	}
+}
+
+func TestQueryAliases(t *testing.T) {
+	doc := NewValueFromBytes(storeDoc)
+	bicycle, _ := doc.Path("store")
+	bicycle, _ = bicycle.Path("bicycle")
+	bicycle.SetPath("color", "blue")
+	results, err := doc.Query("$.store.bicycle.color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Value() != "blue" {
+		t.Errorf("Expected the alias blue, got %v", results)
+	}
+}
+
+func TestQuerySyntaxError(t *testing.T) {
+	for _, query := range []string{"store.book", "$.", "$[", "$[1", "$['a]", "$[?(@.price < 10]", "$[?(@.price <)]", "$['a',1]", "$x"} {
+		_, err := ParseQuery(query)
+		if _, ok := err.(*ExpressionError); !ok {
+			t.Errorf("Expected *ExpressionError for %s, got %v", query, err)
+		}
+	}
+}
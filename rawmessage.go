@@ -0,0 +1,49 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Create a new Value object from an encoding/json.RawMessage, without parsing it.
+// The Value shares storage with msg.
+func NewValueFromRawMessage(msg json.RawMessage) *Value {
+	return NewValueFromBytes(msg)
+}
+
+// Return this Value as an encoding/json.RawMessage, for a struct field which is
+// marshaled by encoding/json.  If this Value is NOT_JSON the message is null.
+func (this *Value) RawMessage() json.RawMessage {
+	if this.parsedType == NOT_JSON {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(this.Bytes())
+}
+
+// MarshalJSON implements encoding/json.Marshaler, so a *Value may be a field of a struct
+// marshaled by encoding/json.  If this Value is NOT_JSON an error is returned.
+func (this *Value) MarshalJSON() ([]byte, error) {
+	if this.parsedType == NOT_JSON {
+		return nil, this.locateSyntaxError("", fmt.Errorf("not JSON"))
+	}
+	return this.Bytes(), nil
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler, so a *Value may be a field of a
+// struct unmarshaled by encoding/json.  The bytes are copied, but not parsed.
+//
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+func (this *Value) UnmarshalJSON(data []byte) error {
+	this.checkMutable()
+	*this = *NewValueFromBytes(append([]byte(nil), data...))
+	return nil
+}
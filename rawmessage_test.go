@@ -0,0 +1,88 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRawMessage(t *testing.T) {
+	val := NewValueFromRawMessage(json.RawMessage(`{"a": [1, 2]}`))
+	if val.Type() != OBJECT {
+		t.Errorf("Expected OBJECT, got %d", val.Type())
+	}
+	val.SetPath("b", true)
+	if string(val.RawMessage()) != `{"a":[1,2],"b":true}` {
+		t.Errorf("Unexpected message %s", val.RawMessage())
+	}
+	if string(NewValueFromBytes([]byte(`{`)).RawMessage()) != "null" {
+		t.Errorf("Expected NOT_JSON to be null")
+	}
+
+	// messages inside trees stay raw
+	tree := NewValue(map[string]interface{}{"doc": json.RawMessage(`{"x": 1}`)})
+	doc, _ := tree.Path("doc")
+	if doc.Type() != OBJECT || doc.raw == nil {
+		t.Errorf("Expected doc to be kept as raw bytes")
+	}
+}
+
+func TestStructWithValue(t *testing.T) {
+	type envelope struct {
+		ID   string `json:"id"`
+		Body *Value `json:"body"`
+	}
+	input := []byte(`{"id":"k1","body":{"name":"widget","tags":["a"]}}`)
+	var e envelope
+	err := json.Unmarshal(input, &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := e.Body.Path("name")
+	if err != nil || name.Value() != "widget" {
+		t.Errorf("Expected widget, got %v", err)
+	}
+
+	e.Body.SetPath("name", "gadget")
+	output, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != `{"id":"k1","body":{"name":"gadget","tags":["a"]}}` {
+		t.Errorf("Unexpected output %s", output)
+	}
+
+	e.Body = NewValueFromBytes([]byte(`{`))
+	if _, err := json.Marshal(e); err == nil {
+		t.Errorf("Expected an error marshaling NOT_JSON")
+	}
+}
+
+func TestUnmarshalJSONIntoChild(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"a": null, "b": true}`))
+	a, _ := doc.Path("a")
+	err := json.Unmarshal([]byte(`{"x":1}`), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// other NULL Values are unaffected
+	elem, _ := NewValue([]interface{}{nil}).Index(0)
+	if elem.Type() != NULL {
+		t.Errorf("Expected NULL, got %s", elem.Bytes())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected unmarshaling into a shared Value to panic")
+		}
+	}()
+	json.Unmarshal([]byte(`{"x":1}`), nullSingleton)
+}
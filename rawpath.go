@@ -0,0 +1,33 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	json "github.com/dustin/gojson"
+)
+
+// Return the JSON encoding of the value at path, as Path(path).Bytes() would, without creating
+// any Values.  The value is found as ExistsPath() finds it, and where it is still raw bytes
+// those bytes are returned directly, without being wrapped, validated again or copied, so
+// sub-documents can be forwarded verbatim.  As for Bytes(), the result must not be modified.
+//
+// If the path does not exist, the return value is nil, and the return error is *Undefined.
+func (this *Value) RawPath(path string) ([]byte, error) {
+	cur := this.locate(path)
+	switch {
+	case cur.missing:
+		return nil, &Undefined{path}
+	case cur.val != nil:
+		return cur.val.Bytes(), nil
+	case cur.raw != nil:
+		return cur.raw, nil
+	}
+	return json.Marshal(cur.native)
+}
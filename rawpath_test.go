@@ -0,0 +1,62 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRawPath(t *testing.T) {
+	raw := []byte(`{"name": "marty", "a.b": 1, "escaped": "\"q\"", "a": {"b": {"c": [1, {"d": [3, 4]}]}}}`)
+	paths := []string{"name", "a.b", "escaped", "a", "a.b.c", "a.b.c.1", "a.b.c.1.d"}
+
+	docs := map[string]*Value{
+		"raw":    NewValueFromBytes(raw),
+		"parsed": NewValueFromBytes(raw),
+		"native": NewValue(NewValueFromBytes(raw).Value()),
+	}
+	docs["parsed"].Value()
+	for name, doc := range docs {
+		for _, path := range paths {
+			val, _ := doc.Path(path)
+			rv, err := doc.RawPath(path)
+			if err != nil {
+				t.Errorf("Unexpected error for %s in the %s document: %v", path, name, err)
+				continue
+			}
+			if !bytes.Equal(rv, val.Bytes()) {
+				t.Errorf("Expected %s for %s in the %s document, got %s", val.Bytes(), path, name, rv)
+			}
+		}
+		_, err := doc.RawPath("a.b.x")
+		if _, ok := err.(*Undefined); !ok {
+			t.Errorf("Expected *Undefined for a missing path in the %s document, got %v", name, err)
+		}
+	}
+
+	// raw sub-documents are returned in place
+	rv, _ := docs["raw"].RawPath("a.b.c")
+	if string(rv) != `[1, {"d": [3, 4]}]` || &rv[0] != &raw[bytes.Index(raw, rv)] {
+		t.Errorf("Expected the raw bytes of a.b.c in place, got %s", rv)
+	}
+
+	// aliases are consulted
+	doc := NewValueFromBytes(raw)
+	doc.SetPath("name", "bob")
+	a, _ := doc.Path("a")
+	a.SetPath("x", map[string]interface{}{"y": true})
+	for path, expected := range map[string]string{"name": `"bob"`, "a.x": `{"y":true}`, "a.x.y": "true"} {
+		rv, err := doc.RawPath(path)
+		if err != nil || string(rv) != expected {
+			t.Errorf("Expected %s for %s, got %s, %v", expected, path, rv, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"sync"
+)
+
+// Return a snapshot of the Values at many paths, keyed by path.  The paths are resolved
+// together as by Paths(), and each result is a copy, so all of them reflect this Value at
+// the time of the call: changes later made to this Value are not seen by the results, and
+// changes made to the results are not seen by this Value.  Paths which are not found are
+// not in the result.
+//
+// Value does no locking, so when another goroutine may modify this Value, use a SyncValue
+// and its ReadPaths() method, which holds the lock of every writer out for the whole read.
+func (this *Value) ReadPaths(paths []string) (map[string]*Value, error) {
+	found, err := this.Paths(paths)
+	if err != nil {
+		return nil, err
+	}
+	rv := make(map[string]*Value, len(found))
+	for path, val := range found {
+		rv[path] = val.newChild(val.Bytes())
+	}
+	return rv, nil
+}
+
+// A SyncValue guards a Value shared between goroutines, which may all read and modify it
+// through the SyncValue.  It is safe for concurrent use.
+type SyncValue struct {
+	mutex sync.Mutex
+	val   *Value
+}
+
+// Create a new SyncValue guarding val.  Once guarded, val must only be used through the SyncValue.
+func NewSyncValue(val *Value) *SyncValue {
+	return &SyncValue{val: val}
+}
+
+// Call fn with the guarded Value, which no other goroutine uses until fn returns.
+// fn may read and modify the Value, but must not keep it, or any Value inside it,
+// once it returns.  The error returned by fn is returned.
+func (this *SyncValue) Update(fn func(val *Value) error) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return fn(this.val)
+}
+
+// Return a snapshot of the Values at many paths of the guarded Value, as (*Value).ReadPaths()
+// does.  Every result comes from the same state of the Value: none is read while a change
+// made with Update() is in progress.
+//
+// Reads also take the lock, as resolving a path records the Values it creates.
+func (this *SyncValue) ReadPaths(paths []string) (map[string]*Value, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.val.ReadPaths(paths)
+}
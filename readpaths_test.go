@@ -0,0 +1,67 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReadPaths(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"a": 1, "b": {"c": [1, 2]}, "d.e": true}`))
+	doc.SetPath("a", 2.0)
+	snapshot, err := doc.ReadPaths([]string{"a", "b.c", "d.e", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot) != 3 {
+		t.Errorf("Expected 3 results, got %v", snapshot)
+	}
+	if !snapshot["a"].Equals(NewValue(2.0)) || string(snapshot["b.c"].Bytes()) != "[1, 2]" || snapshot["d.e"].Value() != true {
+		t.Errorf("Unexpected snapshot %v", snapshot)
+	}
+
+	// the snapshot and the document are independent
+	doc.SetDeepPath("b.c.0", "x")
+	if string(snapshot["b.c"].Bytes()) != "[1, 2]" {
+		t.Errorf("Expected the snapshot to be unchanged, got %s", snapshot["b.c"].Bytes())
+	}
+	snapshot["b.c"].SetIndex(1, "y")
+	if string(doc.Bytes()) != `{"a":2,"b":{"c":["x",2]},"d.e":true}` {
+		t.Errorf("Expected the document to be unchanged, got %s", doc.Bytes())
+	}
+}
+
+func TestSyncValueReadPaths(t *testing.T) {
+	shared := NewSyncValue(NewValueFromBytes([]byte(`{"a": 0, "b": {"n": 0}}`)))
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 200; i++ {
+			shared.Update(func(val *Value) error {
+				val.SetPath("a", float64(i))
+				return val.SetDeepPath("b.n", float64(i))
+			})
+		}
+	}()
+
+	// a writer keeps a and b.n equal, so every snapshot must see them equal
+	for i := 0; i < 200; i++ {
+		snapshot, err := shared.ReadPaths([]string{"a", "b.n"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !snapshot["a"].Equals(snapshot["b.n"]) {
+			t.Fatalf("Expected a consistent snapshot, got a=%s b.n=%s", snapshot["a"].Bytes(), snapshot["b.n"].Bytes())
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,120 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// If this Value is of type OBJECT, this method removes the property at the specified path.  Unlike
+// SetPath(path, nil), which stores null, the property no longer exists: Value() and Bytes() leave
+// it out, and Path() returns *Undefined for it.  If no property has the name path, and path
+// contains dots, it is resolved one step at a time as by Path(), and the last step is removed from
+// the OBJECT or ARRAY before it, so RemovePath("items.0") removes the first element of items.
+// If there is nothing at the path, nothing is done.
+//
+// NOTE: Values held by a SharedValues registry must not be modified, this panics if called on one.
+// If removing a nested property violates a constraint registered with AddConstraint() on this
+// Value, this panics with the *ConstraintViolation.
+func (this *Value) RemovePath(path string) {
+	if this.parsedType != OBJECT || this.removeMember(path) {
+		return
+	}
+	i := strings.LastIndex(path, ".")
+	if i < 0 {
+		return
+	}
+	parentPath, key := path[:i], path[i+1:]
+	chain, err := resolveChain(this, parentPath)
+	if err != nil {
+		return
+	}
+	parent := chain[len(chain)-1]
+	removed := false
+	switch parent.Type() {
+	case OBJECT:
+		removed = parent.removeMember(key)
+	case ARRAY:
+		index, err := strconv.Atoi(key)
+		removed = err == nil && parent.removeElement(index)
+	}
+	if removed {
+		if err := storeChain(chain, parentPath); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// If this Value is of type ARRAY, this method removes the element at the specified index, and
+// later elements shift down to fill the gap, so Value(), Bytes() and Index() see the shorter
+// array.  As for Index(), a negative index counts from the end of the array.  If there is no
+// element at the index, nothing is done.
+//
+// NOTE: Values held by a SharedValues registry must not be modified, this panics if called on one.
+func (this *Value) RemoveIndex(index int) {
+	if this.parsedType == ARRAY {
+		this.removeElement(index)
+	}
+}
+
+// removeMember removes key from this OBJECT, if it exists.  The Values already returned by
+// Path() for the other properties are kept, so changes later made to them are still seen.
+func (this *Value) removeMember(key string) bool {
+	this.checkMutable()
+	members := this.members()
+	if _, ok := members[key]; !ok {
+		return false
+	}
+	delete(members, key)
+	for k, child := range this.children {
+		if _, ok := members[k]; ok && this.alias[k] == nil {
+			members[k] = child
+		}
+	}
+	this.replaceMembers(members)
+	return true
+}
+
+// removeElement removes the element at index from this ARRAY, if it exists, keeping the Values
+// already returned by Index() for the other elements as removeMember() does.
+func (this *Value) removeElement(index int) bool {
+	this.checkMutable()
+	elements, err := this.rememberedElements()
+	if err != nil {
+		return false
+	}
+	if index < 0 {
+		index += len(elements)
+	}
+	if index < 0 || index >= len(elements) {
+		return false
+	}
+	kept := make(ValueCollection, 0, len(elements)-1)
+	kept = append(kept, elements[:index]...)
+	this.replaceElements(append(kept, elements[index+1:]...))
+	return true
+}
+
+// rememberedElements returns a copy of the elements of this ARRAY, in which those already
+// returned by Index() are the same Values, so they can be kept by replaceElements().
+func (this *Value) rememberedElements() (ValueCollection, error) {
+	elements, err := this.elements()
+	if err != nil {
+		return nil, err
+	}
+	elements = append(ValueCollection(nil), elements...)
+	for k, child := range this.children {
+		if i, err := strconv.Atoi(k); err == nil && i < len(elements) && this.alias[k] == nil {
+			elements[i] = child
+		}
+	}
+	return elements, nil
+}
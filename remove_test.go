@@ -0,0 +1,97 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemovePath(t *testing.T) {
+	raw := []byte(`{"name": "marty", "a.b": 1, "address": {"city": "x", "zip": 1}, "items": [{"sku": "a"}, {"sku": "b"}, {"sku": "c"}]}`)
+
+	var tests = []struct {
+		path     string
+		expected string
+	}{
+		{"name", `{"a.b":1,"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}]}`},
+		{"a.b", `{"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}],"name":"marty"}`},
+		{"address.zip", `{"a.b":1,"address":{"city":"x"},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}],"name":"marty"}`},
+		{"items.1", `{"a.b":1,"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"c"}],"name":"marty"}`},
+		{"items.2.sku", `{"a.b":1,"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"b"},{}],"name":"marty"}`},
+		{"missing", `{"a.b":1,"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}],"name":"marty"}`},
+		{"address.missing.x", `{"a.b":1,"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}],"name":"marty"}`},
+		{"items.3", `{"a.b":1,"address":{"city":"x","zip":1},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}],"name":"marty"}`},
+	}
+
+	for _, test := range tests {
+		for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+			doc.RemovePath(test.path)
+			expected := NewValueFromBytes([]byte(test.expected)).Value()
+			if !reflect.DeepEqual(NewValueFromBytes(doc.Bytes()).Value(), expected) {
+				t.Errorf("Expected %s after removing %s, got %s", test.expected, test.path, doc.Bytes())
+			}
+			if !reflect.DeepEqual(doc.Value(), expected) {
+				t.Errorf("Expected Value() to match after removing %s, got %v", test.path, doc.Value())
+			}
+			if _, err := doc.Path(test.path); err == nil && test.path != "items.1" {
+				t.Errorf("Expected %s to be undefined after removing it", test.path)
+			}
+		}
+	}
+
+	// Values already returned keep working, and removing is not setting null
+	doc := NewValueFromBytes(raw)
+	address, _ := doc.Path("address")
+	doc.SetPath("added", "y")
+	doc.RemovePath("name")
+	address.SetPath("city", "z")
+	doc.SetPath("a.b", nil)
+	if string(doc.Bytes()) != `{"a.b":null,"added":"y","address":{"city":"z","zip":1},"items":[{"sku":"a"},{"sku":"b"},{"sku":"c"}]}` {
+		t.Errorf("Unexpected document %s", doc.Bytes())
+	}
+	if fields := doc.Fields(); !reflect.DeepEqual(fields, []string{"a.b", "added", "address", "items"}) {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	if doc.ExistsPath("name") {
+		t.Errorf("Expected name not to exist")
+	}
+
+	// the document order of the other keys is kept
+	ordered, _ := NewValueFromBytesWithOptions([]byte(`{"z": 1, "y": 2, "x": 3}`), ParseOptions{KeyOrder: DOCUMENT_ORDER_KEYS})
+	ordered.RemovePath("y")
+	if string(ordered.Bytes()) != `{"z":1,"x":3}` {
+		t.Errorf("Expected document order to be kept, got %s", ordered.Bytes())
+	}
+}
+
+func TestRemoveIndex(t *testing.T) {
+	raw := []byte(`[1, {"a": 2}, [3], "four"]`)
+	for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+		nested, _ := doc.Index(2)
+		doc.RemoveIndex(0)
+		doc.RemoveIndex(-1)
+		doc.RemoveIndex(5)
+		nested.SetIndex(0, "three")
+		if string(doc.Bytes()) != `[{"a":2},["three"]]` {
+			t.Errorf("Unexpected array %s", doc.Bytes())
+		}
+		if n, _ := doc.Len(); n != 2 {
+			t.Errorf("Expected 2 elements, got %d", n)
+		}
+		if _, err := doc.Index(2); err == nil {
+			t.Errorf("Expected index 2 to be undefined")
+		}
+	}
+	shared, _ := NewSharedValues().Share(NewValueFromBytes(raw)).Index(2)
+	if expectPanic(func() { shared.RemoveIndex(0) }) == nil {
+		t.Errorf("Expected a panic removing from a shared array")
+	}
+}
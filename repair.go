@@ -0,0 +1,170 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+
+	json "github.com/dustin/gojson"
+)
+
+// The kinds of problems RepairJSON is able to fix
+const (
+	TRAILING_COMMA = iota
+	CONTROL_CHARACTER
+	SINGLE_QUOTES
+	NON_FINITE_NUMBER
+)
+
+var repairNames = []string{
+	TRAILING_COMMA:    "trailing comma removed",
+	CONTROL_CHARACTER: "control character escaped",
+	SINGLE_QUOTES:     "single quoted string converted",
+	NON_FINITE_NUMBER: "non-finite number replaced with null",
+}
+
+// A Repair describes one change made by RepairJSON.
+type Repair struct {
+	Kind   int   // one of the repair kind constants
+	Offset int64 // byte offset of the problem in the original input
+}
+
+// Description of the repair and where in the original input it was made.
+func (this Repair) String() string {
+	return fmt.Sprintf("%s at offset %d", repairNames[this.Kind], this.Offset)
+}
+
+// Attempt to fix common problems with almost-valid JSON and create a new Value from the result.
+//
+// The following problems are repaired:
+//
+//         1. Trailing commas before the end of an object or array are removed.
+//         2. Unescaped control characters inside strings are escaped.
+//         3. Single quoted strings are converted to double quoted strings.
+//         4. The literals NaN, Infinity and -Infinity are replaced with null.
+//
+// Every change made is reported in the returned slice of Repair.  If the input is still not
+// valid JSON after these repairs, the return value is nil and the return error is *SyntaxError
+// (with a position relative to the repaired bytes).
+func RepairJSON(input []byte) (*Value, []Repair, error) {
+	var repairs []Repair
+	out := bytes.NewBuffer(make([]byte, 0, len(input)))
+
+	for i := 0; i < len(input); i++ {
+		b := input[i]
+		switch {
+		case b == '"' || b == '\'':
+			if b == '\'' {
+				repairs = append(repairs, Repair{SINGLE_QUOTES, int64(i)})
+			}
+			i = repairString(input, i, out, &repairs)
+		case b == ',':
+			next := skipWhitespace(input, i+1)
+			if next < len(input) && (input[next] == '}' || input[next] == ']') {
+				repairs = append(repairs, Repair{TRAILING_COMMA, int64(i)})
+				continue
+			}
+			out.WriteByte(b)
+		case b == 'N' || b == 'I' || b == '-':
+			n := nonFiniteLiteral(input[i:])
+			if n > 0 {
+				repairs = append(repairs, Repair{NON_FINITE_NUMBER, int64(i)})
+				out.WriteString("null")
+				i += n - 1
+				continue
+			}
+			out.WriteByte(b)
+		default:
+			out.WriteByte(b)
+		}
+	}
+
+	if len(repairs) == 0 {
+		out = bytes.NewBuffer(input)
+	}
+	err := json.Validate(out.Bytes())
+	if err != nil {
+		return nil, repairs, newSyntaxError(out.Bytes(), "", err)
+	}
+	return NewValueFromBytes(out.Bytes()), repairs, nil
+}
+
+// repairString copies the string starting at input[start] to out, converting
+// it to a double quoted string and escaping any control characters.  It
+// returns the offset of the closing quote (or the end of the input).
+func repairString(input []byte, start int, out *bytes.Buffer, repairs *[]Repair) int {
+	quote := input[start]
+	out.WriteByte('"')
+	i := start + 1
+	for ; i < len(input); i++ {
+		b := input[i]
+		switch {
+		case b == quote:
+			out.WriteByte('"')
+			return i
+		case b == '\\' && i+1 < len(input):
+			i++
+			if input[i] == '\'' {
+				// \' is not a valid escape in JSON, and not needed
+				out.WriteByte('\'')
+			} else {
+				out.WriteByte('\\')
+				out.WriteByte(input[i])
+			}
+		case b == '"':
+			// a double quote inside a single quoted string
+			out.WriteString(`\"`)
+		case b < 0x20:
+			*repairs = append(*repairs, Repair{CONTROL_CHARACTER, int64(i)})
+			switch b {
+			case '\n':
+				out.WriteString(`\n`)
+			case '\r':
+				out.WriteString(`\r`)
+			case '\t':
+				out.WriteString(`\t`)
+			default:
+				fmt.Fprintf(out, `\u%04x`, b)
+			}
+		default:
+			out.WriteByte(b)
+		}
+	}
+	return i
+}
+
+// nonFiniteLiteral returns the length of the NaN or Infinity literal at the
+// start of input, or 0 if there is none.
+func nonFiniteLiteral(input []byte) int {
+	for _, literal := range []string{"NaN", "Infinity", "-Infinity"} {
+		if bytes.HasPrefix(input, []byte(literal)) {
+			if len(input) == len(literal) || isDelimiter(input[len(literal)]) {
+				return len(literal)
+			}
+		}
+	}
+	return 0
+}
+
+func skipWhitespace(input []byte, i int) int {
+	for i < len(input) && isWhitespace(input[i]) {
+		i++
+	}
+	return i
+}
+
+func isWhitespace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func isDelimiter(b byte) bool {
+	return isWhitespace(b) || b == ',' || b == ']' || b == '}' || b == ':'
+}
@@ -0,0 +1,55 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	var tests = []struct {
+		input           []byte
+		expectedValue   interface{}
+		expectedRepairs []Repair
+	}{
+		{[]byte(`{"a":1}`), map[string]interface{}{"a": 1.0}, nil},
+		{[]byte(`[1,2,]`), []interface{}{1.0, 2.0}, []Repair{{TRAILING_COMMA, 4}}},
+		{[]byte(`{"a":1 , }`), map[string]interface{}{"a": 1.0}, []Repair{{TRAILING_COMMA, 7}}},
+		{[]byte("\"a\tb\""), "a\tb", []Repair{{CONTROL_CHARACTER, 2}}},
+		{[]byte(`{'a':'it\'s "ok"'}`), map[string]interface{}{"a": `it's "ok"`}, []Repair{{SINGLE_QUOTES, 1}, {SINGLE_QUOTES, 5}}},
+		{[]byte(`[NaN,Infinity,-Infinity]`), []interface{}{nil, nil, nil}, []Repair{{NON_FINITE_NUMBER, 1}, {NON_FINITE_NUMBER, 5}, {NON_FINITE_NUMBER, 14}}},
+		{[]byte(`["NaN,"]`), []interface{}{"NaN,"}, nil},
+	}
+
+	for _, test := range tests {
+		val, repairs, err := RepairJSON(test.input)
+		if err != nil {
+			t.Errorf("Unexpected error %v repairing %s", err, string(test.input))
+			continue
+		}
+		if !reflect.DeepEqual(val.Value(), test.expectedValue) {
+			t.Errorf("Expected %#v, got %#v for %s", test.expectedValue, val.Value(), string(test.input))
+		}
+		if !reflect.DeepEqual(repairs, test.expectedRepairs) {
+			t.Errorf("Expected repairs %v, got %v for %s", test.expectedRepairs, repairs, string(test.input))
+		}
+	}
+}
+
+func TestRepairJSONUnrepairable(t *testing.T) {
+	val, _, err := RepairJSON([]byte(`{"a":}`))
+	if val != nil {
+		t.Errorf("Expected nil value, got %v", val)
+	}
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("Expected *SyntaxError, got %#v", err)
+	}
+}
@@ -0,0 +1,184 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// A validator checks that bytes are a single JSON value, keeping its stack of
+// open objects and arrays from one document to the next.
+type validator struct {
+	stack []byte
+}
+
+// validate checks that data is a single well formed JSON value, optionally surrounded by
+// whitespace, exactly as defined by RFC 8259.  If requireUTF8 is set the strings must also
+// be valid UTF-8, as RFC 8259 requires of JSON exchanged between systems.  If data is not
+// valid, the offset and description of the first problem are returned, otherwise the
+// description is empty.
+func (this *validator) validate(data []byte, requireUTF8 bool) (int, string) {
+	i, msg := this.validatePrefix(data, requireUTF8)
+	if msg == "" && i != len(data) {
+		return i, fmt.Sprintf("invalid character '%c' after top-level value", data[i])
+	}
+	return i, msg
+}
+
+// validatePrefix is like validate, but only checks the first value in data, returning the
+// offset just past it and any whitespace following it.
+func (this *validator) validatePrefix(data []byte, requireUTF8 bool) (int, string) {
+	stack := this.stack[:0]
+	defer func() {
+		this.stack = stack[:0]
+	}()
+
+	i := skipWhitespace(data, 0)
+	for {
+		// a value begins at i
+		if i >= len(data) {
+			return i, "unexpected end of JSON input"
+		}
+		switch data[i] {
+		case '{', '[':
+			open := data[i]
+			i = skipWhitespace(data, i+1)
+			if i < len(data) && data[i] == open+2 {
+				// empty, '}' and ']' follow '{' and '[' by two
+				i++
+				break
+			}
+			stack = append(stack, open)
+			if open == '{' {
+				var msg string
+				if i, msg = validKey(data, i, requireUTF8); msg != "" {
+					return i, msg
+				}
+			}
+			continue
+		case '"':
+			var msg string
+			if i, msg = validString(data, i, requireUTF8); msg != "" {
+				return i, msg
+			}
+		default:
+			end := scanLiteral(data, i)
+			if end == i {
+				return i, fmt.Sprintf("invalid character '%c' looking for beginning of value", data[i])
+			}
+			if _, ok := matchLiteral(data[i:end]); !ok {
+				return i, invalidLiteral(data[i:end])
+			}
+			i = end
+		}
+
+		// a value ended at i, close any objects and arrays which end with it
+		for {
+			i = skipWhitespace(data, i)
+			if len(stack) == 0 {
+				return i, ""
+			}
+			if i >= len(data) {
+				return i, "unexpected end of JSON input"
+			}
+			open := stack[len(stack)-1]
+			if data[i] == open+2 {
+				stack = stack[:len(stack)-1]
+				i++
+				continue
+			}
+			if data[i] != ',' {
+				if open == '{' {
+					return i, fmt.Sprintf("invalid character '%c' after object key:value pair", data[i])
+				}
+				return i, fmt.Sprintf("invalid character '%c' after array element", data[i])
+			}
+			i = skipWhitespace(data, i+1)
+			if open == '{' {
+				var msg string
+				if i, msg = validKey(data, i, requireUTF8); msg != "" {
+					return i, msg
+				}
+			}
+			break
+		}
+	}
+}
+
+// invalidLiteral describes why literal is not a number, true, false or null.
+func invalidLiteral(literal []byte) string {
+	if len(literal) > 1 && literal[0] == '0' && literal[1] >= '0' && literal[1] <= '9' ||
+		len(literal) > 2 && literal[0] == '-' && literal[1] == '0' && literal[2] >= '0' && literal[2] <= '9' {
+		return "invalid number " + string(literal) + ": leading zero"
+	}
+	return fmt.Sprintf("invalid literal %q", literal)
+}
+
+// validKey checks the string and colon of an object member beginning at data[i],
+// returning the offset of its value.
+func validKey(data []byte, i int, requireUTF8 bool) (int, string) {
+	if i >= len(data) {
+		return i, "unexpected end of JSON input"
+	}
+	if data[i] != '"' {
+		return i, fmt.Sprintf("invalid character '%c' looking for beginning of object key string", data[i])
+	}
+	i, msg := validString(data, i, requireUTF8)
+	if msg != "" {
+		return i, msg
+	}
+	i = skipWhitespace(data, i)
+	if i >= len(data) {
+		return i, "unexpected end of JSON input"
+	}
+	if data[i] != ':' {
+		return i, fmt.Sprintf("invalid character '%c' after object key", data[i])
+	}
+	return skipWhitespace(data, i+1), ""
+}
+
+// validString checks the string beginning at data[i], returning the offset just past it.
+func validString(data []byte, i int, requireUTF8 bool) (int, string) {
+	for i++; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			return i + 1, ""
+		case c < 0x20:
+			return i, "invalid control character in string literal"
+		case c == '\\':
+			i++
+			if i >= len(data) {
+				return i, "unexpected end of JSON input"
+			}
+			switch data[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if i+4 >= len(data) || !isHex(data[i+1]) || !isHex(data[i+2]) || !isHex(data[i+3]) || !isHex(data[i+4]) {
+					return i, "invalid unicode escape in string literal"
+				}
+				i += 4
+			default:
+				return i, fmt.Sprintf("invalid escape '\\%c' in string literal", data[i])
+			}
+		case c >= utf8.RuneSelf && requireUTF8:
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				return i, "invalid UTF-8 in string literal"
+			}
+			i += size - 1
+		}
+	}
+	return i, "unexpected end of JSON input"
+}
+
+func isHex(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F'
+}
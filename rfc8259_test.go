@@ -0,0 +1,59 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestRFC8259(t *testing.T) {
+	var tests = []struct {
+		input  string
+		offset int64
+		msg    string
+	}{
+		{`{"a": [1, "é", null]}`, 0, ""},
+		{`[01]`, 1, "invalid number 01: leading zero"},
+		{`{"a": -00.5}`, 6, "invalid number -00.5: leading zero"},
+		{`{"a": 1} {"b": 2}`, 9, "invalid character '{' after top-level value"},
+		{`["\x"]`, 3, `invalid escape '\x' in string literal`},
+		{`["\u12"]`, 3, "invalid unicode escape in string literal"},
+		{"[\"a\xffb\"]", 3, "invalid UTF-8 in string literal"},
+		{`{"a" 1}`, 5, "invalid character '1' after object key"},
+		{`{"a": 1,}`, 8, "invalid character '}' looking for beginning of object key string"},
+		{`[1,]`, 3, "invalid character ']' looking for beginning of value"},
+		{`[1 2]`, 3, "invalid character '2' after array element"},
+		{`[tru]`, 1, `invalid literal "tru"`},
+		{`{"a": [1`, 8, "unexpected end of JSON input"},
+	}
+	for _, test := range tests {
+		val, err := NewValueFromBytesWithOptions([]byte(test.input), ParseOptions{RFC8259: true})
+		if test.msg == "" {
+			if err != nil || val.Type() != OBJECT {
+				t.Errorf("Expected %s to be accepted, got %v", test.input, err)
+			}
+			continue
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Expected *SyntaxError for %s, got %#v", test.input, err)
+			continue
+		}
+		if serr.Offset != test.offset || serr.msg != test.msg {
+			t.Errorf("Expected %q at %d for %s, got %q at %d", test.msg, test.offset, test.input, serr.msg, serr.Offset)
+		}
+	}
+
+	// without RFC8259, invalid UTF-8 is accepted
+	val, err := NewValueFromBytesWithOptions([]byte("[\"a\xffb\"]"), ParseOptions{})
+	if err != nil || val.Type() != ARRAY {
+		t.Errorf("Expected invalid UTF-8 to be accepted, got %v", err)
+	}
+}
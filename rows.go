@@ -0,0 +1,132 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bufio"
+	"io"
+)
+
+// The largest single document NewRowsFromReader will accept.
+const MaxRowSize = 64 * 1024 * 1024
+
+// Rows iterates over a sequence of Values, in the style of database/sql.Rows:
+//
+//         for rows.Next() {
+//                 doc := rows.Value()
+//                 ...
+//         }
+//         if err := rows.Err(); err != nil {
+//                 ...
+//         }
+type Rows struct {
+	next    func() (*Value, error)
+	close   func() error
+	current *Value
+	err     error
+	closed  bool
+}
+
+// Create Rows which return each Value received on the channel, until it is closed.
+func NewRowsFromChannel(ch ValueChannel) *Rows {
+	return &Rows{
+		next: func() (*Value, error) {
+			val, ok := <-ch
+			if !ok {
+				return nil, io.EOF
+			}
+			return val, nil
+		},
+	}
+}
+
+// Create Rows which return each Value in the collection.
+func NewRowsFromCollection(coll ValueCollection) *Rows {
+	i := 0
+	return &Rows{
+		next: func() (*Value, error) {
+			if i >= len(coll) {
+				return nil, io.EOF
+			}
+			i++
+			return coll[i-1], nil
+		},
+	}
+}
+
+// Create Rows which return each JSON document read from r, which may contain any number of
+// back-to-back JSON documents (see ScanDocuments).  If r is also an io.Closer, it is closed
+// by Close().
+func NewRowsFromReader(r io.Reader) *Rows {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxRowSize)
+	scanner.Split(ScanDocuments)
+	rv := Rows{
+		next: func() (*Value, error) {
+			if !scanner.Scan() {
+				if scanner.Err() != nil {
+					return nil, scanner.Err()
+				}
+				return nil, io.EOF
+			}
+			// the scanner reuses its buffer
+			doc := make([]byte, len(scanner.Bytes()))
+			copy(doc, scanner.Bytes())
+			return NewValueFromBytes(doc), nil
+		},
+	}
+	if closer, ok := r.(io.Closer); ok {
+		rv.close = closer.Close
+	}
+	return &rv
+}
+
+// Advance to the next Value, returning false when there are no more Values or an error occurred.
+func (this *Rows) Next() bool {
+	if this.closed {
+		return false
+	}
+	val, err := this.next()
+	if err != nil {
+		if err != io.EOF {
+			this.err = err
+		}
+		this.current = nil
+		this.Close()
+		return false
+	}
+	this.current = val
+	return true
+}
+
+// The current Value, valid after a call to Next() which returned true.
+func (this *Rows) Value() *Value {
+	return this.current
+}
+
+// The error which stopped the iteration, if any.
+func (this *Rows) Err() error {
+	return this.err
+}
+
+// Stop the iteration.  It is safe to call Close more than once, and it is
+// called automatically when Next() returns false.
+//
+// NOTE: Closing Rows created from a channel does not drain the channel.
+func (this *Rows) Close() error {
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+	if this.close != nil {
+		return this.close()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRows(t *testing.T) {
+	ch := make(ValueChannel)
+	go func() {
+		ch <- NewValue(1.0)
+		ch <- NewValue(2.0)
+		close(ch)
+	}()
+
+	var tests = []struct {
+		rows     *Rows
+		expected []interface{}
+		err      bool
+	}{
+		{NewRowsFromChannel(ch), []interface{}{1.0, 2.0}, false},
+		{NewRowsFromCollection(ValueCollection{NewValue("a"), NewValue("b")}), []interface{}{"a", "b"}, false},
+		{NewRowsFromReader(strings.NewReader(`{"a":1} [2]`)), []interface{}{map[string]interface{}{"a": 1.0}, []interface{}{2.0}}, false},
+		{NewRowsFromReader(strings.NewReader(`{"a":1} [2`)), []interface{}{map[string]interface{}{"a": 1.0}}, true},
+	}
+
+	for i, test := range tests {
+		var actual []interface{}
+		for test.rows.Next() {
+			actual = append(actual, test.rows.Value().Value())
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Expected %v, got %v for rows %d", test.expected, actual, i)
+		}
+		if test.err != (test.rows.Err() != nil) {
+			t.Errorf("Unexpected error state %v for rows %d", test.rows.Err(), i)
+		}
+		if test.rows.Next() {
+			t.Errorf("Expected Next to return false after end for rows %d", i)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+)
+
+// The strategies Salvage() may use to recover a NOT_JSON Value
+const (
+	SALVAGE_REPAIR = iota // the bytes are fixed by RepairJSON()
+	SALVAGE_STRING        // the bytes are valid UTF-8 text, and become a STRING holding that text
+	SALVAGE_BASE64        // the bytes become a STRING holding their base64 encoding, this always succeeds
+)
+
+// The attachment key under which Salvage() stores the strategy which succeeded.
+const SALVAGE_ATTACHMENT = "salvage"
+
+// Attempt to recover a NOT_JSON Value by trying each of the strategies in turn.  The first
+// to succeed produces the returned Value, which has the attachments and score of val, and records the
+// strategy (see Salvaged()).  If val is not NOT_JSON, or no strategy succeeds, val is returned
+// unchanged along with false.
+func Salvage(val *Value, strategies ...int) (*Value, bool) {
+	if val.Type() != NOT_JSON {
+		return val, false
+	}
+	for _, strategy := range strategies {
+		var rv *Value
+		switch strategy {
+		case SALVAGE_REPAIR:
+			rv, _, _ = RepairJSON(val.raw)
+		case SALVAGE_STRING:
+			if utf8.Valid(val.raw) {
+				rv = NewValue(string(val.raw))
+			}
+		case SALVAGE_BASE64:
+			rv = NewValue(base64.StdEncoding.EncodeToString(val.raw))
+		}
+		if rv != nil {
+			for k, v := range val.attachments {
+				rv.SetAttachment(k, v)
+			}
+			rv.score = val.score
+			rv.SetAttachment(SALVAGE_ATTACHMENT, strategy)
+			return rv, true
+		}
+	}
+	return val, false
+}
+
+// Return the strategy by which Salvage() recovered this Value, or false if it was not salvaged.
+func (this *Value) Salvaged() (int, bool) {
+	strategy, ok := this.GetAttachment(SALVAGE_ATTACHMENT).(int)
+	return strategy, ok
+}
@@ -0,0 +1,75 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSalvage(t *testing.T) {
+	all := []int{SALVAGE_REPAIR, SALVAGE_STRING, SALVAGE_BASE64}
+	var tests = []struct {
+		input    []byte
+		strategy int
+		expected interface{}
+	}{
+		{[]byte(`{"a":1,}`), SALVAGE_REPAIR, map[string]interface{}{"a": 1.0}},
+		{[]byte(`hello world`), SALVAGE_STRING, "hello world"},
+		{[]byte{0xff, 0x00}, SALVAGE_BASE64, "/wA="},
+	}
+	for _, test := range tests {
+		val, ok := Salvage(NewValueFromBytes(test.input), all...)
+		if !ok {
+			t.Errorf("Expected %q to be salvaged", test.input)
+			continue
+		}
+		strategy, ok := val.Salvaged()
+		if !ok || strategy != test.strategy {
+			t.Errorf("Expected strategy %d, got %d for %q", test.strategy, strategy, test.input)
+		}
+		if !reflect.DeepEqual(val.Value(), test.expected) {
+			t.Errorf("Expected %v, got %v for %q", test.expected, val.Value(), test.input)
+		}
+	}
+
+	bad := NewValueFromMutation(&Mutation{Key: "k", Body: []byte{0xff}})
+	val, ok := Salvage(bad, SALVAGE_REPAIR, SALVAGE_STRING)
+	if ok || val != bad {
+		t.Errorf("Expected salvage to fail")
+	}
+	bad.SetScore(0.5)
+	val, ok = Salvage(bad, SALVAGE_BASE64)
+	if !ok || val.Meta().Key != "k" || val.Score() != 0.5 {
+		t.Errorf("Expected the salvaged value to keep its meta and score")
+	}
+	if _, ok := NewValue(1.0).Salvaged(); ok {
+		t.Errorf("Expected valid JSON not to be salvaged")
+	}
+}
+
+func TestStageSalvage(t *testing.T) {
+	deadLetters := make(ValueChannel, 10)
+	out, _ := RunStage(mutationChannel(`2`, `4,`, `{bad`), keepEven, StageOptions{
+		DeadLetters: deadLetters,
+		Salvage:     []int{SALVAGE_REPAIR},
+	})
+	var actual []interface{}
+	for val := range out {
+		actual = append(actual, val.Value())
+	}
+	close(deadLetters)
+	if !reflect.DeepEqual(actual, []interface{}{2.0}) {
+		t.Errorf("Expected 2, got %v", actual)
+	}
+	if len(deadLetters) != 2 {
+		t.Errorf("Expected 2 dead letters, got %d", len(deadLetters))
+	}
+}
@@ -0,0 +1,317 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	json "github.com/dustin/gojson"
+)
+
+// errUnexpectedEnd is returned by the scanner when the input ends before
+// the value being scanned is complete.
+var errUnexpectedEnd = errors.New("unexpected end of JSON input")
+
+// scanError is returned by the scanner when the input is not well formed.
+type scanError struct {
+	offset int // offset of the offending byte
+	msg    string
+}
+
+func (this *scanError) Error() string {
+	return this.msg
+}
+
+// scanValue finds the extent of the single JSON value which begins at (or
+// after any whitespace following) data[start].  It returns the offset just
+// past the end of the value.
+//
+// The scanner only tracks the nesting of objects, arrays and strings, it
+// does not fully validate the value.
+func scanValue(data []byte, start int) (int, error) {
+	i := skipWhitespace(data, start)
+	if i >= len(data) {
+		return i, errUnexpectedEnd
+	}
+	switch data[i] {
+	case '{', '[':
+		return scanComposite(data, i)
+	case '"':
+		return scanString(data, i)
+	case '}', ']', ',', ':':
+		return i, &scanError{i, fmt.Sprintf("invalid character '%c' looking for beginning of value", data[i])}
+	default:
+		return scanLiteral(data, i), nil
+	}
+}
+
+// scanComposite returns the offset just past the object or array opened
+// at data[start].
+func scanComposite(data []byte, start int) (int, error) {
+	stack := make([]byte, 0, 16)
+	for i := start; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if stack[len(stack)-1] != c {
+				return i, &scanError{i, fmt.Sprintf("invalid character '%c' expecting '%c'", c, stack[len(stack)-1])}
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i + 1, nil
+			}
+		case '"':
+			end, err := scanString(data, i)
+			if err != nil {
+				return end, err
+			}
+			i = end - 1
+		}
+	}
+	return len(data), errUnexpectedEnd
+}
+
+// scanString returns the offset just past the string opened at data[start].
+func scanString(data []byte, start int) (int, error) {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return len(data), errUnexpectedEnd
+}
+
+// scanLiteral returns the offset just past the number, true, false or null
+// literal beginning at data[start].
+func scanLiteral(data []byte, start int) int {
+	i := start
+	for i < len(data) && !isDelimiter(data[i]) && data[i] != '{' && data[i] != '[' && data[i] != '"' {
+		i++
+	}
+	return i
+}
+
+// arrayElements returns the raw bytes of each element of the (well formed)
+// JSON array in data.
+func arrayElements(data []byte) ([][]byte, error) {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '[' {
+		return nil, &scanError{i, "expected array"}
+	}
+	var rv [][]byte
+	i = skipWhitespace(data, i+1)
+	if i < len(data) && data[i] == ']' {
+		return rv, nil
+	}
+	for i < len(data) {
+		start := skipWhitespace(data, i)
+		end, err := scanValue(data, start)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, data[start:end])
+		i = skipWhitespace(data, end)
+		if i >= len(data) {
+			break
+		}
+		switch data[i] {
+		case ',':
+			i++
+		case ']':
+			return rv, nil
+		default:
+			return nil, &scanError{i, fmt.Sprintf("invalid character '%c' after array element", data[i])}
+		}
+	}
+	return nil, errUnexpectedEnd
+}
+
+// countElements returns the number of elements of the (well formed) JSON
+// array in data, without copying them.
+func countElements(data []byte) (int, error) {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '[' {
+		return 0, &scanError{i, "expected array"}
+	}
+	i = skipWhitespace(data, i+1)
+	if i < len(data) && data[i] == ']' {
+		return 0, nil
+	}
+	count := 0
+	for i < len(data) {
+		end, err := scanValue(data, i)
+		if err != nil {
+			return 0, err
+		}
+		count++
+		i = skipWhitespace(data, end)
+		if i >= len(data) {
+			break
+		}
+		switch data[i] {
+		case ',':
+			i++
+		case ']':
+			return count, nil
+		default:
+			return 0, &scanError{i, fmt.Sprintf("invalid character '%c' after array element", data[i])}
+		}
+	}
+	return 0, errUnexpectedEnd
+}
+
+// objectMembers returns the keys, and the raw bytes of the corresponding
+// values, of the (well formed) JSON object in data, in the order they appear.
+func objectMembers(data []byte) ([]string, [][]byte, error) {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return nil, nil, &scanError{i, "expected object"}
+	}
+	var keys []string
+	var values [][]byte
+	i = skipWhitespace(data, i+1)
+	if i < len(data) && data[i] == '}' {
+		return keys, values, nil
+	}
+	for i < len(data) {
+		start := skipWhitespace(data, i)
+		if start >= len(data) || data[start] != '"' {
+			return nil, nil, &scanError{start, "expected object key"}
+		}
+		end, err := scanString(data, start)
+		if err != nil {
+			return nil, nil, err
+		}
+		var key string
+		err = json.Unmarshal(data[start:end], &key)
+		if err != nil {
+			return nil, nil, err
+		}
+		i = skipWhitespace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return nil, nil, &scanError{i, "expected ':' after object key"}
+		}
+		start = skipWhitespace(data, i+1)
+		end, err = scanValue(data, start)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values = append(values, data[start:end])
+		i = skipWhitespace(data, end)
+		if i >= len(data) {
+			break
+		}
+		switch data[i] {
+		case ',':
+			i++
+		case '}':
+			return keys, values, nil
+		default:
+			return nil, nil, &scanError{i, fmt.Sprintf("invalid character '%c' after object value", data[i])}
+		}
+	}
+	return nil, nil, errUnexpectedEnd
+}
+
+// exceedsDepth determines if the objects and arrays in data are nested more
+// than max levels deep, and if so the offset at which the limit is exceeded.
+func exceedsDepth(data []byte, max int) (int, bool) {
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '{', '[':
+			depth++
+			if depth > max {
+				return i, true
+			}
+		case '}', ']':
+			depth--
+		case '"':
+			end, _ := scanString(data, i)
+			i = end - 1
+		}
+	}
+	return 0, false
+}
+
+// rawMember returns the raw bytes of the value of the first member named key in the (well formed)
+// JSON object in data, without copying them.  Keys are only unescaped if they contain escapes.
+func rawMember(data []byte, key string) ([]byte, bool) {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return nil, false
+	}
+	i = skipWhitespace(data, i+1)
+	for i < len(data) && data[i] == '"' {
+		end, err := scanString(data, i)
+		if err != nil {
+			return nil, false
+		}
+		match := false
+		if quoted := data[i+1 : end-1]; bytes.IndexByte(quoted, '\\') < 0 {
+			match = string(quoted) == key
+		} else {
+			var k string
+			match = json.Unmarshal(data[i:end], &k) == nil && k == key
+		}
+		i = skipWhitespace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return nil, false
+		}
+		start := skipWhitespace(data, i+1)
+		end, err = scanValue(data, start)
+		if err != nil {
+			return nil, false
+		}
+		if match {
+			return data[start:end], true
+		}
+		i = skipWhitespace(data, end)
+		if i >= len(data) || data[i] != ',' {
+			return nil, false
+		}
+		i = skipWhitespace(data, i+1)
+	}
+	return nil, false
+}
+
+// rawElement returns the raw bytes of element index of the (well formed) JSON array in data,
+// without copying them.
+func rawElement(data []byte, index int) ([]byte, bool) {
+	i := skipWhitespace(data, 0)
+	if index < 0 || i >= len(data) || data[i] != '[' {
+		return nil, false
+	}
+	i = skipWhitespace(data, i+1)
+	for n := 0; i < len(data) && data[i] != ']'; n++ {
+		end, err := scanValue(data, i)
+		if err != nil {
+			return nil, false
+		}
+		if n == index {
+			return data[i:end], true
+		}
+		i = skipWhitespace(data, end)
+		if i >= len(data) || data[i] != ',' {
+			return nil, false
+		}
+		i = skipWhitespace(data, i+1)
+	}
+	return nil, false
+}
@@ -0,0 +1,195 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A Schema describes the values allowed at some place in a document.
+type Schema struct {
+	Type       int                // the Value type, or ANY_TYPE
+	Properties map[string]*Schema // for an OBJECT, the schema of each known property, nil allows anything
+	Additional bool               // for an OBJECT, allow properties which are not in Properties
+	Items      *Schema            // for an ARRAY, the schema of every element, nil allows anything
+}
+
+// When a property is not allowed by a Schema, the return error (or the value
+// SetPath() panics with) will be *UnknownProperty.
+type UnknownProperty struct {
+	Path string
+}
+
+// Description of the property which is not allowed.
+func (this *UnknownProperty) Error() string {
+	return fmt.Sprintf("%s is not allowed by the schema", this.Path)
+}
+
+// Bind a Schema to this Value, so that SetPath(), SetIndex() and Insert() reject values which it does
+// not allow, catching bugs where the mutation is made rather than downstream.  A rejected
+// mutation is not made, and the call panics with a *TypeMismatch or *UnknownProperty.
+// SetPathErr(), SetIndexErr(), SetDeepPath(), UpdateWhere() and Editor.Commit() are checked
+// too, and return the error rather than panicking.
+//
+// The schema also applies to the objects and arrays later returned by Path() and Index(),
+// so changes made to them are checked as well.  The current contents of the Value are not
+// checked, see CheckSchema().  A nil schema removes the binding.
+func (this *Value) BindSchema(schema *Schema) {
+	this.checkMutable()
+	this.schema = schema
+	this.schemaFrom = nil
+}
+
+// Return the Schema bound to this Value, or nil if there is none.  For an object or array
+// returned by Path() or Index(), this is the part of the Schema of the Value it was found in
+// which applies to it, unless a Schema has been bound to it directly.
+func (this *Value) Schema() *Schema {
+	if this.schema != nil || this.schemaFrom == nil {
+		return this.schema
+	}
+	parent := this.schemaFrom.parent
+	schema := parent.Schema()
+	if schema == nil {
+		return nil
+	}
+	if parent.parsedType == ARRAY {
+		return schema.Items
+	}
+	return schema.Properties[this.schemaFrom.step]
+}
+
+// Check the whole of this Value against its Schema, returning the first problem found
+// as a *TypeMismatch or *UnknownProperty.  A Value without a Schema is always valid.
+func (this *Value) CheckSchema() error {
+	schema := this.Schema()
+	if schema == nil {
+		return nil
+	}
+	return schema.check("", this)
+}
+
+// check determines if val is allowed by this Schema, reporting problems relative to path.
+func (this *Schema) check(path string, val *Value) error {
+	if this.Type != ANY_TYPE && val.Type() != this.Type {
+		return &TypeMismatch{Path: path, Expected: this.Type, Actual: val.Type()}
+	}
+	switch val.Type() {
+	case OBJECT:
+		if this.Properties == nil {
+			return nil
+		}
+		for k, v := range val.members() {
+			err := this.checkProperty(joinPath(path, k), k, v)
+			if err != nil {
+				return err
+			}
+		}
+	case ARRAY:
+		if this.Items == nil {
+			return nil
+		}
+		elements, _ := val.elements()
+		for i, v := range elements {
+			err := this.Items.check(joinPath(path, strconv.Itoa(i)), v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkProperty determines if this OBJECT Schema allows val as property key.
+func (this *Schema) checkProperty(path, key string, val *Value) error {
+	if this.Properties == nil {
+		return nil
+	}
+	property, ok := this.Properties[key]
+	if !ok {
+		if this.Additional {
+			return nil
+		}
+		return &UnknownProperty{path}
+	}
+	return property.check(path, val)
+}
+
+func joinPath(path, step string) string {
+	if path == "" {
+		return step
+	}
+	return path + "." + step
+}
+
+// checkSetPath panics if the Schema bound to this Value does not allow val at path,
+// otherwise it returns val brought into the type system.
+func (this *Value) checkSetPath(path string, val interface{}) interface{} {
+	rv, err := this.validateSetPath(path, val)
+	if err != nil {
+		panic(err)
+	}
+	return rv
+}
+
+// validateSetPath is checkSetPath, returning the error rather than panicking.
+func (this *Value) validateSetPath(path string, val interface{}) (interface{}, error) {
+	if schema := this.Schema(); schema != nil {
+		rv := NewValue(val)
+		if err := schema.checkProperty(path, path, rv); err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+	return val, nil
+}
+
+// checkSetIndex panics if the Schema bound to this Value does not allow val as an element,
+// otherwise it returns val brought into the type system.
+func (this *Value) checkSetIndex(index int, val interface{}) interface{} {
+	rv, err := this.validateSetIndex(index, val)
+	if err != nil {
+		panic(err)
+	}
+	return rv
+}
+
+// validateSetIndex is checkSetIndex, returning the error rather than panicking.
+func (this *Value) validateSetIndex(index int, val interface{}) (interface{}, error) {
+	if schema := this.Schema(); schema != nil && schema.Items != nil {
+		rv := NewValue(val)
+		if err := schema.Items.check(strconv.Itoa(index), rv); err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+	return val, nil
+}
+
+// schemaSource records where a Value was found inside a Value with a Schema,
+// so the part of that Schema which applies to it is found when it is needed.
+type schemaSource struct {
+	parent *Value
+	step   string
+}
+
+// bindChild records that child was found at step inside this Value, if this Value has
+// a Schema, so a change later made to child is checked against the part which applies.
+func (this *Value) bindChild(step string, child *Value) {
+	if child.schema != nil || child.shared || (child.parsedType != OBJECT && child.parsedType != ARRAY) {
+		return
+	}
+	if this.schema == nil && this.schemaFrom == nil {
+		return
+	}
+	if child.schemaFrom == nil || child.schemaFrom.parent != this || child.schemaFrom.step != step {
+		child.schemaFrom = &schemaSource{this, step}
+	}
+}
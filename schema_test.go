@@ -0,0 +1,185 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+var productSchema = &Schema{
+	Type: OBJECT,
+	Properties: map[string]*Schema{
+		"name":  {Type: STRING},
+		"price": {Type: NUMBER},
+		"tags":  {Type: ARRAY, Items: &Schema{Type: STRING}},
+		"stock": {
+			Type:       OBJECT,
+			Properties: map[string]*Schema{"count": {Type: NUMBER}},
+			Additional: true,
+		},
+	},
+}
+
+// expectPanic calls fn, returning the value it panicked with.
+func expectPanic(fn func()) (rv interface{}) {
+	defer func() {
+		rv = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestSchemaBinding(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name": "widget", "price": 1, "tags": ["a"], "stock": {"count": 3}}`))
+	doc.BindSchema(productSchema)
+	if err := doc.CheckSchema(); err != nil {
+		t.Errorf("Expected doc to be valid, got %v", err)
+	}
+
+	doc.SetPath("price", 2.5)
+	if _, ok := expectPanic(func() { doc.SetPath("price", "free") }).(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch setting a string price")
+	}
+	if _, ok := expectPanic(func() { doc.SetPath("colour", "red") }).(*UnknownProperty); !ok {
+		t.Errorf("Expected *UnknownProperty setting an unknown field")
+	}
+	if price, _ := doc.Path("price"); price.Value() != 2.5 {
+		t.Errorf("Expected rejected mutations not to be made, got %v", price.Value())
+	}
+
+	// nested objects and arrays are checked too
+	tags, _ := doc.Path("tags")
+	tags.SetIndex(0, "b")
+	if _, ok := expectPanic(func() { tags.SetIndex(0, 1.0) }).(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch setting a numeric tag")
+	}
+	stock, _ := doc.Path("stock")
+	stock.SetPath("warehouse", "north")
+	if expectPanic(func() { stock.SetPath("count", nil) }) == nil {
+		t.Errorf("Expected a panic setting a null count")
+	}
+	if _, ok := expectPanic(func() { doc.SetPath("stock", map[string]interface{}{"count": "3"}) }).(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch replacing stock with an invalid object")
+	}
+
+	// changes made through other methods return the error rather than panicking
+	err := doc.SetDeepPath("stock.count", "3")
+	if _, ok := err.(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch from SetDeepPath, got %v", err)
+	}
+	err = doc.SetDeepPath("dimensions.width", 2.0)
+	if _, ok := err.(*UnknownProperty); !ok {
+		t.Errorf("Expected *UnknownProperty from SetDeepPath, got %v", err)
+	}
+	editor, _ := doc.Edit("")
+	editor.Set("colour", "red")
+	if _, ok := editor.Commit().(*UnknownProperty); !ok {
+		t.Errorf("Expected *UnknownProperty from Commit")
+	}
+	editor, _ = doc.Edit("stock")
+	editor.Set("count", "3")
+	if _, ok := editor.Commit().(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch from Commit")
+	}
+	expected := `{"name":"widget","price":2.5,"stock":{"count":3,"warehouse":"north"},"tags":["b"]}`
+	if string(doc.Bytes()) != expected {
+		t.Errorf("Expected rejected changes not to be made, got %s", doc.Bytes())
+	}
+
+	invalid := NewValueFromBytes([]byte(`{"name": "widget", "tags": ["a", 2]}`))
+	invalid.BindSchema(productSchema)
+	err = invalid.CheckSchema()
+	if terr, ok := err.(*TypeMismatch); !ok || terr.Path != "tags.1" {
+		t.Errorf("Expected *TypeMismatch at tags.1, got %v", err)
+	}
+
+	invalid.BindSchema(nil)
+	invalid.SetPath("anything", true)
+	if invalid.Schema() != nil {
+		t.Errorf("Expected the schema to be removed")
+	}
+}
+
+func TestSchemaUpdateWhere(t *testing.T) {
+	orderSchema := &Schema{
+		Type: OBJECT,
+		Properties: map[string]*Schema{
+			"items": {Type: ARRAY, Items: &Schema{Type: OBJECT, Properties: map[string]*Schema{"qty": {Type: NUMBER}}}},
+		},
+	}
+	input := `{"items":[{"qty":1},{"qty":2}]}`
+	doc := NewValueFromBytes([]byte(input))
+	doc.BindSchema(orderSchema)
+
+	count, err := UpdateWhere(doc, "items", "qty > 1", map[string]interface{}{"qty": 3.0})
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 update, got %d, %v", count, err)
+	}
+	_, err = UpdateWhere(doc, "items", "qty > 0", map[string]interface{}{"qty": "many"})
+	if _, ok := err.(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch, got %v", err)
+	}
+	_, err = UpdateWhere(doc, "items", "qty > 0", map[string]interface{}{"colour": "red"})
+	if _, ok := err.(*UnknownProperty); !ok {
+		t.Errorf("Expected *UnknownProperty, got %v", err)
+	}
+	if string(doc.Bytes()) != `{"items":[{"qty":1},{"qty":3}]}` {
+		t.Errorf("Expected rejected updates not to be made, got %s", doc.Bytes())
+	}
+
+	// the document itself as the array
+	items, _ := doc.Path("items")
+	array := NewValueFromBytes(items.Bytes())
+	array.BindSchema(orderSchema.Properties["items"])
+	_, err = UpdateWhere(array, "", "qty = 1", map[string]interface{}{"qty": false})
+	if _, ok := err.(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch, got %v", err)
+	}
+	if string(array.Bytes()) != `[{"qty":1},{"qty":3}]` {
+		t.Errorf("Expected rejected updates not to be made, got %s", array.Bytes())
+	}
+}
+
+func TestSchemaLazyBinding(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"name": "widget", "tags": ["a"], "stock": {"count": 3}}`))
+	tags, _ := doc.Path("tags")
+	if tags.schemaFrom != nil {
+		t.Errorf("Expected nothing recorded for a Value without a Schema")
+	}
+
+	// the schema is bound without using the attachments
+	doc.BindSchema(productSchema)
+	if doc.GetAttachment("schema") != nil {
+		t.Errorf("Expected the schema not to be an attachment")
+	}
+
+	// the part of the schema which applies is found when a change is checked,
+	// so it follows the schema bound to the document
+	tags, _ = doc.Path("tags")
+	if tags.Schema() != productSchema.Properties["tags"] {
+		t.Errorf("Expected the tags schema, got %v", tags.Schema())
+	}
+	if _, ok := expectPanic(func() { tags.SetIndex(0, 1.0) }).(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch setting a numeric tag")
+	}
+	doc.BindSchema(nil)
+	tags.SetIndex(0, 1.0)
+	if tags.Schema() != nil {
+		t.Errorf("Expected no schema once the document has none")
+	}
+
+	// a schema bound directly takes precedence
+	stock, _ := doc.Path("stock")
+	stock.BindSchema(&Schema{Type: OBJECT, Properties: map[string]*Schema{}})
+	doc.BindSchema(productSchema)
+	if _, ok := expectPanic(func() { stock.SetPath("warehouse", "north") }).(*UnknownProperty); !ok {
+		t.Errorf("Expected *UnknownProperty from the schema bound to stock")
+	}
+}
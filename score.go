@@ -0,0 +1,39 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"sort"
+)
+
+// Set the score (or rank) of this Value, for example the relevance of a search result.
+// The score is kept alongside the Value, so it is never part of Value() or Bytes().
+func (this *Value) SetScore(score float64) {
+	this.checkMutable()
+	this.score = score
+}
+
+// Return the score of this Value set by SetScore().
+// If no score has been set, 0 is returned.
+func (this *Value) Score() float64 {
+	return this.score
+}
+
+// Sort the Values in this collection by score, highest first.  Values with equal
+// scores keep their relative order.
+func (this ValueCollection) SortByScore() {
+	sort.Stable(byScore(this))
+}
+
+type byScore ValueCollection
+
+func (this byScore) Len() int           { return len(this) }
+func (this byScore) Swap(i, j int)      { this[i], this[j] = this[j], this[i] }
+func (this byScore) Less(i, j int) bool { return this[i].Score() > this[j].Score() }
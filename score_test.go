@@ -0,0 +1,46 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestSortByScore(t *testing.T) {
+	collection := ValueCollection{}
+	for i, score := range []float64{0.5, 2, 0.5, 1} {
+		val := NewValue(float64(i))
+		val.SetScore(score)
+		collection = append(collection, val)
+	}
+	unscored := NewValue("unscored")
+	collection = append(collection, unscored)
+
+	collection.SortByScore()
+
+	expected := []interface{}{1.0, 3.0, 0.0, 2.0, "unscored"}
+	for i, val := range collection {
+		if val.Value() != expected[i] {
+			t.Errorf("Expected %v at %d, got %v", expected[i], i, val.Value())
+		}
+	}
+	if unscored.Score() != 0 {
+		t.Errorf("Expected score 0 for unscored value, got %v", unscored.Score())
+	}
+	if string(collection[0].Bytes()) != "1" {
+		t.Errorf("Score leaked into Bytes(): %s", collection[0].Bytes())
+	}
+
+	// the score is independent of the attachments
+	collection[0].SetAttachment("score", "mine")
+	if collection[0].Score() != 2 || collection[0].GetAttachment("score") != "mine" {
+		t.Errorf("Expected score and attachments to be independent")
+	}
+}
@@ -0,0 +1,98 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Store val at the dotted path inside this OBJECT, creating the objects along the path which
+// do not exist, so SetDeepPath("a.b.c", 1.0) on {} results in {"a":{"b":{"c":1}}}.  Integer
+// steps index into arrays which exist, as in "items.0.name", where a negative index counts from
+// the end.  Each step is a single property name, to set one containing dots use SetPathSteps().
+//
+// The Values along the path are updated in place, so they need not be fetched, changed and
+// set again.  Objects which are created are stored with SetPathErr() on the deepest Value which
+// exists, so a Schema or constraints of that Value apply as they do to SetPathErr(), and a
+// rejected change is returned as its error.
+//
+// If this Value, or a Value along the path, is neither an OBJECT nor an ARRAY (or is an ARRAY,
+// and the step is not an integer), the return error is *TypeMismatch.  If an index is outside
+// of its array, the return error is *Undefined.
+func (this *Value) SetDeepPath(path string, val interface{}) error {
+	return this.SetPathSteps(val, strings.Split(path, ".")...)
+}
+
+// Store val inside this OBJECT as SetDeepPath() does, where each of steps is a property name
+// (or an index into an array), so property names may contain dots.  There must be at least
+// one step, otherwise this panics.
+func (this *Value) SetPathSteps(val interface{}, steps ...string) error {
+	if len(steps) == 0 {
+		panic("SetPathSteps requires at least one step")
+	}
+	return this.setSteps(val, steps)
+}
+
+// setSteps stores val at steps as SetPathSteps() does.
+func (this *Value) setSteps(val interface{}, steps []string) error {
+	cur := this
+	for i, step := range steps {
+		last := i == len(steps)-1
+		switch cur.Type() {
+		case OBJECT:
+			if last {
+				return cur.SetPathErr(step, val)
+			}
+			child, err := cur.member(step)
+			if _, ok := err.(*Undefined); ok {
+				return cur.SetPathErr(step, nestedObjects(steps[i+1:], val))
+			}
+			if err != nil {
+				return err
+			}
+			cur = child
+		case ARRAY:
+			index, err := strconv.Atoi(step)
+			if err != nil {
+				return &TypeMismatch{Path: strings.Join(steps[:i], "."), Expected: OBJECT, Actual: ARRAY}
+			}
+			if index < 0 {
+				n, err := cur.count("")
+				if err != nil {
+					return err
+				}
+				index += n
+			}
+			child, err := cur.Index(index)
+			if err != nil {
+				if _, ok := err.(*Undefined); ok {
+					return &Undefined{strings.Join(steps[:i+1], ".")}
+				}
+				return err
+			}
+			if last {
+				return cur.SetIndexErr(index, val)
+			}
+			cur = child
+		default:
+			return &TypeMismatch{Path: strings.Join(steps[:i], "."), Expected: OBJECT, Actual: cur.Type()}
+		}
+	}
+	return nil
+}
+
+// nestedObjects returns a new OBJECT holding val at steps, each of which is a property.
+func nestedObjects(steps []string, val interface{}) *Value {
+	for i := len(steps) - 1; i >= 0; i-- {
+		val = NewValue(map[string]interface{}{steps[i]: val})
+	}
+	return val.(*Value)
+}
@@ -0,0 +1,74 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestSetDeepPath(t *testing.T) {
+	raw := []byte(`{"name": "marty", "address": {"city": "x"}, "items": [{"sku": "a"}, {"sku": "b"}]}`)
+
+	var tests = []struct {
+		path     string
+		val      interface{}
+		expected string
+	}{
+		{"a.b.c", 1.0, `{"a":{"b":{"c":1}},"address":{"city":"x"},"items":[{"sku":"a"},{"sku":"b"}],"name":"marty"}`},
+		{"address.geo.lat", 1.5, `{"address":{"city":"x","geo":{"lat":1.5}},"items":[{"sku":"a"},{"sku":"b"}],"name":"marty"}`},
+		{"address.city", "y", `{"address":{"city":"y"},"items":[{"sku":"a"},{"sku":"b"}],"name":"marty"}`},
+		{"items.1.qty", 2.0, `{"address":{"city":"x"},"items":[{"sku":"a"},{"qty":2,"sku":"b"}],"name":"marty"}`},
+		{"items.-2.sku", "c", `{"address":{"city":"x"},"items":[{"sku":"c"},{"sku":"b"}],"name":"marty"}`},
+		{"items.0", nil, `{"address":{"city":"x"},"items":[null,{"sku":"b"}],"name":"marty"}`},
+		{"name", NewValue(true), `{"address":{"city":"x"},"items":[{"sku":"a"},{"sku":"b"}],"name":true}`},
+	}
+
+	for _, test := range tests {
+		for _, doc := range []*Value{NewValueFromBytes(raw), NewValue(NewValueFromBytes(raw).Value())} {
+			err := doc.SetDeepPath(test.path, test.val)
+			if err != nil {
+				t.Errorf("Unexpected error %v for %s", err, test.path)
+			}
+			if string(doc.Bytes()) != test.expected {
+				t.Errorf("Expected %s for %s, got %s", test.expected, test.path, doc.Bytes())
+			}
+			expected, ok := test.val.(*Value)
+			if !ok {
+				expected = NewValue(test.val)
+			}
+			if found, err := doc.Path(test.path); err != nil || !found.Equals(expected) {
+				t.Errorf("Expected to find the value at %s, got %v, %v", test.path, found, err)
+			}
+		}
+	}
+
+	doc := NewValueFromBytes(raw)
+	err := doc.SetPathSteps(1.0, "a.b", "c")
+	if err != nil || string(doc.Bytes()) != `{"a.b":{"c":1},"address":{"city":"x"},"items":[{"sku":"a"},{"sku":"b"}],"name":"marty"}` {
+		t.Errorf("Expected a property named a.b, got %s, %v", doc.Bytes(), err)
+	}
+
+	for path, code := range map[string]string{
+		"name.first":  "type_mismatch",
+		"items.x":     "type_mismatch",
+		"items.2.sku": "undefined",
+	} {
+		err := NewValueFromBytes(raw).SetDeepPath(path, 1.0)
+		if classified, ok := err.(ClassifiedError); !ok || classified.Code() != code {
+			t.Errorf("Expected %s for %s, got %v", code, path, err)
+		}
+	}
+	if err := NewValue("x").SetDeepPath("a", 1.0); err == nil {
+		t.Errorf("Expected an error setting a path in a string")
+	}
+	if expectPanic(func() { NewValue(map[string]interface{}{}).SetPathSteps(1.0) }) == nil {
+		t.Errorf("Expected a panic without any steps")
+	}
+}
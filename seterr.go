@@ -0,0 +1,64 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+)
+
+// Store val at the specified path as SetPath() does, but report why the change was rejected
+// rather than ignoring it or panicking.  If this Value is not of type OBJECT, the return error
+// is *TypeMismatch.  If a bound Schema does not allow val, its error is returned (for example
+// *TypeMismatch or *UnknownProperty), and if a constraint would be violated, the return error
+// is *ConstraintViolation.  Nothing is changed when an error is returned.
+//
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+func (this *Value) SetPathErr(path string, val interface{}) error {
+	this.checkMutable()
+	if this.parsedType != OBJECT {
+		return &TypeMismatch{Expected: OBJECT, Actual: this.parsedType}
+	}
+	val, err := this.validateSetPath(path, val)
+	if err != nil {
+		return err
+	}
+	err = this.validateConstraints(path, val)
+	if err != nil {
+		return err
+	}
+	this.setMember(path, val)
+	return nil
+}
+
+// Store val at the specified index as SetIndex() does, but report why the change was rejected
+// rather than ignoring it or panicking.  If this Value is not of type ARRAY, the return error is
+// *TypeMismatch, and if the index is outside of the array, the return error is *OutOfRange.  If a
+// bound Schema does not allow val, its error is returned.  Nothing is changed when an error is returned.
+//
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+func (this *Value) SetIndexErr(index int, val interface{}) error {
+	this.checkMutable()
+	if this.parsedType != ARRAY {
+		return &TypeMismatch{Expected: ARRAY, Actual: this.parsedType}
+	}
+	n, err := this.count("")
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= n {
+		return &OutOfRange{Value: index, msg: fmt.Sprintf("the array has %d elements", n)}
+	}
+	val, err = this.validateSetIndex(index, val)
+	if err != nil {
+		return err
+	}
+	this.setElement(index, val)
+	return nil
+}
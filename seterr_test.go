@@ -0,0 +1,84 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetPathErr(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"price": 1}`))
+	err := doc.SetPathErr("name", "widget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := doc.Path("name"); name == nil || name.Value() != "widget" {
+		t.Errorf("Expected name to be set, got %v", name)
+	}
+
+	err = NewValue([]interface{}{1.0}).SetPathErr("a", 1.0)
+	if tm, ok := err.(*TypeMismatch); !ok || tm.Expected != OBJECT || tm.Actual != ARRAY {
+		t.Errorf("Expected *TypeMismatch, got %v", err)
+	}
+
+	err = doc.AddConstraint("price", "price >= 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doc.SetPathErr("price", -1.0)
+	if _, ok := err.(*ConstraintViolation); !ok {
+		t.Errorf("Expected *ConstraintViolation, got %v", err)
+	}
+	if price, _ := doc.Path("price"); price.Value() != 1.0 {
+		t.Errorf("Expected price to be unchanged, got %v", price.Value())
+	}
+
+	typed := NewValueFromBytes([]byte(`{"n": 1}`))
+	typed.BindSchema(&Schema{Type: OBJECT, Properties: map[string]*Schema{"n": {Type: NUMBER}}})
+	err = typed.SetPathErr("n", "one")
+	if _, ok := err.(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch from the schema, got %v", err)
+	}
+}
+
+func TestSetIndexErr(t *testing.T) {
+	arr := NewValueFromBytes([]byte(`[1, 2, 3]`))
+	err := arr.SetIndexErr(1, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{1.0, "two", 3.0}
+	if !reflect.DeepEqual(arr.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, arr.Value())
+	}
+
+	for _, index := range []int{3, -1} {
+		err = arr.SetIndexErr(index, 0.0)
+		if _, ok := err.(*OutOfRange); !ok {
+			t.Errorf("Expected *OutOfRange for index %d, got %v", index, err)
+		}
+	}
+	if !reflect.DeepEqual(arr.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, arr.Value())
+	}
+
+	err = NewValue(map[string]interface{}{}).SetIndexErr(0, 1.0)
+	if tm, ok := err.(*TypeMismatch); !ok || tm.Expected != ARRAY || tm.Actual != OBJECT {
+		t.Errorf("Expected *TypeMismatch, got %v", err)
+	}
+
+	numbers := NewValueFromBytes([]byte(`[1]`))
+	numbers.BindSchema(&Schema{Type: ARRAY, Items: &Schema{Type: NUMBER}})
+	err = numbers.SetIndexErr(0, "one")
+	if _, ok := err.(*TypeMismatch); !ok {
+		t.Errorf("Expected *TypeMismatch from the schema, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	json "github.com/dustin/gojson"
+)
+
+// A ShapePool remembers the shapes of the documents parsed with it (see ParseOptions.Shapes):
+// the number of properties of each object, and of elements of each array, inside them.  A shape
+// is recorded from the first document with a given fingerprint, which is a hash of its top-level
+// keys in order.  Later documents with the same fingerprint are parsed into maps and slices
+// allocated at those sizes up front, rather than growing (and rehashing) them while parsing,
+// which reduces the cost of bulk loads of documents with the same structure.
+//
+// Shapes are only hints, so documents which differ from the recorded shape (or hash to the same
+// fingerprint) are still parsed correctly.  It is safe for concurrent use.
+type ShapePool struct {
+	mutex  sync.RWMutex
+	shapes map[uint64]*shape
+	max    int
+}
+
+// shape is the recorded size of an object or array, and the shapes of what it holds.
+type shape struct {
+	size     int
+	members  map[string]*shape // for an object, the shapes of its properties which are objects or arrays
+	elements *shape            // for an array, the shapes of its elements merged into one
+}
+
+// Create a new ShapePool holding at most max distinct shapes, 0 means no limit.  Once the
+// pool is full, documents whose shape is not already in it are parsed without hints.
+func NewShapePool(max int) *ShapePool {
+	return &ShapePool{
+		shapes: make(map[uint64]*shape),
+		max:    max,
+	}
+}
+
+// Return the number of distinct shapes in the pool.
+func (this *ShapePool) Len() int {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	return len(this.shapes)
+}
+
+// parse parses the raw bytes of an OBJECT, as json.Unmarshal() into an interface{} would,
+// using the shape recorded for its fingerprint, and records its shape if there is none.
+func (this *ShapePool) parse(data []byte) (interface{}, error) {
+	fingerprint, err := shapeFingerprint(data)
+	if err != nil {
+		return nil, err
+	}
+	this.mutex.RLock()
+	hint, known := this.shapes[fingerprint]
+	this.mutex.RUnlock()
+
+	rv, _, err := parseShaped(data, 0, hint)
+	if err != nil || known {
+		return rv, err
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if _, ok := this.shapes[fingerprint]; !ok && (this.max <= 0 || len(this.shapes) < this.max) {
+		this.shapes[fingerprint] = shapeOf(rv)
+	}
+	return rv, nil
+}
+
+// shapeFingerprint hashes the keys of the object in data, as they appear, without parsing it.
+func shapeFingerprint(data []byte) (uint64, error) {
+	h := fnv.New64a()
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return 0, &scanError{i, "expected object"}
+	}
+	i = skipWhitespace(data, i+1)
+	for i < len(data) && data[i] != '}' {
+		end, err := scanString(data, i)
+		if err != nil {
+			return 0, err
+		}
+		h.Write(data[i:end])
+		i = skipWhitespace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return 0, &scanError{i, "expected ':' after object key"}
+		}
+		end, err = scanValue(data, i+1)
+		if err != nil {
+			return 0, err
+		}
+		i = skipWhitespace(data, end)
+		if i < len(data) && data[i] == ',' {
+			i = skipWhitespace(data, i+1)
+		}
+	}
+	return h.Sum64(), nil
+}
+
+// shapeOf returns the shape of a parsed value, or nil if it is not an object or array.
+func shapeOf(val interface{}) *shape {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		rv := &shape{size: len(val)}
+		for k, v := range val {
+			if child := shapeOf(v); child != nil {
+				if rv.members == nil {
+					rv.members = make(map[string]*shape)
+				}
+				rv.members[k] = child
+			}
+		}
+		return rv
+	case []interface{}:
+		rv := &shape{size: len(val)}
+		for _, v := range val {
+			rv.elements = mergeShapes(rv.elements, shapeOf(v))
+		}
+		return rv
+	}
+	return nil
+}
+
+// mergeShapes returns a shape at least as large as both a and b, either of which may be nil.
+func mergeShapes(a, b *shape) *shape {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	rv := &shape{size: a.size, elements: mergeShapes(a.elements, b.elements)}
+	if b.size > rv.size {
+		rv.size = b.size
+	}
+	if a.members != nil || b.members != nil {
+		rv.members = make(map[string]*shape, len(a.members)+len(b.members))
+		for k, v := range a.members {
+			rv.members[k] = v
+		}
+		for k, v := range b.members {
+			rv.members[k] = mergeShapes(rv.members[k], v)
+		}
+	}
+	return rv
+}
+
+// parseShaped parses the (valid) JSON value at data[start], allocating objects and arrays at
+// the sizes of hint, which may be nil.  It returns the value and the offset just past it.
+func parseShaped(data []byte, start int, hint *shape) (interface{}, int, error) {
+	i := skipWhitespace(data, start)
+	if i >= len(data) {
+		return nil, i, errUnexpectedEnd
+	}
+	switch data[i] {
+	case '{':
+		size := 0
+		var members map[string]*shape
+		if hint != nil {
+			size, members = hint.size, hint.members
+		}
+		rv := make(map[string]interface{}, size)
+		i = skipWhitespace(data, i+1)
+		for i < len(data) && data[i] != '}' {
+			end, err := scanString(data, i)
+			if err != nil {
+				return nil, end, err
+			}
+			key, err := parseString(data[i:end])
+			if err != nil {
+				return nil, end, err
+			}
+			i = skipWhitespace(data, end)
+			if i >= len(data) || data[i] != ':' {
+				return nil, i, &scanError{i, "expected ':' after object key"}
+			}
+			rv[key], i, err = parseShaped(data, i+1, members[key])
+			if err != nil {
+				return nil, i, err
+			}
+			i = skipWhitespace(data, i)
+			if i < len(data) && data[i] == ',' {
+				i = skipWhitespace(data, i+1)
+			}
+		}
+		if i >= len(data) {
+			return nil, i, errUnexpectedEnd
+		}
+		return rv, i + 1, nil
+	case '[':
+		size := 0
+		var elements *shape
+		if hint != nil {
+			size, elements = hint.size, hint.elements
+		}
+		rv := make([]interface{}, 0, size)
+		i = skipWhitespace(data, i+1)
+		for i < len(data) && data[i] != ']' {
+			var val interface{}
+			var err error
+			val, i, err = parseShaped(data, i, elements)
+			if err != nil {
+				return nil, i, err
+			}
+			rv = append(rv, val)
+			i = skipWhitespace(data, i)
+			if i < len(data) && data[i] == ',' {
+				i = skipWhitespace(data, i+1)
+			}
+		}
+		if i >= len(data) {
+			return nil, i, errUnexpectedEnd
+		}
+		return rv, i + 1, nil
+	case '"':
+		end, err := scanString(data, i)
+		if err != nil {
+			return nil, end, err
+		}
+		s, err := parseString(data[i:end])
+		return s, end, err
+	}
+	end := scanLiteral(data, i)
+	switch literal := data[i:end]; string(literal) {
+	case "true":
+		return true, end, nil
+	case "false":
+		return false, end, nil
+	case "null":
+		return nil, end, nil
+	default:
+		f, err := strconv.ParseFloat(string(literal), 64)
+		if err != nil {
+			return nil, end, fmt.Errorf("invalid number %s: %v", literal, err)
+		}
+		return f, end, nil
+	}
+}
+
+// parseString returns the string encoded by the quoted JSON string in data.
+func parseString(data []byte) (string, error) {
+	s := data[1 : len(data)-1]
+	if bytes.IndexByte(s, '\\') < 0 && utf8.Valid(s) {
+		return string(s), nil
+	}
+	var rv string
+	err := json.Unmarshal(data, &rv)
+	return rv, err
+}
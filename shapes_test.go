@@ -0,0 +1,116 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestShapePool(t *testing.T) {
+	pool := NewShapePool(0)
+	options := ParseOptions{Shapes: pool}
+
+	docs := []string{
+		`{"name": "a", "tags": ["x", "y"], "address": {"street": "main", "zip": 12345}, "items": [{"n": 1}, {"n": 2, "m": true}]}`,
+		// same keys, so the same shape, with sizes which differ from it
+		`{"name": "b", "tags": [], "address": {"street": "side", "zip": 1, "city": "x", "country": "y"}, "items": [{"n": 1, "m": false, "o": null}]}`,
+		`{"name": "cé\n\"q\"", "tags": "none", "address": null, "items": [[1, 2], -0.5e3]}`,
+		`{ "other" : [ { } , [ ] , "" , 1.5 , null ] , "escaped\tkey" : { "deep" : { "er" : [ true ] } } }`,
+		`{}`,
+	}
+	for _, doc := range docs {
+		expected := NewValueFromBytes([]byte(doc)).Value()
+		val, err := NewValueFromBytesWithOptions([]byte(doc), options)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(val.Value(), expected) {
+			t.Errorf("Expected %v, got %v", expected, val.Value())
+		}
+	}
+	if pool.Len() != 3 {
+		t.Errorf("Expected 3 shapes, got %d", pool.Len())
+	}
+
+	// a nested object parsed on its own is a shape of its own
+	val, _ := NewValueFromBytesWithOptions([]byte(docs[0]), options)
+	address, _ := val.Path("address")
+	if address.Value().(map[string]interface{})["zip"] != 12345.0 || pool.Len() != 4 {
+		t.Errorf("Expected the address to be parsed with its own shape, got %v and %d shapes", address.Value(), pool.Len())
+	}
+
+	// the recorded shape is of the first document
+	hint := pool.shapes[mustFingerprint(t, docs[0])]
+	if hint.size != 4 || hint.members["address"].size != 2 || hint.members["items"].elements.size != 2 {
+		t.Errorf("Expected the shape of the first document, got %+v", hint)
+	}
+
+	limited := NewShapePool(1)
+	for _, doc := range docs[:3] {
+		val, _ := NewValueFromBytesWithOptions([]byte(doc), ParseOptions{Shapes: limited})
+		val.Value()
+	}
+	val, _ = NewValueFromBytesWithOptions([]byte(docs[3]), ParseOptions{Shapes: limited})
+	if val.Value() == nil || limited.Len() != 1 {
+		t.Errorf("Expected a full pool not to grow, got %d shapes", limited.Len())
+	}
+}
+
+func mustFingerprint(t *testing.T, doc string) uint64 {
+	fingerprint, err := shapeFingerprint([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	return fingerprint
+}
+
+func TestShapePoolConcurrent(t *testing.T) {
+	options := ParseOptions{Shapes: NewShapePool(0)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				doc := fmt.Sprintf(`{"id": %d, "values": [%d, %d]}`, i, j, i+j)
+				val, _ := NewValueFromBytesWithOptions([]byte(doc), options)
+				if val.Value().(map[string]interface{})["id"] != float64(i) {
+					t.Errorf("Expected id %d, got %v", i, val.Value())
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkShapePool(b *testing.B) {
+	fields := make([]string, 30)
+	for i := range fields {
+		fields[i] = fmt.Sprintf(`"field%d": {"a": %d, "b": "value %d", "c": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]}`, i, i, i)
+	}
+	raw := []byte("{" + strings.Join(fields, ",") + "}")
+
+	b.Run("Unmarshal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewValueFromBytes(raw).Value()
+		}
+	})
+	b.Run("ShapePool", func(b *testing.B) {
+		options := ParseOptions{Shapes: NewShapePool(0)}
+		for i := 0; i < b.N; i++ {
+			val, _ := NewValueFromBytesWithOptions(raw, options)
+			val.Value()
+		}
+	})
+}
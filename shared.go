@@ -0,0 +1,96 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// SharedValues is a registry of objects and arrays, identified by a hash of their
+// contents, so that identical subdocuments (for example the same "address" block
+// repeated across many documents) are held in memory once.  It is safe for concurrent use.
+//
+// Values held by the registry are shared, and must not be modified: SetPath(), SetIndex(),
+// SetIndexExtend(), RemovePath(), RemoveIndex(), Append(), Insert(), SetAttachment() and AnnotatePath()
+// panic if called on them.  To change a shared subdocument, replace it in its parent with a new Value.
+type SharedValues struct {
+	mutex  sync.Mutex
+	values map[[sha256.Size]byte]*Value
+}
+
+// Create a new, empty SharedValues registry.
+func NewSharedValues() *SharedValues {
+	return &SharedValues{
+		values: make(map[[sha256.Size]byte]*Value),
+	}
+}
+
+// Return a copy of doc in which every nested OBJECT and ARRAY is the shared Value
+// in the registry with the same contents, adding those not already in the registry.
+// The document itself is not shared, so it may still be modified.
+func (this *SharedValues) Share(doc *Value) *Value {
+	switch doc.Type() {
+	case OBJECT:
+		members := doc.members()
+		rv := NewObjectValueCap(len(members))
+		for k, v := range members {
+			if err := rv.SetPathErr(k, this.share(v)); err != nil {
+				panic("unexpected error setting a property of a new object")
+			}
+		}
+		return rv
+	case ARRAY:
+		elements, err := doc.elements()
+		if err != nil {
+			panic("unexpected scan error on valid JSON")
+		}
+		rv := make([]interface{}, len(elements))
+		for i, v := range elements {
+			rv[i] = this.share(v)
+		}
+		return NewValue(rv)
+	}
+	return doc
+}
+
+// The number of shared Values in the registry.
+func (this *SharedValues) Len() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return len(this.values)
+}
+
+// share returns the shared Value with the same contents as val, sharing its
+// nested objects and arrays first.
+func (this *SharedValues) share(val *Value) *Value {
+	if val.Type() != OBJECT && val.Type() != ARRAY {
+		return val
+	}
+	rv := this.Share(val)
+	hash := sha256.Sum256(rv.Bytes())
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if existing, ok := this.values[hash]; ok {
+		return existing
+	}
+	rv.shared = true
+	this.values[hash] = rv
+	return rv
+}
+
+// checkMutable panics if this Value is held by a SharedValues registry, or is one of
+// the NULL, TRUE and FALSE singletons.
+func (this *Value) checkMutable() {
+	if this.shared {
+		panic("cannot modify a shared Value")
+	}
+}
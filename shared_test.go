@@ -0,0 +1,100 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSharedValues(t *testing.T) {
+	registry := NewSharedValues()
+	first := NewValueFromBytes([]byte(`{"name":"a","address":{"city":"x","geo":[1,2]}}`))
+	second := NewValue(map[string]interface{}{
+		"name":    "b",
+		"address": map[string]interface{}{"geo": []interface{}{1.0, 2.0}, "city": "x"},
+		"other":   []interface{}{1.0, 2.0},
+	})
+
+	a := registry.Share(first)
+	b := registry.Share(second)
+	if !reflect.DeepEqual(a.Value(), first.Value()) || !reflect.DeepEqual(b.Value(), second.Value()) {
+		t.Errorf("Expected shared documents to have the same contents")
+	}
+	if registry.Len() != 2 {
+		t.Errorf("Expected 2 shared values, got %d", registry.Len())
+	}
+
+	aAddress, _ := a.Path("address")
+	bAddress, _ := b.Path("address")
+	if aAddress != bAddress {
+		t.Errorf("Expected address to be shared")
+	}
+	geo, _ := bAddress.Path("geo")
+	other, _ := b.Path("other")
+	if geo != other {
+		t.Errorf("Expected identical arrays to be shared")
+	}
+
+	// the documents themselves may still be modified
+	a.SetPath("name", "c")
+	a.SetPath("address", "elsewhere")
+	if string(a.Bytes()) != `{"address":"elsewhere","name":"c"}` {
+		t.Errorf("Unexpected bytes %s", a.Bytes())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected modifying a shared Value to panic")
+		}
+	}()
+	bAddress.SetPath("city", "y")
+}
+
+func TestSingletons(t *testing.T) {
+	doc := NewValue(map[string]interface{}{
+		"a": true,
+		"b": []interface{}{false, nil, true},
+	})
+	a, _ := doc.Path("a")
+	b, _ := doc.Path("b")
+	b1, _ := b.Index(1)
+	if a == trueSingleton || b1 == nullSingleton {
+		t.Errorf("Expected NULL and BOOLEAN values inside documents not to be singletons")
+	}
+	// values inside documents may have attachments
+	a.SetAttachment("k", "v")
+	b1.SetScore(1)
+	b1.AnnotatePath("x", "k", "v")
+	if trueSingleton.GetAttachment("k") != nil || nullSingleton.Score() != 0 {
+		t.Errorf("Expected singletons to be unaffected")
+	}
+
+	expr, err := ParseExpression("a = TRUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _ := expr.Eval(doc)
+	if result == trueSingleton || !isTrue(result) {
+		t.Errorf("Expected expression result to be a new TRUE Value, got %v", result)
+	}
+	result.SetAttachment("k", "v")
+	if trueSingleton.GetAttachment("k") != nil {
+		t.Errorf("Expected singleton to be unaffected")
+	}
+
+	// the singletons themselves cannot be modified
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected SetAttachment on a singleton to panic")
+		}
+	}()
+	trueSingleton.SetAttachment("k", "v")
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"time"
+)
+
+// A Sink is the destination of the Values at the end of a pipeline, such as a datastore.
+// Write may hold on to Values until Flush is called.
+type Sink interface {
+	Write(val *Value) error
+	Flush() error
+}
+
+// Write every Value received on the channel to sink, then flush it.  If a write fails, the
+// channel is not drained further and the error is returned.
+func WriteAll(ch ValueChannel, sink Sink) error {
+	for val := range ch {
+		err := sink.Write(val)
+		if err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
+
+// A StoreWrite is a single document to be stored, as prepared by a BatchingSink.
+type StoreWrite struct {
+	Key     string // from the Meta of the Value, if any
+	Cas     uint64 // from the Meta of the Value, if any
+	Deleted bool   // the Value is a tombstone, the document should be deleted
+	Body    []byte // the Bytes() of the Value, nil for deletions
+}
+
+// A BatchWriter stores a batch of documents, for example in a single request to a datastore.
+type BatchWriter interface {
+	WriteBatch(writes []*StoreWrite) error
+}
+
+// BatchingOptions control how a BatchingSink writes to its BatchWriter.
+type BatchingOptions struct {
+	BatchSize int           // the number of Values written together, 0 means 100
+	Retries   int           // the number of times a failed batch is retried
+	Backoff   time.Duration // the wait before the first retry, doubled before each further retry
+}
+
+// A BatchingSink is a Sink which collects Values into batches for a BatchWriter,
+// retrying batches which fail.
+type BatchingSink struct {
+	writer  BatchWriter
+	options BatchingOptions
+	pending []*StoreWrite
+}
+
+// Create a new BatchingSink writing to writer.
+func NewBatchingSink(writer BatchWriter, options BatchingOptions) *BatchingSink {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+	return &BatchingSink{
+		writer:  writer,
+		options: options,
+	}
+}
+
+// Add a Value to the current batch, writing the batch if it is full.
+// The Value is serialized immediately, so it may be modified once Write returns.
+func (this *BatchingSink) Write(val *Value) error {
+	write := StoreWrite{}
+	if meta := val.Meta(); meta != nil {
+		write.Key = meta.Key
+		write.Cas = meta.Cas
+		write.Deleted = meta.Deleted
+	}
+	if !write.Deleted {
+		write.Body = val.Bytes()
+	}
+	this.pending = append(this.pending, &write)
+	if len(this.pending) >= this.options.BatchSize {
+		return this.Flush()
+	}
+	return nil
+}
+
+// Write the current batch, even if it is not full.  If the batch still fails after
+// all retries, the error is returned and the batch is kept, to be retried by the next Flush.
+func (this *BatchingSink) Flush() error {
+	if len(this.pending) == 0 {
+		return nil
+	}
+	backoff := this.options.Backoff
+	err := this.writer.WriteBatch(this.pending)
+	for retry := 0; err != nil && retry < this.options.Retries; retry++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = this.writer.WriteBatch(this.pending)
+	}
+	if err != nil {
+		return err
+	}
+	this.pending = nil
+	return nil
+}
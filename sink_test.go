@@ -0,0 +1,73 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testBatchWriter struct {
+	failures int
+	calls    int
+	batches  [][]StoreWrite
+}
+
+func (this *testBatchWriter) WriteBatch(writes []*StoreWrite) error {
+	this.calls++
+	if this.failures > 0 {
+		this.failures--
+		return fmt.Errorf("temporary failure")
+	}
+	batch := make([]StoreWrite, len(writes))
+	for i, w := range writes {
+		batch[i] = *w
+	}
+	this.batches = append(this.batches, batch)
+	return nil
+}
+
+func TestBatchingSink(t *testing.T) {
+	writer := &testBatchWriter{failures: 1}
+	sink := NewBatchingSink(writer, BatchingOptions{BatchSize: 2, Retries: 1})
+
+	ch := make(ValueChannel)
+	go func() {
+		defer close(ch)
+		ch <- NewValueFromMutation(&Mutation{Key: "a", Body: []byte(`{"n":1}`), Cas: 7})
+		ch <- NewValue(map[string]interface{}{"n": 2.0})
+		ch <- NewValueFromMutation(&Mutation{Key: "b", Cas: 8, Deleted: true})
+	}()
+	err := WriteAll(ch, sink)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := [][]StoreWrite{
+		{{Key: "a", Cas: 7, Body: []byte(`{"n":1}`)}, {Body: []byte(`{"n":2}`)}},
+		{{Key: "b", Cas: 8, Deleted: true}},
+	}
+	if !reflect.DeepEqual(writer.batches, expected) {
+		t.Errorf("Expected %v, got %v", expected, writer.batches)
+	}
+	if writer.calls != 3 {
+		t.Errorf("Expected 3 calls including a retry, got %d", writer.calls)
+	}
+
+	writer = &testBatchWriter{failures: 2}
+	sink = NewBatchingSink(writer, BatchingOptions{Retries: 1})
+	sink.Write(NewValue(1.0))
+	if sink.Flush() == nil {
+		t.Errorf("Expected error once retries are exhausted")
+	}
+	if sink.Flush() != nil || len(writer.batches) != 1 {
+		t.Errorf("Expected the failed batch to be written by the next Flush")
+	}
+}
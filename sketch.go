@@ -0,0 +1,239 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// A HistogramBin holds Count numbers merged into one, with mean Value.
+type HistogramBin struct {
+	Value float64
+	Count int
+}
+
+// A Histogram summarizes a stream of numbers in a bounded number of bins, so the distribution
+// of any number of values can be gathered in fixed memory.  When a number would need a new
+// bin beyond the maximum, the two closest bins are merged (the streaming histogram of
+// Ben-Haim and Tom-Tov), so bins are most precise where the numbers are most spread out.
+type Histogram struct {
+	maxBins  int
+	bins     []HistogramBin
+	count    int
+	min, max float64
+}
+
+// Create a new Histogram of at most maxBins bins, 0 means 64.
+func NewHistogram(maxBins int) *Histogram {
+	if maxBins <= 0 {
+		maxBins = 64
+	}
+	return &Histogram{maxBins: maxBins}
+}
+
+// Add a number to the Histogram.  NaN is ignored.
+func (this *Histogram) Add(f float64) {
+	if math.IsNaN(f) {
+		return
+	}
+	if this.count == 0 || f < this.min {
+		this.min = f
+	}
+	if this.count == 0 || f > this.max {
+		this.max = f
+	}
+	this.count++
+
+	i := sort.Search(len(this.bins), func(i int) bool { return this.bins[i].Value >= f })
+	if i < len(this.bins) && this.bins[i].Value == f {
+		this.bins[i].Count++
+		return
+	}
+	this.bins = append(this.bins, HistogramBin{})
+	copy(this.bins[i+1:], this.bins[i:])
+	this.bins[i] = HistogramBin{f, 1}
+	if len(this.bins) <= this.maxBins {
+		return
+	}
+
+	// merge the closest pair of bins
+	closest := 0
+	for i := 1; i < len(this.bins)-1; i++ {
+		if this.bins[i+1].Value-this.bins[i].Value < this.bins[closest+1].Value-this.bins[closest].Value {
+			closest = i
+		}
+	}
+	a, b := this.bins[closest], this.bins[closest+1]
+	count := a.Count + b.Count
+	this.bins[closest] = HistogramBin{(a.Value*float64(a.Count) + b.Value*float64(b.Count)) / float64(count), count}
+	this.bins = append(this.bins[:closest+1], this.bins[closest+2:]...)
+}
+
+// The number of numbers added.
+func (this *Histogram) Count() int {
+	return this.count
+}
+
+// The smallest number added, or 0 if there are none.
+func (this *Histogram) Min() float64 {
+	return this.min
+}
+
+// The largest number added, or 0 if there are none.
+func (this *Histogram) Max() float64 {
+	return this.max
+}
+
+// Return the bins, ordered by Value.
+func (this *Histogram) Bins() []HistogramBin {
+	return append([]HistogramBin(nil), this.bins...)
+}
+
+// Estimate the q-quantile (0 <= q <= 1) of the numbers added, for example Quantile(0.5) for
+// the median, by interpolating between the bins.  If there are no numbers, the result is NaN.
+func (this *Histogram) Quantile(q float64) float64 {
+	if this.count == 0 {
+		return math.NaN()
+	}
+	target := q * float64(this.count)
+	// the numbers of a bin are taken to be spread evenly either side of its Value,
+	// with the minimum and maximum at the ends
+	prevValue, prevRank := this.min, 0.0
+	rank := 0.0
+	for _, bin := range this.bins {
+		center := rank + float64(bin.Count)/2
+		if target <= center {
+			return interpolate(prevValue, prevRank, bin.Value, center, target)
+		}
+		rank += float64(bin.Count)
+		prevValue, prevRank = bin.Value, center
+	}
+	return interpolate(prevValue, prevRank, this.max, rank, target)
+}
+
+func interpolate(x0, y0, x1, y1, y float64) float64 {
+	if y1 <= y0 {
+		return x1
+	}
+	return x0 + (x1-x0)*(y-y0)/(y1-y0)
+}
+
+// A HyperLogLog estimates the number of distinct values in a stream in fixed memory.
+// Values are distinct if they are not equal in their canonical form (see JWSPayload()),
+// so 1.0 and 1 are the same, as are objects with the same members in a different order.
+type HyperLogLog struct {
+	precision uint
+	registers []uint8
+}
+
+// Create a new HyperLogLog with 2^precision registers, where precision is between 4 and 16,
+// 0 means 14.  The standard error of the estimate is about 1.04/sqrt(2^precision), 0.8% by
+// default.  Other precisions are a programming error, and this panics.
+func NewHyperLogLog(precision int) *HyperLogLog {
+	if precision == 0 {
+		precision = 14
+	}
+	if precision < 4 || precision > 16 {
+		panic("HyperLogLog precision must be between 4 and 16")
+	}
+	return &HyperLogLog{
+		precision: uint(precision),
+		registers: make([]uint8, 1<<uint(precision)),
+	}
+}
+
+// Add a Value to the HyperLogLog.  Values which are NOT_JSON are ignored.
+func (this *HyperLogLog) Add(val *Value) {
+	payload, err := val.JWSPayload()
+	if err != nil {
+		return
+	}
+	h := fnv.New64a()
+	h.Write(payload)
+	hash := mix64(h.Sum64())
+
+	register := hash >> (64 - this.precision)
+	rank := uint8(bits.LeadingZeros64(hash<<this.precision|1<<(this.precision-1))) + 1
+	if rank > this.registers[register] {
+		this.registers[register] = rank
+	}
+}
+
+// mix64 spreads the bits of an FNV hash, which are not uniform enough in the high bits.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Estimate the number of distinct Values added.
+func (this *HyperLogLog) Count() uint64 {
+	m := float64(len(this.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range this.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Merge the Values added to other into this HyperLogLog, as if they had been added to it.
+// Both must have the same precision, otherwise this panics.
+func (this *HyperLogLog) Merge(other *HyperLogLog) {
+	if this.precision != other.precision {
+		panic("cannot merge HyperLogLogs of different precision")
+	}
+	for i, r := range other.registers {
+		if r > this.registers[i] {
+			this.registers[i] = r
+		}
+	}
+}
+
+// Build a Histogram of at most maxBins bins (see NewHistogram()) from the numbers at the dotted
+// path (as for FillTemplate()) of every Value received on ch, until ch is closed.  Values where
+// the path is not defined, or is not a number, are skipped.
+func BuildHistogram(ch ValueChannel, path string, maxBins int) *Histogram {
+	rv := NewHistogram(maxBins)
+	for val := range ch {
+		f, err := resolvePath(val, path)
+		if err == nil && f.Type() == NUMBER {
+			rv.Add(nativeNumber(f.Value()))
+		}
+	}
+	return rv
+}
+
+// Build a HyperLogLog of the given precision (see NewHyperLogLog()) from the values at the dotted
+// path (as for FillTemplate()) of every Value received on ch, until ch is closed.  Values where
+// the path is not defined are skipped.
+func BuildHyperLogLog(ch ValueChannel, path string, precision int) *HyperLogLog {
+	rv := NewHyperLogLog(precision)
+	for val := range ch {
+		v, err := resolvePath(val, path)
+		if err == nil {
+			rv.Add(v)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(10)
+	for i := 1; i <= 1000; i++ {
+		h.Add(float64(i))
+	}
+	h.Add(math.NaN())
+	if h.Count() != 1000 || h.Min() != 1 || h.Max() != 1000 {
+		t.Errorf("Expected 1000 numbers from 1 to 1000, got %d from %v to %v", h.Count(), h.Min(), h.Max())
+	}
+	bins := h.Bins()
+	if len(bins) != 10 {
+		t.Errorf("Expected 10 bins, got %d", len(bins))
+	}
+	total := 0
+	for i, bin := range bins {
+		total += bin.Count
+		if i > 0 && bin.Value <= bins[i-1].Value {
+			t.Errorf("Expected bins in order, got %v", bins)
+		}
+	}
+	if total != 1000 {
+		t.Errorf("Expected bins to hold 1000 numbers, got %d", total)
+	}
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		if actual := h.Quantile(q); math.Abs(actual-1000*q) > 50 {
+			t.Errorf("Expected quantile %v near %v, got %v", q, 1000*q, actual)
+		}
+	}
+	if h.Quantile(0) != 1 || h.Quantile(1) != 1000 {
+		t.Errorf("Expected quantiles 0 and 1 at the extremes, got %v and %v", h.Quantile(0), h.Quantile(1))
+	}
+	if !math.IsNaN(NewHistogram(0).Quantile(0.5)) {
+		t.Errorf("Expected NaN for an empty histogram")
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	hll := NewHyperLogLog(0)
+	for i := 0; i < 10000; i++ {
+		hll.Add(NewValue(float64(i % 5000)))
+	}
+	if count := hll.Count(); count < 4800 || count > 5200 {
+		t.Errorf("Expected about 5000 distinct values, got %d", count)
+	}
+
+	// equal values in different forms are not distinct
+	small := NewHyperLogLog(4)
+	small.Add(NewValueFromBytes([]byte(`{"a":1,"b":2}`)))
+	small.Add(NewValueFromBytes([]byte(`{"b":2.0, "a":1}`)))
+	small.Add(NewValueFromBytes([]byte(`not json`)))
+	if small.Count() != 1 {
+		t.Errorf("Expected 1 distinct value, got %d", small.Count())
+	}
+
+	other := NewHyperLogLog(0)
+	for i := 5000; i < 10000; i++ {
+		other.Add(NewValue(float64(i)))
+	}
+	hll.Merge(other)
+	if count := hll.Count(); count < 9600 || count > 10400 {
+		t.Errorf("Expected about 10000 distinct values after merging, got %d", count)
+	}
+
+	if r := expectPanic(func() { hll.Merge(small) }); r == nil {
+		t.Errorf("Expected merging different precisions to panic")
+	}
+	if r := expectPanic(func() { NewHyperLogLog(17) }); r == nil {
+		t.Errorf("Expected precision 17 to panic")
+	}
+}
+
+func TestBuildSketches(t *testing.T) {
+	docs := func() ValueChannel {
+		ch := make(ValueChannel)
+		go func() {
+			for i := 0; i < 100; i++ {
+				ch <- NewValueFromBytes([]byte(fmt.Sprintf(`{"order": {"total": %d, "customer": "c%d"}}`, i, i%10)))
+			}
+			ch <- NewValueFromBytes([]byte(`{"order": {"total": "n/a"}}`))
+			ch <- NewValueFromBytes([]byte(`{}`))
+			close(ch)
+		}()
+		return ch
+	}
+
+	h := BuildHistogram(docs(), "order.total", 0)
+	if h.Count() != 100 || h.Min() != 0 || h.Max() != 99 {
+		t.Errorf("Expected 100 totals from 0 to 99, got %d from %v to %v", h.Count(), h.Min(), h.Max())
+	}
+	hll := BuildHyperLogLog(docs(), "order.customer", 0)
+	if hll.Count() != 10 {
+		t.Errorf("Expected 10 customers, got %d", hll.Count())
+	}
+}
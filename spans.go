@@ -0,0 +1,60 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"sync/atomic"
+)
+
+// The operations reported to SpanHooks
+const (
+	PARSE_OPERATION     = iota // a full parse of raw bytes, triggered by Value(), Bytes() or ParseContext()
+	SERIALIZE_OPERATION        // a call to Bytes()
+)
+
+// SpanHooks let an application report parsing and serializing to its tracing system.
+// Start is called when an operation begins, and whatever it returns (typically a span)
+// is passed to End when the operation finishes, along with the number of bytes parsed
+// or produced, and any error.  Either may be nil.
+//
+// The hooks are called on the goroutine doing the work, and must not call back into
+// the Value being parsed or serialized.
+type SpanHooks struct {
+	Start func(operation int) interface{}
+	End   func(span interface{}, operation int, bytes int, err error)
+}
+
+var spanHooks atomic.Value
+
+func init() {
+	spanHooks.Store((*SpanHooks)(nil))
+}
+
+// Install the hooks called for every parse and serialize operation, nil removes them.
+func SetSpanHooks(hooks *SpanHooks) {
+	spanHooks.Store(hooks)
+}
+
+func currentSpanHooks() *SpanHooks {
+	return spanHooks.Load().(*SpanHooks)
+}
+
+func (this *SpanHooks) start(operation int) interface{} {
+	if this.Start == nil {
+		return nil
+	}
+	return this.Start(operation)
+}
+
+func (this *SpanHooks) end(span interface{}, operation int, bytes int, err error) {
+	if this.End != nil {
+		this.End(span, operation, bytes, err)
+	}
+}
@@ -0,0 +1,52 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpanHooks(t *testing.T) {
+	type span struct {
+		operation, bytes int
+	}
+	var started int
+	var ended []span
+	SetSpanHooks(&SpanHooks{
+		Start: func(operation int) interface{} {
+			started++
+			return started
+		},
+		End: func(s interface{}, operation int, bytes int, err error) {
+			if s != started {
+				t.Errorf("Expected span %d, got %v", started, s)
+			}
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			ended = append(ended, span{operation, bytes})
+		},
+	})
+	defer SetSpanHooks(nil)
+
+	val := NewValueFromBytes([]byte(`{"a": [1, 2]}`))
+	val.Value()
+	val.SetPath("b", true)
+	val.Bytes()
+
+	expected := []span{
+		{PARSE_OPERATION, 13},
+		{SERIALIZE_OPERATION, len(`{"a":[1,2],"b":true}`)},
+	}
+	if !reflect.DeepEqual(ended, expected) {
+		t.Errorf("Expected %v, got %v", expected, ended)
+	}
+}
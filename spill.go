@@ -0,0 +1,314 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// The spill format persists Values, for example to spill a large collection to disk, or to
+// checkpoint the state of a pipeline.  It begins with the 4 byte magic "DPVS" and a version
+// byte, followed by a frame for each Value:
+//
+//         length      the length of the body
+//         crc         the CRC-32 of the body, 4 bytes little endian
+//         body        flags, the raw bytes, the overlay, then the Meta if the meta flag is set
+//
+// The raw bytes are those the Value was created from, or Bytes() if it has none.  The overlay
+// is a count followed by a key and the Bytes() of each property or element changed since
+// then (see SetPath()), so a modified document is written without being parsed or encoded
+// again.  The Meta is its Key, Seqno, Cas, Expiry and a byte for Deleted.  Lengths, counts
+// and numbers are unsigned varints, and strings and bytes are preceded by their length.
+const (
+	spillMagic   = "DPVS"
+	spillVersion = 1
+	spillMeta    = 1 // the flag set when the frame includes the Meta
+)
+
+// A SpillWriter writes Values in the spill format, to be read back with NewRowsFromSpill().
+// Only the contents of each Value and its Meta (see FeedChannel()) are written, not its
+// options or other attachments.  Writes are buffered until Flush() is called.  A SpillWriter
+// is a Sink, so WriteAll() can spill the Values received on a channel.
+type SpillWriter struct {
+	w      *bufio.Writer
+	header bool
+	body   []byte
+}
+
+// Create a new SpillWriter writing to w.
+func NewSpillWriter(w io.Writer) *SpillWriter {
+	return &SpillWriter{w: bufio.NewWriter(w)}
+}
+
+// Write a Value.
+func (this *SpillWriter) Write(val *Value) error {
+	err := this.writeHeader()
+	if err != nil {
+		return err
+	}
+	this.body = appendSpillBody(this.body[:0], val)
+	frame := binary.AppendUvarint(nil, uint64(len(this.body)))
+	frame = binary.LittleEndian.AppendUint32(frame, crc32.ChecksumIEEE(this.body))
+	_, err = this.w.Write(frame)
+	if err != nil {
+		return err
+	}
+	_, err = this.w.Write(this.body)
+	return err
+}
+
+// Write any buffered Values to the underlying writer.
+func (this *SpillWriter) Flush() error {
+	err := this.writeHeader()
+	if err != nil {
+		return err
+	}
+	return this.w.Flush()
+}
+
+func (this *SpillWriter) writeHeader() error {
+	if this.header {
+		return nil
+	}
+	this.header = true
+	_, err := this.w.WriteString(spillMagic)
+	if err != nil {
+		return err
+	}
+	return this.w.WriteByte(spillVersion)
+}
+
+// Write every Value in coll to w in the spill format.
+func SpillCollection(w io.Writer, coll ValueCollection) error {
+	writer := NewSpillWriter(w)
+	for _, val := range coll {
+		err := writer.Write(val)
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func appendSpillBody(body []byte, val *Value) []byte {
+	meta := val.Meta()
+	flags := byte(0)
+	if meta != nil {
+		flags |= spillMeta
+	}
+	body = append(body, flags)
+
+	raw, overlay := spillParts(val)
+	body = appendSpillBytes(body, raw)
+	body = binary.AppendUvarint(body, uint64(len(overlay)))
+	for _, k := range sortedMemberKeys(overlay) {
+		body = appendSpillBytes(body, []byte(k))
+		body = appendSpillBytes(body, overlay[k].Bytes())
+	}
+
+	if meta != nil {
+		body = appendSpillBytes(body, []byte(meta.Key))
+		body = binary.AppendUvarint(body, meta.Seqno)
+		body = binary.AppendUvarint(body, meta.Cas)
+		body = binary.AppendUvarint(body, uint64(meta.Expiry))
+		if meta.Deleted {
+			body = append(body, 1)
+		} else {
+			body = append(body, 0)
+		}
+	}
+	return body
+}
+
+func appendSpillBytes(body []byte, data []byte) []byte {
+	body = binary.AppendUvarint(body, uint64(len(data)))
+	return append(body, data...)
+}
+
+// spillParts returns the raw bytes of val, and the changes to overlay on them.  Only objects and
+// arrays whose raw bytes have not been replaced by parsed Values have an overlay.
+func spillParts(val *Value) ([]byte, map[string]*Value) {
+	if val.raw == nil {
+		return val.Bytes(), nil
+	}
+	switch val.parsedType {
+	case OBJECT, ARRAY:
+		switch val.parsedValue.(type) {
+		case map[string]*Value, []*Value:
+			return val.Bytes(), nil
+		}
+		return val.raw, val.overlay()
+	case NOT_JSON:
+		return val.raw, nil
+	}
+	return val.Bytes(), nil
+}
+
+// Create Rows which return each Value read from r, which is in the spill format (see
+// SpillWriter).  Values are read one at a time as Next() is called.  If r is also an
+// io.Closer, it is closed by Close().
+//
+// If r is not in the spill format, or a frame is malformed (including one which is
+// truncated or fails its CRC), the iteration stops with an error.
+func NewRowsFromSpill(r io.Reader) *Rows {
+	reader := bufio.NewReader(r)
+	header := false
+	rv := Rows{
+		next: func() (*Value, error) {
+			if !header {
+				magic := make([]byte, len(spillMagic)+1)
+				_, err := io.ReadFull(reader, magic)
+				if err != nil {
+					if err == io.ErrUnexpectedEOF {
+						return nil, fmt.Errorf("not in the spill format")
+					}
+					return nil, err
+				}
+				if string(magic[:len(spillMagic)]) != spillMagic {
+					return nil, fmt.Errorf("not in the spill format")
+				}
+				if magic[len(spillMagic)] != spillVersion {
+					return nil, fmt.Errorf("unsupported spill version %d", magic[len(spillMagic)])
+				}
+				header = true
+			}
+			return readSpillFrame(reader)
+		},
+	}
+	if closer, ok := r.(io.Closer); ok {
+		rv.close = closer.Close
+	}
+	return &rv
+}
+
+func readSpillFrame(r *bufio.Reader) (*Value, error) {
+	length, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed spill frame: %v", err)
+	}
+	if length > MaxRowSize {
+		return nil, fmt.Errorf("malformed spill frame: length %d exceeds MaxRowSize", length)
+	}
+	frame := make([]byte, 4+length)
+	_, err = io.ReadFull(r, frame)
+	if err != nil {
+		return nil, fmt.Errorf("malformed spill frame: %v", io.ErrUnexpectedEOF)
+	}
+	body := frame[4:]
+	if binary.LittleEndian.Uint32(frame) != crc32.ChecksumIEEE(body) {
+		return nil, fmt.Errorf("malformed spill frame: CRC mismatch")
+	}
+
+	decoder := spillDecoder{bytes.NewReader(body), body}
+	flags, err := decoder.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("malformed spill frame: %v", err)
+	}
+	raw, err := decoder.bytes()
+	if err != nil {
+		return nil, err
+	}
+	rv := NewValueFromBytes(raw)
+	n, err := decoder.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		k, err := decoder.bytes()
+		if err != nil {
+			return nil, err
+		}
+		v, err := decoder.bytes()
+		if err != nil {
+			return nil, err
+		}
+		if rv.Type() == ARRAY {
+			index, err := strconv.Atoi(string(k))
+			if err != nil {
+				return nil, fmt.Errorf("malformed spill frame: invalid index %q", k)
+			}
+			err = rv.SetIndexErr(index, NewValueFromBytes(v))
+		} else {
+			err = rv.SetPathErr(string(k), NewValueFromBytes(v))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed spill frame: %v", err)
+		}
+	}
+
+	if flags&spillMeta != 0 {
+		meta := Meta{}
+		key, err := decoder.bytes()
+		if err != nil {
+			return nil, err
+		}
+		meta.Key = string(key)
+		meta.Seqno, err = decoder.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		meta.Cas, err = decoder.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		expiry, err := decoder.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		meta.Expiry = uint32(expiry)
+		deleted, err := decoder.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("malformed spill frame: %v", err)
+		}
+		meta.Deleted = deleted != 0
+		rv.SetAttachment(META_ATTACHMENT, &meta)
+	}
+	if decoder.Len() != 0 {
+		return nil, fmt.Errorf("malformed spill frame: unexpected data at the end")
+	}
+	return rv, nil
+}
+
+// spillDecoder reads the fields of the body of a frame.
+type spillDecoder struct {
+	*bytes.Reader
+	body []byte
+}
+
+func (this spillDecoder) uvarint() (uint64, error) {
+	rv, err := binary.ReadUvarint(this.Reader)
+	if err != nil {
+		return 0, fmt.Errorf("malformed spill frame: %v", err)
+	}
+	return rv, nil
+}
+
+// bytes returns the next length-prefixed bytes, sharing the body of the frame.
+func (this spillDecoder) bytes() ([]byte, error) {
+	n, err := this.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(this.Len()) {
+		return nil, fmt.Errorf("malformed spill frame: field past the end of the frame")
+	}
+	start := len(this.body) - this.Len()
+	this.Seek(int64(n), io.SeekCurrent)
+	return this.body[start : start+int(n)], nil
+}
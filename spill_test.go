@@ -0,0 +1,112 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestSpill(t *testing.T) {
+	modified := NewValueFromBytes([]byte(`{"name": "marty", "address": {"city": "x", "zip": 1}, "tags": ["a", "b"]}`))
+	modified.SetPath("name", "bob")
+	address, _ := modified.Path("address")
+	address.SetPath("city", "y")
+	array := NewValueFromBytes([]byte(`[1, 2, {"a": 3}]`))
+	array.SetIndex(1, "two")
+	parsed := NewValueFromBytes([]byte(`{"a": [1, 2]}`))
+	parsed.SetPath("b", map[string]interface{}{"c": true})
+	parsed.Value()
+	fromFeed := NewValueFromMutation(&Mutation{Key: "doc::1", Body: []byte(`{"n": 1}`), Seqno: 7, Cas: 99, Expiry: 1700000000})
+	tombstone := NewValueFromMutation(&Mutation{Key: "doc::2", Seqno: 8, Deleted: true})
+
+	coll := ValueCollection{
+		modified,
+		array,
+		parsed,
+		NewValue(map[string]interface{}{"native": []interface{}{1.0, "x"}}),
+		NewValue("string"),
+		NewValueFromBytes([]byte(`{"not json"`)),
+		fromFeed,
+		tombstone,
+	}
+
+	var buf bytes.Buffer
+	err := SpillCollection(&buf, coll)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	rows := NewRowsFromSpill(bytes.NewReader(buf.Bytes()))
+	var read ValueCollection
+	for rows.Next() {
+		read = append(read, rows.Value())
+	}
+	if rows.Err() != nil {
+		t.Fatalf("Unexpected error %v", rows.Err())
+	}
+	if len(read) != len(coll) {
+		t.Fatalf("Expected %d values, got %d", len(coll), len(read))
+	}
+	for i, val := range read {
+		if val.Type() != coll[i].Type() || !bytes.Equal(val.Bytes(), coll[i].Bytes()) {
+			t.Errorf("Expected %s for value %d, got %s", coll[i].Bytes(), i, val.Bytes())
+		}
+		if !reflect.DeepEqual(val.Meta(), coll[i].Meta()) {
+			t.Errorf("Expected meta %+v for value %d, got %+v", coll[i].Meta(), i, val.Meta())
+		}
+	}
+
+	// the raw bytes of a modified document are kept, with the changes overlaid
+	if !bytes.Contains(buf.Bytes(), []byte(`{"name": "marty", "address": {"city": "x", "zip": 1}, "tags": ["a", "b"]}`)) {
+		t.Errorf("Expected the raw bytes of the modified document to be written")
+	}
+	if string(read[0].raw) != `{"name": "marty", "address": {"city": "x", "zip": 1}, "tags": ["a", "b"]}` {
+		t.Errorf("Expected the read document to keep its raw bytes, got %s", read[0].raw)
+	}
+
+	// an empty spill has no values
+	rows = NewRowsFromSpill(bytes.NewReader(nil))
+	if rows.Next() || rows.Err() != nil {
+		t.Errorf("Expected no values and no error from empty input, got %v", rows.Err())
+	}
+	var empty bytes.Buffer
+	NewSpillWriter(&empty).Flush()
+	rows = NewRowsFromSpill(&empty)
+	if rows.Next() || rows.Err() != nil {
+		t.Errorf("Expected no values and no error from an empty spill, got %v", rows.Err())
+	}
+}
+
+func TestSpillMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	SpillCollection(&buf, ValueCollection{NewValue(1.0), NewValueFromBytes([]byte(`{"a": "b"}`))})
+	valid := buf.Bytes()
+
+	corrupt := append([]byte(nil), valid...)
+	corrupt[len(corrupt)-2] ^= 0xFF
+
+	tests := [][]byte{
+		[]byte(`{"a": 1}`),
+		append([]byte("DPVS"), 9),
+		valid[:len(valid)-3],
+		corrupt,
+	}
+	for _, data := range tests {
+		rows := NewRowsFromSpill(bytes.NewReader(data))
+		for rows.Next() {
+		}
+		if rows.Err() == nil || rows.Err() == io.EOF {
+			t.Errorf("Expected an error reading %q", data)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"io"
+
+	json "github.com/dustin/gojson"
+)
+
+// Split a slice of bytes containing multiple back-to-back JSON values (optionally separated by
+// whitespace) into the individual documents.  The returned slices share storage with the input.
+//
+// If the input contains anything other than valid JSON documents, the documents found before
+// the problem are returned along with a *SyntaxError describing the position of the problem.
+func SplitDocuments(bytes []byte) ([][]byte, error) {
+	var rv [][]byte
+	i := skipWhitespace(bytes, 0)
+	for i < len(bytes) {
+		end, err := nextDocument(bytes, i)
+		if err == errUnexpectedEnd {
+			return rv, syntaxErrorAt(bytes, "", int64(end), err.Error())
+		}
+		if err != nil {
+			return rv, err
+		}
+		rv = append(rv, bytes[i:end])
+		i = skipWhitespace(bytes, end)
+	}
+	return rv, nil
+}
+
+// Parse the first of the back-to-back JSON values (optionally separated by whitespace) in a
+// slice of bytes, also returning the bytes which follow it, so that concatenated values can be
+// consumed one at a time.  The Value and the rest share storage with the input.
+//
+// If nothing but whitespace remains, the return error is io.EOF.  If the next value is not
+// valid JSON, the return error is *SyntaxError and rest is the input, unchanged.
+func ParseOne(bytes []byte) (*Value, []byte, error) {
+	i := skipWhitespace(bytes, 0)
+	if i >= len(bytes) {
+		return nil, nil, io.EOF
+	}
+	end, err := nextDocument(bytes, i)
+	if err == errUnexpectedEnd {
+		return nil, bytes, syntaxErrorAt(bytes, "", int64(end), err.Error())
+	}
+	if err != nil {
+		return nil, bytes, err
+	}
+	return NewValueFromBytes(bytes[i:end]), bytes[end:], nil
+}
+
+// ScanDocuments is a split function for a bufio.Scanner that returns each JSON document in a
+// stream of back-to-back JSON values.  Tokens are suitable for passing to NewValueFromBytes,
+// however the underlying storage may be overwritten by a subsequent call to Scan.
+//
+// If the stream contains anything other than valid JSON documents, scanning stops with a
+// *SyntaxError whose position is relative to the start of the current document.
+func ScanDocuments(data []byte, atEOF bool) (int, []byte, error) {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) {
+		// nothing but whitespace, consume it
+		return i, nil, nil
+	}
+	end, err := nextDocument(data, i)
+	if !atEOF && (err == errUnexpectedEnd || (end == len(data) && isLiteralStart(data[i]))) {
+		// the document (or a number) may continue in the next read
+		return i, nil, nil
+	}
+	switch serr := err.(type) {
+	case nil:
+		return end, data[i:end], nil
+	case *SyntaxError:
+		return 0, nil, syntaxErrorAt(data[i:], "", serr.Offset-int64(i), serr.msg)
+	default:
+		return 0, nil, syntaxErrorAt(data[i:], "", int64(end-i), err.Error())
+	}
+}
+
+// nextDocument returns the end of the valid JSON document starting at bytes[start].
+// If the input ends before the document is complete, errUnexpectedEnd is returned.
+func nextDocument(bytes []byte, start int) (int, error) {
+	end, err := scanValue(bytes, start)
+	switch err := err.(type) {
+	case nil:
+	case *scanError:
+		return end, syntaxErrorAt(bytes, "", int64(err.offset+1), err.msg)
+	default:
+		return end, err
+	}
+	err = json.Validate(bytes[start:end])
+	if err != nil {
+		serr := newSyntaxError(bytes[start:end], "", err)
+		return end, syntaxErrorAt(bytes, "", int64(start)+serr.Offset, serr.msg)
+	}
+	return end, nil
+}
+
+func isLiteralStart(b byte) bool {
+	return b != '{' && b != '[' && b != '"'
+}
@@ -0,0 +1,108 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSplitDocuments(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected []string
+		err      bool
+	}{
+		{``, nil, false},
+		{` `, nil, false},
+		{`{"a":1}{"b":2}`, []string{`{"a":1}`, `{"b":2}`}, false},
+		{"{\"a\":\"}{\"}\n[1,[2]] 3 \"x\" true null", []string{`{"a":"}{"}`, `[1,[2]]`, `3`, `"x"`, `true`, `null`}, false},
+		{`{"a":1}{"b":`, []string{`{"a":1}`}, true},
+		{`{"a":1}]`, []string{`{"a":1}`}, true},
+		{`[1,2}`, nil, true},
+		{`{"a" 1}`, nil, true},
+	}
+
+	for _, test := range tests {
+		docs, err := SplitDocuments([]byte(test.input))
+		var actual []string
+		for _, doc := range docs {
+			actual = append(actual, string(doc))
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Expected %v, got %v for %s", test.expected, actual, test.input)
+		}
+		if test.err {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Errorf("Expected *SyntaxError, got %#v for %s", err, test.input)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.input)
+		}
+	}
+}
+
+func TestScanDocuments(t *testing.T) {
+	input := "{\"a\":1}\n{\"b\":[1,2,3]} 12345 \"str\"\n"
+	// read one byte at a time to force documents to span reads
+	scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(input)))
+	scanner.Split(ScanDocuments)
+	var actual []string
+	for scanner.Scan() {
+		actual = append(actual, scanner.Text())
+	}
+	if scanner.Err() != nil {
+		t.Errorf("Unexpected error %v", scanner.Err())
+	}
+	expected := []string{`{"a":1}`, `{"b":[1,2,3]}`, `12345`, `"str"`}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	scanner = bufio.NewScanner(strings.NewReader(`{"a":1} {"b":`))
+	scanner.Split(ScanDocuments)
+	for scanner.Scan() {
+	}
+	if _, ok := scanner.Err().(*SyntaxError); !ok {
+		t.Errorf("Expected *SyntaxError, got %#v", scanner.Err())
+	}
+}
+
+func TestParseOne(t *testing.T) {
+	rest := []byte(` {"a":1}[2] "three"4 `)
+	var actual []interface{}
+	for {
+		var val *Value
+		var err error
+		val, rest, err = ParseOne(rest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		actual = append(actual, val.Value())
+	}
+	expected := []interface{}{map[string]interface{}{"a": 1.0}, []interface{}{2.0}, "three", 4.0}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	input := []byte(`{"a":1} {"b":`)
+	_, rest, _ = ParseOne(input)
+	val, rest2, err := ParseOne(rest)
+	if _, ok := err.(*SyntaxError); !ok || val != nil || string(rest2) != string(rest) {
+		t.Errorf("Expected *SyntaxError and the input unchanged, got %v %q", err, rest2)
+	}
+}
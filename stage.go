@@ -0,0 +1,112 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+)
+
+// A Stage is one step of a pipeline, transforming each Value it is given.
+// Returning a nil Value (and a nil error) drops the Value from the pipeline.
+type Stage func(val *Value) (*Value, error)
+
+// A Checkpointer records how far through its source a pipeline has got, so that
+// a restarted pipeline can resume from there (see ResumeAfter()).
+type Checkpointer interface {
+	// Checkpoint is called with the sequence number (from its Meta) of each Value
+	// once it has been fully processed.
+	Checkpoint(seqno uint64) error
+}
+
+// StageOptions control how RunStage() runs a Stage.
+type StageOptions struct {
+	// Checkpointer, if not nil, is called for each Value once the Value produced from
+	// it has been accepted by the next stage, or once it has been dropped.  Values
+	// without Meta are not checkpointed.
+	Checkpointer Checkpointer
+
+	// DeadLetters, if not nil, receives the Values which are NOT_JSON or for which the
+	// stage returns an error, with the error attached (see StageError()), instead of
+	// the stage ending.  NOT_JSON Values are not given to the stage.
+	DeadLetters ValueChannel
+
+	// Salvage, if not empty, lists the strategies used to recover NOT_JSON Values (see
+	// Salvage()) before they are given to the stage.  Values which cannot be recovered
+	// are treated as NOT_JSON.
+	Salvage []int
+}
+
+// The attachment key under which RunStage() stores the error for a dead letter.
+const STAGE_ERROR_ATTACHMENT = "stageError"
+
+// Return the error attached to this Value when RunStage() sent it to the dead letters,
+// or nil if there is none.
+func (this *Value) StageError() error {
+	err, _ := this.GetAttachment(STAGE_ERROR_ATTACHMENT).(error)
+	return err
+}
+
+// Run stage on each Value received on ch, sending the results on the returned channel,
+// which is closed once ch is closed.  If the stage fails (and there are no dead letters),
+// or checkpointing fails, no more Values are received from ch, the returned channel is
+// closed, and the returned function then returns the error.
+func RunStage(ch ValueChannel, stage Stage, options StageOptions) (ValueChannel, func() error) {
+	rv := make(ValueChannel)
+	var err error
+	go func() {
+		defer close(rv)
+		for val := range ch {
+			var out *Value
+			val, _ = Salvage(val, options.Salvage...)
+			if val.Type() == NOT_JSON && options.DeadLetters != nil {
+				err = val.locateSyntaxError("", fmt.Errorf("not JSON"))
+			} else {
+				out, err = stage(val)
+			}
+			if err != nil {
+				if options.DeadLetters == nil {
+					return
+				}
+				val.SetAttachment(STAGE_ERROR_ATTACHMENT, err)
+				options.DeadLetters <- val
+				out, err = nil, nil
+			}
+			if out != nil {
+				rv <- out
+			}
+			if meta := val.Meta(); meta != nil && options.Checkpointer != nil {
+				err = options.Checkpointer.Checkpoint(meta.Seqno)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return rv, func() error {
+		return err
+	}
+}
+
+// Drop the Values received on ch with a sequence number (from their Meta) no greater
+// than seqno, the last one checkpointed before a pipeline was restarted.  Values without
+// Meta are kept.  The returned channel is closed once ch is closed.
+func ResumeAfter(ch ValueChannel, seqno uint64) ValueChannel {
+	rv := make(ValueChannel)
+	go func() {
+		defer close(rv)
+		for val := range ch {
+			if meta := val.Meta(); meta != nil && meta.Seqno <= seqno {
+				continue
+			}
+			rv <- val
+		}
+	}()
+	return rv
+}
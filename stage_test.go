@@ -0,0 +1,125 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testCheckpointer struct {
+	seqnos []uint64
+}
+
+func (this *testCheckpointer) Checkpoint(seqno uint64) error {
+	this.seqnos = append(this.seqnos, seqno)
+	return nil
+}
+
+func mutationChannel(bodies ...string) ValueChannel {
+	rv := make(ValueChannel)
+	go func() {
+		defer close(rv)
+		for i, body := range bodies {
+			rv <- NewValueFromMutation(&Mutation{Key: fmt.Sprint(i), Body: []byte(body), Seqno: uint64(i + 1)})
+		}
+	}()
+	return rv
+}
+
+// keepEven drops odd numbers and fails on strings.
+func keepEven(val *Value) (*Value, error) {
+	n, ok := val.Value().(float64)
+	if !ok {
+		return nil, fmt.Errorf("not a number")
+	}
+	if int(n)%2 != 0 {
+		return nil, nil
+	}
+	return val, nil
+}
+
+func TestRunStage(t *testing.T) {
+	checkpointer := &testCheckpointer{}
+	out, errFn := RunStage(mutationChannel(`1`, `2`, `3`, `4`), keepEven, StageOptions{Checkpointer: checkpointer})
+	var actual []interface{}
+	for val := range out {
+		actual = append(actual, val.Value())
+	}
+	if errFn() != nil {
+		t.Errorf("Unexpected error %v", errFn())
+	}
+	if !reflect.DeepEqual(actual, []interface{}{2.0, 4.0}) {
+		t.Errorf("Expected 2 and 4, got %v", actual)
+	}
+	// dropped values are checkpointed too
+	if !reflect.DeepEqual(checkpointer.seqnos, []uint64{1, 2, 3, 4}) {
+		t.Errorf("Unexpected checkpoints %v", checkpointer.seqnos)
+	}
+
+	checkpointer = &testCheckpointer{}
+	out, errFn = RunStage(mutationChannel(`2`, `"x"`, `4`), keepEven, StageOptions{Checkpointer: checkpointer})
+	for range out {
+	}
+	if errFn() == nil {
+		t.Errorf("Expected error from the stage")
+	}
+	if !reflect.DeepEqual(checkpointer.seqnos, []uint64{1}) {
+		t.Errorf("Expected only the first value to be checkpointed, got %v", checkpointer.seqnos)
+	}
+}
+
+func TestResumeAfter(t *testing.T) {
+	var seqnos []uint64
+	for val := range ResumeAfter(mutationChannel(`1`, `2`, `3`), 2) {
+		seqnos = append(seqnos, val.Meta().Seqno)
+	}
+	if !reflect.DeepEqual(seqnos, []uint64{3}) {
+		t.Errorf("Expected to resume at 3, got %v", seqnos)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	deadLetters := make(ValueChannel, 10)
+	checkpointer := &testCheckpointer{}
+	out, errFn := RunStage(mutationChannel(`2`, `"x"`, `{bad`, `4`), keepEven, StageOptions{
+		Checkpointer: checkpointer,
+		DeadLetters:  deadLetters,
+	})
+	var actual []interface{}
+	for val := range out {
+		actual = append(actual, val.Value())
+	}
+	close(deadLetters)
+	if errFn() != nil {
+		t.Errorf("Unexpected error %v", errFn())
+	}
+	if !reflect.DeepEqual(actual, []interface{}{2.0, 4.0}) {
+		t.Errorf("Expected 2 and 4, got %v", actual)
+	}
+	if !reflect.DeepEqual(checkpointer.seqnos, []uint64{1, 2, 3, 4}) {
+		t.Errorf("Expected dead letters to be checkpointed, got %v", checkpointer.seqnos)
+	}
+
+	var keys []string
+	for val := range deadLetters {
+		keys = append(keys, val.Meta().Key)
+		if val.StageError() == nil {
+			t.Errorf("Expected error attached to dead letter %s", val.Bytes())
+		}
+		if _, ok := val.StageError().(*SyntaxError); val.Type() == NOT_JSON && !ok {
+			t.Errorf("Expected *SyntaxError for NOT_JSON, got %v", val.StageError())
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"1", "2"}) {
+		t.Errorf("Expected dead letters 1 and 2, got %v", keys)
+	}
+}
@@ -0,0 +1,192 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	json "github.com/dustin/gojson"
+)
+
+// The number of most frequent strings Summarize() reports for each path.
+const SUMMARY_TOP_K = 5
+
+// Summarize profiles a collection of documents, returning an OBJECT of the form:
+//
+//         {"documents": 3, "size": {...}, "paths": {"price": {...}, "tags[]": {...}, ...}}
+//
+// Paths are dotted property names, with "[]" standing for every element of an array.  For
+// each path the summary counts how many times it is present ("count"), how many of those are
+// null ("nulls", "null_rate") and of each type ("types").  Numbers add "min", "max" and "avg",
+// strings add the SUMMARY_TOP_K most frequent values ("top"), and every path has percentiles
+// of the serialized size of its values in bytes ("size"), as do the documents themselves.
+func Summarize(coll ValueCollection) *Value {
+	summary := summarizer{paths: make(map[string]*pathSummary)}
+	sizes := make([]int, 0, len(coll))
+	for _, doc := range coll {
+		sizes = append(sizes, len(doc.Bytes()))
+		summary.addChildren("", doc)
+	}
+
+	paths := make(map[string]interface{}, len(summary.paths))
+	for path, stats := range summary.paths {
+		paths[path] = stats.value()
+	}
+	return NewValue(map[string]interface{}{
+		"documents": float64(len(coll)),
+		"size":      sizePercentiles(sizes),
+		"paths":     paths,
+	})
+}
+
+type summarizer struct {
+	paths map[string]*pathSummary
+}
+
+// pathSummary accumulates the statistics of the values found at one path.
+type pathSummary struct {
+	count, nulls  int
+	types         map[int]int
+	numbers       int
+	min, max, sum float64
+	strings       map[string]int
+	sizes         []int
+}
+
+func (this *summarizer) add(path string, val *Value) {
+	stats, ok := this.paths[path]
+	if !ok {
+		stats = &pathSummary{types: make(map[int]int)}
+		this.paths[path] = stats
+	}
+	stats.count++
+	stats.types[val.Type()]++
+	stats.sizes = append(stats.sizes, len(val.Bytes()))
+	switch val.Type() {
+	case NULL:
+		stats.nulls++
+	case NUMBER:
+		if f, ok := summaryNumber(val.Value()); ok {
+			if stats.numbers == 0 || f < stats.min {
+				stats.min = f
+			}
+			if stats.numbers == 0 || f > stats.max {
+				stats.max = f
+			}
+			stats.sum += f
+			stats.numbers++
+		}
+	case STRING:
+		if stats.strings == nil {
+			stats.strings = make(map[string]int)
+		}
+		stats.strings[val.Value().(string)]++
+	}
+	this.addChildren(path, val)
+}
+
+// addChildren adds the members of an OBJECT, or the elements of an ARRAY, below path.
+func (this *summarizer) addChildren(path string, val *Value) {
+	switch val.Type() {
+	case OBJECT:
+		for k, v := range val.members() {
+			if path != "" {
+				k = path + "." + k
+			}
+			this.add(k, v)
+		}
+	case ARRAY:
+		elements, _ := val.elements()
+		for _, v := range elements {
+			this.add(path+"[]", v)
+		}
+	}
+}
+
+// summaryNumber converts a number, which may be a json.Number if parsed with EXACT_NUMBERS.
+func summaryNumber(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(val), 64)
+		return f, err == nil && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
+
+func (this *pathSummary) value() map[string]interface{} {
+	types := make(map[string]interface{}, len(this.types))
+	for t, n := range this.types {
+		types[typeNames[t]] = float64(n)
+	}
+	rv := map[string]interface{}{
+		"count":     float64(this.count),
+		"nulls":     float64(this.nulls),
+		"null_rate": float64(this.nulls) / float64(this.count),
+		"types":     types,
+		"size":      sizePercentiles(this.sizes),
+	}
+	if this.numbers > 0 {
+		rv["min"] = this.min
+		rv["max"] = this.max
+		rv["avg"] = this.sum / float64(this.numbers)
+	}
+	if this.strings != nil {
+		rv["top"] = topStrings(this.strings, SUMMARY_TOP_K)
+	}
+	return rv
+}
+
+// topStrings returns the k most frequent strings, most frequent first, breaking ties by the string.
+func topStrings(counts map[string]int, k int) []interface{} {
+	values := make([]string, 0, len(counts))
+	for s := range counts {
+		values = append(values, s)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	if len(values) > k {
+		values = values[:k]
+	}
+	rv := make([]interface{}, len(values))
+	for i, s := range values {
+		rv[i] = map[string]interface{}{"value": s, "count": float64(counts[s])}
+	}
+	return rv
+}
+
+// sizePercentiles returns the minimum, maximum and nearest-rank percentiles of sizes.
+func sizePercentiles(sizes []int) map[string]interface{} {
+	if len(sizes) == 0 {
+		return map[string]interface{}{}
+	}
+	sort.Ints(sizes)
+	percentile := func(p float64) interface{} {
+		rank := int(math.Ceil(p / 100 * float64(len(sizes))))
+		if rank < 1 {
+			rank = 1
+		}
+		return float64(sizes[rank-1])
+	}
+	return map[string]interface{}{
+		"min": float64(sizes[0]),
+		"p50": percentile(50),
+		"p90": percentile(90),
+		"p99": percentile(99),
+		"max": float64(sizes[len(sizes)-1]),
+	}
+}
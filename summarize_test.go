@@ -0,0 +1,86 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	coll := ValueCollection{
+		NewValueFromBytes([]byte(`{"name":"a","price":1,"tags":["x","y"]}`)),
+		NewValueFromBytes([]byte(`{"name":"b","price":3,"tags":["x"],"stock":{"count":2}}`)),
+		NewValueFromBytes([]byte(`{"name":"a","price":null}`)),
+		NewValue(map[string]interface{}{"name": "c", "price": 8.0}),
+	}
+	summary := Summarize(coll).Value().(map[string]interface{})
+
+	if summary["documents"] != 4.0 {
+		t.Errorf("Expected 4 documents, got %v", summary["documents"])
+	}
+	size := summary["size"].(map[string]interface{})
+	if size["min"] != 22.0 || size["max"] != 55.0 || size["p50"] != 25.0 {
+		t.Errorf("Unexpected document sizes %v", size)
+	}
+
+	paths := summary["paths"].(map[string]interface{})
+	expectedPaths := []string{"name", "price", "tags", "tags[]", "stock", "stock.count"}
+	if len(paths) != len(expectedPaths) {
+		t.Errorf("Expected paths %v, got %v", expectedPaths, paths)
+	}
+	for _, path := range expectedPaths {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("Expected path %s", path)
+		}
+	}
+
+	price := paths["price"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"count":     4.0,
+		"nulls":     1.0,
+		"null_rate": 0.25,
+		"types":     map[string]interface{}{"number": 3.0, "null": 1.0},
+		"min":       1.0,
+		"max":       8.0,
+		"avg":       4.0,
+		"size":      map[string]interface{}{"min": 1.0, "p50": 1.0, "p90": 4.0, "p99": 4.0, "max": 4.0},
+	}
+	if !reflect.DeepEqual(price, expected) {
+		t.Errorf("Expected %v, got %v", expected, price)
+	}
+
+	name := paths["name"].(map[string]interface{})
+	top := []interface{}{
+		map[string]interface{}{"value": "a", "count": 2.0},
+		map[string]interface{}{"value": "b", "count": 1.0},
+		map[string]interface{}{"value": "c", "count": 1.0},
+	}
+	if !reflect.DeepEqual(name["top"], top) {
+		t.Errorf("Expected top %v, got %v", top, name["top"])
+	}
+
+	tags := paths["tags[]"].(map[string]interface{})
+	if tags["count"] != 3.0 {
+		t.Errorf("Expected 3 tags, got %v", tags["count"])
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	summary := Summarize(nil).Value().(map[string]interface{})
+	expected := map[string]interface{}{
+		"documents": 0.0,
+		"size":      map[string]interface{}{},
+		"paths":     map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected %v, got %v", expected, summary)
+	}
+}
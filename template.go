@@ -0,0 +1,247 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Create a new Value by filling in the placeholders of a template with values from params.
+//
+// Placeholders take two forms:
+//
+//         1. A string node of the form "$path" is replaced by the value found at path.  The value keeps its type, so "$count" may become a number or an object.
+//         2. Inside any other string, "{{path}}" is replaced by the value found at path.  Strings are inserted as-is, other values are inserted as their JSON representation.
+//
+// Paths are dotted, for example "user.name" or "items.0", with integer steps indexing into arrays.
+// A string beginning with "$$" is not a placeholder, it is copied with the leading "$" removed.
+// If a placeholder refers to a path not defined in params, the return error is *Undefined.
+//
+// Objects with the following keys are control nodes, whose expressions are evaluated
+// against params using the expression engine (see ParseExpression()):
+//
+//         1. {"$if": expr, "then": node, "else": node} is replaced by the filled then node if expr is true, otherwise by the filled else node.  A missing then or else node is omitted from the enclosing object or array.
+//         2. {"$each": expr, "as": name, "do": node} is replaced by an array, with the do node filled once for each element of the array expr evaluates to.  The element is available to placeholders and expressions as name (default "item").
+//
+// The template itself is not modified.
+func FillTemplate(template *Value, params *Value) (*Value, error) {
+	rv, err := fillNode(template.Value(), &templateScope{params: params})
+	if err != nil {
+		return nil, err
+	}
+	if rv == templateOmit {
+		rv = nil
+	}
+	return NewValue(rv), nil
+}
+
+// templateOmit is returned for control nodes which produce nothing.
+type templateOmitted struct{}
+
+var templateOmit = templateOmitted{}
+
+// templateScope resolves placeholders and expressions against the params,
+// and any variables bound by enclosing $each nodes.
+type templateScope struct {
+	params *Value
+	vars   map[string]*Value
+}
+
+func (this *templateScope) resolve(path string) (*Value, error) {
+	first, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		first, rest = path[:i], path[i+1:]
+	}
+	v, ok := this.vars[first]
+	if !ok {
+		return resolvePath(this.params, path)
+	}
+	rv, err := resolvePath(v, rest)
+	if _, ok := err.(*Undefined); ok {
+		return nil, &Undefined{path}
+	}
+	return rv, err
+}
+
+func (this *templateScope) eval(expr interface{}) (*Value, error) {
+	s, ok := expr.(string)
+	if !ok {
+		return nil, fmt.Errorf("template expression must be a string, got %T", expr)
+	}
+	e, err := ParseExpression(s)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := e.root.eval(&evalContext{doc: this.params, vars: this.vars})
+	if err != nil {
+		return nil, err
+	}
+	return unshared(rv), nil
+}
+
+func (this *templateScope) bind(name string, val *Value) *templateScope {
+	vars := make(map[string]*Value, len(this.vars)+1)
+	for k, v := range this.vars {
+		vars[k] = v
+	}
+	vars[name] = val
+	return &templateScope{params: this.params, vars: vars}
+}
+
+func fillNode(node interface{}, scope *templateScope) (interface{}, error) {
+	switch node := node.(type) {
+	case string:
+		return fillString(node, scope)
+	case []interface{}:
+		rv := make([]interface{}, 0, len(node))
+		for _, v := range node {
+			filled, err := fillNode(v, scope)
+			if err != nil {
+				return nil, err
+			}
+			if filled != templateOmit {
+				rv = append(rv, filled)
+			}
+		}
+		return rv, nil
+	case map[string]interface{}:
+		if cond, ok := node["$if"]; ok {
+			return fillIf(node, cond, scope)
+		}
+		if list, ok := node["$each"]; ok {
+			return fillEach(node, list, scope)
+		}
+		rv := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			filled, err := fillNode(v, scope)
+			if err != nil {
+				return nil, err
+			}
+			if filled != templateOmit {
+				rv[k] = filled
+			}
+		}
+		return rv, nil
+	default:
+		return node, nil
+	}
+}
+
+func fillIf(node map[string]interface{}, cond interface{}, scope *templateScope) (interface{}, error) {
+	val, err := scope.eval(cond)
+	if err != nil {
+		return nil, err
+	}
+	branch, ok := node["else"]
+	if isTrue(val) {
+		branch, ok = node["then"]
+	}
+	if !ok {
+		return templateOmit, nil
+	}
+	return fillNode(branch, scope)
+}
+
+func fillEach(node map[string]interface{}, list interface{}, scope *templateScope) (interface{}, error) {
+	val, err := scope.eval(list)
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]interface{}, 0)
+	if val == nil {
+		// missing produces nothing
+		return rv, nil
+	}
+	elements, err := val.elements()
+	if err != nil {
+		return nil, &TypeMismatch{Path: fmt.Sprintf("$each expression %v", list), Expected: ARRAY, Actual: val.Type()}
+	}
+	name := "item"
+	if as, ok := node["as"].(string); ok {
+		name = as
+	}
+	for _, element := range elements {
+		filled, err := fillNode(node["do"], scope.bind(name, element))
+		if err != nil {
+			return nil, err
+		}
+		if filled != templateOmit {
+			rv = append(rv, filled)
+		}
+	}
+	return rv, nil
+}
+
+func fillString(s string, scope *templateScope) (interface{}, error) {
+	if strings.HasPrefix(s, "$$") {
+		return s[1:], nil
+	}
+	if strings.HasPrefix(s, "$") {
+		return scope.resolve(s[1:])
+	}
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var buf bytes.Buffer
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			break
+		}
+		buf.WriteString(s[:start])
+		val, err := scope.resolve(strings.TrimSpace(s[start+2 : start+end]))
+		if err != nil {
+			return nil, err
+		}
+		if str, ok := val.Value().(string); ok {
+			buf.WriteString(str)
+		} else {
+			buf.Write(val.Bytes())
+		}
+		s = s[start+end+2:]
+	}
+	buf.WriteString(s)
+	return buf.String(), nil
+}
+
+// resolvePath finds the Value at a dotted path inside val.  Steps which are
+// integers index into arrays, all other steps access object properties.
+func resolvePath(val *Value, path string) (*Value, error) {
+	if path == "" {
+		return val, nil
+	}
+	steps := strings.Split(path, ".")
+	if rv, ok, err := val.findNested(steps); ok {
+		return rv, err
+	}
+	for _, step := range steps {
+		var err error
+		index, ierr := strconv.Atoi(step)
+		if ierr == nil && val.Type() == ARRAY {
+			val, err = val.Index(index)
+		} else {
+			val, err = val.Path(step)
+		}
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return nil, &Undefined{path}
+			}
+			return nil, err
+		}
+	}
+	return val, nil
+}
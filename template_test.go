@@ -0,0 +1,89 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFillTemplate(t *testing.T) {
+	params := NewValueFromBytes([]byte(`{"host":{"name":"db1","load":[0.5,0.75]},"level":3}`))
+
+	var tests = []struct {
+		template string
+		output   interface{}
+	}{
+		{`"plain"`, "plain"},
+		{`"$level"`, 3.0},
+		{`"$$level"`, "$level"},
+		{`"$host.load.1"`, 0.75},
+		{`"{{host.name}} is at level {{ level }}"`, "db1 is at level 3"},
+		{`"load {{host.load}}"`, "load [0.5,0.75]"},
+		{`{"alert":{"host":"$host.name","load":"$host.load"},"tags":["{{host.name}}-{{level}}"]}`,
+			map[string]interface{}{
+				"alert": map[string]interface{}{"host": "db1", "load": []interface{}{0.5, 0.75}},
+				"tags":  []interface{}{"db1-3"},
+			}},
+	}
+
+	for _, test := range tests {
+		template := NewValueFromBytes([]byte(test.template))
+		result, err := FillTemplate(template, params)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.template)
+			continue
+		}
+		if !reflect.DeepEqual(result.Value(), test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, result.Value(), test.template)
+		}
+	}
+
+	_, err := FillTemplate(NewValueFromBytes([]byte(`{"a":"{{host.missing}}"}`)), params)
+	if err == nil || err.Error() != "host.missing is not defined" {
+		t.Errorf("Expected host.missing is not defined, got %v", err)
+	}
+}
+
+func TestFillTemplateControlNodes(t *testing.T) {
+	params := NewValueFromBytes([]byte(`{"user":{"name":"marty","admin":true},"orders":[{"id":1,"total":25},{"id":2,"total":150}]}`))
+
+	var tests = []struct {
+		template string
+		output   interface{}
+	}{
+		{`{"$if":"user.admin","then":"admin","else":"user"}`, "admin"},
+		{`{"$if":"NOT user.admin","then":"user","else":"admin"}`, "admin"},
+		{`{"name":"$user.name","role":{"$if":"user.missing = 1","then":"x"}}`,
+			map[string]interface{}{"name": "marty"}},
+		{`{"$each":"orders","do":"order {{item.id}}"}`, []interface{}{"order 1", "order 2"}},
+		{`{"$each":"orders","as":"o","do":{"$if":"o.total > 100","then":{"id":"$o.id","who":"$user.name"}}}`,
+			[]interface{}{map[string]interface{}{"id": 2.0, "who": "marty"}}},
+		{`{"$each":"nothing","do":"x"}`, []interface{}{}},
+		{`["a",{"$if":"false","then":"b"},"c"]`, []interface{}{"a", "c"}},
+	}
+
+	for _, test := range tests {
+		template := NewValueFromBytes([]byte(test.template))
+		result, err := FillTemplate(template, params)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, test.template)
+			continue
+		}
+		if !reflect.DeepEqual(result.Value(), test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, result.Value(), test.template)
+		}
+	}
+
+	_, err := FillTemplate(NewValueFromBytes([]byte(`{"$each":"user","do":"x"}`)), params)
+	if err == nil {
+		t.Errorf("Expected error for $each over an object")
+	}
+}
@@ -0,0 +1,157 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	json "github.com/dustin/gojson"
+)
+
+// Return functions for Go templates, so Values can be rendered without converting them to maps
+// first.  The result can be passed to the Funcs() method of both text/template and html/template:
+//
+//         1. path "a.b" val is the Value at a dotted path (as for FillTemplate()) inside val, or nothing if it is not defined.
+//         2. index val step... is the Value found by taking each step in turn, an int indexing an ARRAY and a string accessing a property.  It replaces the builtin index, which it still performs for arguments which are not Values.
+//         3. jsonpretty val is the JSON encoding of val, indented by two spaces.
+//         4. exists "a.b" val determines if the dotted path is defined inside val (see ExistsPath()).
+//
+// Values are printed by templates using String(), so {{path "name" .}} prints a STRING without quotes.
+// Paths come first, so a Value can be piped in, as in {{. | path "user.name"}}.
+func TemplateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"path":       templatePath,
+		"index":      templateIndex,
+		"jsonpretty": templateJSONPretty,
+		"exists":     templateExists,
+	}
+}
+
+func templatePath(path string, val *Value) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	rv, err := resolvePath(val, path)
+	if err != nil {
+		if _, ok := err.(*Undefined); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rv, nil
+}
+
+func templateExists(path string, val *Value) bool {
+	return val != nil && val.ExistsPath(path)
+}
+
+func templateJSONPretty(val interface{}) (string, error) {
+	var data []byte
+	if v, ok := val.(*Value); ok {
+		data = v.Bytes()
+	} else {
+		var err error
+		data, err = json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+	}
+	buf := bytes.Buffer{}
+	err := json.Indent(&buf, data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// templateIndex looks up the steps inside a Value, where a missing step yields nothing.
+// For anything else, it indexes slices, arrays, strings and maps as the builtin index does.
+func templateIndex(item interface{}, steps ...interface{}) (interface{}, error) {
+	val, ok := item.(*Value)
+	if !ok {
+		return reflectIndex(item, steps)
+	}
+	for _, step := range steps {
+		if val == nil {
+			return nil, nil
+		}
+		var err error
+		if s, ok := step.(string); ok {
+			val, err = val.Path(s)
+		} else if i, ok := templateInt(step); ok {
+			val, err = val.Index(i)
+		} else {
+			return nil, fmt.Errorf("cannot index a Value with type %T", step)
+		}
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+func templateInt(step interface{}) (int, bool) {
+	v := reflect.ValueOf(step)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int(v.Uint()), true
+	}
+	return 0, false
+}
+
+func reflectIndex(item interface{}, steps []interface{}) (interface{}, error) {
+	v := reflect.ValueOf(item)
+	if !v.IsValid() {
+		return nil, fmt.Errorf("index of untyped nil")
+	}
+	for k, step := range steps {
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, fmt.Errorf("index of nil pointer")
+			}
+			if val, ok := v.Interface().(*Value); ok {
+				// the rest of the steps are inside a Value, for example one held in a map
+				return templateIndex(val, steps[k:]...)
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array, reflect.String:
+			i, ok := templateInt(step)
+			if !ok {
+				return nil, fmt.Errorf("cannot index slice/array with type %T", step)
+			}
+			if i < 0 || i >= v.Len() {
+				return nil, fmt.Errorf("index out of range: %d", i)
+			}
+			v = v.Index(i)
+		case reflect.Map:
+			key := reflect.ValueOf(step)
+			if !key.IsValid() || !key.Type().ConvertibleTo(v.Type().Key()) {
+				return nil, fmt.Errorf("value has type %T; should be %s", step, v.Type().Key())
+			}
+			elem := v.MapIndex(key.Convert(v.Type().Key()))
+			if !elem.IsValid() {
+				elem = reflect.Zero(v.Type().Elem())
+			}
+			v = elem
+		default:
+			return nil, fmt.Errorf("can't index item of type %s", v.Type())
+		}
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,61 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"testing"
+	"text/template"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"user": {"name": "Ann <a>", "tags": ["x", "y"]}, "n": 2}`))
+	var tests = []struct {
+		tmpl     string
+		data     interface{}
+		expected string
+	}{
+		{`{{path "user.name" .}}`, doc, "Ann <a>"},
+		{`{{. | path "user.tags.1"}}`, doc, "y"},
+		{`{{path "n" .}}`, doc, "2"},
+		{`{{with path "missing" .}}yes{{else}}no{{end}}`, doc, "no"},
+		{`{{index . "user" "tags" 0}}`, doc, "x"},
+		{`{{index . "user" "tags" 5}}`, doc, "<no value>"},
+		{`{{if exists "user.tags" .}}yes{{end}}{{if exists "user.age" .}}no{{end}}`, doc, "yes"},
+		{`{{jsonpretty (path "user.tags" .)}}`, doc, "[\n  \"x\",\n  \"y\"\n]"},
+		{`{{index .docs "a" "n"}}`, map[string]interface{}{"docs": map[string]*Value{"a": doc}}, "2"},
+		{`{{index .list 1}} {{index .m "k"}}`, map[string]interface{}{"list": []int{3, 4}, "m": map[string]string{"k": "v"}}, "4 v"},
+	}
+	for _, test := range tests {
+		tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(test.tmpl))
+		buf := bytes.Buffer{}
+		err := tmpl.Execute(&buf, test.data)
+		if err != nil || buf.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.tmpl, test.expected, buf.String(), err)
+		}
+	}
+
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(`{{index .list 5}}`))
+	err := tmpl.Execute(&bytes.Buffer{}, map[string]interface{}{"list": []int{1}})
+	if err == nil {
+		t.Errorf("Expected an error indexing past the end of a slice")
+	}
+}
+
+func TestTemplateFuncsHTML(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"user": {"name": "Ann <a>"}}`))
+	tmpl := htmltemplate.Must(htmltemplate.New("").Funcs(TemplateFuncs()).Parse(`<p>{{path "user.name" .}}</p>`))
+	buf := bytes.Buffer{}
+	err := tmpl.Execute(&buf, doc)
+	if err != nil || buf.String() != "<p>Ann &lt;a&gt;</p>" {
+		t.Errorf("Expected escaped name, got %q, %v", buf.String(), err)
+	}
+}
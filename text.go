@@ -0,0 +1,65 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"fmt"
+
+	json "github.com/dustin/gojson"
+)
+
+// MarshalText implements encoding.TextMarshaler for scalar Values, so they can be used as the
+// keys of maps encoded as JSON, YAML or TOML, in URL query strings, and in text templates.  A
+// STRING is its contents, without quotes or escapes, and a NUMBER, BOOLEAN or NULL is its JSON
+// encoding, such as 1.5, true or null.
+//
+// If this Value is an OBJECT or ARRAY, the return error is *TypeMismatch.  If this Value is
+// NOT_JSON, an error is returned.
+func (this *Value) MarshalText() ([]byte, error) {
+	switch this.parsedType {
+	case NOT_JSON:
+		return nil, this.locateSyntaxError("", fmt.Errorf("not JSON"))
+	case OBJECT, ARRAY:
+		return nil, &TypeMismatch{Expected: STRING, Actual: this.parsedType}
+	case STRING:
+		return []byte(this.Value().(string)), nil
+	}
+	return json.Marshal(this.Value())
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, the inverse of MarshalText().  Text which is
+// a JSON number, true, false or null (without surrounding whitespace) becomes a NUMBER, BOOLEAN or
+// NULL, anything else (including text which looks like an object or array) becomes a STRING.
+// So a STRING holding, for example, "10" or "true" is read back as a NUMBER or BOOLEAN.  The
+// text is copied.
+//
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+func (this *Value) UnmarshalText(text []byte) error {
+	this.checkMutable()
+	var rv *Value
+	if len(text) > 0 && !isWhitespace(text[0]) && !isWhitespace(text[len(text)-1]) {
+		rv = NewValueFromBytes(append([]byte(nil), text...))
+	}
+	if rv == nil || (rv.parsedType != NUMBER && rv.parsedType != BOOLEAN && rv.parsedType != NULL) {
+		rv = NewValue(string(text))
+	}
+	*this = *rv
+	return nil
+}
+
+// String implements fmt.Stringer, so Values print (and appear in text templates) as text: a scalar
+// Value as MarshalText() encodes it, and an OBJECT or ARRAY (or a NOT_JSON Value) as Bytes().
+func (this *Value) String() string {
+	text, err := this.MarshalText()
+	if err != nil {
+		return string(this.Bytes())
+	}
+	return string(text)
+}
@@ -0,0 +1,109 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"text/template"
+)
+
+func TestMarshalText(t *testing.T) {
+	var tests = []struct {
+		val      *Value
+		expected string
+	}{
+		{NewValue("plain"), "plain"},
+		{NewValueFromBytes([]byte(`"esc\"apedé"`)), `esc"apedé`},
+		{NewValue(1.5), "1.5"},
+		{NewValueFromBytes([]byte(`1e3`)), "1000"},
+		{NewValue(true), "true"},
+		{NewValue(nil), "null"},
+	}
+	for _, test := range tests {
+		text, err := test.val.MarshalText()
+		if err != nil || string(text) != test.expected {
+			t.Errorf("Expected %q, got %q, %v", test.expected, text, err)
+		}
+	}
+	if _, err := NewValueFromBytes([]byte(`{"a": 1}`)).MarshalText(); err == nil {
+		t.Errorf("Expected an error for an object")
+	}
+	if _, err := NewValueFromBytes([]byte(`{`)).MarshalText(); err == nil {
+		t.Errorf("Expected an error for NOT_JSON")
+	}
+
+	// scalar Values as map keys, in query strings and in templates
+	encoded, err := json.Marshal(map[*Value]int{NewValue("a"): 1})
+	if err != nil || string(encoded) != `{"a":1}` {
+		t.Errorf("Unexpected map %s, %v", encoded, err)
+	}
+	text, _ := NewValue(10.0).MarshalText()
+	query := url.Values{"limit": {string(text)}}
+	if query.Encode() != "limit=10" {
+		t.Errorf("Unexpected query %s", query.Encode())
+	}
+	var buf bytes.Buffer
+	template.Must(template.New("t").Parse(`{{.}}`)).Execute(&buf, NewValue("hello"))
+	if buf.String() != "hello" {
+		t.Errorf("Unexpected template output %q", buf.String())
+	}
+	if s := NewValueFromBytes([]byte(`{"a": [1, "x"]}`)).String(); s != `{"a": [1, "x"]}` {
+		t.Errorf("Expected the JSON of an object, got %q", s)
+	}
+}
+
+func TestUnmarshalText(t *testing.T) {
+	var tests = []struct {
+		text     string
+		typ      int
+		expected interface{}
+	}{
+		{"plain", STRING, "plain"},
+		{"10", NUMBER, 10.0},
+		{"-1.5e2", NUMBER, -150.0},
+		{"true", BOOLEAN, true},
+		{"null", NULL, nil},
+		{" 10", STRING, " 10"},
+		{`{"a": 1}`, STRING, `{"a": 1}`},
+		{`"quoted"`, STRING, `"quoted"`},
+		{"", STRING, ""},
+	}
+	for _, test := range tests {
+		var val Value
+		err := val.UnmarshalText([]byte(test.text))
+		if err != nil || val.Type() != test.typ || val.Value() != test.expected {
+			t.Errorf("Expected %v for %q, got %v, %v", test.expected, test.text, val.Value(), err)
+		}
+		val.SetAttachment("mutable", true)
+	}
+
+	var decoded map[string]*Value
+	err := json.Unmarshal([]byte(`{"a": 1}`), &decoded)
+	if err != nil || decoded["a"].Value() != 1.0 {
+		t.Errorf("Expected UnmarshalJSON to still be used for values, got %v, %v", decoded, err)
+	}
+
+	// unmarshaling into an element leaves other BOOLEAN Values unaffected
+	elem, _ := NewValueFromBytes([]byte(`[true]`)).Index(0)
+	err = elem.UnmarshalText([]byte("changed"))
+	if err != nil || elem.Value() != "changed" {
+		t.Errorf("Expected the element to change, got %v, %v", elem.Value(), err)
+	}
+	other, _ := NewValue([]interface{}{true}).Index(0)
+	if other.Value() != true {
+		t.Errorf("Expected true, got %v", other.Value())
+	}
+	if expectPanic(func() { trueSingleton.UnmarshalText([]byte("x")) }) == nil {
+		t.Errorf("Expected unmarshaling into a shared Value to panic")
+	}
+}
@@ -0,0 +1,60 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"time"
+)
+
+// The layouts of the date strings recognized by FilterTimeRange(), tried in turn.
+// Layouts without a zone are taken to be UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// recognizeDate returns the time val holds, if it is a STRING in one of the dateLayouts.
+func recognizeDate(val *Value) (time.Time, bool) {
+	s, ok := val.Value().(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Return the Values in this collection whose dotted path (as for FillTemplate()) holds a date
+// at or after from and before to.  Dates are strings in RFC 3339 format, optionally with a
+// space in place of the T, without a zone (meaning UTC), or only the date (meaning midnight UTC).
+// Values where the path is not defined, or is not a date, are left out.  A zero from or to
+// leaves that end of the range open.
+func (this ValueCollection) FilterTimeRange(path string, from, to time.Time) ValueCollection {
+	var rv ValueCollection
+	for _, val := range this {
+		v, err := resolvePath(val, path)
+		if err != nil {
+			continue
+		}
+		t, ok := recognizeDate(v)
+		if !ok || (!from.IsZero() && t.Before(from)) || (!to.IsZero() && !t.Before(to)) {
+			continue
+		}
+		rv = append(rv, val)
+	}
+	return rv
+}
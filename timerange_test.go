@@ -0,0 +1,53 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterTimeRange(t *testing.T) {
+	events := ValueCollection{
+		NewValueFromBytes([]byte(`{"id":"a","event":{"at":"2024-03-01T09:30:00Z"}}`)),
+		NewValueFromBytes([]byte(`{"id":"b","event":{"at":"2024-03-01T12:00:00+02:00"}}`)),
+		NewValueFromBytes([]byte(`{"id":"c","event":{"at":"2024-03-01 10:15:00.250"}}`)),
+		NewValueFromBytes([]byte(`{"id":"d","event":{"at":"2024-03-02"}}`)),
+		NewValueFromBytes([]byte(`{"id":"e","event":{"at":"yesterday"}}`)),
+		NewValueFromBytes([]byte(`{"id":"f","event":{"at":1709285400}}`)),
+		NewValueFromBytes([]byte(`{"id":"g"}`)),
+	}
+	from := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		from, to time.Time
+		expected []string
+	}{
+		{from, to, []string{"a", "b", "c"}},
+		// from is inclusive and to is exclusive
+		{from.Add(time.Nanosecond), to.Add(time.Nanosecond), []string{"b", "c", "d"}},
+		{time.Time{}, from, nil},
+		{from, time.Time{}, []string{"a", "b", "c", "d"}},
+		{time.Time{}, time.Time{}, []string{"a", "b", "c", "d"}},
+	}
+	for _, test := range tests {
+		result := events.FilterTimeRange("event.at", test.from, test.to)
+		var ids []string
+		for _, val := range result {
+			id, _ := val.Path("id")
+			ids = append(ids, id.Value().(string))
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("Expected %v between %v and %v, got %v", test.expected, test.from, test.to, ids)
+		}
+	}
+}
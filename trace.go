@@ -0,0 +1,190 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The sources from which a step of a path may be resolved
+const (
+	TRACE_UNDEFINED = iota // the step was not found
+	TRACE_ALIAS            // an alias set by SetPath() or SetIndex()
+	TRACE_PARSED           // the already parsed tree
+	TRACE_RAW              // a scan of the raw bytes
+	TRACE_VARIABLE         // a variable bound by the caller (for example by a template $each)
+)
+
+var traceSourceNames = map[int]string{
+	TRACE_UNDEFINED: "undefined",
+	TRACE_ALIAS:     "alias",
+	TRACE_PARSED:    "parsed",
+	TRACE_RAW:       "raw",
+	TRACE_VARIABLE:  "variable",
+}
+
+// A Trace describes how each step of a path or expression was resolved, see ExplainPath()
+// and Expression.Explain().
+type Trace struct {
+	Steps []*TraceStep
+}
+
+// A TraceStep describes how one property or index was resolved.
+type TraceStep struct {
+	Path         string // the property or index
+	Source       int    // where the value was found, one of the TRACE_ constants
+	Fallbacks    []int  // the sources consulted before Source, which did not have the value
+	BytesScanned int    // bytes of raw JSON scanned, if the raw bytes were consulted
+}
+
+// The total number of bytes of raw JSON scanned by all steps.
+func (this *Trace) BytesScanned() int {
+	rv := 0
+	for _, step := range this.Steps {
+		rv += step.BytesScanned
+	}
+	return rv
+}
+
+// Description of the trace, one line per step.
+func (this *Trace) String() string {
+	lines := make([]string, len(this.Steps))
+	for i, step := range this.Steps {
+		lines[i] = step.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Description of the step, for example: "name: raw (scanned 42 bytes, after alias)"
+func (this *TraceStep) String() string {
+	details := make([]string, 0)
+	if this.BytesScanned > 0 {
+		details = append(details, fmt.Sprintf("scanned %d bytes", this.BytesScanned))
+	}
+	if len(this.Fallbacks) > 0 {
+		fallbacks := make([]string, len(this.Fallbacks))
+		for i, fallback := range this.Fallbacks {
+			fallbacks[i] = traceSourceNames[fallback]
+		}
+		details = append(details, "after "+strings.Join(fallbacks, ", "))
+	}
+	rv := fmt.Sprintf("%s: %s", this.Path, traceSourceNames[this.Source])
+	if len(details) > 0 {
+		rv += " (" + strings.Join(details, ", ") + ")"
+	}
+	return rv
+}
+
+// Access the requested path inside this Value as Path() does, also returning a Trace
+// describing how it was resolved.  When a dotted path is not a property of this Value,
+// the Trace has a step for the whole path followed by one for each of its parts.
+func (this *Value) ExplainPath(path string) (*Value, *Trace, error) {
+	trace := Trace{}
+	rv, err := this.path(path, &trace)
+	if _, undefined := err.(*Undefined); undefined && strings.Contains(path, ".") {
+		rv, err = this.explainSteps(path, &trace)
+		return rv, &trace, err
+	}
+	if rv != nil {
+		this.bindChild(path, rv)
+	}
+	return rv, &trace, err
+}
+
+// Access the requested index inside this Value as Index() does, also returning a Trace
+// describing how it was resolved.  A negative index is recorded as the index it counts to.
+func (this *Value) ExplainIndex(index int) (*Value, *Trace, error) {
+	trace := Trace{}
+	index, err := this.fromEnd(index)
+	if err != nil {
+		return nil, &trace, err
+	}
+	rv, err := this.index(index, &trace)
+	if rv != nil {
+		this.bindChild(strconv.Itoa(index), rv)
+	}
+	return rv, &trace, err
+}
+
+// Evaluate this Expression against a document as Eval() does, also returning a Trace
+// describing how each path in the expression was resolved.
+func (this *Expression) Explain(doc *Value) (*Value, *Trace, error) {
+	trace := Trace{}
+	rv, err := this.eval(&evalContext{doc: doc, trace: &trace})
+	return rv, &trace, err
+}
+
+// explainSteps resolves a dotted path one part at a time, as resolvePath() does,
+// recording each part in trace.
+func (this *Value) explainSteps(path string, trace *Trace) (*Value, error) {
+	val := this
+	for _, step := range strings.Split(path, ".") {
+		var next *Value
+		var err error
+		index, ierr := strconv.Atoi(step)
+		if ierr == nil && val.Type() == ARRAY {
+			index, err = val.fromEnd(index)
+			if err == nil {
+				next, err = val.index(index, trace)
+			}
+		} else {
+			next, err = val.path(step, trace)
+		}
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return nil, &Undefined{path}
+			}
+			return nil, err
+		}
+		val.bindChild(step, next)
+		val = next
+	}
+	return val, nil
+}
+
+// begin records a new step, it is safe to call on a nil Trace.
+func (this *Trace) begin(path string) *TraceStep {
+	if this == nil {
+		return nil
+	}
+	rv := &TraceStep{Path: path}
+	this.Steps = append(this.Steps, rv)
+	return rv
+}
+
+func (this *TraceStep) found(source int) {
+	if this != nil {
+		this.Source = source
+	}
+}
+
+func (this *TraceStep) fallback(source int) {
+	if this != nil {
+		this.Fallbacks = append(this.Fallbacks, source)
+	}
+}
+
+// scanned records the bytes of raw scanned to find res.  When res was found
+// inside raw, the scan stopped at its end, otherwise all of raw was scanned.
+func (this *TraceStep) scanned(raw, res []byte) {
+	if this == nil {
+		return
+	}
+	this.BytesScanned = len(raw)
+	if res != nil {
+		end := cap(raw) - cap(res) + len(res)
+		if end >= len(res) && end <= len(raw) && bytes.Equal(raw[end-len(res):end], res) {
+			this.BytesScanned = end
+		}
+	}
+}
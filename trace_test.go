@@ -0,0 +1,94 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestExplainPath(t *testing.T) {
+	val := NewValueFromBytes([]byte(`{"a":1,"b":{"c":[10,20]},"d":"x"}`))
+	val.SetPath("e", 5.0)
+
+	_, trace, err := val.ExplainPath("b")
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if trace.String() != "b: raw (scanned 24 bytes, after alias)" {
+		t.Errorf("Unexpected trace %s", trace)
+	}
+
+	_, trace, _ = val.ExplainPath("e")
+	if trace.String() != "e: alias" {
+		t.Errorf("Unexpected trace %s", trace)
+	}
+
+	_, trace, err = val.ExplainPath("z")
+	if _, ok := err.(*Undefined); !ok {
+		t.Errorf("Expected *Undefined, got %v", err)
+	}
+	if trace.String() != "z: undefined (scanned 33 bytes, after alias)" {
+		t.Errorf("Unexpected trace %s", trace)
+	}
+
+	// dotted paths are resolved one part at a time, as by Path()
+	c1, trace, err := val.ExplainPath("b.c.1")
+	if err != nil || c1.Value() != 20.0 {
+		t.Errorf("Expected 20, got %v, %v", c1, err)
+	}
+	if len(trace.Steps) != 4 || trace.Steps[0].Source != TRACE_UNDEFINED {
+		t.Fatalf("Unexpected trace %s", trace)
+	}
+	for i, path := range []string{"b", "c", "1"} {
+		if step := trace.Steps[i+1]; step.Path != path || step.Source != TRACE_RAW {
+			t.Errorf("Expected %s from raw, got %s", path, step)
+		}
+	}
+	_, _, err = val.ExplainPath("b.x")
+	if undefined, ok := err.(*Undefined); !ok || undefined.Path != "b.x" {
+		t.Errorf("Expected *Undefined for b.x, got %v", err)
+	}
+
+	parsed := NewValue([]interface{}{1.0, 2.0})
+	_, trace, _ = parsed.ExplainIndex(1)
+	if trace.String() != "1: parsed" {
+		t.Errorf("Unexpected trace %s", trace)
+	}
+
+	// negative indexes count from the end, as for Index()
+	last, trace, err := parsed.ExplainIndex(-1)
+	if err != nil || last.Value() != 2.0 || trace.String() != "1: parsed" {
+		t.Errorf("Expected 2 from parsed, got %v, %v\n%s", last, err, trace)
+	}
+	c, _, err := val.ExplainPath("b.c.-2")
+	if err != nil || c.Value() != 10.0 {
+		t.Errorf("Expected 10, got %v, %v", c, err)
+	}
+}
+
+func TestExplainExpression(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"a":1,"b":{"c":[10,20]}}`))
+	e, _ := ParseExpression(`b.c[1] > a`)
+	result, trace, err := e.Explain(doc)
+	if err != nil || result.Value() != true {
+		t.Errorf("Expected true, got %v, %v", result, err)
+	}
+	if len(trace.Steps) != 4 {
+		t.Fatalf("Expected 4 steps, got %d", len(trace.Steps))
+	}
+	for i, source := range []int{TRACE_RAW, TRACE_RAW, TRACE_RAW, TRACE_RAW} {
+		if trace.Steps[i].Source != source {
+			t.Errorf("Expected source %d, got %d for step %d", source, trace.Steps[i].Source, i)
+		}
+	}
+	if trace.BytesScanned() != 24+12+6+6 {
+		t.Errorf("Expected %d bytes scanned, got %d\n%s", 24+12+6+6, trace.BytesScanned(), trace)
+	}
+}
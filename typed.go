@@ -0,0 +1,82 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package dparval
+
+import (
+	json "github.com/dustin/gojson"
+)
+
+// Convert the Value into a T.
+//
+// If T is the native Go representation of the Value (as returned by Value()), or *Value itself,
+// no conversion is necessary.  If *T implements ValueUnmarshaler, it is used.  Otherwise the
+// Value is serialized and decoded into T using the rules of encoding/json.
+func As[T any](v *Value) (T, error) {
+	var rv T
+	switch target := any(&rv).(type) {
+	case **Value:
+		*target = v
+		return rv, nil
+	case ValueUnmarshaler:
+		err := target.UnmarshalValue(v)
+		return rv, err
+	}
+	if native, ok := v.Value().(T); ok {
+		return native, nil
+	}
+	err := json.Unmarshal(v.Bytes(), &rv)
+	return rv, err
+}
+
+// Create a new Value from a T.
+//
+// Types supported by NewValue() (including ValueMarshaler) are converted directly, without
+// serialization.  Otherwise t is serialized using the rules of encoding/json, and the Value
+// is created from the resulting bytes (which are not parsed until needed).
+func FromTyped[T any](t T) *Value {
+	switch val := any(t).(type) {
+	case nil, bool, float64, string, []interface{}, map[string]interface{}, *Value, ValueMarshaler:
+		return NewValue(val)
+	}
+	bytes, err := json.Marshal(t)
+	if err != nil {
+		panic(err.Error())
+	}
+	return NewValueFromBytes(bytes)
+}
+
+// Typed wraps a Value which is known to hold a T, giving typed access while preserving the
+// (possibly unparsed) Value underneath.
+type Typed[T any] struct {
+	value *Value
+}
+
+// Wrap the Value, which is expected to hold a T.
+func NewTyped[T any](v *Value) Typed[T] {
+	return Typed[T]{v}
+}
+
+// Convert the underlying Value into a T.
+func (this Typed[T]) Get() (T, error) {
+	return As[T](this.value)
+}
+
+// Replace the underlying Value with a new Value created from t.
+func (this *Typed[T]) Set(t T) {
+	this.value = FromTyped(t)
+}
+
+// The underlying Value.
+func (this Typed[T]) Value() *Value {
+	return this.value
+}
@@ -0,0 +1,87 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package dparval
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testAddress struct {
+	Street string `json:"street"`
+	Number int    `json:"number"`
+}
+
+func TestAs(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"street":"sutton oaks","number":7}`))
+
+	address, err := As[testAddress](doc)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if address != (testAddress{"sutton oaks", 7}) {
+		t.Errorf("Expected sutton oaks 7, got %v", address)
+	}
+
+	street, _ := doc.Path("street")
+	s, err := As[string](street)
+	if err != nil || s != "sutton oaks" {
+		t.Errorf("Expected sutton oaks, got %v (%v)", s, err)
+	}
+
+	m, err := As[map[string]interface{}](doc)
+	if err != nil || !reflect.DeepEqual(m, map[string]interface{}{"street": "sutton oaks", "number": 7.0}) {
+		t.Errorf("Unexpected map %v (%v)", m, err)
+	}
+
+	same, _ := As[*Value](doc)
+	if same != doc {
+		t.Errorf("Expected the same *Value")
+	}
+
+	contact, err := As[testContact](NewValueFromBytes([]byte(`{"name":"marty"}`)))
+	if err != nil || contact.Name != "marty" {
+		t.Errorf("Expected marty, got %v (%v)", contact, err)
+	}
+
+	_, err = As[int](street)
+	if err == nil {
+		t.Errorf("Expected error converting string to int")
+	}
+}
+
+func TestFromTyped(t *testing.T) {
+	val := FromTyped(testAddress{"sutton oaks", 7})
+	if val.Type() != OBJECT {
+		t.Errorf("Expected OBJECT, got %d", val.Type())
+	}
+	if string(val.Bytes()) != `{"street":"sutton oaks","number":7}` {
+		t.Errorf("Unexpected bytes %s", string(val.Bytes()))
+	}
+
+	val = FromTyped(3)
+	if val.Value() != 3.0 {
+		t.Errorf("Expected 3, got %v", val.Value())
+	}
+
+	typed := NewTyped[testAddress](NewValueFromBytes([]byte(`{"street":"main","number":1}`)))
+	address, err := typed.Get()
+	if err != nil || address.Street != "main" {
+		t.Errorf("Expected main, got %v (%v)", address, err)
+	}
+	typed.Set(testAddress{"elm", 2})
+	number, _ := typed.Value().Path("number")
+	if number.Value() != 2.0 {
+		t.Errorf("Expected 2, got %v", number.Value())
+	}
+}
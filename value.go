@@ -10,8 +10,10 @@
 package dparval
 
 import (
+	stdjson "encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	jsonpointer "github.com/dustin/go-jsonpointer"
 	json "github.com/dustin/gojson"
@@ -31,6 +33,25 @@ func (this *Undefined) Error() string {
 	return fmt.Sprint("not defined")
 }
 
+// When a lookup into the raw bytes of a Value fails because those bytes are
+// malformed, the return error will be *SyntaxError, describing where the
+// problem was found.
+type SyntaxError struct {
+	Path   string // the path or index being resolved (if known)
+	Offset int64  // byte offset of the problem in the raw bytes
+	Line   int    // 1-based line of the problem
+	Column int    // 1-based column (in bytes) of the problem
+	msg    string
+}
+
+// Description of the problem, including its position in the raw bytes.
+func (this *SyntaxError) Error() string {
+	if this.Path != "" {
+		return fmt.Sprintf("error resolving %s: %s at line %d, column %d (offset %d)", this.Path, this.msg, this.Line, this.Column, this.Offset)
+	}
+	return fmt.Sprintf("%s at line %d, column %d (offset %d)", this.msg, this.Line, this.Column, this.Offset)
+}
+
 // A channel of *Value objects
 type ValueChannel chan *Value
 
@@ -44,10 +65,25 @@ type Value struct {
 	alias       map[string]*Value
 	parsedType  int
 	attachments map[string]interface{}
+	options     *ParseOptions
+	order       []string
+	annotations map[string]map[string]interface{}
+	schema      *Schema           // bound by BindSchema()
+	schemaFrom  *schemaSource     // where this Value was found inside a Value with a Schema, see bindChild()
+	constraints []*Constraint     // registered by AddConstraint()
+	score       float64           // set by SetScore()
+	children    map[string]*Value // Values found in raw, kept so that changes made to them are seen by this Value
+	rawCount    int               // the number of elements in raw plus one, once they have been counted
+	shared      bool              // held by a SharedValues registry (or a singleton), so must not be modified
 }
 
 // Create a new Value object from an existing object.  MUST be one of the types supported by JSON.
 // If the argument passed is an existing *Value, that will be returned without creating a new object.
+// If the argument implements ValueMarshaler, the result of MarshalValue() is returned.
+// An encoding/json.RawMessage (anywhere inside the argument) is kept as raw bytes, as by NewValueFromRawMessage(),
+// and an encoding/json.Number is kept exactly, as a json.Number is.
+// Non-finite numbers are handled according to NonFinitePolicy(), nil maps and slices
+// according to NilCollectionPolicy().
 func NewValue(val interface{}) *Value {
 	switch val := val.(type) {
 	case nil:
@@ -55,20 +91,85 @@ func NewValue(val interface{}) *Value {
 	case bool:
 		return newBooleanValue(val)
 	case float64:
+		if isNonFinite(val) {
+			return newNonFiniteValue(val)
+		}
 		return newNumberValue(val)
 	case string:
 		return newStringValue(val)
 	case []interface{}:
+		if val == nil && nilCollectionsAsNull() {
+			return newNullValue()
+		}
 		return newArrayValue(val)
 	case map[string]interface{}:
+		if val == nil && nilCollectionsAsNull() {
+			return newNullValue()
+		}
 		return newObjectValue(val)
+	case json.Number:
+		return newExactNumberValue(val)
 	case *Value:
 		return val
+	case stdjson.RawMessage:
+		return NewValueFromRawMessage(val)
+	case ValueMarshaler:
+		return val.MarshalValue()
 	default:
+		// encoding/json.Number, as found in trees decoded with UseNumber()
+		if n, ok := val.(stdjson.Number); ok {
+			return newExactNumberValue(json.Number(n))
+		}
 		panic(fmt.Sprintf("Cannot create value for type %T", val))
 	}
 }
 
+// Create a new Value object of type OBJECT with no keys, ready to be filled with SetPath().
+func NewEmptyObjectValue() *Value {
+	return NewObjectValueCap(0)
+}
+
+// Create a new Value object of type OBJECT with no keys, with room for n keys
+// to be added with SetPath() before it needs to grow.
+func NewObjectValueCap(n int) *Value {
+	rv := Value{
+		parsedType:  OBJECT,
+		parsedValue: make(map[string]*Value, n),
+	}
+	return &rv
+}
+
+// Create a new Value object of type ARRAY with no elements.
+func NewEmptyArrayValue() *Value {
+	rv := Value{
+		parsedType:  ARRAY,
+		parsedValue: make([]*Value, 0),
+	}
+	return &rv
+}
+
+// Create a new Value object from a tree already decoded by another JSON library, made of nil,
+// bool, float64, json.Number (or the Number of encoding/json, as decoded with UseNumber()), string,
+// []interface{} and map[string]interface{}.  Unlike NewValue(),
+// the tree is not converted up front: each object or array inside it is converted when it is
+// first accessed.  Other types are only found when accessed, and then panic as in NewValue().
+//
+// The tree is not copied, so it must not be modified after this call.  Changes made with
+// SetPath() and SetIndex() are kept alongside the tree, as they are for raw bytes.
+func NewValueFromParsed(tree interface{}) *Value {
+	switch tree := tree.(type) {
+	case map[string]interface{}:
+		if tree != nil {
+			return &Value{parsedType: OBJECT, parsedValue: tree}
+		}
+	case []interface{}:
+		if tree != nil {
+			return &Value{parsedType: ARRAY, parsedValue: tree}
+		}
+	}
+	return NewValue(tree)
+}
+
 // Create a new Value object from a slice of bytes. (this need not be valid JSON)
 func NewValueFromBytes(bytes []byte) *Value {
 	rv := Value{
@@ -77,6 +178,10 @@ func NewValueFromBytes(bytes []byte) *Value {
 		parsedValue: nil,
 		alias:       nil,
 	}
+	if literalType, ok := matchLiteral(bytes); ok {
+		rv.parsedType = literalType
+		return &rv
+	}
 	err := json.Validate(bytes)
 	if err != nil {
 		rv.parsedType = NOT_JSON
@@ -100,31 +205,71 @@ func (this *Value) Type() int {
 //         2. If no alias has been set for this path, and the value has already been parsed, the value for that key in the parsed object is returned.
 //         3. If no alias has been set, and the value has not yet been parsed, the value is accessed in the byte array using a jsonpointer expression.
 //         4. If none of these successfully find a value, the return value is nil, and the return error is *Undefined.
+//
+// If no property has the name path, and path contains dots, it is resolved one step at a time, so
+// Path("address.street") is Path("address") followed by Path("street").  Integer steps index into
+// arrays, as in "items.0.name".  Each step is looked up as above, so raw bytes are still not parsed.
 func (this *Value) Path(path string) (*Value, error) {
+	rv, err := this.path(path, nil)
+	if _, undefined := err.(*Undefined); undefined && strings.Contains(path, ".") {
+		chain, err := resolveChain(this, path)
+		if err != nil {
+			return nil, err
+		}
+		return chain[len(chain)-1], nil
+	}
+	if rv != nil {
+		this.bindChild(path, rv)
+	}
+	return rv, err
+}
+
+// path implements Path(), recording each source consulted in trace (if not nil).
+func (this *Value) path(path string, trace *Trace) (*Value, error) {
+	step := trace.begin(path)
 	// aliases always have priority
 
 	if this.alias != nil {
 		result, ok := this.alias[path]
 		if ok {
+			step.found(TRACE_ALIAS)
 			return result, nil
 		}
+		step.fallback(TRACE_ALIAS)
 	}
 	// next we already parsed, used that
 	switch parsedValue := this.parsedValue.(type) {
 	case map[string]*Value:
 		result, ok := parsedValue[path]
 		if ok {
+			step.found(TRACE_PARSED)
 			return result, nil
 		}
+		step.fallback(TRACE_PARSED)
 	}
 	// finally, consult the raw bytes
+	if child, ok := this.children[path]; ok {
+		step.found(TRACE_RAW)
+		return child, nil
+	}
 	if this.raw != nil {
-		res, err := jsonpointer.Find(this.raw, "/"+path)
+		res, err := jsonpointer.Find(this.raw, "/"+escapePointer(path))
+		step.scanned(this.raw, res)
 		if err != nil {
-			return nil, err
+			return nil, this.locateSyntaxError(path, err)
 		}
 		if res != nil {
-			return NewValueFromBytes(res), nil
+			step.found(TRACE_RAW)
+			return this.rememberChild(path, this.newChild(res)), nil
+		}
+		if this.parsedType == NOT_JSON {
+			return nil, this.locateSyntaxError(path, &Undefined{path})
+		}
+	} else if parsedValue, ok := this.parsedValue.(map[string]interface{}); ok {
+		// the raw bytes were dropped after parsing
+		if result, ok := parsedValue[path]; ok {
+			step.found(TRACE_PARSED)
+			return this.rememberChild(path, this.newParsedChild(result)), nil
 		}
 	}
 
@@ -132,34 +277,49 @@ func (this *Value) Path(path string) (*Value, error) {
 }
 
 // If this Value is of type OBJECT, this method attempts to store an alias for this value at the specified path.
-// If this Value is not of type OBJECT, nothing is done.
+// If this Value is not of type OBJECT, nothing is done, use SetPathErr() to be told why a change was
+// rejected.  The path is a single property name, even if it contains dots, to store a value at a
+// dotted path creating any objects along it, use SetDeepPath().
 //
 // NOTE: All incoming values are brought into the type system, so the val argument must be compatible with the NewValue() method.
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+// If a Schema is bound to this Value (see BindSchema()) which does not allow val, or a constraint
+// registered with AddConstraint() would be violated, this panics.
 func (this *Value) SetPath(path string, val interface{}) {
-
+	this.checkMutable()
 	if this.parsedType == OBJECT {
-		switch parsedValue := this.parsedValue.(type) {
-		case map[string]*Value:
-			// if we've already parsed the object, store it there
-			switch val := val.(type) {
-			case *Value:
-				parsedValue[path] = val
-			default:
-				parsedValue[path] = NewValue(val)
-			}
-		case nil:
-			// if not store it in alias
-			if this.alias == nil {
-				this.alias = make(map[string]*Value)
-			}
-			switch val := val.(type) {
-			case *Value:
-				this.alias[path] = val
-			default:
-				this.alias[path] = NewValue(val)
-			}
+		val = this.checkSetPath(path, val)
+		this.checkConstraints(path, val)
+		this.setMember(path, val)
+	}
+}
 
+// setMember stores val at path inside this OBJECT, once any Schema and constraints are checked.
+func (this *Value) setMember(path string, val interface{}) {
+	switch parsedValue := this.parsedValue.(type) {
+	case map[string]*Value:
+		this.trackKey(path)
+		// if we've already parsed the object, store it there
+		switch val := val.(type) {
+		case *Value:
+			parsedValue[path] = val
+		default:
+			parsedValue[path] = NewValue(val)
 		}
+	case nil, map[string]interface{}:
+		this.trackKey(path)
+		// if not (or only parsed by Value()) store it in alias
+		if this.alias == nil {
+			this.alias = make(map[string]*Value)
+		}
+		delete(this.children, path)
+		switch val := val.(type) {
+		case *Value:
+			this.alias[path] = val
+		default:
+			this.alias[path] = NewValue(val)
+		}
+
 	}
 }
 
@@ -172,74 +332,137 @@ func (this *Value) SetPath(path string, val interface{}) {
 //         2. If no alias has been set for this index, and the value has already been parsed, the value for that index in the parsed array is returned.
 //         3. If no alias has been set, and the value has not yet been parsed, the value is accessed in the byte array using a jsonpointer expression.
 //         4. If none of these successfully find a value, the return value is nil, and the return error is *Undefined.
+//
+// A negative index counts from the end of the array, so Index(-1) returns the last element.  If the
+// array has not been parsed, its elements are counted by scanning the raw bytes (see CountPath()).
 func (this *Value) Index(index int) (*Value, error) {
+	index, err := this.fromEnd(index)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := this.index(index, nil)
+	if rv != nil {
+		this.bindChild(strconv.Itoa(index), rv)
+	}
+	return rv, err
+}
+
+// fromEnd converts a negative index into an array to the index it counts from the end.
+func (this *Value) fromEnd(index int) (int, error) {
+	if index < 0 && this.parsedType == ARRAY {
+		n, err := this.count("")
+		if err != nil {
+			return 0, err
+		}
+		index += n
+	}
+	return index, nil
+}
+
+// index implements Index(), recording each source consulted in trace (if not nil).
+func (this *Value) index(index int, trace *Trace) (*Value, error) {
+	step := trace.begin(strconv.Itoa(index))
 	// aliases always have priority
 	if this.alias != nil {
 		result, ok := this.alias[strconv.Itoa(index)]
 		if ok {
+			step.found(TRACE_ALIAS)
 			return result, nil
 		}
+		step.fallback(TRACE_ALIAS)
 	}
 	// next we already parsed, used that
 	switch parsedValue := this.parsedValue.(type) {
 	case []*Value:
 		if index >= 0 && index < len(parsedValue) {
 			result := parsedValue[index]
+			step.found(TRACE_PARSED)
 			return result, nil
 		} else {
 			// this way it behaves consistent with jsonpointer below
+			step.fallback(TRACE_PARSED)
 			return nil, &Undefined{}
 		}
 	}
 	// finally, consult the raw bytes
+	if child, ok := this.children[strconv.Itoa(index)]; ok {
+		step.found(TRACE_RAW)
+		return child, nil
+	}
 	if this.raw != nil {
 		res, err := jsonpointer.Find(this.raw, "/"+strconv.Itoa(index))
+		step.scanned(this.raw, res)
 		if err != nil {
-			return nil, err
+			return nil, this.locateSyntaxError(strconv.Itoa(index), err)
 		}
 		if res != nil {
-			return NewValueFromBytes(res), nil
+			step.found(TRACE_RAW)
+			return this.rememberChild(strconv.Itoa(index), this.newChild(res)), nil
+		}
+		if this.parsedType == NOT_JSON {
+			return nil, this.locateSyntaxError(strconv.Itoa(index), &Undefined{})
+		}
+	} else if parsedValue, ok := this.parsedValue.([]interface{}); ok {
+		// the raw bytes were dropped after parsing
+		if index >= 0 && index < len(parsedValue) {
+			step.found(TRACE_PARSED)
+			return this.rememberChild(strconv.Itoa(index), this.newParsedChild(parsedValue[index])), nil
 		}
 	}
 	return nil, &Undefined{}
 }
 
 // If this Value is of type ARRAY, this method attempts to store an alias for this value at the specified index.
-// If this Value is not of type ARRAY, nothing is done.
+// If this Value is not of type ARRAY, or the index is outside of the array, nothing is done, use
+// SetIndexErr() to be told why a change was rejected.  To add elements use SetIndexExtend(), Append() or Insert().
 //
 // NOTE: All incoming values are brought into the type system, so the val argument must be compatible with the NewValue() method.
+// Values held by a SharedValues registry must not be modified, this panics if called on one.
+// If a Schema is bound to this Value (see BindSchema()) which does not allow val, this panics.
 func (this *Value) SetIndex(index int, val interface{}) {
+	this.checkMutable()
 	if this.parsedType == ARRAY && index >= 0 {
-		switch parsedValue := this.parsedValue.(type) {
-		case []*Value:
-			if index < len(parsedValue) {
-				// if we've already parsed the object, store it there
-				switch val := val.(type) {
-				case *Value:
-					parsedValue[index] = val
-				default:
-					parsedValue[index] = NewValue(val)
-				}
-			}
-		case nil:
-			// if not store it in alias
-			if this.alias == nil {
-				this.alias = make(map[string]*Value)
-			}
+		if n, err := this.count(""); err != nil || index >= n {
+			return
+		}
+		val = this.checkSetIndex(index, val)
+		this.setElement(index, val)
+	}
+}
+
+// setElement stores val at index inside this ARRAY, once any Schema is checked.
+func (this *Value) setElement(index int, val interface{}) {
+	switch parsedValue := this.parsedValue.(type) {
+	case []*Value:
+		if index < len(parsedValue) {
+			// if we've already parsed the object, store it there
 			switch val := val.(type) {
 			case *Value:
-				this.alias[strconv.Itoa(index)] = val
+				parsedValue[index] = val
 			default:
-				this.alias[strconv.Itoa(index)] = NewValue(val)
+				parsedValue[index] = NewValue(val)
 			}
-
 		}
+	case nil, []interface{}:
+		// if not (or only parsed by Value()) store it in alias
+		if this.alias == nil {
+			this.alias = make(map[string]*Value)
+		}
+		delete(this.children, strconv.Itoa(index))
+		switch val := val.(type) {
+		case *Value:
+			this.alias[strconv.Itoa(index)] = val
+		default:
+			this.alias[strconv.Itoa(index)] = NewValue(val)
+		}
+
 	}
 }
 
 // Attach an arbitrary object to this Value with the specified key.
 // Any existing value attached with this same key will be overwritten.
 func (this *Value) SetAttachment(key string, val interface{}) {
+	this.checkMutable()
 	if this.attachments == nil {
 		this.attachments = make(map[string]interface{})
 	}
@@ -270,24 +493,27 @@ func (this *Value) RemoveAttachment(key string) interface{} {
 // If this Value has not yet been parsed, it will be parsed at this time.
 //
 // NOTE:  If the Value is of type NOT_JSON, null will be returned.
+// If the Value contains itself this never returns, see CheckCycles().
 func (this *Value) Value() interface{} {
 	if this.parsedValue != nil || this.parsedType == NULL {
 		rv := devalue(this.parsedValue)
-		if this.alias != nil {
-			overlayAlias(rv, this.alias)
+		if overlay := this.overlay(); overlay != nil {
+			// we cannot damage the original parsed value
+			rv = safeCopy(rv)
+			overlayAlias(rv, overlay)
 		}
 		return rv
 	} else if this.parsedType != NOT_JSON {
-		err := json.Unmarshal(this.raw, &this.parsedValue)
+		err := this.parseRaw()
 		if err != nil {
 			panic("unexpected parse error on valid JSON")
 		}
 		// if there are any aliases, we must make a safe copy
 		// and then overlay them
-		if this.alias != nil {
+		if overlay := this.overlay(); overlay != nil {
 			// we cannot damange the original parsed value
 			rv := safeCopy(this.parsedValue)
-			overlayAlias(rv, this.alias)
+			overlayAlias(rv, overlay)
 			return rv
 		} else {
 			// otherwise its safe to return directly
@@ -298,21 +524,37 @@ func (this *Value) Value() interface{} {
 	}
 }
 
+// Return the JSON encoding of this Value, the raw bytes it was created from if it has not been modified.
 func (this *Value) Bytes() []byte {
+	hooks := currentSpanHooks()
+	if hooks == nil {
+		return this.encode()
+	}
+	span := hooks.start(SERIALIZE_OPERATION)
+	rv := this.encode()
+	hooks.end(span, SERIALIZE_OPERATION, len(rv), nil)
+	return rv
+}
+
+// encode returns the bytes for Bytes(), without reporting a span.
+func (this *Value) encode() []byte {
 	switch this.parsedType {
 	case OBJECT:
-		if this.parsedValue == nil && this.alias == nil && this.raw != nil {
+		if this.parsedValue == nil && this.raw != nil && !this.modified() {
 			return this.raw
 		}
+		if this.documentOrder() {
+			return this.orderedBytes()
+		}
 		if this.parsedValue == nil {
-			err := json.Unmarshal(this.raw, &this.parsedValue)
+			err := this.parseRaw()
 			if err != nil {
 				panic("unexpected parse error on valid JSON")
 			}
 		}
 		rv := safeCopy(this.parsedValue)
-		if this.alias != nil {
-			overlayAlias(rv, this.alias)
+		if overlay := this.overlay(); overlay != nil {
+			overlayAlias(rv, overlay)
 		}
 		// now we just need to serialize rv
 		var togo map[string]*json.RawMessage
@@ -320,7 +562,7 @@ func (this *Value) Bytes() []byte {
 		case map[string]*Value:
 			togo = make(map[string]*json.RawMessage, len(rv))
 			for k, v := range rv {
-				innerBytes := v.Bytes()
+				innerBytes := v.encode()
 				rawMessage := json.RawMessage(innerBytes)
 				togo[k] = &rawMessage
 			}
@@ -343,18 +585,18 @@ func (this *Value) Bytes() []byte {
 		}
 		return final
 	case ARRAY:
-		if this.parsedValue == nil && this.alias == nil && this.raw != nil {
+		if this.parsedValue == nil && this.raw != nil && !this.modified() {
 			return this.raw
 		}
 		if this.parsedValue == nil {
-			err := json.Unmarshal(this.raw, &this.parsedValue)
+			err := this.parseRaw()
 			if err != nil {
 				panic("unexpected parse error on valid JSON")
 			}
 		}
 		rv := safeCopy(this.parsedValue)
-		if this.alias != nil {
-			overlayAlias(rv, this.alias)
+		if overlay := this.overlay(); overlay != nil {
+			overlayAlias(rv, overlay)
 		}
 		// now we just need to serialize rv
 		var togo []*json.RawMessage
@@ -362,7 +604,7 @@ func (this *Value) Bytes() []byte {
 		case []*Value:
 			togo = make([]*json.RawMessage, len(rv))
 			for i, v := range rv {
-				innerBytes := v.Bytes()
+				innerBytes := v.encode()
 				rawMessage := json.RawMessage(innerBytes)
 				togo[i] = &rawMessage
 			}
@@ -400,6 +642,41 @@ func (this *Value) Bytes() []byte {
 	}
 }
 
+// elements returns the elements of an ARRAY Value without parsing them.
+func (this *Value) elements() (ValueCollection, error) {
+	if this.parsedType != ARRAY {
+		return nil, &TypeMismatch{Expected: ARRAY, Actual: this.parsedType}
+	}
+	switch parsedValue := this.parsedValue.(type) {
+	case []*Value:
+		return ValueCollection(parsedValue), nil
+	}
+	overlay := this.overlay()
+	if parsedValue, ok := this.parsedValue.([]interface{}); ok && this.raw == nil {
+		// the raw bytes were dropped after parsing
+		rv := make(ValueCollection, len(parsedValue))
+		for i, v := range parsedValue {
+			rv[i] = this.newParsedChild(v)
+			if alias, ok := overlay[strconv.Itoa(i)]; ok {
+				rv[i] = alias
+			}
+		}
+		return rv, nil
+	}
+	raw, err := arrayElements(this.raw)
+	if err != nil {
+		return nil, err
+	}
+	rv := make(ValueCollection, len(raw))
+	for i, r := range raw {
+		rv[i] = this.newChild(r)
+		if alias, ok := overlay[strconv.Itoa(i)]; ok {
+			rv[i] = alias
+		}
+	}
+	return rv, nil
+}
+
 // The types supported by Value
 const (
 	NOT_JSON = iota
@@ -451,6 +728,57 @@ func safeCopy(base interface{}) interface{} {
 	}
 }
 
+// rememberChild keeps a Value found in the raw bytes of this Value, so that
+// navigating to it again returns the same Value, and changes made to it are
+// seen when this Value is serialized.
+func (this *Value) rememberChild(key string, child *Value) *Value {
+	if child.parsedType != ARRAY && child.parsedType != OBJECT {
+		// scalars are immutable, there is nothing to remember
+		return child
+	}
+	if this.children == nil {
+		this.children = make(map[string]*Value)
+	}
+	this.children[key] = child
+	return child
+}
+
+// modified determines if this Value, or any Value remembered inside of it,
+// has been changed since it was created from raw bytes.
+func (this *Value) modified() bool {
+	if this.raw == nil || this.alias != nil {
+		return true
+	}
+	for _, child := range this.children {
+		if child.modified() {
+			return true
+		}
+	}
+	return false
+}
+
+// overlay returns the Values which must be overlaid on the parsed or raw
+// contents of this Value: the aliases, and any remembered children which
+// have been modified.  The return value is nil if there are none.
+func (this *Value) overlay() map[string]*Value {
+	var rv map[string]*Value
+	for k, child := range this.children {
+		if child.modified() {
+			if rv == nil {
+				rv = make(map[string]*Value, len(this.alias)+1)
+			}
+			rv[k] = child
+		}
+	}
+	if rv == nil {
+		return this.alias
+	}
+	for k, v := range this.alias {
+		rv[k] = v
+	}
+	return rv
+}
+
 func overlayAlias(base interface{}, alias map[string]*Value) {
 	switch base := base.(type) {
 	case map[string]interface{}:
@@ -475,6 +803,40 @@ func overlayAlias(base interface{}, alias map[string]*Value) {
 	}
 }
 
+// The NULL, TRUE and FALSE Values produced while evaluating expressions are these shared
+// singletons, rather than being allocated each time.  They never escape an evaluation (see
+// unshared()), as a caller could otherwise set attachments on them.
+var (
+	nullSingleton  = &Value{parsedType: NULL, shared: true}
+	trueSingleton  = &Value{parsedType: BOOLEAN, parsedValue: true, shared: true}
+	falseSingleton = &Value{parsedType: BOOLEAN, parsedValue: false, shared: true}
+)
+
+// singletonValue is like NewValue(), but returns the shared singleton for nil, true and false.
+func singletonValue(val interface{}) *Value {
+	switch val := val.(type) {
+	case nil:
+		return nullSingleton
+	case bool:
+		if val {
+			return trueSingleton
+		}
+		return falseSingleton
+	}
+	return NewValue(val)
+}
+
+// unshared returns val, or a new Value in place of one of the singletons.
+func unshared(val *Value) *Value {
+	switch val {
+	case nullSingleton:
+		return newNullValue()
+	case trueSingleton, falseSingleton:
+		return newBooleanValue(val.parsedValue.(bool))
+	}
+	return val
+}
+
 func newNullValue() *Value {
 	rv := Value{
 		parsedType: NULL,
@@ -498,6 +860,18 @@ func newNumberValue(val float64) *Value {
 	return &rv
 }
 
+// The largest integer which a float64 represents exactly.  Decoders of formats with
+// integers (such as Avro and CBOR) keep larger ones exactly, as a json.Number.
+const maxExactInteger = 1 << 53
+
+func newExactNumberValue(val json.Number) *Value {
+	rv := Value{
+		parsedType:  NUMBER,
+		parsedValue: val,
+	}
+	return &rv
+}
+
 func newStringValue(val string) *Value {
 	rv := Value{
 		parsedType:  STRING,
@@ -561,5 +935,60 @@ func identifyType(bytes []byte) int {
 		}
 	}
 	panic("Unable to identify type of valid JSON")
-	return -1
+}
+
+// escapePointer escapes a property name for use in a jsonpointer expression.
+func escapePointer(path string) string {
+	return strings.Replace(strings.Replace(path, "~", "~0", -1), "/", "~1", -1)
+}
+
+// locateSyntaxError determines if the raw bytes of this Value are malformed,
+// and if so returns a *SyntaxError describing the position of the problem.
+// If the raw bytes are valid, the original error is returned unchanged.
+func (this *Value) locateSyntaxError(path string, orig error) error {
+	err := json.Validate(this.raw)
+	if err == nil {
+		return orig
+	}
+	return newSyntaxError(this.raw, path, err)
+}
+
+// newSyntaxError builds a *SyntaxError for the validation error err found
+// in bytes.
+func newSyntaxError(bytes []byte, path string, err error) *SyntaxError {
+	offset := int64(len(bytes))
+	if err, ok := err.(*json.SyntaxError); ok {
+		offset = err.Offset
+	}
+	return syntaxErrorAt(bytes, path, offset, err.Error())
+}
+
+// syntaxErrorAt builds a *SyntaxError for a problem found in bytes after
+// reading offset bytes.
+func syntaxErrorAt(bytes []byte, path string, offset int64, msg string) *SyntaxError {
+	rv := SyntaxError{
+		Path:   path,
+		Offset: offset,
+		msg:    msg,
+	}
+	rv.Line, rv.Column = position(bytes, rv.Offset)
+	return &rv
+}
+
+// position computes the 1-based line and column of the byte which caused
+// a problem after reading offset bytes.
+func position(bytes []byte, offset int64) (int, int) {
+	if offset > int64(len(bytes)) {
+		offset = int64(len(bytes))
+	}
+	line, column := 1, 1
+	for i := int64(0); i < offset-1; i++ {
+		if bytes[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
 }
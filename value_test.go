@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	// "time"
 
@@ -110,6 +111,9 @@ func TestIndexAccess(t *testing.T) {
 		{0, &Value{raw: []byte(`"marty"`), parsedType: STRING}, nil},
 		{1, &Value{raw: []byte(`{"type":"contact"}`), parsedType: OBJECT}, nil},
 		{2, nil, &Undefined{}},
+		{-1, &Value{raw: []byte(`{"type":"contact"}`), parsedType: OBJECT}, nil},
+		{-2, &Value{raw: []byte(`"marty"`), parsedType: STRING}, nil},
+		{-3, nil, &Undefined{}},
 	}
 
 	for _, test := range tests {
@@ -132,6 +136,8 @@ func TestIndexAccess(t *testing.T) {
 		{0, &Value{parsedValue: "marty", parsedType: STRING}, nil},
 		{1, &Value{parsedValue: map[string]*Value{"type": NewValue("contact")}, parsedType: OBJECT}, nil},
 		{2, nil, &Undefined{}},
+		{-2, &Value{parsedValue: "marty", parsedType: STRING}, nil},
+		{-3, nil, &Undefined{}},
 	}
 
 	for _, test := range tests {
@@ -167,6 +173,10 @@ func TestAliasOverrides(t *testing.T) {
 	if nameVal != "gerald" {
 		t.Errorf("Expected name to be gerald, got %v", nameVal)
 	}
+	name, err = val.Index(-2)
+	if err != nil || name.Value() != "gerald" {
+		t.Errorf("Expected index -2 to be gerald, got %v, %v", name, err)
+	}
 }
 
 func TestAttachments(t *testing.T) {
@@ -502,3 +512,232 @@ func TestJsonPointerInvalidJSON(t *testing.T) {
 		t.Errorf(`expected "value", got : %v`, string(val))
 	}
 }
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	val := NewValueFromBytes([]byte("{\"a\":1,\n \"b\": tru}"))
+	if val.Type() != NOT_JSON {
+		t.Fatalf("Expected NOT_JSON, got %d", val.Type())
+	}
+
+	_, err := val.Path("c")
+	serr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("Expected *SyntaxError, got %#v", err)
+	}
+	if serr.Path != "c" {
+		t.Errorf("Expected path c, got %s", serr.Path)
+	}
+	if serr.Line != 2 || serr.Column != 10 {
+		t.Errorf("Expected line 2 column 10, got line %d column %d", serr.Line, serr.Column)
+	}
+	if serr.Offset != 18 {
+		t.Errorf("Expected offset 18, got %d", serr.Offset)
+	}
+
+	// valid documents still report *Undefined
+	val = NewValueFromBytes([]byte(`{"a":1}`))
+	_, err = val.Path("c")
+	if !reflect.DeepEqual(err, &Undefined{"c"}) {
+		t.Errorf("Expected *Undefined, got %#v", err)
+	}
+}
+
+func TestPathSpecialCharacters(t *testing.T) {
+	val := NewValueFromBytes([]byte(`{"a/b":1,"c~d":2,"~1":3}`))
+	for key, expected := range map[string]float64{"a/b": 1, "c~d": 2, "~1": 3} {
+		result, err := val.Path(key)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %s", err, key)
+			continue
+		}
+		if result.Value() != expected {
+			t.Errorf("Expected %v, got %v for %s", expected, result.Value(), key)
+		}
+	}
+}
+
+func TestSetAfterValue(t *testing.T) {
+	val := NewValueFromBytes([]byte(`{"a":1,"b":[1,2]}`))
+	before := val.Value().(map[string]interface{})
+	val.SetPath("a", 2.0)
+	b, _ := val.Path("b")
+	b.Value()
+	b.SetIndex(0, "x")
+	val.SetPath("b", b)
+
+	if string(val.Bytes()) != `{"a":2,"b":["x",2]}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+	after := val.Value().(map[string]interface{})
+	if after["a"] != 2.0 || before["a"] != 1.0 {
+		t.Errorf("Expected a to be 1 before and 2 after, got %v and %v", before["a"], after["a"])
+	}
+}
+
+func TestNestedSetOnRawValue(t *testing.T) {
+	val := NewValueFromBytes([]byte(`{"a":1,"b":{"c":{"d":1}},"e":[{"f":1}]}`))
+	val.SetPath("a", 2.0)
+	b, _ := val.Path("b")
+	c, _ := b.Path("c")
+	c.SetPath("d", 2.0)
+	e, _ := val.Path("e")
+	e0, _ := e.Index(0)
+	e0.SetPath("g", true)
+
+	if string(val.Bytes()) != `{"a":2,"b":{"c":{"d":2}},"e":[{"f":1,"g":true}]}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+	expected := map[string]interface{}{
+		"a": 2.0,
+		"b": map[string]interface{}{"c": map[string]interface{}{"d": 2.0}},
+		"e": []interface{}{map[string]interface{}{"f": 1.0, "g": true}},
+	}
+	if !reflect.DeepEqual(val.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Value())
+	}
+
+	// navigating again must find the modified children
+	again, _ := val.Path("b")
+	again, _ = again.Path("c")
+	if again != c {
+		t.Errorf("Expected navigation to return the same child Value")
+	}
+
+	// replacing a child discards the remembered one
+	val.SetPath("b", "x")
+	if string(val.Bytes()) != `{"a":2,"b":"x","e":[{"f":1,"g":true}]}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+}
+
+func TestEmptyConstructors(t *testing.T) {
+	obj := NewEmptyObjectValue()
+	if obj.Type() != OBJECT || string(obj.Bytes()) != `{}` {
+		t.Errorf("Expected empty object, got %d %s", obj.Type(), obj.Bytes())
+	}
+
+	obj = NewObjectValueCap(2)
+	obj.SetPath("a", 1.0)
+	obj.SetPath("b", NewEmptyArrayValue())
+	if string(obj.Bytes()) != `{"a":1,"b":[]}` {
+		t.Errorf(`Expected {"a":1,"b":[]}, got %s`, obj.Bytes())
+	}
+	if !reflect.DeepEqual(obj.Value(), map[string]interface{}{"a": 1.0, "b": []interface{}{}}) {
+		t.Errorf("Unexpected value %#v", obj.Value())
+	}
+
+	arr := NewEmptyArrayValue()
+	if arr.Type() != ARRAY || string(arr.Bytes()) != `[]` {
+		t.Errorf("Expected empty array, got %d %s", arr.Type(), arr.Bytes())
+	}
+	if _, err := arr.Index(0); err == nil {
+		t.Errorf("Expected index 0 of an empty array to be undefined")
+	}
+}
+
+func TestDottedPath(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"address": {"street": "Main", "lines": ["a", "b"]}, "a.b": 1}`))
+	street, err := doc.Path("address.street")
+	if err != nil || street.Value() != "Main" {
+		t.Errorf("Expected Main, got %v", err)
+	}
+	line, err := doc.Path("address.lines.1")
+	if err != nil || line.Value() != "b" {
+		t.Errorf("Expected b, got %v", err)
+	}
+	// a property whose name contains dots is found first
+	ab, err := doc.Path("a.b")
+	if err != nil || ab.Value() != 1.0 {
+		t.Errorf("Expected 1, got %v", err)
+	}
+	_, err = doc.Path("address.zip")
+	if uerr, ok := err.(*Undefined); !ok || uerr.Path != "address.zip" {
+		t.Errorf("Expected *Undefined for address.zip, got %v", err)
+	}
+	// the raw bytes were not parsed, and changes are seen by the document
+	if doc.parsedValue != nil {
+		t.Errorf("Expected doc not to be parsed")
+	}
+	addr, _ := doc.Path("address")
+	addr.SetPath("street", "High")
+	if s, _ := doc.Path("address.street"); s.Value() != "High" {
+		t.Errorf("Expected High, got %v", s.Value())
+	}
+}
+
+func BenchmarkSetIndexRaw(b *testing.B) {
+	raw := []byte("[" + strings.Repeat("0,", 9999) + "0]")
+	for i := 0; i < b.N; i++ {
+		arr := NewValueFromBytes(raw)
+		for j := 0; j < 10000; j++ {
+			arr.SetIndex(j, 1.0)
+		}
+	}
+}
+
+func TestNewValueFromParsed(t *testing.T) {
+	tree := map[string]interface{}{
+		"a": map[string]interface{}{"b": []interface{}{1.0, "two", nil}},
+		"c": true,
+	}
+	val := NewValueFromParsed(tree)
+	if val.Type() != OBJECT {
+		t.Fatalf("Expected OBJECT, got %d", val.Type())
+	}
+	a, err := val.Path("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the subtree is not converted until it is touched
+	if _, ok := a.parsedValue.(map[string]interface{}); !ok {
+		t.Errorf("Expected a to be converted lazily, got %T", a.parsedValue)
+	}
+	b, _ := a.Path("b")
+	two, _ := b.Index(1)
+	if two.Value() != "two" {
+		t.Errorf("Expected two, got %v", two.Value())
+	}
+
+	b.SetIndex(0, 10.0)
+	val.SetPath("d", "new")
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{"b": []interface{}{10.0, "two", nil}},
+		"c": true,
+		"d": "new",
+	}
+	if !reflect.DeepEqual(val.Value(), expected) {
+		t.Errorf("Expected %v, got %v", expected, val.Value())
+	}
+	if string(val.Bytes()) != `{"a":{"b":[10,"two",null]},"c":true,"d":"new"}` {
+		t.Errorf("Unexpected bytes %s", val.Bytes())
+	}
+	// the tree itself is unchanged
+	if _, ok := tree["d"]; ok || tree["a"].(map[string]interface{})["b"].([]interface{})[0] != 1.0 {
+		t.Errorf("Expected the tree to be unchanged, got %v", tree)
+	}
+
+	if NewValueFromParsed("x").Type() != STRING {
+		t.Errorf("Expected scalars to be handled as NewValue() does")
+	}
+
+	// trees decoded by encoding/json with UseNumber() hold its Number
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(`{"n": 12345678901234567890, "list": [1.5]}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	val = NewValueFromParsed(decoded)
+	n, err := val.Path("n")
+	if err != nil || n.Type() != NUMBER || string(n.Bytes()) != "12345678901234567890" {
+		t.Errorf("Expected the exact number, got %v, %v", n, err)
+	}
+	list, _ := val.Path("list")
+	first, err := list.Index(0)
+	if err != nil || !first.Equals(NewValue(1.5)) {
+		t.Errorf("Expected 1.5, got %v, %v", first, err)
+	}
+	if !n.Equals(NewValue(12345678901234567890.0)) {
+		t.Errorf("Expected the number to compare by value")
+	}
+}
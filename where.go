@@ -0,0 +1,203 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Update every element of the array at arrayPath inside doc for which filterExpr is true,
+// setting each property in set on the element.  The number of elements updated is returned.
+//
+// The arrayPath is dotted, as for FillTemplate(), and may be empty to update doc itself.
+// The filterExpr is evaluated against each element (see ParseExpression()), and elements
+// which are not of type OBJECT are never updated.  The values in set are brought into the
+// type system, so they must be compatible with the NewValue() method.
+//
+// The elements are updated together: the changes are made to a copy of the array, which replaces
+// it only once they have all been made.  If a Schema bound to doc (see BindSchema()) does not
+// allow a value in set, or a constraint registered with AddConstraint() would be violated, its
+// error is returned as for SetPathErr(), and no element is updated.
+//
+// If arrayPath does not exist, the return error is *Undefined.  If filterExpr is malformed,
+// the return error is *ExpressionError.
+func UpdateWhere(doc *Value, arrayPath, filterExpr string, set map[string]interface{}) (int, error) {
+	filter, err := ParseExpression(filterExpr)
+	if err != nil {
+		return 0, err
+	}
+	chain, err := resolveChain(doc, arrayPath)
+	if err != nil {
+		return 0, err
+	}
+	array := chain[len(chain)-1]
+	elements, err := array.elements()
+	if err != nil {
+		return 0, &TypeMismatch{Path: arrayPath, Expected: ARRAY, Actual: array.Type()}
+	}
+	working := array.newChild(array.Bytes())
+	working.BindSchema(array.Schema())
+	count := 0
+	for i, element := range elements {
+		if element.Type() != OBJECT {
+			continue
+		}
+		matches, err := filter.Matches(element)
+		if err != nil {
+			return 0, err
+		}
+		if matches {
+			updated, err := working.Index(i)
+			if err != nil {
+				return 0, err
+			}
+			for k, v := range set {
+				err = updated.SetPathErr(k, v)
+				if err != nil {
+					return 0, err
+				}
+			}
+			err = working.SetIndexErr(i, updated)
+			if err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+	if count > 0 {
+		err = replaceChain(chain, arrayPath, working)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// Remove every element of the array at arrayPath inside doc for which filterExpr is true.
+// The number of elements removed is returned.  Later elements shift down to fill the gaps,
+// so subsequent Index(), Value() and Bytes() calls see the shorter array.
+//
+// The arrayPath and filterExpr are as for UpdateWhere(), except that elements of any type
+// may be removed.
+func RemoveWhere(doc *Value, arrayPath, filterExpr string) (int, error) {
+	filter, err := ParseExpression(filterExpr)
+	if err != nil {
+		return 0, err
+	}
+	chain, err := resolveChain(doc, arrayPath)
+	if err != nil {
+		return 0, err
+	}
+	array := chain[len(chain)-1]
+	elements, err := array.elements()
+	if err != nil {
+		return 0, &TypeMismatch{Path: arrayPath, Expected: ARRAY, Actual: array.Type()}
+	}
+	kept := make(ValueCollection, 0, len(elements))
+	for _, element := range elements {
+		matches, err := filter.Matches(element)
+		if err != nil {
+			return 0, err
+		}
+		if !matches {
+			kept = append(kept, element)
+		}
+	}
+	count := len(elements) - len(kept)
+	if count > 0 {
+		working := array.newParsedChild([]interface{}{})
+		working.replaceElements(kept)
+		err = replaceChain(chain, arrayPath, working)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// replaceElements replaces the contents of this ARRAY with elements.
+func (this *Value) replaceElements(elements ValueCollection) {
+	this.checkMutable()
+	this.raw = nil
+	this.alias = nil
+	this.children = nil
+	this.parsedValue = []*Value(elements)
+}
+
+// resolveChain resolves a dotted path inside val, returning each Value
+// along the way, beginning with val itself.
+func resolveChain(val *Value, path string) ([]*Value, error) {
+	rv := []*Value{val}
+	if path == "" {
+		return rv, nil
+	}
+	for _, step := range strings.Split(path, ".") {
+		next, err := resolvePath(rv[len(rv)-1], step)
+		if err != nil {
+			if _, ok := err.(*Undefined); ok {
+				return nil, &Undefined{path}
+			}
+			return nil, err
+		}
+		rv = append(rv, next)
+	}
+	return rv, nil
+}
+
+// storeChain stores each Value of a chain returned by resolveChain() back
+// into its parent, so that changes made to the last Value are visible
+// from the first.  Each Value is stored with SetPathErr() or SetIndexErr(),
+// and if one is rejected those already stored are put back as they were,
+// and its error is returned.
+func storeChain(chain []*Value, path string) error {
+	if path == "" {
+		return nil
+	}
+	steps := strings.Split(path, ".")
+	previous := make([]*Value, len(steps))
+	for i := len(steps) - 1; i >= 0; i-- {
+		parent, child := chain[i], chain[i+1]
+		index, err := strconv.Atoi(steps[i])
+		if err == nil && parent.Type() == ARRAY {
+			index, err = parent.fromEnd(index)
+			if err == nil {
+				previous[i], _ = parent.Index(index)
+				err = parent.SetIndexErr(index, child)
+			}
+		} else {
+			previous[i], _ = parent.member(steps[i])
+			err = parent.SetPathErr(steps[i], child)
+		}
+		if err != nil {
+			for j := i + 1; j < len(steps); j++ {
+				if index, aerr := strconv.Atoi(steps[j]); aerr == nil && chain[j].Type() == ARRAY {
+					index, _ = chain[j].fromEnd(index)
+					chain[j].setElement(index, previous[j])
+				} else {
+					chain[j].setMember(steps[j], previous[j])
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceChain puts working in place of the last Value of a chain returned by
+// resolveChain() for path, storing it as storeChain() does.  If path is empty,
+// the contents of the first Value are replaced instead.
+func replaceChain(chain []*Value, path string, working *Value) error {
+	if path == "" {
+		return chain[0].replaceContents(working)
+	}
+	chain[len(chain)-1] = working
+	return storeChain(chain, path)
+}
@@ -0,0 +1,92 @@
+//  Copyright (c) 2013 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package dparval
+
+import (
+	"testing"
+)
+
+func TestUpdateWhere(t *testing.T) {
+	input := `{"order":{"items":[{"sku":"X","qty":1},{"sku":"Y","qty":2},{"sku":"X","qty":3},"note"]}}`
+
+	doc := NewValueFromBytes([]byte(input))
+	count, err := UpdateWhere(doc, "order.items", `sku = "X"`, map[string]interface{}{"status": "backordered"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 updates, got %d", count)
+	}
+	expected := `{"order":{"items":[{"qty":1,"sku":"X","status":"backordered"},{"qty":2,"sku":"Y"},{"qty":3,"sku":"X","status":"backordered"},"note"]}}`
+	if string(doc.Bytes()) != expected {
+		t.Errorf("Expected %s, got %s", expected, doc.Bytes())
+	}
+
+	// parsed documents, and arrays as the document
+	parsed := NewValue([]interface{}{map[string]interface{}{"n": 1.0}, map[string]interface{}{"n": 5.0}})
+	count, err = UpdateWhere(parsed, "", `n > 2`, map[string]interface{}{"big": true})
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 update, got %d, %v", count, err)
+	}
+	if string(parsed.Bytes()) != `[{"n":1},{"big":true,"n":5}]` {
+		t.Errorf("Unexpected bytes %s", parsed.Bytes())
+	}
+
+	doc = NewValueFromBytes([]byte(input))
+	if _, err := UpdateWhere(doc, "order.missing", `true`, nil); err == nil {
+		t.Errorf("Expected error for missing array")
+	}
+	if _, err := UpdateWhere(doc, "order", `true`, nil); err == nil {
+		t.Errorf("Expected error for non-array")
+	}
+	if _, err := UpdateWhere(doc, "order.items", `sku =`, nil); err == nil {
+		t.Errorf("Expected error for malformed expression")
+	}
+}
+
+func TestRemoveWhere(t *testing.T) {
+	doc := NewValueFromBytes([]byte(`{"cart":{"items":[{"sku":"X"},{"sku":"Y"},{"sku":"X"},{"sku":"Z"}]}}`))
+	count, err := RemoveWhere(doc, "cart.items", `sku = "X"`)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 removals, got %d", count)
+	}
+	if string(doc.Bytes()) != `{"cart":{"items":[{"sku":"Y"},{"sku":"Z"}]}}` {
+		t.Errorf("Unexpected bytes %s", doc.Bytes())
+	}
+
+	// indexes shift after removal
+	cart, _ := doc.Path("cart")
+	items, _ := cart.Path("items")
+	second, err := items.Index(1)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	sku, _ := second.Path("sku")
+	if sku.Value() != "Z" {
+		t.Errorf("Expected Z at index 1, got %v", sku.Value())
+	}
+	if _, err := items.Index(2); err == nil {
+		t.Errorf("Expected index 2 to be undefined")
+	}
+
+	// aliases set before removal are honored
+	array := NewValueFromBytes([]byte(`[{"n":1},{"n":2},{"n":3}]`))
+	array.SetIndex(0, map[string]interface{}{"n": 5.0})
+	count, err = RemoveWhere(array, "", `n < 3`)
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 removal, got %d, %v", count, err)
+	}
+	if string(array.Bytes()) != `[{"n":5},{"n":3}]` {
+		t.Errorf("Unexpected bytes %s", array.Bytes())
+	}
+}